@@ -14,7 +14,15 @@
 
 package applydriver
 
+import "context"
+
 type Interface interface {
 	Apply() error
+	// ApplyContext is Apply which stops before the next step once ctx is done.
+	ApplyContext(ctx context.Context) error
 	Delete() error
+	// DeleteContext is Delete which stops before the next step once ctx is done.
+	DeleteContext(ctx context.Context) error
+	// Plan compute the actions Apply will take, without touching any host.
+	Plan() (*Plan, error)
 }
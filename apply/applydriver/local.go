@@ -15,6 +15,7 @@
 package applydriver
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alibaba/sealer/apply/processor"
@@ -33,6 +34,7 @@ import (
 	"github.com/alibaba/sealer/pkg/client/k8s"
 	"github.com/alibaba/sealer/pkg/image"
 	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/platform"
 )
 
 // Applier cloud builder using cloud provider to build a cluster image
@@ -45,9 +47,19 @@ type Applier struct {
 	Client             *k8s.Client
 	ImageStore         store.ImageStore
 	CurrentClusterInfo *version.Info
+	// RuntimeOptions are the options of the runtimes applying the cluster, like deleting nodes without confirmation.
+	RuntimeOptions runtime.Options
+	ctx            context.Context
+	// planning is set by Plan, which does not touch the hosts.
+	planning bool
 }
 
 func (c *Applier) Delete() (err error) {
+	return c.DeleteContext(context.Background())
+}
+
+func (c *Applier) DeleteContext(ctx context.Context) (err error) {
+	c.ctx = ctx
 	t := metav1.Now()
 	c.ClusterDesired.DeletionTimestamp = &t
 	return c.deleteCluster()
@@ -55,16 +67,18 @@ func (c *Applier) Delete() (err error) {
 
 // Apply different actions between ClusterDesired and ClusterCurrent.
 func (c *Applier) Apply() (err error) {
-	// first time to init cluster
-	if c.ClusterFile == nil {
-		c.ClusterFile = clusterfile.NewClusterFile(c.ClusterDesired.GetAnnotationsByKey(common.ClusterfileName))
-		if path := c.ClusterDesired.GetAnnotationsByKey(common.ClusterfileName); path != "" {
-			if err = c.ClusterFile.Process(); err != nil {
-				return err
-			}
-		}
+	return c.ApplyContext(context.Background())
+}
+
+func (c *Applier) ApplyContext(ctx context.Context) (err error) {
+	c.ctx = ctx
+	if err = c.initClusterFile(); err != nil {
+		return err
 	}
-	if !utils.IsFileExist(common.DefaultKubeConfigFile()) {
+	if err = c.loadClusterStatus(); err != nil {
+		return err
+	}
+	if !utils.IsFileExist(common.DefaultKubeConfigFile()) || processor.IsCreateUnfinished(c.ClusterDesired) {
 		if err = c.initCluster(); err != nil {
 			return err
 		}
@@ -77,6 +91,36 @@ func (c *Applier) Apply() (err error) {
 	return utils.SaveClusterInfoToFile(c.ClusterDesired, c.ClusterDesired.Name)
 }
 
+func (c *Applier) initClusterFile() error {
+	// first time to init cluster
+	if c.ClusterFile == nil {
+		c.ClusterFile = clusterfile.NewClusterFile(c.ClusterDesired.GetAnnotationsByKey(common.ClusterfileName))
+		if path := c.ClusterDesired.GetAnnotationsByKey(common.ClusterfileName); path != "" {
+			if err := c.ClusterFile.Process(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadClusterStatus keep the status observed by last apply, desired cluster may be loaded from a Clusterfile without status.
+func (c *Applier) loadClusterStatus() error {
+	if c.ClusterDesired.Status.Phase != "" {
+		return nil
+	}
+	workClusterfile := common.GetClusterWorkClusterfile(c.ClusterDesired.Name)
+	if !utils.IsFileExist(workClusterfile) {
+		return nil
+	}
+	cluster, err := clusterfile.GetClusterFromFile(workClusterfile)
+	if err != nil {
+		return err
+	}
+	c.ClusterDesired.Status = cluster.Status
+	return nil
+}
+
 func (c *Applier) fillClusterCurrent() error {
 	currentCluster, err := GetCurrentCluster(c.Client)
 	if err != nil {
@@ -90,8 +134,7 @@ func (c *Applier) fillClusterCurrent() error {
 }
 
 func (c *Applier) mountClusterImage() error {
-	imageName := c.ClusterDesired.Spec.Image
-	err := c.ImageManager.PullIfNotExist(imageName)
+	err := c.pullImage(c.ClusterDesired.Spec.Image)
 	if err != nil {
 		return err
 	}
@@ -102,11 +145,25 @@ func (c *Applier) mountClusterImage() error {
 	return nil
 }
 
+// pullImage pulls imageName unless it exists, picking the image built for the
+// hosts if it is a manifest list. Plan does not detect the platform of the hosts,
+// it reads the local image, or pulls the one of the local platform.
+func (c *Applier) pullImage(imageName string) error {
+	if c.planning {
+		return c.ImageManager.PullIfNotExist(imageName)
+	}
+	hostPlatform, err := platform.GetClusterPlatform(c.ClusterDesired)
+	if err != nil {
+		return err
+	}
+	return c.ImageManager.PullIfNotExistWithPlatform(imageName, hostPlatform)
+}
+
 func (c *Applier) unMountClusterImage() error {
 	return c.CloudImageMounter.UnMountImage(c.ClusterDesired)
 }
 
-func (c *Applier) reconcileCluster() error {
+func (c *Applier) initClusterClient() error {
 	client, err := k8s.Newk8sClient()
 	if err != nil {
 		return err
@@ -118,7 +175,11 @@ func (c *Applier) reconcileCluster() error {
 	}
 	c.CurrentClusterInfo = info
 
-	if err := c.fillClusterCurrent(); err != nil {
+	return c.fillClusterCurrent()
+}
+
+func (c *Applier) reconcileCluster() error {
+	if err := c.initClusterClient(); err != nil {
 		return err
 	}
 
@@ -158,10 +219,13 @@ func (c *Applier) scaleCluster(mj, md, nj, nd []string) error {
 		return nil
 	}
 
+	if err := c.ctx.Err(); err != nil {
+		return fmt.Errorf("cancelled before scaling: %v", err)
+	}
 	logger.Info("Start to scale this cluster")
 	logger.Debug("current cluster: master %s, worker %s", c.ClusterCurrent.GetMasterIPList(), c.ClusterCurrent.GetNodeIPList())
 
-	scaleProcessor, err := processor.NewScaleProcessor(c.ClusterFile.GetKubeadmConfig(), common.DefaultTheClusterRootfsDir(c.ClusterDesired.Name), mj, md, nj, nd)
+	scaleProcessor, err := processor.NewScaleProcessor(c.ctx, c.RuntimeOptions, c.ClusterFile.GetKubeadmConfig(), common.DefaultTheClusterRootfsDir(c.ClusterDesired.Name), mj, md, nj, nd)
 	if err != nil {
 		return err
 	}
@@ -177,6 +241,7 @@ func (c *Applier) scaleCluster(mj, md, nj, nd []string) error {
 		cluster = c.ClusterDesired
 	}
 	err = scaleProcessor.Execute(cluster)
+	c.ClusterDesired.Status = cluster.Status
 	if err != nil {
 		return err
 	}
@@ -186,25 +251,51 @@ func (c *Applier) scaleCluster(mj, md, nj, nd []string) error {
 	return nil
 }
 
+// getDesiredClusterMetadata fetch the metadata of desired cluster from the mounted cluster image.
+func (c *Applier) getDesiredClusterMetadata() (runtime.Interface, *runtime.Metadata, error) {
+	runtimeInterface, err := runtime.NewDefaultRuntimeContext(c.ctx, c.ClusterDesired, c.ClusterFile.GetKubeadmConfig(), c.RuntimeOptions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to init runtime, %v", err)
+	}
+	clusterMetadata, err := runtimeInterface.GetClusterMetadata()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get cluster metadata: %v", err)
+	}
+	return runtimeInterface, clusterMetadata, nil
+}
+
 func (c *Applier) upgradeCluster(mj, nj []string) error {
 	// use k8sClient to fetch current cluster version.
 	info := c.CurrentClusterInfo
 	// fetch form exec machine
-	runtimeInterface, err := runtime.NewDefaultRuntime(c.ClusterDesired, c.ClusterFile.GetKubeadmConfig())
+	runtimeInterface, clusterMetadata, err := c.getDesiredClusterMetadata()
 	if err != nil {
-		return fmt.Errorf("failed to init runtime, %v", err)
-	}
-	clusterMetadata, err := runtimeInterface.GetClusterMetadata()
-	if err != nil {
-		return fmt.Errorf("failed to get cluster metadata: %v", err)
+		return err
 	}
 
 	if info.GitVersion == clusterMetadata.Version {
 		return nil
 	}
 
-	logger.Info("Start to upgrade this cluster from version(%s) to version(%s)", info.GitVersion, clusterMetadata.Version)
-	upgradeProcessor, err := processor.NewUpgradeProcessor(common.DefaultMountCloudImageDir(c.ClusterDesired.Name), runtimeInterface, mj, nj)
+	path, err := c.getUpgradePath(info.GitVersion, clusterMetadata.Version)
+	if err != nil {
+		return err
+	}
+	current := info.GitVersion
+	for _, step := range path {
+		if err = c.ctx.Err(); err != nil {
+			return fmt.Errorf("cancelled before upgrading to %s: %v", step.Version, err)
+		}
+		logger.Info("Start to upgrade this cluster from version(%s) to version(%s) with intermediate image %s", current, step.Version, step.Image)
+		if err = c.upgradeThrough(step, mj, nj); err != nil {
+			return fmt.Errorf("failed to upgrade cluster with intermediate image %s: %v", step.Image, err)
+		}
+		logger.Info("Succeeded in upgrading current cluster from version(%s) to version(%s)", current, step.Version)
+		current = step.Version
+	}
+
+	logger.Info("Start to upgrade this cluster from version(%s) to version(%s)", current, clusterMetadata.Version)
+	upgradeProcessor, err := processor.NewUpgradeProcessor(c.ctx, common.DefaultMountCloudImageDir(c.ClusterDesired.Name), runtimeInterface, mj, nj)
 	if err != nil {
 		return err
 	}
@@ -213,11 +304,59 @@ func (c *Applier) upgradeCluster(mj, nj []string) error {
 		return err
 	}
 
-	logger.Info("Succeeded in upgrading current cluster from version(%s) to version(%s)", info.GitVersion, clusterMetadata.Version)
+	logger.Info("Succeeded in upgrading current cluster from version(%s) to version(%s)", current, clusterMetadata.Version)
 
 	return nil
 }
 
+// upgradeThrough upgrades the cluster with an intermediate image in place of the desired one,
+// the desired image is mounted back when it is done.
+func (c *Applier) upgradeThrough(step upgradeStep, mj, nj []string) (err error) {
+	cluster := c.ClusterDesired.DeepCopy()
+	cluster.Spec.Image = step.Image
+	if err = c.unMountClusterImage(); err != nil {
+		return err
+	}
+	defer func() {
+		if mountErr := c.mountClusterImage(); mountErr != nil && err == nil {
+			err = mountErr
+		}
+	}()
+
+	if err = c.pullImage(step.Image); err != nil {
+		return err
+	}
+	if err = c.CloudImageMounter.MountImage(cluster); err != nil {
+		return err
+	}
+	defer func() {
+		if unMountErr := c.CloudImageMounter.UnMountImage(cluster); unMountErr != nil && err == nil {
+			err = unMountErr
+		}
+	}()
+
+	// the kubeadm config of Clusterfile is written for the desired version, use the default one of the image.
+	runtimeInterface, err := runtime.NewDefaultRuntimeContext(c.ctx, cluster, nil, c.RuntimeOptions)
+	if err != nil {
+		return fmt.Errorf("failed to init runtime, %v", err)
+	}
+	metadata, err := runtimeInterface.GetClusterMetadata()
+	if err != nil {
+		return fmt.Errorf("failed to get cluster metadata: %v", err)
+	}
+	if !sameMinorVersion(metadata.Version, step.Version) {
+		return fmt.Errorf("image is tagged %s but its kubernetes version is %s", step.Version, metadata.Version)
+	}
+
+	upgradeProcessor, err := processor.NewUpgradeProcessor(c.ctx, common.DefaultMountCloudImageDir(cluster.Name), runtimeInterface, mj, nj)
+	if err != nil {
+		return err
+	}
+	err = upgradeProcessor.Execute(cluster)
+	c.ClusterDesired.Status = cluster.Status
+	return err
+}
+
 func (c *Applier) installApp() error {
 	rootfs := common.DefaultMountCloudImageDir(c.ClusterDesired.Name)
 	// use k8sClient to fetch current cluster version.
@@ -233,7 +372,7 @@ func (c *Applier) installApp() error {
 		}
 	}
 
-	installProcessor, err := processor.NewInstallProcessor(rootfs, c.ClusterFile)
+	installProcessor, err := processor.NewInstallProcessor(c.ctx, rootfs, c.ClusterFile)
 	if err != nil {
 		return err
 	}
@@ -247,7 +386,7 @@ func (c *Applier) installApp() error {
 
 func (c *Applier) initCluster() error {
 	logger.Info("Start to create a new cluster: master %s, worker %s", c.ClusterDesired.GetMasterIPList(), c.ClusterDesired.GetNodeIPList())
-	createProcessor, err := processor.NewCreateProcessor(c.ClusterFile)
+	createProcessor, err := processor.NewCreateProcessor(c.ctx, c.RuntimeOptions, c.ClusterFile)
 	if err != nil {
 		return err
 	}
@@ -262,7 +401,7 @@ func (c *Applier) initCluster() error {
 }
 
 func (c *Applier) deleteCluster() error {
-	deleteProcessor, err := processor.NewDeleteProcessor(c.ClusterFile)
+	deleteProcessor, err := processor.NewDeleteProcessor(c.ctx, c.RuntimeOptions, c.ClusterFile)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,260 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package applydriver
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/apply/processor"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/guest"
+	"github.com/alibaba/sealer/pkg/plugin"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	PlanCreate     = "Create"
+	PlanReconcile  = "Reconcile"
+	PlanInstallApp = "InstallApp"
+)
+
+// Plan is the actions that Apply will take on the cluster.
+type Plan struct {
+	ClusterName     string       `json:"clusterName"`
+	Image           string       `json:"image"`
+	Action          string       `json:"action"`
+	Runtime         string       `json:"runtime"`
+	MastersToJoin   []string     `json:"mastersToJoin,omitempty"`
+	MastersToDelete []string     `json:"mastersToDelete,omitempty"`
+	NodesToJoin     []string     `json:"nodesToJoin,omitempty"`
+	NodesToDelete   []string     `json:"nodesToDelete,omitempty"`
+	Upgrade         *UpgradePlan `json:"upgrade,omitempty"`
+	Plugins         []PluginPlan `json:"plugins,omitempty"`
+	Configs         []ConfigPlan `json:"configs,omitempty"`
+	GuestCMDs       []string     `json:"guestCMDs,omitempty"`
+}
+
+type UpgradePlan struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Path is the intermediate images to upgrade through in order.
+	Path []string `json:"path,omitempty"`
+}
+
+type PluginPlan struct {
+	Phase string `json:"phase"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+}
+
+type ConfigPlan struct {
+	Name string `json:"name"`
+	// Path is where the config is written to on every host.
+	Path     string `json:"path"`
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// Print write the plan to w in yaml format.
+func (p *Plan) Print(w io.Writer) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Plan compute the actions Apply will take. The cluster image is pulled and mounted on local
+// to read its metadata, and the cluster is only read through kube apiserver, no host is touched.
+func (c *Applier) Plan() (*Plan, error) {
+	if err := c.initClusterFile(); err != nil {
+		return nil, err
+	}
+	if err := c.loadClusterStatus(); err != nil {
+		return nil, err
+	}
+	c.planning = true
+	defer func() { c.planning = false }()
+	p := &Plan{
+		ClusterName: c.ClusterDesired.Name,
+		Image:       c.ClusterDesired.Spec.Image,
+	}
+
+	if err := c.mountClusterImage(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := c.unMountClusterImage(); err != nil {
+			logger.Warn("failed to umount image %s, %v", c.ClusterDesired.ClusterName, err)
+		}
+	}()
+
+	runtimeType, err := runtime.GetClusterRuntimeType(c.ClusterDesired)
+	if err != nil {
+		return nil, err
+	}
+	p.Runtime = runtimeType
+
+	var phases []plugin.Phase
+	if !utils.IsFileExist(common.DefaultKubeConfigFile()) || processor.IsCreateUnfinished(c.ClusterDesired) {
+		p.Action = PlanCreate
+		p.MastersToJoin = c.ClusterDesired.GetMasterIPList()
+		p.NodesToJoin = c.ClusterDesired.GetNodeIPList()
+		phases = []plugin.Phase{plugin.PhaseOriginally, plugin.PhasePreInit, plugin.PhasePreGuest, plugin.PhasePostInstall}
+	} else {
+		if err := c.initClusterClient(); err != nil {
+			return nil, err
+		}
+		baseImage, err := c.ImageStore.GetByName(c.ClusterDesired.Spec.Image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get base image err: %s", err)
+		}
+		if baseImage.Spec.ImageConfig.ImageType == common.AppImage {
+			p.Action = PlanInstallApp
+			phases = []plugin.Phase{plugin.PhasePreGuest, plugin.PhasePostInstall}
+		} else {
+			p.Action = PlanReconcile
+			p.MastersToJoin, p.MastersToDelete = utils.GetDiffHosts(c.ClusterCurrent.GetMasterIPList(), c.ClusterDesired.GetMasterIPList())
+			p.NodesToJoin, p.NodesToDelete = utils.GetDiffHosts(c.ClusterCurrent.GetNodeIPList(), c.ClusterDesired.GetNodeIPList())
+			_, clusterMetadata, err := c.getDesiredClusterMetadata()
+			if err != nil {
+				return nil, err
+			}
+			// scale only joins or deletes hosts at a time, joins first.
+			if len(p.MastersToJoin) > 0 || len(p.NodesToJoin) > 0 {
+				phases = append(phases, plugin.PhasePreJoin, plugin.PhasePostJoin)
+			} else if len(p.MastersToDelete) > 0 || len(p.NodesToDelete) > 0 {
+				phases = append(phases, plugin.PhasePreDelete, plugin.PhasePostDelete)
+			}
+			if c.CurrentClusterInfo.GitVersion != clusterMetadata.Version {
+				p.Upgrade = &UpgradePlan{From: c.CurrentClusterInfo.GitVersion, To: clusterMetadata.Version}
+				path, err := c.getUpgradePath(p.Upgrade.From, p.Upgrade.To)
+				if err != nil {
+					return nil, err
+				}
+				for _, step := range path {
+					p.Upgrade.Path = append(p.Upgrade.Path, step.Image)
+				}
+				phases = append(phases, plugin.PhasePreUpgrade, plugin.PhasePostUpgrade)
+			}
+			// scale and upgrade do not write configs or run guest cmds.
+			if p.Plugins, err = c.planPlugins(phases); err != nil {
+				return nil, err
+			}
+			return p, nil
+		}
+	}
+
+	plugins, err := c.planPlugins(phases)
+	if err != nil {
+		return nil, err
+	}
+	p.Plugins = plugins
+
+	for _, config := range c.ClusterFile.GetConfigs() {
+		p.Configs = append(p.Configs, ConfigPlan{
+			Name:     config.Name,
+			Path:     filepath.Join(common.DefaultTheClusterRootfsDir(c.ClusterDesired.Name), config.Spec.Path),
+			Strategy: config.Spec.Strategy,
+		})
+	}
+
+	gs, err := guest.NewGuestManager()
+	if err != nil {
+		return nil, err
+	}
+	if p.GuestCMDs, err = gs.GetCmds(c.ClusterDesired); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// planPlugins list plugins which will fire in the given phases, in the order they are run.
+// Plugins of Clusterfile are dumped to the rootfs plugin dir together with those of the cluster
+// image, and the dir is loaded in file name order before sorted by order and dependsOn, only Originally
+// phase runs Clusterfile plugins directly.
+func (c *Applier) planPlugins(phases []plugin.Phase) ([]PluginPlan, error) {
+	pluginFiles := map[string][]v1.Plugin{}
+	imagePluginDir := filepath.Join(common.DefaultMountCloudImageDir(c.ClusterDesired.Name), "plugins")
+	if utils.IsExist(imagePluginDir) {
+		files, err := ioutil.ReadDir(imagePluginDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plugin dir %v", err)
+		}
+		for _, f := range files {
+			if !utils.YamlMatcher(f.Name()) {
+				continue
+			}
+			plugins, err := utils.DecodePlugins(filepath.Join(imagePluginDir, f.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to load plugin %v", err)
+			}
+			pluginFiles[f.Name()] = plugins
+		}
+	}
+	for _, p := range c.ClusterFile.GetPlugins() {
+		name := p.Name
+		if !utils.YamlMatcher(name) {
+			name = fmt.Sprintf("%s.yaml", name)
+		}
+		pluginFiles[name] = []v1.Plugin{p}
+	}
+
+	var fileNames []string
+	for name := range pluginFiles {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+
+	var loaded []v1.Plugin
+	for _, name := range fileNames {
+		loaded = append(loaded, pluginFiles[name]...)
+	}
+
+	var plans []PluginPlan
+	for _, phase := range phases {
+		plugins := loaded
+		if phase == plugin.PhaseOriginally {
+			plugins = c.ClusterFile.GetPlugins()
+		}
+		phasePlans, err := sortPluginPlans(plugins, phase)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, phasePlans...)
+	}
+	return plans, nil
+}
+
+func sortPluginPlans(plugins []v1.Plugin, phase plugin.Phase) ([]PluginPlan, error) {
+	sorted, err := plugin.SortPlugins(plugins, phase)
+	if err != nil {
+		return nil, err
+	}
+	var plans []PluginPlan
+	for _, p := range sorted {
+		plans = append(plans, PluginPlan{Phase: string(phase), Name: p.Name, Type: p.Spec.Type})
+	}
+	return plans, nil
+}
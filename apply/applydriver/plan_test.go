@@ -0,0 +1,274 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package applydriver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/image"
+	"github.com/alibaba/sealer/pkg/image/store"
+	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/pkg/runtime/kubeadm_types/v1beta2"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+type fakeClusterFile struct {
+	cluster v2.Cluster
+	plugins []v1.Plugin
+	version string
+}
+
+func (f *fakeClusterFile) Process() error          { return nil }
+func (f *fakeClusterFile) GetCluster() v2.Cluster  { return f.cluster }
+func (f *fakeClusterFile) GetConfigs() []v1.Config { return nil }
+func (f *fakeClusterFile) GetPlugins() []v1.Plugin { return f.plugins }
+func (f *fakeClusterFile) GetKubeadmConfig() *runtime.KubeadmConfig {
+	return &runtime.KubeadmConfig{ClusterConfiguration: v1beta2.ClusterConfiguration{KubernetesVersion: f.version}}
+}
+
+// fakeImageService only serves the local images, the methods not used by Plan are not implemented.
+type fakeImageService struct {
+	image.Service
+	pulled []string
+}
+
+func (f *fakeImageService) PullIfNotExist(imageName string) error {
+	f.pulled = append(f.pulled, imageName)
+	return nil
+}
+
+type fakeMounter struct {
+	mounted int
+}
+
+func (f *fakeMounter) MountImage(cluster *v2.Cluster) error {
+	f.mounted++
+	return nil
+}
+
+func (f *fakeMounter) UnMountImage(cluster *v2.Cluster) error {
+	f.mounted--
+	return nil
+}
+
+// fakeAPIServer serves the version and nodes of the current cluster.
+func fakeAPIServer(t *testing.T, gitVersion string, masters, nodes []string) *httptest.Server {
+	nodeList := corev1.NodeList{TypeMeta: metav1.TypeMeta{Kind: "NodeList", APIVersion: "v1"}}
+	for i, ip := range append(append([]string{}, masters...), nodes...) {
+		node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node%d", i), Labels: map[string]string{}}}
+		if i < len(masters) {
+			node.Labels[MasterRoleLabel] = ""
+		}
+		node.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: ip}}
+		nodeList.Items = append(nodeList.Items, node)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		switch r.URL.Path {
+		case "/version":
+			body = version.Info{GitVersion: gitVersion}
+		case "/api/v1/nodes":
+			body = nodeList
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			t.Error(err)
+		}
+	}))
+}
+
+func TestApplier_Plan(t *testing.T) {
+	root := t.TempDir()
+	common.SetDataRoot(root)
+	defer common.SetDataRoot(common.DefaultDataRoot)
+	home := filepath.Join(root, "home")
+	defer func(home string) {
+		_ = os.Setenv("HOME", home)
+	}(os.Getenv("HOME"))
+	if err := os.Setenv("HOME", home); err != nil {
+		t.Fatal(err)
+	}
+	homedir.DisableCache = true
+	defer func() {
+		homedir.DisableCache = false
+	}()
+
+	imageStore, err := store.NewDefaultImageStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, imageType := range map[string]string{"kubernetes:v1.19.9": "", "app:v1": common.AppImage} {
+		img := v1.Image{Spec: v1.ImageSpec{ID: name, ImageConfig: v1.ImageConfig{ImageType: imageType}}}
+		if err = imageStore.Save(img, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the hosts listen on a port which counts the connections, Plan must not connect to them.
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var connected int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&connected, 1)
+			_ = conn.Close()
+		}
+	}()
+	sshPort := fmt.Sprintf("%d", listener.Addr().(*net.TCPAddr).Port)
+
+	plugins := []v1.Plugin{
+		{ObjectMeta: metav1.ObjectMeta{Name: "label"}, Spec: v1.PluginSpec{Type: "LABEL", Action: "PostInstall"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "hostname"}, Spec: v1.PluginSpec{Type: "HOSTNAME", Action: "PreInit"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "check"}, Spec: v1.PluginSpec{Type: "SHELL", Action: "Originally"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "prepare"}, Spec: v1.PluginSpec{Type: "SHELL", Action: "PreJoin"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "drain"}, Spec: v1.PluginSpec{Type: "SHELL", Action: "PreDelete"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "backup"}, Spec: v1.PluginSpec{Type: "SHELL", Action: "PreUpgrade"}},
+	}
+	tests := []struct {
+		name string
+		// current is the version and the masters and nodes of the current cluster, no cluster if version is empty.
+		current        string
+		currentMasters []string
+		currentNodes   []string
+		image          string
+		masters        []string
+		nodes          []string
+		want           *Plan
+	}{
+		{
+			name:    "create",
+			image:   "kubernetes:v1.19.9",
+			masters: []string{"127.0.0.2", "127.0.0.3"},
+			nodes:   []string{"127.0.0.4"},
+			want: &Plan{Action: PlanCreate, MastersToJoin: []string{"127.0.0.2", "127.0.0.3"}, NodesToJoin: []string{"127.0.0.4"},
+				Plugins: []PluginPlan{
+					{Phase: "Originally", Name: "check", Type: "SHELL"},
+					{Phase: "PreInit", Name: "hostname", Type: "HOSTNAME"},
+					{Phase: "PostInstall", Name: "label", Type: "LABEL"},
+				}},
+		},
+		{
+			name:    "scale up",
+			current: "v1.19.9", currentMasters: []string{"127.0.0.2"},
+			image:   "kubernetes:v1.19.9",
+			masters: []string{"127.0.0.2", "127.0.0.3"},
+			nodes:   []string{"127.0.0.4"},
+			want: &Plan{Action: PlanReconcile, MastersToJoin: []string{"127.0.0.3"}, NodesToJoin: []string{"127.0.0.4"},
+				Plugins: []PluginPlan{{Phase: "PreJoin", Name: "prepare", Type: "SHELL"}}},
+		},
+		{
+			name:    "scale down",
+			current: "v1.19.9", currentMasters: []string{"127.0.0.2"}, currentNodes: []string{"127.0.0.4", "127.0.0.5"},
+			image:   "kubernetes:v1.19.9",
+			masters: []string{"127.0.0.2"},
+			nodes:   []string{"127.0.0.4"},
+			want: &Plan{Action: PlanReconcile, NodesToDelete: []string{"127.0.0.5"},
+				Plugins: []PluginPlan{{Phase: "PreDelete", Name: "drain", Type: "SHELL"}}},
+		},
+		{
+			name:    "upgrade",
+			current: "v1.19.8", currentMasters: []string{"127.0.0.2"},
+			image:   "kubernetes:v1.19.9",
+			masters: []string{"127.0.0.2"},
+			want: &Plan{Action: PlanReconcile, Upgrade: &UpgradePlan{From: "v1.19.8", To: "v1.19.9"},
+				Plugins: []PluginPlan{{Phase: "PreUpgrade", Name: "backup", Type: "SHELL"}}},
+		},
+		{
+			name:    "install app",
+			current: "v1.19.9", currentMasters: []string{"127.0.0.2"},
+			image:   "app:v1",
+			masters: []string{"127.0.0.2"},
+			want:    &Plan{Action: PlanInstallApp, Plugins: []PluginPlan{{Phase: "PostInstall", Name: "label", Type: "LABEL"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.RemoveAll(home); err != nil {
+				t.Fatal(err)
+			}
+			if tt.current != "" {
+				server := fakeAPIServer(t, tt.current, tt.currentMasters, tt.currentNodes)
+				defer server.Close()
+				kubeconfig := fmt.Sprintf("apiVersion: v1\nkind: Config\nclusters:\n- name: test\n  cluster:\n    server: %s\n"+
+					"contexts:\n- name: test\n  context:\n    cluster: test\ncurrent-context: test\n", server.URL)
+				if err := os.MkdirAll(common.DefaultKubeConfigDir(), common.FileMode0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(common.DefaultKubeConfigFile(), []byte(kubeconfig), common.FileMode0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cluster := v2.Cluster{}
+			cluster.Name = "plan-test"
+			cluster.Spec.Image = tt.image
+			cluster.Spec.SSH.Port = sshPort
+			cluster.Spec.Hosts = []v2.Host{{IPS: tt.masters, Roles: []string{common.MASTER}}}
+			if len(tt.nodes) > 0 {
+				cluster.Spec.Hosts = append(cluster.Spec.Hosts, v2.Host{IPS: tt.nodes, Roles: []string{common.NODE}})
+			}
+			imageService := &fakeImageService{}
+			mounter := &fakeMounter{}
+			applier := &Applier{
+				ClusterDesired:    &cluster,
+				ClusterFile:       &fakeClusterFile{cluster: cluster, plugins: plugins, version: "v1.19.9"},
+				ImageManager:      imageService,
+				CloudImageMounter: mounter,
+				ImageStore:        imageStore,
+			}
+			got, err := applier.Plan()
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+			tt.want.ClusterName, tt.want.Image, tt.want.Runtime = "plan-test", tt.image, runtime.KubeadmRuntimeType
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Plan() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(imageService.pulled, []string{tt.image}) || mounter.mounted != 0 {
+				t.Errorf("Plan() pulled %v and left %d images mounted, want %s pulled and unmounted", imageService.pulled, mounter.mounted, tt.image)
+			}
+			if n := atomic.LoadInt32(&connected); n != 0 {
+				t.Errorf("Plan() connected to the hosts %d times", n)
+			}
+		})
+	}
+}
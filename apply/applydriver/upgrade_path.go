@@ -0,0 +1,144 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package applydriver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/image/distributionutil"
+	"github.com/alibaba/sealer/pkg/image/reference"
+)
+
+// upgradeStep is an intermediate cluster image to upgrade through.
+type upgradeStep struct {
+	Image   string
+	Version string
+}
+
+// planUpgradePath returns the intermediate images to upgrade from current to target version, because kubeadm
+// can only upgrade kubernetes one minor version at a time. images are the candidates tagged by kubernetes
+// version like kubernetes:v1.20.4, the one with the highest patch version is chosen for each minor version.
+func planUpgradePath(current, target string, images []string) ([]upgradeStep, error) {
+	from, err := semver.NewVersion(current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse current version %s: %v", current, err)
+	}
+	to, err := semver.NewVersion(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse target version %s: %v", target, err)
+	}
+	if from.Major() != to.Major() {
+		return nil, fmt.Errorf("can not upgrade from %s to %s across major versions", current, target)
+	}
+	// downgrade and skipping nothing are left to kubeadm.
+	if to.Minor() <= from.Minor()+1 {
+		return nil, nil
+	}
+
+	candidates := map[uint64]*semver.Version{}
+	names := map[uint64]string{}
+	for _, name := range images {
+		named, err := reference.ParseToNamed(name)
+		if err != nil {
+			continue
+		}
+		v, err := semver.NewVersion(named.Tag())
+		if err != nil || v.Prerelease() != "" || v.Major() != from.Major() ||
+			v.Minor() <= from.Minor() || v.Minor() >= to.Minor() {
+			continue
+		}
+		if latest, ok := candidates[v.Minor()]; !ok || v.GreaterThan(latest) {
+			candidates[v.Minor()] = v
+			names[v.Minor()] = name
+		}
+	}
+
+	var (
+		path    []upgradeStep
+		missing []string
+	)
+	for minor := from.Minor() + 1; minor < to.Minor(); minor++ {
+		v, ok := candidates[minor]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("v%d.%d", from.Major(), minor))
+			continue
+		}
+		path = append(path, upgradeStep{Image: names[minor], Version: v.Original()})
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("can not upgrade from %s to %s: kubernetes is upgraded one minor version at a time, "+
+			"but no cluster image is found for %s, please load or push them first", current, target, strings.Join(missing, ", "))
+	}
+	return path, nil
+}
+
+// getUpgradePath finds the intermediate images in the local image store and the registry of the upgrade
+// image repository, which is the repository of the cluster image by default.
+func (c *Applier) getUpgradePath(current, target string) ([]upgradeStep, error) {
+	// skip looking up the images if there is no minor version in between.
+	if path, err := planUpgradePath(current, target, nil); err == nil && len(path) == 0 {
+		return nil, nil
+	}
+
+	repo := c.ClusterDesired.Spec.Image
+	if strategy := c.ClusterDesired.Spec.UpgradeStrategy; strategy != nil && strategy.ImageRepository != "" {
+		repo = strategy.ImageRepository
+	}
+	named, err := reference.ParseToNamed(repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse upgrade image repository %s: %v", repo, err)
+	}
+
+	var images []string
+	metadataMap, err := c.ImageStore.GetImageMetadataMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local images: %v", err)
+	}
+	for name := range metadataMap {
+		n, err := reference.ParseToNamed(name)
+		if err != nil {
+			continue
+		}
+		if n.Domain() == named.Domain() && n.Repo() == named.Repo() {
+			images = append(images, name)
+		}
+	}
+
+	tags, err := distributionutil.ListTags(named)
+	if err != nil {
+		logger.Warn("failed to find upgrade images in registry, only local images are used: %v", err)
+	}
+	prefix := strings.TrimSuffix(named.Raw(), ":"+named.Tag())
+	for _, tag := range tags {
+		images = append(images, prefix+":"+tag)
+	}
+	return planUpgradePath(current, target, images)
+}
+
+func sameMinorVersion(v1, v2 string) bool {
+	a, err := semver.NewVersion(v1)
+	if err != nil {
+		return false
+	}
+	b, err := semver.NewVersion(v2)
+	if err != nil {
+		return false
+	}
+	return a.Major() == b.Major() && a.Minor() == b.Minor()
+}
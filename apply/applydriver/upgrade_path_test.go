@@ -0,0 +1,91 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package applydriver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanUpgradePath(t *testing.T) {
+	images := []string{
+		"kubernetes:v1.19.8",
+		"kubernetes:v1.19.16",
+		"kubernetes:v1.20.4",
+		"kubernetes:v1.20.5-rc.0",
+		"kubernetes:v1.21.0",
+		"kubernetes:latest",
+	}
+	tests := []struct {
+		name    string
+		current string
+		target  string
+		images  []string
+		want    []upgradeStep
+		wantErr bool
+	}{
+		{
+			name:    "next minor version",
+			current: "v1.19.8",
+			target:  "v1.20.4",
+			images:  images,
+		},
+		{
+			name:    "patch version",
+			current: "v1.19.8",
+			target:  "v1.19.16",
+		},
+		{
+			name:    "highest patch of each minor version",
+			current: "v1.18.8",
+			target:  "v1.21.0",
+			images:  images,
+			want: []upgradeStep{
+				{Image: "kubernetes:v1.19.16", Version: "v1.19.16"},
+				{Image: "kubernetes:v1.20.4", Version: "v1.20.4"},
+			},
+		},
+		{
+			name:    "missing intermediate image",
+			current: "v1.17.3",
+			target:  "v1.20.4",
+			images:  images,
+			wantErr: true,
+		},
+		{
+			name:    "major version",
+			current: "v1.19.8",
+			target:  "v2.0.0",
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			current: "unknown",
+			target:  "v1.20.4",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := planUpgradePath(tt.current, tt.target, tt.images)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planUpgradePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planUpgradePath() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
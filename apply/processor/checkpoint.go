@@ -0,0 +1,189 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/events"
+)
+
+const checkpointFileName = "checkpoint.yaml"
+
+var (
+	// FromStep run the create pipeline from the given step, the saved checkpoint is ignored.
+	FromStep string
+	// Restart ignore the saved checkpoint and run the whole create pipeline again.
+	Restart bool
+)
+
+// Step is a named step of pipeline, the name is recorded to checkpoint once the step completed.
+type Step struct {
+	Name string
+	Run  func(cluster *v2.Cluster) error
+}
+
+// Checkpoint records the completed steps of pipeline in cluster work dir,
+// so that a failed apply can be resumed from the step it failed on.
+type Checkpoint struct {
+	// SpecHash is hash of the cluster spec when the steps completed, the checkpoint of a changed spec will not be used.
+	SpecHash       string   `json:"specHash"`
+	CompletedSteps []string `json:"completedSteps,omitempty"`
+	file           string
+}
+
+func (c *Checkpoint) IsCompleted(step string) bool {
+	return !utils.NotIn(step, c.CompletedSteps)
+}
+
+func (c *Checkpoint) Complete(step string) error {
+	if c.IsCompleted(step) {
+		return nil
+	}
+	c.CompletedSteps = append(c.CompletedSteps, step)
+	return utils.MarshalYamlToFile(c.file, c)
+}
+
+func (c *Checkpoint) Remove() error {
+	return utils.CleanFiles(c.file)
+}
+
+func getCheckpointFile(clusterName string) string {
+	return filepath.Join(common.GetClusterWorkDir(clusterName), checkpointFileName)
+}
+
+func getSpecHash(cluster *v2.Cluster) (string, error) {
+	data, err := yaml.Marshal(cluster.Spec)
+	if err != nil {
+		return "", err
+	}
+	return utils.MD5(data), nil
+}
+
+// LoadCheckpoint load the checkpoint of cluster, an empty checkpoint is returned
+// if it does not exist or it is saved by a different cluster spec.
+func LoadCheckpoint(cluster *v2.Cluster) (*Checkpoint, error) {
+	hash, err := getSpecHash(cluster)
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{SpecHash: hash, file: getCheckpointFile(cluster.Name)}
+	if !utils.IsFileExist(cp.file) {
+		return cp, nil
+	}
+	saved := &Checkpoint{}
+	if err = utils.UnmarshalYamlFile(cp.file, saved); err != nil {
+		return nil, err
+	}
+	if saved.SpecHash != hash {
+		logger.Info("cluster spec changed since last apply, ignore checkpoint %s", cp.file)
+		return cp, nil
+	}
+	cp.CompletedSteps = saved.CompletedSteps
+	return cp, nil
+}
+
+// IsCreateUnfinished reports whether the cluster is still being created: a previous create was stopped
+// before it completed, or the create pipeline is asked to run again by FromStep or Restart. Such a cluster
+// may already have a kubeconfig written by Init, but it should go on with the create pipeline rather than be reconciled.
+func IsCreateUnfinished(cluster *v2.Cluster) bool {
+	if FromStep != "" || Restart {
+		return true
+	}
+	if cluster.Status.Phase == v2.ClusterCreating {
+		return true
+	}
+	// the checkpoint is removed once the cluster is created.
+	return utils.IsFileExist(getCheckpointFile(cluster.Name))
+}
+
+// executeSteps run steps in order and record each completed step to checkpoint,
+// steps completed by a previous run are skipped unless Restart or FromStep is set.
+// It stops before the next step once ctx is done, the cancelled apply can be resumed like a failed one.
+func executeSteps(ctx context.Context, cluster *v2.Cluster, cp *Checkpoint, steps []Step) error {
+	if Restart {
+		cp.CompletedSteps = nil
+	}
+	start := 0
+	if FromStep != "" {
+		start = -1
+		for i, step := range steps {
+			if step.Name == FromStep {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			return fmt.Errorf("step %s not found, valid steps: %v", FromStep, getStepNames(steps))
+		}
+	}
+
+	for i, step := range steps {
+		if i < start {
+			logger.Info("skip step %s before %s", step.Name, FromStep)
+			events.Emit(events.Event{Kind: events.KindStep, Status: events.Skipped, Cluster: cluster.Name, Name: step.Name})
+			continue
+		}
+		if FromStep == "" && !Restart && cp.IsCompleted(step.Name) {
+			logger.Info("skip completed step %s", step.Name)
+			events.Emit(events.Event{Kind: events.KindStep, Status: events.Skipped, Cluster: cluster.Name, Name: step.Name})
+			continue
+		}
+		if err := runStep(ctx, cluster, step); err != nil {
+			return recordClusterError(cluster, fmt.Errorf("failed to run step %s: %v", step.Name, err))
+		}
+		if err := cp.Complete(step.Name); err != nil {
+			return fmt.Errorf("failed to save checkpoint of step %s: %v", step.Name, err)
+		}
+	}
+	return nil
+}
+
+// runSteps run steps in order without checkpoint.
+func runSteps(ctx context.Context, cluster *v2.Cluster, steps []Step) error {
+	for _, step := range steps {
+		if err := runStep(ctx, cluster, step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runStep(ctx context.Context, cluster *v2.Cluster, step Step) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("cancelled before step %s: %v", step.Name, err)
+	}
+	logger.Debug("start to run step %s", step.Name)
+	done := events.Start(events.Event{Kind: events.KindStep, Cluster: cluster.Name, Name: step.Name})
+	err := step.Run(cluster)
+	done(err)
+	return err
+}
+
+func getStepNames(steps []Step) []string {
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.Name)
+	}
+	return names
+}
@@ -0,0 +1,127 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestExecuteSteps(t *testing.T) {
+	tests := []struct {
+		name      string
+		completed []string
+		fromStep  string
+		restart   bool
+		wantRun   []string
+		wantErr   bool
+	}{
+		{"run all steps", nil, "", false, []string{"a", "b", "c"}, false},
+		{"skip completed steps", []string{"a", "b"}, "", false, []string{"c"}, false},
+		{"restart", []string{"a", "b"}, "", true, []string{"a", "b", "c"}, false},
+		{"from step", []string{"a", "b", "c"}, "b", false, []string{"b", "c"}, false},
+		{"unknown step", nil, "d", false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "checkpoint")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			FromStep, Restart = tt.fromStep, tt.restart
+			defer func() { FromStep, Restart = "", false }()
+
+			var run []string
+			newStep := func(name string) Step {
+				return Step{name, func(cluster *v2.Cluster) error {
+					run = append(run, name)
+					return nil
+				}}
+			}
+			cp := &Checkpoint{CompletedSteps: tt.completed, file: filepath.Join(dir, checkpointFileName)}
+			err = executeSteps(context.Background(), &v2.Cluster{}, cp, []Step{newStep("a"), newStep("b"), newStep("c")})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("executeSteps() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(run, tt.wantRun) {
+				t.Errorf("executeSteps() run %v, want %v", run, tt.wantRun)
+			}
+		})
+	}
+}
+
+func TestResumeCreateAfterInit(t *testing.T) {
+	common.SetDataRoot(t.TempDir())
+	defer common.SetDataRoot(common.DefaultDataRoot)
+
+	cluster := &v2.Cluster{}
+	cluster.Name = "my-cluster"
+	var run []string
+	joinErr := fmt.Errorf("failed to join node")
+	steps := []Step{
+		{"Init", func(cluster *v2.Cluster) error {
+			run = append(run, "Init")
+			return nil
+		}},
+		{"Join", func(cluster *v2.Cluster) error {
+			run = append(run, "Join")
+			return joinErr
+		}},
+		{"RunGuest", func(cluster *v2.Cluster) error {
+			run = append(run, "RunGuest")
+			return nil
+		}},
+	}
+
+	cp, err := LoadCheckpoint(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = executeSteps(context.Background(), cluster, cp, steps); err == nil {
+		t.Fatal("executeSteps() should fail on Join")
+	}
+	// Init has written the kubeconfig by now, but the next apply should still resume the create pipeline.
+	if !IsCreateUnfinished(cluster) {
+		t.Fatal("IsCreateUnfinished() = false after create failed on Join")
+	}
+
+	run, joinErr = nil, nil
+	if cp, err = LoadCheckpoint(cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err = executeSteps(context.Background(), cluster, cp, steps); err != nil {
+		t.Fatalf("executeSteps() error = %v", err)
+	}
+	if want := []string{"Join", "RunGuest"}; !reflect.DeepEqual(run, want) {
+		t.Errorf("resumed create run %v, want %v", run, want)
+	}
+
+	if err = cp.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if IsCreateUnfinished(cluster) {
+		t.Error("IsCreateUnfinished() = true after the checkpoint is removed")
+	}
+}
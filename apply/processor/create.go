@@ -15,6 +15,7 @@
 package processor
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alibaba/sealer/common"
@@ -28,9 +29,12 @@ import (
 	"github.com/alibaba/sealer/pkg/runtime"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/platform"
 )
 
 type CreateProcessor struct {
+	ctx               context.Context
+	runtimeOpts       runtime.Options
 	ClusterFile       clusterfile.Interface
 	ImageManager      image.Service
 	cloudImageMounter cloudimage.Interface
@@ -38,20 +42,15 @@ type CreateProcessor struct {
 	Guest             guest.Interface
 	Config            config.Interface
 	Plugins           plugin.Plugins
+	pluginsLoaded     bool
 }
 
 func (c *CreateProcessor) Execute(cluster *v2.Cluster) error {
-	runTime, err := runtime.NewDefaultRuntime(cluster, c.ClusterFile.GetKubeadmConfig())
-	if err != nil {
-		return fmt.Errorf("failed to init runtime, %v", err)
-	}
-	c.Runtime = runTime
 	c.Config = config.NewConfiguration(cluster.Name)
 	if err := c.initPlugin(cluster); err != nil {
 		return err
 	}
-	err = utils.SaveClusterInfoToFile(cluster, cluster.Name)
-	if err != nil {
+	if err := startClusterPhase(cluster, v2.ClusterCreating); err != nil {
 		return err
 	}
 	pipLine, err := c.GetPipeLine()
@@ -59,39 +58,55 @@ func (c *CreateProcessor) Execute(cluster *v2.Cluster) error {
 		return err
 	}
 
-	for _, f := range pipLine {
-		if err = f(cluster); err != nil {
-			return err
-		}
+	cp, err := LoadCheckpoint(cluster)
+	if err != nil {
+		return err
+	}
+	if err = executeSteps(c.ctx, cluster, cp, pipLine); err != nil {
+		return err
+	}
+	// the cluster is created, the next apply should not resume from this checkpoint.
+	if err = cp.Remove(); err != nil {
+		return err
 	}
 
-	return nil
+	return finishClusterPhase(cluster)
 }
-func (c *CreateProcessor) GetPipeLine() ([]func(cluster *v2.Cluster) error, error) {
-	var todoList []func(cluster *v2.Cluster) error
+
+func (c *CreateProcessor) GetPipeLine() ([]Step, error) {
+	var todoList []Step
 	todoList = append(todoList,
-		c.GetPhasePluginFunc(plugin.PhaseOriginally),
-		c.MountImage,
-		c.RunConfig,
-		c.MountRootfs,
-		c.GetPhasePluginFunc(plugin.PhasePreInit),
-		c.Init,
-		c.Join,
-		c.GetPhasePluginFunc(plugin.PhasePreGuest),
-		c.RunGuest,
-		c.UnMountImage,
-		c.GetPhasePluginFunc(plugin.PhasePostInstall),
+		c.GetPhasePluginStep(plugin.PhaseOriginally),
+		Step{"MountImage", c.MountImage},
+		Step{"RunConfig", c.RunConfig},
+		Step{"MountRootfs", c.MountRootfs},
+		c.GetPhasePluginStep(plugin.PhasePreInit),
+		Step{"Init", c.Init},
+		Step{"Join", c.Join},
+		c.GetPhasePluginStep(plugin.PhasePreGuest),
+		Step{"RunGuest", c.RunGuest},
+		Step{"UnMountImage", c.UnMountImage},
+		c.GetPhasePluginStep(plugin.PhasePostInstall),
 	)
 	return todoList, nil
 }
 
 func (c *CreateProcessor) MountImage(cluster *v2.Cluster) error {
-	err := c.ImageManager.PullIfNotExist(cluster.Spec.Image)
+	// pick the image built for the hosts if it is a manifest list.
+	hostPlatform, err := platform.GetClusterPlatform(cluster)
+	if err != nil {
+		return err
+	}
+	err = c.ImageManager.PullIfNotExistWithPlatform(cluster.Spec.Image, hostPlatform)
 	if err != nil {
 		return err
 	}
 
-	return c.cloudImageMounter.MountImage(cluster)
+	err = c.cloudImageMounter.MountImage(cluster)
+	if err != nil {
+		return err
+	}
+	return recordImageID(cluster)
 }
 
 func (c *CreateProcessor) RunConfig(cluster *v2.Cluster) error {
@@ -110,23 +125,60 @@ func (c *CreateProcessor) MountRootfs(cluster *v2.Cluster) error {
 		return err
 	}
 
-	return fs.MountRootfs(cluster, hosts, true)
+	if err = setHostPhase(cluster, v2.HostPending, hosts...); err != nil {
+		return err
+	}
+	if err = fs.MountRootfs(cluster, hosts, true); err != nil {
+		return err
+	}
+	return setHostPhase(cluster, v2.HostRootfsMounted, hosts...)
+}
+
+// initRuntime create the runtime once the cluster image is mounted, because the runtime type is read from the image Metadata.
+func (c *CreateProcessor) initRuntime(cluster *v2.Cluster) error {
+	if c.Runtime != nil {
+		return nil
+	}
+	runTime, err := runtime.NewDefaultRuntimeContext(c.ctx, cluster, c.ClusterFile.GetKubeadmConfig(), c.runtimeOpts)
+	if err != nil {
+		return fmt.Errorf("failed to init runtime, %v", err)
+	}
+	c.Runtime = runTime
+	return nil
 }
 
 func (c *CreateProcessor) Init(cluster *v2.Cluster) error {
-	return c.Runtime.Init(cluster)
+	if err := c.initRuntime(cluster); err != nil {
+		return err
+	}
+	if err := c.Runtime.Init(cluster); err != nil {
+		return err
+	}
+	metadata, err := c.Runtime.GetClusterMetadata()
+	if err != nil {
+		return err
+	}
+	cluster.Status.KubeVersion = metadata.Version
+	return setHostPhase(cluster, v2.HostInitialized, cluster.GetMaster0IP())
 }
 
 func (c *CreateProcessor) Join(cluster *v2.Cluster) error {
-	err := c.Runtime.JoinMasters(cluster.GetMasterIPList()[1:])
+	if err := c.initRuntime(cluster); err != nil {
+		return err
+	}
+	masters := cluster.GetMasterIPList()[1:]
+	err := c.Runtime.JoinMasters(masters)
 	if err != nil {
 		return err
 	}
+	if err = setHostPhase(cluster, v2.HostJoined, masters...); err != nil {
+		return err
+	}
 	err = c.Runtime.JoinNodes(cluster.GetNodeIPList())
 	if err != nil {
 		return err
 	}
-	return utils.SaveClusterInfoToFile(cluster, cluster.Name)
+	return setHostPhase(cluster, v2.HostJoined, cluster.GetNodeIPList()...)
 }
 
 func (c *CreateProcessor) RunGuest(cluster *v2.Cluster) error {
@@ -141,18 +193,28 @@ func (c *CreateProcessor) initPlugin(cluster *v2.Cluster) error {
 	return c.Plugins.Dump(c.ClusterFile.GetPlugins())
 }
 
+func (c *CreateProcessor) GetPhasePluginStep(phase plugin.Phase) Step {
+	return Step{Name: "Plugin" + string(phase), Run: c.GetPhasePluginFunc(phase)}
+}
+
 func (c *CreateProcessor) GetPhasePluginFunc(phase plugin.Phase) func(cluster *v2.Cluster) error {
 	return func(cluster *v2.Cluster) error {
-		if phase == plugin.PhasePreInit {
+		// plugins in rootfs are loaded once rootfs is mounted, on the first phase which is not skipped by checkpoint.
+		if phase != plugin.PhaseOriginally && !c.pluginsLoaded {
 			if err := c.Plugins.Load(); err != nil {
 				return err
 			}
+			c.pluginsLoaded = true
+		}
+		if err := c.Plugins.Run(cluster, phase); err != nil {
+			return err
 		}
-		return c.Plugins.Run(cluster, phase)
+		cluster.Status.PluginPhases = append(cluster.Status.PluginPhases, string(phase))
+		return saveClusterStatus(cluster)
 	}
 }
 
-func NewCreateProcessor(clusterFile clusterfile.Interface) (Interface, error) {
+func NewCreateProcessor(ctx context.Context, opts runtime.Options, clusterFile clusterfile.Interface) (Interface, error) {
 	imgSvc, err := image.NewImageService()
 	if err != nil {
 		return nil, err
@@ -169,6 +231,8 @@ func NewCreateProcessor(clusterFile clusterfile.Interface) (Interface, error) {
 	}
 
 	return &CreateProcessor{
+		ctx:               ctx,
+		runtimeOpts:       opts,
 		ClusterFile:       clusterFile,
 		ImageManager:      imgSvc,
 		cloudImageMounter: mounter,
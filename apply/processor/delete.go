@@ -15,6 +15,7 @@
 package processor
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alibaba/sealer/pkg/clusterfile"
@@ -34,42 +35,56 @@ import (
 )
 
 type DeleteProcessor struct {
+	ctx               context.Context
+	runtimeOpts       runtime.Options
 	cloudImageMounter cloudimage.Interface
 	ClusterFile       clusterfile.Interface
+	Plugins           plugin.Plugins
 }
 
 // Execute :according to the different of desired cluster to delete cluster.
 func (d DeleteProcessor) Execute(cluster *v2.Cluster) (err error) {
-	runTime, err := runtime.NewDefaultRuntime(cluster, d.ClusterFile.GetKubeadmConfig())
+	runTime, err := runtime.NewDefaultRuntimeContext(d.ctx, cluster, d.ClusterFile.GetKubeadmConfig(), d.runtimeOpts)
 	if err != nil {
 		return fmt.Errorf("failed to init runtime, %v", err)
 	}
 
+	// plugins are loaded before reset, as PreDelete plugins may need the rootfs.
+	d.Plugins = plugin.NewPlugins(cluster.Name)
+	if err = d.Plugins.Dump(d.ClusterFile.GetPlugins()); err != nil {
+		return err
+	}
+	if err = d.Plugins.Load(); err != nil {
+		return err
+	}
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	if err = runHostPlugins(d.Plugins, cluster, plugin.PhasePreDelete, hosts); err != nil {
+		return err
+	}
+
 	err = runTime.Reset()
 	if err != nil {
 		return err
 	}
 
-	pipLine, err := d.GetPipeLine()
-	if err != nil {
+	if err = runHostPlugins(d.Plugins, cluster, plugin.PhasePostDelete, hosts); err != nil {
 		return err
 	}
 
-	for _, f := range pipLine {
-		if err = f(cluster); err != nil {
-			return err
-		}
+	pipLine, err := d.GetPipeLine()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return runSteps(d.ctx, cluster, pipLine)
 }
-func (d DeleteProcessor) GetPipeLine() ([]func(cluster *v2.Cluster) error, error) {
-	var todoList []func(cluster *v2.Cluster) error
+func (d DeleteProcessor) GetPipeLine() ([]Step, error) {
+	var todoList []Step
 	todoList = append(todoList,
-		d.ApplyCleanPlugin,
-		d.UnMountRootfs,
-		d.UnMountImage,
-		d.CleanFS,
+		Step{"ApplyCleanPlugin", d.ApplyCleanPlugin},
+		Step{"UnMountRootfs", d.UnMountRootfs},
+		Step{"UnMountImage", d.UnMountImage},
+		Step{"CleanFS", d.CleanFS},
 	)
 	return todoList, nil
 }
@@ -93,27 +108,22 @@ func (d DeleteProcessor) UnMountImage(cluster *v2.Cluster) error {
 }
 
 func (d DeleteProcessor) ApplyCleanPlugin(cluster *v2.Cluster) error {
-	plugins := plugin.NewPlugins(cluster.Name)
-	if err := plugins.Dump(d.ClusterFile.GetPlugins()); err != nil {
-		return err
-	}
-	if err := plugins.Load(); err != nil {
-		return err
-	}
-	return plugins.Run(cluster, plugin.PhasePostClean)
+	return d.Plugins.Run(cluster, plugin.PhasePostClean)
 }
 
 func (d DeleteProcessor) CleanFS(cluster *v2.Cluster) error {
 	return cloudfilesystem.CleanFilesystem(cluster.Name)
 }
 
-func NewDeleteProcessor(clusterFile clusterfile.Interface) (Interface, error) {
+func NewDeleteProcessor(ctx context.Context, opts runtime.Options, clusterFile clusterfile.Interface) (Interface, error) {
 	mounter, err := filesystem.NewCloudImageMounter()
 	if err != nil {
 		return nil, err
 	}
 
 	return DeleteProcessor{
+		ctx:               ctx,
+		runtimeOpts:       opts,
 		ClusterFile:       clusterFile,
 		cloudImageMounter: mounter,
 	}, nil
@@ -15,6 +15,8 @@
 package processor
 
 import (
+	"context"
+
 	"github.com/alibaba/sealer/pkg/clusterfile"
 	"github.com/alibaba/sealer/pkg/config"
 	"github.com/alibaba/sealer/pkg/filesystem"
@@ -25,6 +27,7 @@ import (
 )
 
 type InstallProcessor struct {
+	ctx         context.Context
 	fileSystem  cloudfilesystem.Interface
 	clusterFile clusterfile.Interface
 	Guest       guest.Interface
@@ -44,27 +47,21 @@ func (i InstallProcessor) Execute(cluster *v2.Cluster) error {
 		return err
 	}
 
-	for _, f := range pipLine {
-		if err = f(cluster); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return runSteps(i.ctx, cluster, pipLine)
 }
 
 func (i InstallProcessor) initPlugin() error {
 	return i.Plugins.Dump(i.clusterFile.GetPlugins())
 }
 
-func (i InstallProcessor) GetPipeLine() ([]func(cluster *v2.Cluster) error, error) {
-	var todoList []func(cluster *v2.Cluster) error
+func (i InstallProcessor) GetPipeLine() ([]Step, error) {
+	var todoList []Step
 	todoList = append(todoList,
-		i.RunConfig,
-		i.MountRootfs,
-		i.GetPhasePluginFunc(plugin.PhasePreGuest),
-		i.Install,
-		i.GetPhasePluginFunc(plugin.PhasePostInstall),
+		Step{"RunConfig", i.RunConfig},
+		Step{"MountRootfs", i.MountRootfs},
+		Step{"Plugin" + string(plugin.PhasePreGuest), i.GetPhasePluginFunc(plugin.PhasePreGuest)},
+		Step{"Install", i.Install},
+		Step{"Plugin" + string(plugin.PhasePostInstall), i.GetPhasePluginFunc(plugin.PhasePostInstall)},
 	)
 	return todoList, nil
 }
@@ -94,7 +91,7 @@ func (i InstallProcessor) GetPhasePluginFunc(phase plugin.Phase) func(cluster *v
 	}
 }
 
-func NewInstallProcessor(rootfs string, clusterFile clusterfile.Interface) (Interface, error) {
+func NewInstallProcessor(ctx context.Context, rootfs string, clusterFile clusterfile.Interface) (Interface, error) {
 	gs, err := guest.NewGuestManager()
 	if err != nil {
 		return nil, err
@@ -106,6 +103,7 @@ func NewInstallProcessor(rootfs string, clusterFile clusterfile.Interface) (Inte
 	}
 
 	return InstallProcessor{
+		ctx:         ctx,
 		clusterFile: clusterFile,
 		fileSystem:  fs,
 		Guest:       gs,
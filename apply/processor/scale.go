@@ -15,20 +15,24 @@
 package processor
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alibaba/sealer/pkg/filesystem/cloudfilesystem"
 
-	"github.com/alibaba/sealer/utils"
-
+	"github.com/alibaba/sealer/logger"
 	"github.com/alibaba/sealer/pkg/filesystem"
+	"github.com/alibaba/sealer/pkg/plugin"
 	"github.com/alibaba/sealer/pkg/runtime"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 )
 
 type ScaleProcessor struct {
+	ctx             context.Context
+	runtimeOpts     runtime.Options
 	fileSystem      cloudfilesystem.Interface
 	Runtime         runtime.Interface
+	Plugins         plugin.Plugins
 	KubeadmConfig   *runtime.KubeadmConfig
 	MastersToJoin   []string
 	MastersToDelete []string
@@ -45,52 +49,98 @@ func (s ScaleProcessor) Execute(cluster *v2.Cluster) error {
 		3. master scale up + node scale down: not support
 		4. master scale up + master scale down: not support
 	*/
-	runTime, err := runtime.NewDefaultRuntime(cluster, s.KubeadmConfig)
+	runtimeOpts := s.runtimeOpts
+	if !s.IsScaleUp {
+		// confirm before PreDelete plugins which may drain the hosts, and the runtime does not ask again.
+		logger.Info("%s will be deleted", append(append([]string{}, s.MastersToDelete...), s.NodesToDelete...))
+		if err := runtimeOpts.ConfirmDeleteNodes(); err != nil {
+			return err
+		}
+		runtimeOpts.ForceDelete = true
+	}
+	runTime, err := runtime.NewDefaultRuntimeContext(s.ctx, cluster, s.KubeadmConfig, runtimeOpts)
 	if err != nil {
 		return fmt.Errorf("failed to init runtime, %v", err)
 	}
 	s.Runtime = runTime
-
-	if s.IsScaleUp {
-		err = utils.SaveClusterInfoToFile(cluster, cluster.Name)
-		if err != nil {
-			return err
-		}
-		return s.ScaleUp(cluster)
+	s.Plugins = plugin.NewPlugins(cluster.Name)
+	if err = s.Plugins.Load(); err != nil {
+		return fmt.Errorf("failed to load plugins, %v", err)
 	}
-	return s.ScaleDown(cluster)
-}
 
-func (s ScaleProcessor) ScaleUp(cluster *v2.Cluster) error {
-	hosts := append(s.MastersToJoin, s.NodesToJoin...)
-	err := s.fileSystem.MountRootfs(cluster, hosts, true)
-	if err != nil {
+	if err = startClusterPhase(cluster, v2.ClusterScaling); err != nil {
 		return err
 	}
-	err = s.Runtime.JoinMasters(s.MastersToJoin)
-	if err != nil {
-		return err
+	if s.IsScaleUp {
+		err = s.ScaleUp(cluster)
+	} else {
+		err = s.ScaleDown(cluster)
 	}
-	err = s.Runtime.JoinNodes(s.NodesToJoin)
 	if err != nil {
-		return err
+		return recordClusterError(cluster, err)
 	}
-	return nil
+	return finishClusterPhase(cluster)
+}
+
+func (s ScaleProcessor) ScaleUp(cluster *v2.Cluster) error {
+	hosts := append(append([]string{}, s.MastersToJoin...), s.NodesToJoin...)
+	return runSteps(s.ctx, cluster, []Step{
+		{"MountRootfs", func(cluster *v2.Cluster) error {
+			if err := setHostPhase(cluster, v2.HostPending, hosts...); err != nil {
+				return err
+			}
+			if err := s.fileSystem.MountRootfs(cluster, hosts, true); err != nil {
+				return err
+			}
+			return setHostPhase(cluster, v2.HostRootfsMounted, hosts...)
+		}},
+		{"Plugin" + string(plugin.PhasePreJoin), func(cluster *v2.Cluster) error {
+			return s.Plugins.Run(cluster, plugin.PhasePreJoin, hosts...)
+		}},
+		{"JoinMasters", func(cluster *v2.Cluster) error {
+			if err := s.Runtime.JoinMasters(s.MastersToJoin); err != nil {
+				return err
+			}
+			return setHostPhase(cluster, v2.HostJoined, s.MastersToJoin...)
+		}},
+		{"JoinNodes", func(cluster *v2.Cluster) error {
+			if err := s.Runtime.JoinNodes(s.NodesToJoin); err != nil {
+				return err
+			}
+			return setHostPhase(cluster, v2.HostJoined, s.NodesToJoin...)
+		}},
+		{"Plugin" + string(plugin.PhasePostJoin), func(cluster *v2.Cluster) error {
+			return s.Plugins.Run(cluster, plugin.PhasePostJoin, hosts...)
+		}},
+	})
 }
 
 func (s ScaleProcessor) ScaleDown(cluster *v2.Cluster) error {
-	err := s.Runtime.DeleteMasters(s.MastersToDelete)
-	if err != nil {
-		return err
-	}
-	err = s.Runtime.DeleteNodes(s.NodesToDelete)
-	if err != nil {
-		return err
-	}
-	return s.fileSystem.UnMountRootfs(cluster, append(s.MastersToDelete, s.NodesToDelete...))
+	hosts := append(append([]string{}, s.MastersToDelete...), s.NodesToDelete...)
+	return runSteps(s.ctx, cluster, []Step{
+		{"Plugin" + string(plugin.PhasePreDelete), func(cluster *v2.Cluster) error {
+			return runHostPlugins(s.Plugins, cluster, plugin.PhasePreDelete, hosts)
+		}},
+		{"DeleteMasters", func(cluster *v2.Cluster) error {
+			return s.Runtime.DeleteMasters(s.MastersToDelete)
+		}},
+		{"DeleteNodes", func(cluster *v2.Cluster) error {
+			return s.Runtime.DeleteNodes(s.NodesToDelete)
+		}},
+		{"Plugin" + string(plugin.PhasePostDelete), func(cluster *v2.Cluster) error {
+			return runHostPlugins(s.Plugins, cluster, plugin.PhasePostDelete, hosts)
+		}},
+		{"UnMountRootfs", func(cluster *v2.Cluster) error {
+			if err := s.fileSystem.UnMountRootfs(cluster, hosts); err != nil {
+				return err
+			}
+			cluster.RemoveHostStatus(hosts...)
+			return nil
+		}},
+	})
 }
 
-func NewScaleProcessor(kubeadmConfig *runtime.KubeadmConfig, rootfs string, masterToJoin, masterToDelete, nodeToJoin, nodeToDelete []string) (Interface, error) {
+func NewScaleProcessor(ctx context.Context, opts runtime.Options, kubeadmConfig *runtime.KubeadmConfig, rootfs string, masterToJoin, masterToDelete, nodeToJoin, nodeToDelete []string) (Interface, error) {
 	var up bool
 	// only scale up or scale down at a time
 	if len(masterToJoin) > 0 || len(nodeToJoin) > 0 {
@@ -101,6 +151,8 @@ func NewScaleProcessor(kubeadmConfig *runtime.KubeadmConfig, rootfs string, mast
 		return nil, err
 	}
 	return ScaleProcessor{
+		ctx:             ctx,
+		runtimeOpts:     opts,
 		MastersToDelete: masterToDelete,
 		MastersToJoin:   masterToJoin,
 		NodesToDelete:   nodeToDelete,
@@ -110,3 +162,13 @@ func NewScaleProcessor(kubeadmConfig *runtime.KubeadmConfig, rootfs string, mast
 		fileSystem:      fs,
 	}, nil
 }
+
+// runHostPlugins run the plugins of phase for each host, with only the host in plugin context.
+func runHostPlugins(plugins plugin.Plugins, cluster *v2.Cluster, phase plugin.Phase, hosts []string) error {
+	for _, host := range hosts {
+		if err := plugins.Run(cluster, phase, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/image/store"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/events"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func startClusterPhase(cluster *v2.Cluster, phase v2.ClusterPhase) error {
+	cluster.Status.Phase = phase
+	cluster.Status.LastError = ""
+	cluster.Status.PluginPhases = nil
+	cluster.Status.PluginRuns = nil
+	events.Emit(events.Event{Kind: events.KindCluster, Status: events.Started, Cluster: cluster.Name, Phase: string(phase)})
+	return saveClusterStatus(cluster)
+}
+
+func finishClusterPhase(cluster *v2.Cluster) error {
+	events.Emit(events.Event{Kind: events.KindCluster, Status: events.Succeeded, Cluster: cluster.Name, Phase: string(cluster.Status.Phase)})
+	cluster.Status.Phase = v2.ClusterRunning
+	return saveClusterStatus(cluster)
+}
+
+func recordClusterError(cluster *v2.Cluster, err error) error {
+	events.Emit(events.Event{Kind: events.KindCluster, Status: events.Failed, Cluster: cluster.Name, Phase: string(cluster.Status.Phase), Error: err.Error()})
+	cluster.Status.Phase = v2.ClusterFailed
+	cluster.Status.LastError = err.Error()
+	if saveErr := saveClusterStatus(cluster); saveErr != nil {
+		logger.Warn("failed to save status of cluster %s: %v", cluster.Name, saveErr)
+	}
+	return err
+}
+
+// setHostPhase update the phase of hosts and save it, so the status is still accurate if the next step fails.
+func setHostPhase(cluster *v2.Cluster, phase v2.HostPhase, hosts ...string) error {
+	if len(hosts) == 0 {
+		return nil
+	}
+	cluster.SetHostPhase(phase, hosts...)
+	return saveClusterStatus(cluster)
+}
+
+func recordImageID(cluster *v2.Cluster) error {
+	is, err := store.NewDefaultImageStore()
+	if err != nil {
+		return err
+	}
+	img, err := is.GetByName(cluster.Spec.Image)
+	if err != nil {
+		return err
+	}
+	cluster.Status.ImageID = img.Spec.ID
+	return saveClusterStatus(cluster)
+}
+
+func saveClusterStatus(cluster *v2.Cluster) error {
+	cluster.Status.LastUpdateTime = metav1.Now()
+	return utils.SaveClusterInfoToFile(cluster, cluster.Name)
+}
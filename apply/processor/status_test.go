@@ -0,0 +1,114 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/clusterfile"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils/events"
+)
+
+func TestClusterStatusTransitions(t *testing.T) {
+	common.SetDataRoot(t.TempDir())
+	defer common.SetDataRoot(common.DefaultDataRoot)
+	var received []events.Event
+	defer events.Subscribe(func(e events.Event) {
+		received = append(received, e)
+	})()
+
+	cluster := &v2.Cluster{}
+	cluster.Name = "my-cluster"
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2"}, Roles: []string{common.MASTER}},
+		{IPS: []string{"192.168.0.3", "192.168.0.4"}, Roles: []string{common.NODE}},
+	}
+	cluster.Status.Phase = v2.ClusterFailed
+	cluster.Status.LastError = "failed last time"
+	cluster.Status.PluginPhases = []string{"PreInit"}
+	cluster.Status.PluginRuns = []v2.PluginRunStatus{{Name: "label"}}
+	// saved reads the status saved to the cluster work dir, which the next apply starts from.
+	saved := func() v2.ClusterStatus {
+		c, err := clusterfile.GetClusterFromFile(common.GetClusterWorkClusterfile(cluster.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.Status
+	}
+
+	// scale up joins two nodes.
+	if err := startClusterPhase(cluster, v2.ClusterScaling); err != nil {
+		t.Fatal(err)
+	}
+	if s := saved(); s.Phase != v2.ClusterScaling || s.LastError != "" || s.PluginPhases != nil || s.PluginRuns != nil {
+		t.Errorf("startClusterPhase() saved %+v, want phase %s and the last apply cleared", s, v2.ClusterScaling)
+	}
+	if err := setHostPhase(cluster, v2.HostPending, "192.168.0.3", "192.168.0.4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := setHostPhase(cluster, v2.HostJoined, "192.168.0.3"); err != nil {
+		t.Fatal(err)
+	}
+	pending := *cluster.GetHostStatus("192.168.0.4")
+	if err := setHostPhase(cluster, v2.HostPending, "192.168.0.4"); err != nil {
+		t.Fatal(err)
+	}
+	if got := *cluster.GetHostStatus("192.168.0.4"); got != pending {
+		t.Errorf("setHostPhase() to the same phase changed %+v to %+v", pending, got)
+	}
+	if err := setHostPhase(cluster, v2.HostJoined); err != nil {
+		t.Fatal(err)
+	}
+	hosts := saved().Hosts
+	if len(hosts) != 2 || hosts[0].IP != "192.168.0.3" || hosts[0].Phase != v2.HostJoined || hosts[0].Role != common.NODE ||
+		hosts[1].IP != "192.168.0.4" || hosts[1].Phase != v2.HostPending {
+		t.Errorf("setHostPhase() saved hosts %+v, want 192.168.0.3 Joined and 192.168.0.4 Pending", hosts)
+	}
+
+	// the join of the second node fails.
+	joinErr := fmt.Errorf("failed to join node 192.168.0.4")
+	if err := recordClusterError(cluster, joinErr); err != joinErr {
+		t.Errorf("recordClusterError() = %v, want %v", err, joinErr)
+	}
+	if s := saved(); s.Phase != v2.ClusterFailed || s.LastError != joinErr.Error() || len(s.Hosts) != 2 {
+		t.Errorf("recordClusterError() saved %+v, want phase %s with the error and the host phases kept", s, v2.ClusterFailed)
+	}
+
+	// the next apply scales down the failed node.
+	if err := startClusterPhase(cluster, v2.ClusterScaling); err != nil {
+		t.Fatal(err)
+	}
+	cluster.RemoveHostStatus("192.168.0.4")
+	if err := finishClusterPhase(cluster); err != nil {
+		t.Fatal(err)
+	}
+	if s := saved(); s.Phase != v2.ClusterRunning || s.LastError != "" || len(s.Hosts) != 1 || s.Hosts[0].IP != "192.168.0.3" {
+		t.Errorf("finishClusterPhase() saved %+v, want phase %s with only host 192.168.0.3", s, v2.ClusterRunning)
+	}
+
+	var statuses []events.Status
+	for _, e := range received {
+		if e.Kind != events.KindCluster || e.Cluster != cluster.Name || e.Phase != string(v2.ClusterScaling) {
+			t.Errorf("got event %+v, want cluster events of %s at phase %s", e, cluster.Name, v2.ClusterScaling)
+		}
+		statuses = append(statuses, e.Status)
+	}
+	if fmt.Sprint(statuses) != fmt.Sprint([]events.Status{events.Started, events.Failed, events.Started, events.Succeeded}) {
+		t.Errorf("got cluster event statuses %v", statuses)
+	}
+}
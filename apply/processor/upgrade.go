@@ -15,16 +15,23 @@
 package processor
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/alibaba/sealer/pkg/filesystem/cloudfilesystem"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 
 	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/checker"
 	"github.com/alibaba/sealer/pkg/filesystem"
+	"github.com/alibaba/sealer/pkg/plugin"
 	"github.com/alibaba/sealer/pkg/runtime"
 	"github.com/alibaba/sealer/utils"
 )
 
 type UpgradeProcessor struct {
+	ctx           context.Context
+	rootfs        string
 	fileSystem    cloudfilesystem.Interface
 	Runtime       runtime.Interface
 	MastersToJoin []string
@@ -33,16 +40,48 @@ type UpgradeProcessor struct {
 
 // Execute :according to the different of desired cluster to upgrade cluster.
 func (u UpgradeProcessor) Execute(cluster *v2.Cluster) error {
-	err := u.MountRootfs(cluster)
-	if err != nil {
+	if err := startClusterPhase(cluster, v2.ClusterUpgrading); err != nil {
 		return err
 	}
-	err = u.Upgrade()
+	if err := u.upgrade(cluster); err != nil {
+		return recordClusterError(cluster, err)
+	}
+	return finishClusterPhase(cluster)
+}
+
+func (u UpgradeProcessor) upgrade(cluster *v2.Cluster) error {
+	metadata, err := u.Runtime.GetClusterMetadata()
 	if err != nil {
 		return err
 	}
-
-	return nil
+	// plugins are loaded from the rootfs of new image.
+	plugins := plugin.NewPlugins(cluster.Name)
+	return runSteps(u.ctx, cluster, []Step{
+		// check the cluster before anything is copied to the hosts.
+		{"CheckUpgrade", func(cluster *v2.Cluster) error {
+			return checker.RunCheckList([]checker.Interface{checker.NewUpgradeChecker(metadata.Version, u.rootfs)}, cluster, checker.PhasePreUpgrade)
+		}},
+		{"MountRootfs", u.MountRootfs},
+		{"Plugin" + string(plugin.PhasePreUpgrade), func(cluster *v2.Cluster) error {
+			if err := plugins.Load(); err != nil {
+				return fmt.Errorf("failed to load plugins, %v", err)
+			}
+			return plugins.Run(cluster, plugin.PhasePreUpgrade)
+		}},
+		{"Upgrade", func(cluster *v2.Cluster) error {
+			if err := u.Upgrade(); err != nil {
+				return err
+			}
+			cluster.Status.KubeVersion = metadata.Version
+			if err := recordImageID(cluster); err != nil {
+				return err
+			}
+			return setHostPhase(cluster, v2.HostUpgraded, append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)...)
+		}},
+		{"Plugin" + string(plugin.PhasePostUpgrade), func(cluster *v2.Cluster) error {
+			return plugins.Run(cluster, plugin.PhasePostUpgrade)
+		}},
+	})
 }
 
 func (u UpgradeProcessor) MountRootfs(cluster *v2.Cluster) error {
@@ -61,7 +100,7 @@ func (u UpgradeProcessor) Upgrade() error {
 	return u.Runtime.Upgrade()
 }
 
-func NewUpgradeProcessor(rootfs string, rt runtime.Interface, masterToJoin, nodeToJoin []string) (Interface, error) {
+func NewUpgradeProcessor(ctx context.Context, rootfs string, rt runtime.Interface, masterToJoin, nodeToJoin []string) (Interface, error) {
 	// only do upgrade here. cancel scale action.
 	fs, err := filesystem.NewFilesystem(rootfs)
 	if err != nil {
@@ -69,6 +108,8 @@ func NewUpgradeProcessor(rootfs string, rt runtime.Interface, masterToJoin, node
 	}
 
 	return UpgradeProcessor{
+		ctx:           ctx,
+		rootfs:        rootfs,
 		fileSystem:    fs,
 		Runtime:       rt,
 		MastersToJoin: masterToJoin,
@@ -14,11 +14,21 @@
 
 package buildimage
 
+import (
+	"context"
+
+	"github.com/alibaba/sealer/pkg/parser"
+)
+
 type Context struct {
 	BuildContext string
 	//cache flag,will change for each layer ctx
 	UseCache  bool
 	BuildArgs map[string]string
+	// Stages are the build stages before the last one of multi-stage Kubefile, used by "COPY --from".
+	Stages []parser.Stage
+	// Cancel stops the build before the next layer once it is done, nil never cancels.
+	Cancel context.Context
 }
 
 type SaveOpts struct {
@@ -18,15 +18,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/alibaba/sealer/build/buildkit/buildinstruction"
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
 	"github.com/alibaba/sealer/pkg/image"
 	"github.com/alibaba/sealer/pkg/image/store"
+	"github.com/alibaba/sealer/pkg/parser"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/events"
+	"github.com/opencontainers/go-digest"
 	"golang.org/x/sync/errgroup"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -37,6 +42,7 @@ type layerExecutor struct {
 	buildType       string
 	baseLayers      []v1.Layer
 	layerStore      store.LayerStore
+	fs              store.Backend
 	rootfsMountInfo *buildinstruction.MountTarget
 }
 
@@ -56,9 +62,20 @@ func (l *layerExecutor) Execute(ctx Context, rawLayers []v1.Layer) ([]v1.Layer,
 		ctx.BuildArgs,
 		ctx.UseCache, l.layerStore)
 
+	// build and mount the stages to copy from.
+	stageContexts, stageMounts, err := prepareStageContexts(ctx, rawLayers, l.buildType, l.layerStore)
+	if err != nil {
+		return []v1.Layer{}, err
+	}
+	defer cleanupStages(stageMounts)
+	execCtx.StageContexts = stageContexts
+
 	for i := 0; i < len(rawLayers); i++ {
 		//we are to set layer id for each new layers.
 		layer := &rawLayers[i]
+		if ctx.Cancel != nil && ctx.Cancel.Err() != nil {
+			return []v1.Layer{}, fmt.Errorf("cancelled before layer %s %s: %v", layer.Type, layer.Value, ctx.Cancel.Err())
+		}
 		logger.Info("run build layer: %s %s", layer.Type, layer.Value)
 
 		if l.buildType == common.LiteBuild && layer.Type == common.CMDCOMMAND {
@@ -74,7 +91,10 @@ func (l *layerExecutor) Execute(ctx Context, rawLayers []v1.Layer) ([]v1.Layer,
 		if err != nil {
 			return []v1.Layer{}, err
 		}
+		done := events.Start(events.Event{Kind: events.KindLayer, Name: fmt.Sprintf("%s %s", layer.Type, layer.Value),
+			Current: i + 1, Total: len(rawLayers)})
 		out, err := inst.Exec(execCtx)
+		done(err)
 		if err != nil {
 			return []v1.Layer{}, err
 		}
@@ -88,6 +108,7 @@ func (l *layerExecutor) Execute(ctx Context, rawLayers []v1.Layer) ([]v1.Layer,
 		if out.LayerID == "" {
 			continue
 		}
+		layer.Created = l.layerCreated(out.LayerID)
 		baseLayers = append(baseLayers, *layer)
 	}
 	logger.Info("exec all build instructs success")
@@ -176,9 +197,28 @@ func (l *layerExecutor) genNewLayer(layerType, layerValue, filepath string) (v1.
 	}
 
 	imageLayer.ID = layerID
+	if layerID != "" {
+		imageLayer.Created = l.layerCreated(layerID)
+	}
 	return imageLayer, nil
 }
 
+// layerCreated returns when the layer was built first, so that a layer hit from the cache
+// keeps its original build time instead of the time of the current build.
+func (l *layerExecutor) layerCreated(layerID digest.Digest) *metav1.Time {
+	if data, err := l.fs.GetMetadata(layerID, common.LayerCreated); err == nil {
+		if created, err := time.Parse(time.RFC3339, string(data)); err == nil {
+			return &metav1.Time{Time: created}
+		}
+	}
+
+	created := metav1.Now().Rfc3339Copy()
+	if err := l.fs.SetMetadata(layerID, common.LayerCreated, []byte(created.Format(time.RFC3339))); err != nil {
+		logger.Warn("failed to record the build time of layer %s: %v", layerID, err)
+	}
+	return &created
+}
+
 func (l *layerExecutor) Cleanup() error {
 	l.rootfsMountInfo.CleanUp()
 	return nil
@@ -193,30 +233,40 @@ func NewLayerExecutor(baseLayers []v1.Layer, buildType string) (Executor, error)
 	if err != nil {
 		return nil, err
 	}
+	fs, err := store.NewFSStoreBackend()
+	if err != nil {
+		return nil, err
+	}
 
 	return &layerExecutor{
 		buildType:       buildType,
 		baseLayers:      baseLayers,
 		layerStore:      layerStore,
+		fs:              fs,
 		rootfsMountInfo: mountInfo,
 	}, nil
 }
 
 // NewBuildImageByKubefile init image spec by kubefile and check if base image exists ,if not will pull it.
-func NewBuildImageByKubefile(kubefileName string) (*v1.Image, []v1.Layer, error) {
+// For multi-stage kubefile, the image is built from the last stage, and the stages before it are returned.
+func NewBuildImageByKubefile(kubefileName string) (*v1.Image, []v1.Layer, []parser.Stage, error) {
 	rawImage, err := InitImageSpec(kubefileName)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
+	stages := parser.SplitStages(rawImage.Spec.Layers)
+	imageStage := stages[len(stages)-1]
+	rawImage.Spec.Layers = append([]v1.Layer{{Type: common.FROMCOMMAND, Value: imageStage.Image}}, imageStage.Layers...)
+
 	imageStore, err := store.NewDefaultImageStore()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	service, err := image.NewImageService()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	var (
@@ -230,18 +280,18 @@ func NewBuildImageByKubefile(kubefileName string) (*v1.Image, []v1.Layer, error)
 		baseImage = &v1.Image{}
 	} else {
 		if err = service.PullIfNotExist(layer0.Value); err != nil {
-			return nil, nil, fmt.Errorf("failed to pull baseImage: %v", err)
+			return nil, nil, nil, fmt.Errorf("failed to pull baseImage: %v", err)
 		}
 		baseImage, err = imageStore.GetByName(layer0.Value)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to get base image err: %s", err)
+			return nil, nil, nil, fmt.Errorf("failed to get base image err: %s", err)
 		}
 	}
 
 	baseLayers := append([]v1.Layer{}, baseImage.Spec.Layers...)
 	newLayers := append([]v1.Layer{}, rawImage.Spec.Layers[1:]...)
 	if len(baseLayers)+len(newLayers) > maxLayerDeep {
-		return nil, nil, errors.New("current number of layers exceeds 128 layers")
+		return nil, nil, nil, errors.New("current number of layers exceeds 128 layers")
 	}
 
 	// merge base image cmd and set to raw image as parent.
@@ -250,6 +300,9 @@ func NewBuildImageByKubefile(kubefileName string) (*v1.Image, []v1.Layer, error)
 	// merge base image args and set to raw image as parent.
 	rawImage.Spec.ImageConfig.Args.Parent = utils.MergeMap(baseImage.Spec.ImageConfig.Args.Parent,
 		baseImage.Spec.ImageConfig.Args.Current)
+	// env and labels of base image are inherited, and overwritten by the current ones.
+	rawImage.Spec.ImageConfig.Env = utils.MergeMap(baseImage.Spec.ImageConfig.Env, rawImage.Spec.ImageConfig.Env)
+	rawImage.Spec.ImageConfig.Labels = utils.MergeMap(baseImage.Spec.ImageConfig.Labels, rawImage.Spec.ImageConfig.Labels)
 
-	return rawImage, baseLayers, nil
+	return rawImage, baseLayers, stages[:len(stages)-1], nil
 }
@@ -49,7 +49,7 @@ func (i imageSaver) setImageAttribute(image *v1.Image) error {
 	defer mi.CleanUp()
 
 	rootfsPath := mi.GetMountTarget()
-	is := []ImageSetter{NewAnnotationSetter(rootfsPath), NewPlatformSetter(rootfsPath)}
+	is := []ImageSetter{NewAnnotationSetter(rootfsPath), NewPlatformSetter(rootfsPath), NewLayerSizeSetter()}
 	for _, s := range is {
 		if err = s.Set(image); err != nil {
 			return err
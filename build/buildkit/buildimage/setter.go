@@ -89,3 +89,25 @@ func NewPlatformSetter(rootfs string) ImageSetter {
 		source: rootfs,
 	}
 }
+
+type layerSize struct{}
+
+// Set records the size of the layers built, the layers of base image keep the sizes they are built with.
+func (l layerSize) Set(ima *v1.Image) error {
+	for i := range ima.Spec.Layers {
+		layer := &ima.Spec.Layers[i]
+		if layer.ID == "" || layer.Size != 0 {
+			continue
+		}
+		size, err := utils.GetFilesSize([]string{filepath.Join(common.DefaultLayerDir, layer.ID.Hex())})
+		if err != nil {
+			return fmt.Errorf("failed to get size of layer %s, err: %v", layer.ID, err)
+		}
+		layer.Size = size
+	}
+	return nil
+}
+
+func NewLayerSizeSetter() ImageSetter {
+	return layerSize{}
+}
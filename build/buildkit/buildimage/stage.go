@@ -0,0 +1,146 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildimage
+
+import (
+	"fmt"
+
+	"github.com/alibaba/sealer/build/buildkit/buildinstruction"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/image"
+	"github.com/alibaba/sealer/pkg/image/store"
+	"github.com/alibaba/sealer/pkg/parser"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+// prepareStageContexts build the stages referred by "COPY --from" of layers and mount their rootfs,
+// a reference which is not a stage name or index is treated as an image. It returns the mount dir of
+// each reference, and the mounts which should be cleaned up after build.
+func prepareStageContexts(ctx Context, layers []v1.Layer, buildType string, layerStore store.LayerStore) (map[string]string, []*buildinstruction.MountTarget, error) {
+	var (
+		contexts = map[string]string{}
+		mounts   []*buildinstruction.MountTarget
+	)
+	for _, layer := range layers {
+		if layer.Type != common.COPYCOMMAND {
+			continue
+		}
+		from := buildinstruction.ParseCopyFrom(layer.Value)
+		if from == "" || contexts[from] != "" {
+			continue
+		}
+
+		stageLayers, stageMounts, err := buildStage(ctx, from, buildType, layerStore)
+		mounts = append(mounts, stageMounts...)
+		if err != nil {
+			cleanupStages(mounts)
+			return nil, nil, fmt.Errorf("failed to build stage %s: %v", from, err)
+		}
+		mi, err := mountRootfs(buildinstruction.GetBaseLayersPath(stageLayers))
+		if err != nil {
+			cleanupStages(mounts)
+			return nil, nil, err
+		}
+		mounts = append(mounts, mi)
+		contexts[from] = mi.GetMountTarget()
+		logger.Info("stage %s is mounted at %s", from, mi.GetMountTarget())
+	}
+	return contexts, mounts, nil
+}
+
+// buildStage execute the instructions of the stage named ref on its base image, and return all its layers.
+func buildStage(ctx Context, ref, buildType string, layerStore store.LayerStore) ([]v1.Layer, []*buildinstruction.MountTarget, error) {
+	stage, ok := parser.FindStage(ctx.Stages, ref)
+	if !ok {
+		stage = parser.Stage{Image: ref, Index: len(ctx.Stages)}
+	}
+	layers, err := getImageLayers(stage.Image)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(stage.Layers) == 0 {
+		return layers, nil, nil
+	}
+
+	// a stage can only copy from the stages before it.
+	stageCtx := ctx
+	stageCtx.Stages = ctx.Stages[:stage.Index]
+	contexts, mounts, err := prepareStageContexts(stageCtx, stage.Layers, buildType, layerStore)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanupStages(mounts)
+
+	execCtx := buildinstruction.NewExecContext(buildType, ctx.BuildContext, ctx.BuildArgs, ctx.UseCache, layerStore)
+	execCtx.StageContexts = contexts
+	for i := range stage.Layers {
+		layer := stage.Layers[i]
+		// CMD of the stage is not a part of the image.
+		if layer.Type == common.CMDCOMMAND {
+			continue
+		}
+		logger.Info("run stage %s layer: %s %s", ref, layer.Type, layer.Value)
+		inst, err := buildinstruction.NewInstruction(buildinstruction.InstructionContext{
+			BaseLayers:   layers,
+			CurrentLayer: &layer,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		out, err := inst.Exec(execCtx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if execCtx.ContinueCache {
+			execCtx.ParentID = out.ParentID
+			execCtx.ContinueCache = out.ContinueCache
+		}
+		if out.LayerID == "" {
+			continue
+		}
+		layer.ID = out.LayerID
+		layers = append(layers, layer)
+	}
+	return layers, nil, nil
+}
+
+func getImageLayers(imageName string) ([]v1.Layer, error) {
+	if imageName == common.ImageScratch {
+		return nil, nil
+	}
+	service, err := image.NewImageService()
+	if err != nil {
+		return nil, err
+	}
+	if err = service.PullIfNotExist(imageName); err != nil {
+		return nil, fmt.Errorf("failed to pull image %s: %v", imageName, err)
+	}
+	imageStore, err := store.NewDefaultImageStore()
+	if err != nil {
+		return nil, err
+	}
+	ima, err := imageStore.GetByName(imageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image %s: %v", imageName, err)
+	}
+	return append([]v1.Layer{}, ima.Spec.Layers...), nil
+}
+
+func cleanupStages(mounts []*buildinstruction.MountTarget) {
+	for _, m := range mounts {
+		m.CleanUp()
+	}
+}
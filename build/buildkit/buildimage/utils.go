@@ -43,9 +43,9 @@ func InitImageSpec(kubefile string) (*v1.Image, error) {
 		return nil, fmt.Errorf("failed to load kubefile: %v", err)
 	}
 
-	rawImage := parser.NewParse().Parse(kubeFile)
-	if rawImage == nil {
-		return nil, fmt.Errorf("failed to parse kubefile, image is nil")
+	rawImage, err := parser.NewParse().Parse(kubeFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubefile %s: %v", kubefile, err)
 	}
 
 	layer0 := rawImage.Spec.Layers[0]
@@ -125,7 +125,13 @@ func getClusterFileFromContext(layers []v1.Layer) (string, error) {
 	return "", fmt.Errorf("failed to get ClusterFile from Context")
 }
 
+// generateImageID digests the image without the build times of its layers, so that rebuilding
+// the same image from cache gives the same id.
 func generateImageID(image v1.Image) (string, error) {
+	image.Spec.Layers = append([]v1.Layer{}, image.Spec.Layers...)
+	for i := range image.Spec.Layers {
+		image.Spec.Layers[i].Created = nil
+	}
 	imageBytes, err := yaml.Marshal(image)
 	if err != nil {
 		return "", err
@@ -37,6 +37,8 @@ type ExecContext struct {
 	Prober   image.Prober
 	//used to gen layer
 	LayerStore store.LayerStore
+	// StageContexts is the rootfs of stage or image referred by "COPY --from", keyed by the reference.
+	StageContexts map[string]string
 }
 
 type InstructionContext struct {
@@ -29,6 +29,8 @@ import (
 )
 
 type CopyInstruction struct {
+	// from is the stage or image to copy from, src is relative to its rootfs instead of build context.
+	from      string
 	src       string
 	dest      string
 	rawLayer  v1.Layer
@@ -48,8 +50,16 @@ func (c CopyInstruction) Exec(execContext ExecContext) (out Out, err error) {
 		out.ParentID = chainID
 	}()
 
+	buildContext := execContext.BuildContext
+	if c.from != "" {
+		var ok bool
+		if buildContext, ok = execContext.StageContexts[c.from]; !ok {
+			return out, fmt.Errorf("stage or image %s to copy from is not prepared", c.from)
+		}
+	}
+
 	if !isRemoteSource(c.src) {
-		cacheID, err = GenerateSourceFilesDigest(execContext.BuildContext, c.src)
+		cacheID, err = GenerateSourceFilesDigest(buildContext, c.src)
 		if err != nil {
 			logger.Warn("failed to generate src digest,discard cache,%s", err)
 		}
@@ -70,7 +80,7 @@ func (c CopyInstruction) Exec(execContext ExecContext) (out Out, err error) {
 		return out, fmt.Errorf("failed to create tmp dir %s:%v", tmp, err)
 	}
 
-	err = c.collector.Collect(execContext.BuildContext, c.src, filepath.Join(tmp, c.dest))
+	err = c.collector.Collect(buildContext, c.src, filepath.Join(tmp, c.dest))
 	if err != nil {
 		return out, fmt.Errorf("failed to collect files to temp dir %s, err: %v", tmp, err)
 	}
@@ -107,6 +117,7 @@ func NewCopyInstruction(ctx InstructionContext) (*CopyInstruction, error) {
 	return &CopyInstruction{
 		fs:        fs,
 		rawLayer:  *ctx.CurrentLayer,
+		from:      ParseCopyFrom(ctx.CurrentLayer.Value),
 		src:       src,
 		dest:      dest,
 		collector: c,
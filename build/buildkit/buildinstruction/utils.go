@@ -27,6 +27,7 @@ import (
 
 	"github.com/alibaba/sealer/pkg/image"
 	"github.com/alibaba/sealer/pkg/image/cache"
+	"github.com/alibaba/sealer/pkg/parser"
 	"github.com/alibaba/sealer/utils"
 
 	"github.com/opencontainers/go-digest"
@@ -121,15 +122,38 @@ func GetBaseLayersPath(layers []v1.Layer) (res []string) {
 }
 
 func ParseCopyLayerContent(layerValue string) (src, dst string) {
-	dst = strings.Fields(layerValue)[1]
+	fields := copyArgs(layerValue)
+	dst = fields[1]
 	for _, p := range []string{"./", "/"} {
 		dst = strings.TrimPrefix(dst, p)
 	}
 	dst = strings.TrimSuffix(dst, "/")
-	src = strings.Fields(layerValue)[0]
+	src = fields[0]
 	return
 }
 
+// ParseCopyFrom return the stage or image of "COPY --from=stage src dst", it is empty if no "--from" flag.
+func ParseCopyFrom(layerValue string) string {
+	for _, field := range strings.Fields(layerValue) {
+		if !strings.HasPrefix(field, "--") {
+			break
+		}
+		if strings.HasPrefix(field, parser.CopyFromFlag) {
+			return strings.ToLower(strings.TrimPrefix(field, parser.CopyFromFlag))
+		}
+	}
+	return ""
+}
+
+// copyArgs return the source and destination of COPY without flags.
+func copyArgs(layerValue string) []string {
+	fields := strings.Fields(layerValue)
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+		fields = fields[1:]
+	}
+	return fields
+}
+
 func isRemoteSource(src string) bool {
 	if collector.IsURL(src) || collector.IsGitURL(src) {
 		return true
@@ -30,6 +30,7 @@ import (
 
 	"github.com/alibaba/sealer/build/buildkit/buildimage"
 	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/parser"
 
 	"github.com/alibaba/sealer/build/buildkit"
 
@@ -144,7 +145,9 @@ func (c *cloudBuilder) InitClusterFile() error {
 		return nil
 	}
 
-	rawClusterFile, err := buildimage.GetRawClusterFile(c.image.Spec.Layers[0].Value, c.image.Spec.Layers)
+	stages := parser.SplitStages(c.image.Spec.Layers)
+	imageStage := stages[len(stages)-1]
+	rawClusterFile, err := buildimage.GetRawClusterFile(imageStage.Image, imageStage.Layers)
 	if err != nil {
 		return fmt.Errorf("failed to get base image err: %s", err)
 	}
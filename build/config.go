@@ -14,10 +14,14 @@
 
 package build
 
+import "context"
+
 type Config struct {
 	BuildType string
 	NoCache   bool
 	NoBase    bool
 	ImageName string
 	BuildArgs map[string]string
+	// Context cancels the lite and local build before the next layer, nil never cancels.
+	Context context.Context
 }
@@ -15,12 +15,16 @@
 package build
 
 import (
+	"context"
+
 	"github.com/alibaba/sealer/build/buildkit"
 	"github.com/alibaba/sealer/build/buildkit/buildimage"
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
 	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/parser"
 	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
 )
 
 type liteBuilder struct {
@@ -31,8 +35,10 @@ type liteBuilder struct {
 	context      string
 	kubeFileName string
 	buildArgs    map[string]string
+	cancelCtx    context.Context
 	baseLayers   []v1.Layer
 	rawImage     *v1.Image
+	stages       []parser.Stage
 	executor     buildimage.Executor
 	saver        buildimage.ImageSaver
 }
@@ -56,11 +62,11 @@ func (l liteBuilder) Build(name string, context string, kubefileName string) err
 	}
 	l.context = absContext
 
-	rawImage, baseLayers, err := buildimage.NewBuildImageByKubefile(absKubeFile)
+	rawImage, baseLayers, stages, err := buildimage.NewBuildImageByKubefile(absKubeFile)
 	if err != nil {
 		return err
 	}
-	l.rawImage, l.baseLayers = rawImage, baseLayers
+	l.rawImage, l.baseLayers, l.stages = rawImage, baseLayers, stages
 
 	executor, err := buildimage.NewLayerExecutor(baseLayers, l.buildType)
 	if err != nil {
@@ -106,7 +112,9 @@ func (l liteBuilder) ExecBuild() error {
 	ctx := buildimage.Context{
 		BuildContext: l.context,
 		UseCache:     !l.noCache,
-		BuildArgs:    l.rawImage.Spec.ImageConfig.Args.Current,
+		BuildArgs:    utils.MergeMap(l.rawImage.Spec.ImageConfig.Env, l.rawImage.Spec.ImageConfig.Args.Current),
+		Stages:       l.stages,
+		Cancel:       l.cancelCtx,
 	}
 
 	layers, err := l.executor.Execute(ctx, l.rawImage.Spec.Layers[1:])
@@ -145,5 +153,6 @@ func NewLiteBuilder(config *Config) (Interface, error) {
 		noCache:   config.NoCache,
 		noBase:    config.NoBase,
 		buildArgs: config.BuildArgs,
+		cancelCtx: config.Context,
 	}, nil
 }
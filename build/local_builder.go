@@ -15,6 +15,7 @@
 package build
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	"github.com/alibaba/sealer/logger"
 	"github.com/alibaba/sealer/pkg/client/k8s"
 	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/parser"
 	"github.com/alibaba/sealer/utils"
 )
 
@@ -38,8 +40,10 @@ type localBuilder struct {
 	context      string
 	kubeFileName string
 	buildArgs    map[string]string
+	cancelCtx    context.Context
 	baseLayers   []v1.Layer
 	rawImage     *v1.Image
+	stages       []parser.Stage
 	executor     buildimage.Executor
 	saver        buildimage.ImageSaver
 }
@@ -63,11 +67,11 @@ func (l localBuilder) Build(name string, context string, kubefileName string) er
 	l.imageNamed = named
 	l.context = absContext
 	l.kubeFileName = absKubeFile
-	rawImage, baseLayers, err := buildimage.NewBuildImageByKubefile(absKubeFile)
+	rawImage, baseLayers, stages, err := buildimage.NewBuildImageByKubefile(absKubeFile)
 	if err != nil {
 		return err
 	}
-	l.rawImage, l.baseLayers = rawImage, baseLayers
+	l.rawImage, l.baseLayers, l.stages = rawImage, baseLayers, stages
 
 	executor, err := buildimage.NewLayerExecutor(baseLayers, l.buildType)
 	if err != nil {
@@ -113,7 +117,9 @@ func (l localBuilder) ExecBuild() error {
 	ctx := buildimage.Context{
 		BuildContext: l.context,
 		UseCache:     !l.noCache,
-		BuildArgs:    l.rawImage.Spec.ImageConfig.Args.Current,
+		BuildArgs:    utils.MergeMap(l.rawImage.Spec.ImageConfig.Env, l.rawImage.Spec.ImageConfig.Args.Current),
+		Stages:       l.stages,
+		Cancel:       l.cancelCtx,
 	}
 
 	layers, err := l.executor.Execute(ctx, l.rawImage.Spec.Layers)
@@ -196,5 +202,6 @@ func NewLocalBuilder(config *Config) (Interface, error) {
 		noCache:   config.NoCache,
 		noBase:    config.NoBase,
 		buildArgs: config.BuildArgs,
+		cancelCtx: config.Context,
 	}, nil
 }
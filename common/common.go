@@ -39,9 +39,7 @@ const (
 const (
 	DefaultWorkDir                = "/tmp/%s/workdir"
 	EtcDir                        = "etc"
-	DefaultTmpDir                 = "/var/lib/sealer/tmp"
 	DefaultLiteBuildUpper         = "/var/lib/sealer/tmp/lite_build_upper"
-	DefaultLogDir                 = "/var/lib/sealer/log"
 	DefaultClusterFileName        = "Clusterfile"
 	DefaultClusterRootfsDir       = "/var/lib/sealer/data"
 	DefaultClusterInitBashFile    = "/var/lib/sealer/data/%s/scripts/init.sh"
@@ -62,6 +60,7 @@ const (
 	DefaultCloudProvider          = AliCloud
 	ClusterfileName               = "ClusterfileName"
 	CacheID                       = "cacheID"
+	LayerCreated                  = "created"
 	RenderChartsDir               = "charts"
 	RenderManifestsDir            = "manifests"
 	APIVersion                    = "sealer.cloud/v2"
@@ -71,17 +70,16 @@ const (
 
 // image module
 const (
-	DefaultImageRootDir          = "/var/lib/sealer/data"
 	DefaultMetadataName          = "Metadata"
 	DefaultImageMetadataFileName = "image_metadata.yaml"
+	DefaultSignaturesFileName    = "image_signatures.json"
 	ImageScratch                 = "scratch"
-	DefaultImageMetaRootDir      = "/var/lib/sealer/metadata"
-	DefaultImageDBRootDir        = "/var/lib/sealer/metadata/imagedb"
-	DefaultImageMetadataFile     = "/var/lib/sealer/metadata/images_metadata.json"
-	DefaultLayerDir              = "/var/lib/sealer/data/overlay2"
-	DefaultLayerDBRoot           = "/var/lib/sealer/metadata/layerdb"
 )
 
+// DefaultTrustPolicyFile is the trust policy images are verified by on pull and apply,
+// the programs embedding sealer could point it to their own.
+var DefaultTrustPolicyFile = "/etc/sealer/policy.json"
+
 //about infra
 const (
 	AliDomain         = "sea.aliyun.com/"
@@ -146,6 +144,9 @@ const (
 )
 
 func GetClusterWorkDir(clusterName string) string {
+	if clusterWorkRoot != "" {
+		return filepath.Join(clusterWorkRoot, clusterName)
+	}
 	return filepath.Join(GetHomeDir(), ".sealer", clusterName)
 }
 
@@ -0,0 +1,54 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import "path/filepath"
+
+// DefaultDataRoot is the local dir of sealer data, the cluster work dirs are under $HOME/.sealer.
+const DefaultDataRoot = "/var/lib/sealer"
+
+// the local dirs moved by SetDataRoot, the rootfs dirs of clusters are kept as they are the same on hosts.
+var (
+	DefaultTmpDir            = "/var/lib/sealer/tmp"
+	DefaultLogDir            = "/var/lib/sealer/log"
+	DefaultImageRootDir      = "/var/lib/sealer/data"
+	DefaultImageMetaRootDir  = "/var/lib/sealer/metadata"
+	DefaultImageDBRootDir    = "/var/lib/sealer/metadata/imagedb"
+	DefaultImageMetadataFile = "/var/lib/sealer/metadata/images_metadata.json"
+	DefaultManifestListFile  = "/var/lib/sealer/metadata/manifest_lists.json"
+	DefaultSignatureDir      = "/var/lib/sealer/metadata/signatures"
+	DefaultLayerDir          = "/var/lib/sealer/data/overlay2"
+	DefaultLayerTmpDir       = "/var/lib/sealer/data/tmp"
+	DefaultLayerDBRoot       = "/var/lib/sealer/metadata/layerdb"
+
+	clusterWorkRoot string
+)
+
+// SetDataRoot moves the image store, temp files, logs and cluster work dirs to root, for the programs embedding
+// sealer which do not own /var/lib/sealer. It should be called before anything is stored.
+func SetDataRoot(root string) {
+	DefaultTmpDir = filepath.Join(root, "tmp")
+	DefaultLogDir = filepath.Join(root, "log")
+	DefaultImageRootDir = filepath.Join(root, "data")
+	DefaultImageMetaRootDir = filepath.Join(root, "metadata")
+	DefaultImageDBRootDir = filepath.Join(DefaultImageMetaRootDir, "imagedb")
+	DefaultImageMetadataFile = filepath.Join(DefaultImageMetaRootDir, "images_metadata.json")
+	DefaultManifestListFile = filepath.Join(DefaultImageMetaRootDir, "manifest_lists.json")
+	DefaultSignatureDir = filepath.Join(DefaultImageMetaRootDir, "signatures")
+	DefaultLayerDir = filepath.Join(DefaultImageRootDir, "overlay2")
+	DefaultLayerTmpDir = filepath.Join(DefaultImageRootDir, "tmp")
+	DefaultLayerDBRoot = filepath.Join(DefaultImageMetaRootDir, "layerdb")
+	clusterWorkRoot = filepath.Join(root, "clusters")
+}
@@ -40,10 +40,12 @@ enSideBar = {
           'advanced/raw-docker-baseimage',
           'advanced/registry-configuration',
           'advanced/save-charts-package',
+          'advanced/sign-cloudimage',
           'advanced/takeover-existed-cluster',
           'advanced/use-clusterfile',
           'advanced/use-kyverno-baseimage',
           'advanced/use-sealer-in-container',
+          'advanced/use-sealer-as-library',
         ]
       },
       {
@@ -118,6 +120,7 @@ zhSideBar = {
           'advanced/raw-docker-baseimage',
           'advanced/registry-configuration',
           'advanced/save-charts-package',
+          'advanced/sign-cloudimage',
           'advanced/use-kyverno-baseimage',
         ]
       },
@@ -0,0 +1,54 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// AdapterWriter is the output set by SetWriter.
+const AdapterWriter = "writer"
+
+// Writer receives the logs of sealer embedded as a library, level is one of EMER, ALRT, CRIT, EROR, WARN, INFO,
+// DEBG and TRAC, msg has the time, level and source prefixed like the console.
+type Writer func(when time.Time, level string, msg string)
+
+type writerLogger struct {
+	write Writer
+}
+
+func (w *writerLogger) Init(string) error {
+	return nil
+}
+
+func (w *writerLogger) LogWrite(when time.Time, msg interface{}, level logLevel) error {
+	w.write(when, levelPrefix[level], fmt.Sprint(msg))
+	return nil
+}
+
+func (w *writerLogger) Destroy() {
+}
+
+// SetWriter replaces all the outputs of logs, like the console and log files, by write.
+func SetWriter(write Writer) {
+	defaultLogger.lock.Lock()
+	defer defaultLogger.lock.Unlock()
+	for _, output := range defaultLogger.outputs {
+		output.Destroy()
+	}
+	defaultLogger.outputs = []*nameLogger{{Logger: &writerLogger{write: write}, name: AdapterWriter}}
+	defaultLogger.init = true
+}
@@ -0,0 +1,202 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/plugin"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	SnapshotSuffix = ".db"
+	MetadataSuffix = ".yaml"
+	nameTimeFormat = "20060102150405"
+)
+
+// Metadata is recorded next to each snapshot file as <name>.yaml.
+type Metadata struct {
+	Name         string      `json:"name"`
+	ClusterName  string      `json:"clusterName"`
+	Revision     int64       `json:"revision"`
+	Size         int64       `json:"size"`
+	KubeVersion  string      `json:"kubeVersion,omitempty"`
+	Image        string      `json:"image,omitempty"`
+	CreationTime metav1.Time `json:"creationTime"`
+}
+
+func (m *Metadata) SnapshotFile() string {
+	return m.Name + SnapshotSuffix
+}
+
+// Create take an etcd snapshot of cluster and put it with its metadata to storage.
+func Create(cluster *v2.Cluster, storage Storage) (*Metadata, error) {
+	tmpDir, err := ioutil.TempDir("", "sealer-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			logger.Warn("failed to remove %s: %v", tmpDir, err)
+		}
+	}()
+
+	now := time.Now()
+	md := &Metadata{
+		Name:         fmt.Sprintf("%s-%s", cluster.Name, now.Format(nameTimeFormat)),
+		ClusterName:  cluster.Name,
+		KubeVersion:  cluster.Status.KubeVersion,
+		Image:        cluster.Spec.Image,
+		CreationTime: metav1.NewTime(now),
+	}
+	snapshotPath := filepath.Join(tmpDir, md.SnapshotFile())
+	if md.Revision, err = plugin.SnapshotEtcd(cluster, snapshotPath); err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	md.Size = fi.Size()
+
+	metadataPath := filepath.Join(tmpDir, md.Name+MetadataSuffix)
+	if err = utils.MarshalYamlToFile(metadataPath, md); err != nil {
+		return nil, err
+	}
+	if err = storage.Put(snapshotPath, md.SnapshotFile()); err != nil {
+		return nil, fmt.Errorf("failed to put snapshot %s: %v", md.SnapshotFile(), err)
+	}
+	// metadata is put at last, so a backup is listed only if its snapshot is complete.
+	if err = storage.Put(metadataPath, md.Name+MetadataSuffix); err != nil {
+		return nil, fmt.Errorf("failed to put metadata of %s: %v", md.Name, err)
+	}
+	logger.Info("etcd backup %s is created, revision: %d", md.Name, md.Revision)
+	return md, nil
+}
+
+// List return the metadata of all the backups in storage, the newest first.
+func List(storage Storage) ([]*Metadata, error) {
+	names, err := storage.List()
+	if err != nil {
+		return nil, err
+	}
+	tmpDir, err := ioutil.TempDir("", "sealer-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			logger.Warn("failed to remove %s: %v", tmpDir, err)
+		}
+	}()
+
+	var backups []*Metadata
+	for _, name := range names {
+		if !strings.HasSuffix(name, MetadataSuffix) {
+			continue
+		}
+		localPath := filepath.Join(tmpDir, name)
+		if err = storage.Get(name, localPath); err != nil {
+			return nil, fmt.Errorf("failed to get backup metadata %s: %v", name, err)
+		}
+		md := &Metadata{}
+		if err = utils.UnmarshalYamlFile(localPath, md); err != nil {
+			return nil, fmt.Errorf("failed to decode backup metadata %s: %v", name, err)
+		}
+		backups = append(backups, md)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreationTime.After(backups[j].CreationTime.Time)
+	})
+	return backups, nil
+}
+
+// Prune delete the backups except the newest retain ones, and return the deleted backups.
+// Like Schedule, retain 0 means keep all, use PruneAll to delete all the backups.
+func Prune(storage Storage, retain int) ([]*Metadata, error) {
+	if retain < 0 {
+		return nil, fmt.Errorf("retain count must not be negative")
+	}
+	if retain == 0 {
+		return nil, nil
+	}
+	return prune(storage, retain)
+}
+
+// PruneAll delete all the backups, and return the deleted backups.
+func PruneAll(storage Storage) ([]*Metadata, error) {
+	return prune(storage, 0)
+}
+
+func prune(storage Storage, retain int) ([]*Metadata, error) {
+	backups, err := List(storage)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) <= retain {
+		return nil, nil
+	}
+
+	pruned := backups[retain:]
+	for _, md := range pruned {
+		// delete metadata first, an orphan snapshot is better than a backup which can not be restored.
+		if err = storage.Delete(md.Name + MetadataSuffix); err != nil {
+			return nil, fmt.Errorf("failed to delete metadata of %s: %v", md.Name, err)
+		}
+		if err = storage.Delete(md.SnapshotFile()); err != nil {
+			return nil, fmt.Errorf("failed to delete snapshot %s: %v", md.SnapshotFile(), err)
+		}
+		logger.Info("etcd backup %s is pruned", md.Name)
+	}
+	return pruned, nil
+}
+
+// Schedule create a backup every interval and keep the newest retain ones, retain 0 means keep all.
+// A failed round is logged and the next round goes on, it returns only when stop is closed.
+func Schedule(cluster *v2.Cluster, storage Storage, interval time.Duration, retain int, stop <-chan struct{}) error {
+	if interval <= 0 {
+		return fmt.Errorf("backup interval must be positive")
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := Create(cluster, storage); err != nil {
+			logger.Error("failed to create etcd backup: %v", err)
+		} else if retain > 0 {
+			if _, err = Prune(storage, retain); err != nil {
+				logger.Error("failed to prune etcd backups: %v", err)
+			}
+		}
+		logger.Info("next etcd backup will be created at %s", time.Now().Add(interval).Format(time.RFC3339))
+
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+func putFakeBackup(t *testing.T, storage Storage, md *Metadata) {
+	tmpDir, err := ioutil.TempDir("", "sealer-backup-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapshotPath := filepath.Join(tmpDir, md.SnapshotFile())
+	if err = ioutil.WriteFile(snapshotPath, []byte("snapshot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	metadataPath := filepath.Join(tmpDir, md.Name+MetadataSuffix)
+	if err = utils.MarshalYamlToFile(metadataPath, md); err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.Put(snapshotPath, md.SnapshotFile()); err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.Put(metadataPath, md.Name+MetadataSuffix); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		backups    int
+		retain     int
+		wantPruned []string
+		wantKept   []string
+	}{
+		{"keep all", 2, 3, nil, []string{"my-cluster-1", "my-cluster-0"}},
+		{"prune the oldest", 3, 2, []string{"my-cluster-0"}, []string{"my-cluster-2", "my-cluster-1"}},
+		{"retain 0 keeps all", 2, 0, nil, []string{"my-cluster-1", "my-cluster-0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sealer-backup-storage")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			storage := NewLocalStorage(dir)
+			for i := 0; i < tt.backups; i++ {
+				putFakeBackup(t, storage, &Metadata{
+					Name:         "my-cluster-" + string(rune('0'+i)),
+					ClusterName:  "my-cluster",
+					Revision:     int64(i),
+					CreationTime: metav1.NewTime(now.Add(time.Duration(i) * time.Minute)),
+				})
+			}
+
+			pruned, err := Prune(storage, tt.retain)
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+			if got := backupNames(pruned); !reflect.DeepEqual(got, tt.wantPruned) {
+				t.Errorf("Prune() pruned = %v, want %v", got, tt.wantPruned)
+			}
+			kept, err := List(storage)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if got := backupNames(kept); !reflect.DeepEqual(got, tt.wantKept) {
+				t.Errorf("List() = %v, want %v", got, tt.wantKept)
+			}
+			files, err := storage.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 2*len(tt.wantKept) {
+				t.Errorf("files left in storage = %v, want snapshots and metadata of %v", files, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestPruneAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sealer-backup-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	storage := NewLocalStorage(dir)
+	for i := 0; i < 2; i++ {
+		putFakeBackup(t, storage, &Metadata{
+			Name:         "my-cluster-" + string(rune('0'+i)),
+			ClusterName:  "my-cluster",
+			Revision:     int64(i),
+			CreationTime: metav1.NewTime(time.Now().Add(time.Duration(i) * time.Minute)),
+		})
+	}
+
+	pruned, err := PruneAll(storage)
+	if err != nil {
+		t.Fatalf("PruneAll() error = %v", err)
+	}
+	if got, want := backupNames(pruned), []string{"my-cluster-1", "my-cluster-0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PruneAll() pruned = %v, want %v", got, want)
+	}
+	if files, err := storage.List(); err != nil || len(files) != 0 {
+		t.Errorf("files left in storage = %v, %v, want none", files, err)
+	}
+}
+
+func backupNames(backups []*Metadata) []string {
+	var names []string
+	for _, md := range backups {
+		names = append(names, md.Name)
+	}
+	return names
+}
+
+func TestNewStorage(t *testing.T) {
+	cluster := &v2.Cluster{}
+	cluster.Name = "my-cluster"
+	tests := []struct {
+		name    string
+		target  string
+		want    Storage
+		wantErr bool
+	}{
+		{"default", "", &LocalStorage{Dir: DefaultBackupDir("my-cluster")}, false},
+		{"local path", "/data/backup", &LocalStorage{Dir: "/data/backup"}, false},
+		{"local url", "file:///data/backup", &LocalStorage{Dir: "/data/backup"}, false},
+		{"s3", "s3://etcd/my-cluster?endpoint=127.0.0.1:9000&region=cn-hangzhou&insecure=true",
+			&S3Storage{Endpoint: "127.0.0.1:9000", Region: "cn-hangzhou", Bucket: "etcd", Prefix: "my-cluster", Insecure: true}, false},
+		{"s3 without endpoint", "s3://etcd/my-cluster", nil, true},
+		{"remote without dir", "ssh://192.168.0.2", nil, true},
+		{"unknown scheme", "ftp://192.168.0.2/data", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewStorage(tt.target, cluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewStorage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if s3, ok := got.(*S3Storage); ok {
+				s3.AccessKey, s3.SecretKey = "", ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewStorage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
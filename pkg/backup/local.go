@@ -0,0 +1,67 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/utils"
+)
+
+// LocalStorage keep backups in a dir on local.
+type LocalStorage struct {
+	Dir string
+}
+
+func NewLocalStorage(dir string) Storage {
+	return &LocalStorage{Dir: dir}
+}
+
+func (l *LocalStorage) Put(localPath, name string) error {
+	if err := os.MkdirAll(l.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup dir %s: %v", l.Dir, err)
+	}
+	_, err := utils.CopySingleFile(localPath, filepath.Join(l.Dir, name))
+	return err
+}
+
+func (l *LocalStorage) Get(name, localPath string) error {
+	_, err := utils.CopySingleFile(filepath.Join(l.Dir, name), localPath)
+	return err
+}
+
+func (l *LocalStorage) List() ([]string, error) {
+	if !utils.IsExist(l.Dir) {
+		return nil, nil
+	}
+	files, err := ioutil.ReadDir(l.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup dir %s: %v", l.Dir, err)
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
+
+func (l *LocalStorage) Delete(name string) error {
+	return os.Remove(filepath.Join(l.Dir, name))
+}
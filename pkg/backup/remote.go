@@ -0,0 +1,76 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// RemoteStorage keep backups in a dir on remote host, files are transferred by ssh.
+type RemoteStorage struct {
+	Host string
+	Dir  string
+	ssh  ssh.Interface
+}
+
+// NewRemoteStorage use the ssh config of host if it is in cluster, otherwise the global ssh config of cluster.
+func NewRemoteStorage(cluster *v2.Cluster, host, dir string) (Storage, error) {
+	if !utils.InList(host, append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)) {
+		logger.Debug("backup host %s is not in cluster %s, use the ssh config of cluster", host, cluster.Name)
+		sshConfig := cluster.Spec.SSH
+		return &RemoteStorage{Host: host, Dir: dir, ssh: ssh.NewSSHClient(&sshConfig, false)}, nil
+	}
+	client, err := ssh.GetHostSSHClient(host, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ssh client of backup host %s: %v", host, err)
+	}
+	return &RemoteStorage{Host: host, Dir: dir, ssh: client}, nil
+}
+
+func (r *RemoteStorage) Put(localPath, name string) error {
+	if err := r.ssh.CmdAsync(r.Host, fmt.Sprintf("mkdir -p %s", r.Dir)); err != nil {
+		return fmt.Errorf("failed to create backup dir %s on %s: %v", r.Dir, r.Host, err)
+	}
+	return r.ssh.Copy(r.Host, localPath, filepath.Join(r.Dir, name))
+}
+
+func (r *RemoteStorage) Get(name, localPath string) error {
+	return r.ssh.Fetch(r.Host, localPath, filepath.Join(r.Dir, name))
+}
+
+func (r *RemoteStorage) List() ([]string, error) {
+	out, err := r.ssh.CmdToString(r.Host, fmt.Sprintf("if [ -d %s ];then ls -1 %s;fi", r.Dir, r.Dir), ",")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup dir %s on %s: %v", r.Dir, r.Host, err)
+	}
+	var names []string
+	for _, name := range strings.Split(out, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (r *RemoteStorage) Delete(name string) error {
+	return r.ssh.CmdAsync(r.Host, fmt.Sprintf("rm -f %s", filepath.Join(r.Dir, name)))
+}
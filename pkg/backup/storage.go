@@ -0,0 +1,90 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alibaba/sealer/common"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+// Storage is where the etcd snapshots and their metadata are kept.
+type Storage interface {
+	// Put upload the local file to storage as name.
+	Put(localPath, name string) error
+	// Get download the file named name from storage to localPath.
+	Get(name, localPath string) error
+	// List return the names of all the files in storage.
+	List() ([]string, error)
+	Delete(name string) error
+}
+
+const (
+	LocalStorageScheme  = "file"
+	RemoteStorageScheme = "ssh"
+	S3StorageScheme     = "s3"
+)
+
+// DefaultBackupDir return the local dir to save backups if no target is specified: ~/.sealer/my-cluster/backup
+func DefaultBackupDir(clusterName string) string {
+	return filepath.Join(common.GetClusterWorkDir(clusterName), "backup")
+}
+
+// NewStorage create the storage by target:
+//
+//	/data/backup or file:///data/backup: the dir on local.
+//	ssh://192.168.0.2/data/backup: the dir on remote host, which is logged in by the ssh config of cluster.
+//	s3://bucket/prefix?endpoint=oss-cn-hangzhou.aliyuncs.com&region=cn-hangzhou: S3-compatible endpoint, the access key
+//	is read from env AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, set insecure=true to use http.
+func NewStorage(target string, cluster *v2.Cluster) (Storage, error) {
+	if target == "" {
+		return NewLocalStorage(DefaultBackupDir(cluster.Name)), nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse backup target %s: %v", target, err)
+	}
+
+	switch u.Scheme {
+	case "", LocalStorageScheme:
+		return NewLocalStorage(u.Path), nil
+	case RemoteStorageScheme:
+		if u.Hostname() == "" || u.Path == "" {
+			return nil, fmt.Errorf("remote backup target must be like ssh://192.168.0.2/data/backup")
+		}
+		return NewRemoteStorage(cluster, u.Hostname(), u.Path)
+	case S3StorageScheme:
+		q := u.Query()
+		if q.Get("endpoint") == "" {
+			return nil, fmt.Errorf("endpoint of s3 backup target must be set")
+		}
+		return &S3Storage{
+			Endpoint:  q.Get("endpoint"),
+			Region:    q.Get("region"),
+			Bucket:    u.Host,
+			Prefix:    strings.Trim(u.Path, "/"),
+			Insecure:  q.Get("insecure") == "true",
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("backup target scheme %s is not supported", u.Scheme)
+	}
+}
@@ -21,8 +21,9 @@ import (
 )
 
 const (
-	PhasePre  = "Pre"
-	PhasePost = "Post"
+	PhasePre        = "Pre"
+	PhasePost       = "Post"
+	PhasePreUpgrade = "PreUpgrade"
 )
 
 // Interface Define checkers when pre or post install, like checker node status, checker pod status...
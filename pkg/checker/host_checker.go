@@ -15,8 +15,10 @@
 package checker
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	v2 "github.com/alibaba/sealer/types/api/v2"
@@ -24,6 +26,8 @@ import (
 	"github.com/alibaba/sealer/utils/ssh"
 )
 
+const hostCmdTimeout = 30 * time.Second
+
 type HostChecker struct {
 }
 
@@ -50,7 +54,7 @@ func checkHostnameUnique(cluster *v2.Cluster, ipList []string) error {
 		if err != nil {
 			return fmt.Errorf("checker: failed to get host %s client,%v", ip, err)
 		}
-		hostname, err := s.CmdToString(ip, "hostname", "")
+		hostname, err := cmdWithTimeout(s, ip, "hostname")
 		if err != nil {
 			return fmt.Errorf("checker: failed to get host %s hostname, %v", ip, err)
 		}
@@ -69,7 +73,7 @@ func checkTimeSync(cluster *v2.Cluster, ipList []string) error {
 		if err != nil {
 			return fmt.Errorf("checker: failed to get host %s client,%v", ip, err)
 		}
-		timeStamp, err := s.CmdToString(ip, "date +%s", "")
+		timeStamp, err := cmdWithTimeout(s, ip, "date +%s")
 		if err != nil {
 			return fmt.Errorf("checker: failed to get %s timestamp, %v", ip, err)
 		}
@@ -84,3 +88,14 @@ func checkTimeSync(cluster *v2.Cluster, ipList []string) error {
 	}
 	return nil
 }
+
+// cmdWithTimeout runs the quick cmd on host, and fails if the host does not respond in hostCmdTimeout.
+func cmdWithTimeout(s ssh.Interface, ip, cmd string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hostCmdTimeout)
+	defer cancel()
+	out, err := s.CmdContext(ctx, ip, cmd)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
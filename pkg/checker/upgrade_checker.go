@@ -0,0 +1,417 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/client/k8s"
+	"github.com/alibaba/sealer/pkg/plugin"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+type CheckResult string
+
+const (
+	CheckPass CheckResult = "PASS"
+	CheckWarn CheckResult = "WARN"
+	CheckFail CheckResult = "FAIL"
+)
+
+const (
+	// the default backend quota of etcd is 2GiB.
+	etcdQuotaBytes          = 2 * 1024 * 1024 * 1024
+	lastAppliedConfigAnnKey = "kubectl.kubernetes.io/last-applied-configuration"
+	diskAvailableCmd        = "df -Pk %s | tail -1 | awk '{print $4}'"
+)
+
+type CheckItem struct {
+	Check   string
+	Target  string
+	Result  CheckResult
+	Message string
+}
+
+// Report is the pass/warn/fail result of every check.
+type Report struct {
+	Items []CheckItem
+}
+
+func (r *Report) add(check, target string, result CheckResult, format string, args ...interface{}) {
+	r.Items = append(r.Items, CheckItem{Check: check, Target: target, Result: result, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) count(result CheckResult) int {
+	var n int
+	for _, item := range r.Items {
+		if item.Result == result {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) Output() error {
+	t := template.New("upgrade_checker")
+	t, err := t.Parse(
+		`Upgrade Pre-flight Check
+  {{- range .Items }}
+  [{{ .Result }}] {{ .Check }} {{ .Target }}: {{ .Message }}
+  {{- end }}
+`)
+	if err != nil {
+		panic(err)
+	}
+	t = template.Must(t, err)
+	err = t.Execute(common.StdOut, r)
+	if err != nil {
+		logger.Error("upgrade checkers template can not excute %s", err)
+		return err
+	}
+	return nil
+}
+
+// removedAPI is an api removed in a kubernetes version, and the one to migrate to.
+type removedAPI struct {
+	gvr         schema.GroupVersionResource
+	removedIn   string
+	replacement string
+}
+
+var removedAPIs = []removedAPI{
+	{schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "deployments"}, "v1.16.0", "apps/v1"},
+	{schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "daemonsets"}, "v1.16.0", "apps/v1"},
+	{schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "replicasets"}, "v1.16.0", "apps/v1"},
+	{schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "networkpolicies"}, "v1.16.0", "networking.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta1", Resource: "deployments"}, "v1.16.0", "apps/v1"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta1", Resource: "statefulsets"}, "v1.16.0", "apps/v1"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta2", Resource: "deployments"}, "v1.16.0", "apps/v1"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta2", Resource: "daemonsets"}, "v1.16.0", "apps/v1"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta2", Resource: "statefulsets"}, "v1.16.0", "apps/v1"},
+	{schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "ingresses"}, "v1.22.0", "networking.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1beta1", Resource: "ingresses"}, "v1.22.0", "networking.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1beta1", Resource: "customresourcedefinitions"}, "v1.22.0", "apiextensions.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "apiregistration.k8s.io", Version: "v1beta1", Resource: "apiservices"}, "v1.22.0", "apiregistration.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "mutatingwebhookconfigurations"}, "v1.22.0", "admissionregistration.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "validatingwebhookconfigurations"}, "v1.22.0", "admissionregistration.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "clusterroles"}, "v1.22.0", "rbac.authorization.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "clusterrolebindings"}, "v1.22.0", "rbac.authorization.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "roles"}, "v1.22.0", "rbac.authorization.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "rolebindings"}, "v1.22.0", "rbac.authorization.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "scheduling.k8s.io", Version: "v1beta1", Resource: "priorityclasses"}, "v1.22.0", "scheduling.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1beta1", Resource: "csidrivers"}, "v1.22.0", "storage.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1beta1", Resource: "storageclasses"}, "v1.22.0", "storage.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1beta1", Resource: "volumeattachments"}, "v1.22.0", "storage.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}, "v1.25.0", "batch/v1"},
+	{schema.GroupVersionResource{Group: "policy", Version: "v1beta1", Resource: "poddisruptionbudgets"}, "v1.25.0", "policy/v1"},
+	{schema.GroupVersionResource{Group: "policy", Version: "v1beta1", Resource: "podsecuritypolicies"}, "v1.25.0", "Pod Security Admission"},
+	{schema.GroupVersionResource{Group: "autoscaling", Version: "v2beta1", Resource: "horizontalpodautoscalers"}, "v1.25.0", "autoscaling/v2"},
+	{schema.GroupVersionResource{Group: "discovery.k8s.io", Version: "v1beta1", Resource: "endpointslices"}, "v1.25.0", "discovery.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "node.k8s.io", Version: "v1beta1", Resource: "runtimeclasses"}, "v1.25.0", "node.k8s.io/v1"},
+	{schema.GroupVersionResource{Group: "autoscaling", Version: "v2beta2", Resource: "horizontalpodautoscalers"}, "v1.26.0", "autoscaling/v2"},
+}
+
+// UpgradeChecker checks whether the cluster is ready to be upgraded to TargetVersion, before anything is copied.
+type UpgradeChecker struct {
+	// TargetVersion is the kubernetes version to upgrade to.
+	TargetVersion string
+	// Rootfs is the rootfs of the new image, which is copied to every host.
+	Rootfs string
+	Report Report
+	client *k8s.Client
+}
+
+func (u *UpgradeChecker) Check(cluster *v2.Cluster, phase string) error {
+	if phase != PhasePreUpgrade {
+		return nil
+	}
+	c, err := k8s.Newk8sClient()
+	if err != nil {
+		return err
+	}
+	u.client = c
+	u.Report = Report{}
+
+	u.checkVersionSkew()
+	u.checkEtcd(cluster)
+	u.checkDisk(cluster)
+	u.checkRemovedAPIs()
+
+	if err = u.Report.Output(); err != nil {
+		return err
+	}
+	if failed := u.Report.count(CheckFail); failed > 0 {
+		return fmt.Errorf("upgrade pre-flight check failed: %d failed, %d warned", failed, u.Report.count(CheckWarn))
+	}
+	return nil
+}
+
+func (u *UpgradeChecker) checkVersionSkew() {
+	info, err := u.client.GetClusterVersion()
+	if err != nil {
+		u.Report.add("version-skew", "apiserver", CheckFail, "failed to get version: %v", err)
+		return
+	}
+	nodes, err := u.client.ListNodes()
+	if err != nil {
+		u.Report.add("version-skew", "kubelet", CheckFail, "%v", err)
+		return
+	}
+	kubelets := map[string]string{}
+	for _, node := range nodes.Items {
+		kubelets[node.Name] = node.Status.NodeInfo.KubeletVersion
+	}
+	u.Report.Items = append(u.Report.Items, checkVersionSkew(info.GitVersion, u.TargetVersion, kubelets)...)
+}
+
+// checkVersionSkew checks the skew between apiserver, kubelets and target version by the version skew policy:
+// apiserver is upgraded one minor version at a time, and kubelets must not be newer than the apiserver
+// or older than it by more than two minor versions.
+func checkVersionSkew(apiserver, target string, kubelets map[string]string) []CheckItem {
+	r := Report{}
+	current, err := semver.NewVersion(apiserver)
+	if err != nil {
+		r.add("version-skew", "apiserver", CheckFail, "invalid version %s", apiserver)
+		return r.Items
+	}
+	to, err := semver.NewVersion(target)
+	if err != nil {
+		r.add("version-skew", "apiserver", CheckFail, "invalid target version %s", target)
+		return r.Items
+	}
+	switch {
+	case to.LessThan(current):
+		r.add("version-skew", "apiserver", CheckFail, "downgrade from %s to %s is not supported", apiserver, target)
+	case to.Major() != current.Major() || to.Minor() > current.Minor()+1:
+		r.add("version-skew", "apiserver", CheckFail, "can not upgrade from %s to %s, only one minor version at a time", apiserver, target)
+	default:
+		r.add("version-skew", "apiserver", CheckPass, "%s to %s", apiserver, target)
+	}
+
+	var names []string
+	for name := range kubelets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	skewed := false
+	for _, name := range names {
+		v, err := semver.NewVersion(kubelets[name])
+		if err != nil {
+			r.add("version-skew", name, CheckWarn, "invalid kubelet version %s", kubelets[name])
+			skewed = true
+			continue
+		}
+		switch {
+		case v.GreaterThan(current):
+			r.add("version-skew", name, CheckFail, "kubelet %s is newer than apiserver %s", kubelets[name], apiserver)
+		case v.Major() != to.Major() || v.Minor()+2 < to.Minor():
+			r.add("version-skew", name, CheckFail, "kubelet %s is too old for %s, it must be upgraded first", kubelets[name], target)
+		case !v.Equal(current):
+			r.add("version-skew", name, CheckWarn, "kubelet %s differs from apiserver %s, the last upgrade may be unfinished", kubelets[name], apiserver)
+		default:
+			continue
+		}
+		skewed = true
+	}
+	if !skewed && len(names) > 0 {
+		r.add("version-skew", "kubelet", CheckPass, "%d kubelets are %s", len(names), apiserver)
+	}
+	return r.Items
+}
+
+func (u *UpgradeChecker) checkEtcd(cluster *v2.Cluster) {
+	cfg, err := plugin.NewEtcdConfig(cluster)
+	if err != nil {
+		// like external etcd or runtimes without etcd certs on master.
+		u.Report.add("etcd", "", CheckWarn, "skipped, failed to connect to etcd: %v", err)
+		return
+	}
+	cli, err := clientv3.New(cfg)
+	if err != nil {
+		u.Report.add("etcd", "", CheckFail, "failed to connect to etcd: %v", err)
+		return
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	members, err := cli.MemberList(ctx)
+	if err != nil {
+		u.Report.add("etcd", "", CheckFail, "failed to list members: %v", err)
+		return
+	}
+	for _, member := range members.Members {
+		if len(member.ClientURLs) == 0 {
+			u.Report.add("etcd", member.Name, CheckFail, "member is not started")
+			continue
+		}
+		status, err := cli.Status(ctx, member.ClientURLs[0])
+		if err != nil {
+			u.Report.add("etcd", member.Name, CheckFail, "member is unhealthy: %v", err)
+			continue
+		}
+		if len(status.Errors) > 0 {
+			u.Report.add("etcd", member.Name, CheckFail, "member has errors: %s", strings.Join(status.Errors, "; "))
+			continue
+		}
+		u.Report.Items = append(u.Report.Items, checkEtcdDBSize(member.Name, status.DbSize))
+	}
+}
+
+// checkEtcdDBSize warns when etcd db is over 70% of the quota, and fails over 90%,
+// etcd rejects writes with NOSPACE alarm when the quota is exceeded.
+func checkEtcdDBSize(member string, size int64) CheckItem {
+	r := Report{}
+	usage := float64(size) / etcdQuotaBytes * 100
+	switch {
+	case usage >= 90:
+		r.add("etcd", member, CheckFail, "db size %s is %.0f%% of quota, compact and defrag it first", utils.FormatBytes(size), usage)
+	case usage >= 70:
+		r.add("etcd", member, CheckWarn, "db size %s is %.0f%% of quota", utils.FormatBytes(size), usage)
+	default:
+		r.add("etcd", member, CheckPass, "healthy, db size %s", utils.FormatBytes(size))
+	}
+	return r.Items[0]
+}
+
+func (u *UpgradeChecker) checkDisk(cluster *v2.Cluster) {
+	need, err := utils.GetFileSize(u.Rootfs)
+	if err != nil {
+		u.Report.add("disk", "", CheckFail, "failed to get size of rootfs %s: %v", u.Rootfs, err)
+		return
+	}
+	rootfs := common.DefaultTheClusterRootfsDir(cluster.Name)
+	for _, ip := range append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...) {
+		s, err := ssh.GetHostSSHClient(ip, cluster)
+		if err != nil {
+			u.Report.add("disk", ip, CheckFail, "failed to get ssh client: %v", err)
+			continue
+		}
+		out, err := cmdWithTimeout(s, ip, fmt.Sprintf(diskAvailableCmd, rootfs))
+		if err != nil {
+			u.Report.add("disk", ip, CheckFail, "failed to get free disk of %s: %v", rootfs, err)
+			continue
+		}
+		kb, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+		if err != nil {
+			u.Report.add("disk", ip, CheckFail, "failed to parse free disk %q: %v", out, err)
+			continue
+		}
+		u.Report.Items = append(u.Report.Items, checkDiskSpace(ip, kb*1024, need))
+	}
+}
+
+// checkDiskSpace fails when there is no room for the new rootfs, and warns when there is no room
+// for the backup of the host besides it.
+func checkDiskSpace(host string, available, need int64) CheckItem {
+	r := Report{}
+	switch {
+	case available < need:
+		r.add("disk", host, CheckFail, "%s free, %s is needed for the new rootfs", utils.FormatBytes(available), utils.FormatBytes(need))
+	case available < 2*need:
+		r.add("disk", host, CheckWarn, "%s free, less than twice the new rootfs %s", utils.FormatBytes(available), utils.FormatBytes(need))
+	default:
+		r.add("disk", host, CheckPass, "%s free", utils.FormatBytes(available))
+	}
+	return r.Items[0]
+}
+
+func (u *UpgradeChecker) checkRemovedAPIs() {
+	found := false
+	for _, api := range removedAPIsIn(u.TargetVersion) {
+		served, err := u.client.ServesResource(api.gvr)
+		if err != nil {
+			u.Report.add("removed-api", api.gvr.String(), CheckWarn, "failed to discover: %v", err)
+			continue
+		}
+		if !served {
+			continue
+		}
+		list, err := u.client.ListResources(api.gvr)
+		if err != nil {
+			u.Report.add("removed-api", api.gvr.String(), CheckWarn, "%v", err)
+			continue
+		}
+		if len(list.Items) == 0 {
+			continue
+		}
+		found = true
+		// objects are readable with all the served versions, the applied version tells which one the client uses.
+		var applied []string
+		for _, obj := range list.Items {
+			if appliedAPIVersion(obj.GetAnnotations()[lastAppliedConfigAnnKey]) == api.gvr.GroupVersion().String() {
+				applied = append(applied, strings.TrimPrefix(obj.GetNamespace()+"/"+obj.GetName(), "/"))
+			}
+		}
+		target := fmt.Sprintf("%s %s", api.gvr.GroupVersion(), api.gvr.Resource)
+		if len(applied) > 0 {
+			u.Report.add("removed-api", target, CheckFail, "removed in %s but %s applied with it, migrate to %s",
+				api.removedIn, strings.Join(applied, ", "), api.replacement)
+			continue
+		}
+		u.Report.add("removed-api", target, CheckWarn, "removed in %s, %d objects exist, make sure their clients use %s",
+			api.removedIn, len(list.Items), api.replacement)
+	}
+	if !found {
+		u.Report.add("removed-api", "", CheckPass, "no objects of apis removed in %s", u.TargetVersion)
+	}
+}
+
+// removedAPIsIn returns the apis which are removed in target version or before.
+func removedAPIsIn(target string) []removedAPI {
+	to, err := semver.NewVersion(target)
+	if err != nil {
+		return nil
+	}
+	var apis []removedAPI
+	for _, api := range removedAPIs {
+		if !to.LessThan(semver.MustParse(api.removedIn)) {
+			apis = append(apis, api)
+		}
+	}
+	return apis
+}
+
+func appliedAPIVersion(lastApplied string) string {
+	if lastApplied == "" {
+		return ""
+	}
+	var obj struct {
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := json.Unmarshal([]byte(lastApplied), &obj); err != nil {
+		return ""
+	}
+	return obj.APIVersion
+}
+
+func NewUpgradeChecker(targetVersion, rootfs string) Interface {
+	return &UpgradeChecker{TargetVersion: targetVersion, Rootfs: rootfs}
+}
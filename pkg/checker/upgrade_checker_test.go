@@ -0,0 +1,115 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"testing"
+)
+
+func results(items []CheckItem) map[string]CheckResult {
+	r := map[string]CheckResult{}
+	for _, item := range items {
+		r[item.Target] = item.Result
+	}
+	return r
+}
+
+func TestCheckVersionSkew(t *testing.T) {
+	tests := []struct {
+		name      string
+		apiserver string
+		target    string
+		kubelets  map[string]string
+		want      map[string]CheckResult
+	}{
+		{
+			name:      "one minor version",
+			apiserver: "v1.19.8",
+			target:    "v1.20.4",
+			kubelets:  map[string]string{"node1": "v1.19.8", "node2": "v1.19.8"},
+			want:      map[string]CheckResult{"apiserver": CheckPass, "kubelet": CheckPass},
+		},
+		{
+			name:      "skip minor version",
+			apiserver: "v1.19.8",
+			target:    "v1.21.0",
+			kubelets:  map[string]string{"node1": "v1.19.8"},
+			want:      map[string]CheckResult{"apiserver": CheckFail, "kubelet": CheckPass},
+		},
+		{
+			name:      "downgrade",
+			apiserver: "v1.20.4",
+			target:    "v1.19.8",
+			want:      map[string]CheckResult{"apiserver": CheckFail},
+		},
+		{
+			name:      "skewed kubelets",
+			apiserver: "v1.20.4",
+			target:    "v1.21.0",
+			kubelets:  map[string]string{"node1": "v1.20.4", "node2": "v1.18.8", "node3": "v1.19.8", "node4": "v1.21.0"},
+			want:      map[string]CheckResult{"apiserver": CheckPass, "node2": CheckFail, "node3": CheckWarn, "node4": CheckFail},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := results(checkVersionSkew(tt.apiserver, tt.target, tt.kubelets))
+			if len(got) != len(tt.want) {
+				t.Fatalf("checkVersionSkew() got = %v, want %v", got, tt.want)
+			}
+			for target, result := range tt.want {
+				if got[target] != result {
+					t.Errorf("checkVersionSkew() %s got = %s, want %s", target, got[target], result)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+	tests := []struct {
+		name string
+		item CheckItem
+		want CheckResult
+	}{
+		{"etcd small db", checkEtcdDBSize("master0", 100*1024*1024), CheckPass},
+		{"etcd large db", checkEtcdDBSize("master0", 1536*1024*1024), CheckWarn},
+		{"etcd full db", checkEtcdDBSize("master0", 1900*1024*1024), CheckFail},
+		{"enough disk", checkDiskSpace("node1", 10*gib, 2*gib), CheckPass},
+		{"no room for backup", checkDiskSpace("node1", 3*gib, 2*gib), CheckWarn},
+		{"no room for rootfs", checkDiskSpace("node1", 1*gib, 2*gib), CheckFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.item.Result != tt.want {
+				t.Errorf("got %s: %s, want %s", tt.item.Result, tt.item.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemovedAPIsIn(t *testing.T) {
+	if apis := removedAPIsIn("v1.15.3"); len(apis) != 0 {
+		t.Errorf("removedAPIsIn(v1.15.3) got %d apis, want 0", len(apis))
+	}
+	for _, api := range removedAPIsIn("v1.22.0") {
+		if api.removedIn == "v1.25.0" || api.removedIn == "v1.26.0" {
+			t.Errorf("removedAPIsIn(v1.22.0) got %s removed in %s", api.gvr, api.removedIn)
+		}
+	}
+	if got := appliedAPIVersion(`{"apiVersion":"extensions/v1beta1","kind":"Ingress"}`); got != "extensions/v1beta1" {
+		t.Errorf("appliedAPIVersion() got %s", got)
+	}
+}
@@ -20,8 +20,12 @@ import (
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
@@ -30,7 +34,8 @@ import (
 )
 
 type Client struct {
-	client *kubernetes.Clientset
+	client  *kubernetes.Clientset
+	dynamic dynamic.Interface
 }
 
 type NamespacePod struct {
@@ -60,8 +65,14 @@ func Newk8sClient() (*Client, error) {
 		return nil, err
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Client{
-		client: clientSet,
+		client:  clientSet,
+		dynamic: dynamicClient,
 	}, nil
 }
 
@@ -96,6 +107,30 @@ func (c *Client) listNamespaces() (*v1.NamespaceList, error) {
 	return namespaceList, nil
 }
 
+func (c *Client) GetNode(name string) (*v1.Node, error) {
+	node, err := c.client.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get node %s", name)
+	}
+	return node, nil
+}
+
+// GetNodeByIP return the node whose InternalIP is ip.
+func (c *Client) GetNodeByIP(ip string) (*v1.Node, error) {
+	nodes, err := c.ListNodes()
+	if err != nil {
+		return nil, err
+	}
+	for i, node := range nodes.Items {
+		for _, v := range node.Status.Addresses {
+			if v.Type == v1.NodeInternalIP && v.Address == ip {
+				return &nodes.Items[i], nil
+			}
+		}
+	}
+	return nil, errors.Errorf("node with ip %s not found", ip)
+}
+
 func (c *Client) ListNodesByLabel(label string) (*v1.NodeList, error) {
 	nodes, err := c.client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{LabelSelector: label})
 	if err != nil {
@@ -185,6 +220,32 @@ func (c *Client) GetClusterVersion() (*version.Info, error) {
 	return info, nil
 }
 
+// ServesResource returns whether the apiserver serves the resource of gvr.
+func (c *Client) ServesResource(gvr schema.GroupVersionResource) (bool, error) {
+	resources, err := c.client.Discovery().ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to discover %s", gvr.GroupVersion())
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == gvr.Resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListResources lists the objects of gvr in all namespaces.
+func (c *Client) ListResources(gvr schema.GroupVersionResource) (*unstructured.UnstructuredList, error) {
+	list, err := c.dynamic.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s", gvr)
+	}
+	return list, nil
+}
+
 func (c *Client) ListKubeSystemPodsStatus() (bool, error) {
 	pods, err := c.client.CoreV1().Pods("kube-system").List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
@@ -0,0 +1,263 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudfilesystem
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// distributor copies the rootfs to hosts with limited concurrency and bandwidth. With relay, the hosts which
+// have received the rootfs copy it to the later hosts, each of them serves one host at a time.
+type distributor struct {
+	cluster        *v2.Cluster
+	src            string
+	target         string
+	registryIP     string
+	concurrency    int
+	bandwidthLimit int64
+	relay          bool
+	incremental    bool
+	// manifest is the digests of rootfs for incremental sync, registryManifest includes the registry.
+	manifest         *rootfsManifest
+	registryManifest *rootfsManifest
+
+	// local limits the hosts copied from this host, relays are the hosts free to serve.
+	local  chan struct{}
+	relays chan string
+
+	rootfsSize   int64
+	registrySize int64
+	copiedBytes  int64
+	relayedHosts int32
+
+	// hostClient connects to a host, and relayTo copies the rootfs from relay to a host.
+	hostClient func(ip string) (ssh.Interface, error)
+	relayTo    func(relay, ip string) error
+}
+
+func newDistributor(cluster *v2.Cluster, src, target, registryIP string, hosts int) (*distributor, error) {
+	d := &distributor{
+		cluster:     cluster,
+		src:         src,
+		target:      target,
+		registryIP:  registryIP,
+		concurrency: hosts,
+	}
+	d.hostClient = func(ip string) (ssh.Interface, error) {
+		return ssh.GetHostSSHClient(ip, cluster)
+	}
+	d.relayTo = d.relayCopy
+	if spec := cluster.Spec.Distribution; spec != nil {
+		if spec.Concurrency > 0 {
+			d.concurrency = spec.Concurrency
+		}
+		if spec.BandwidthLimit != "" {
+			q, err := resource.ParseQuantity(spec.BandwidthLimit)
+			if err != nil {
+				return nil, fmt.Errorf("invalid distribution bandwidth limit %s: %v", spec.BandwidthLimit, err)
+			}
+			d.bandwidthLimit = q.Value()
+		}
+		d.relay = spec.Relay
+		d.incremental = spec.Incremental
+	}
+	if d.concurrency < 1 {
+		d.concurrency = 1
+	}
+
+	var err error
+	if d.rootfsSize, err = utils.GetFileSize(src); err != nil {
+		return nil, fmt.Errorf("failed to get size of %s: %v", src, err)
+	}
+	if registry := filepath.Join(src, common.RegistryDirName); utils.IsExist(registry) {
+		if d.registrySize, err = utils.GetFileSize(registry); err != nil {
+			return nil, fmt.Errorf("failed to get size of %s: %v", registry, err)
+		}
+	}
+	if d.incremental {
+		if d.registryManifest, err = buildManifest(src); err != nil {
+			return nil, fmt.Errorf("failed to build rootfs manifest: %v", err)
+		}
+		d.manifest = d.registryManifest.withoutRegistry()
+	}
+	return d, nil
+}
+
+// distribute copies the rootfs to hosts and calls after on each host when it is copied.
+func (d *distributor) distribute(hosts []string, after func(ip string) error) error {
+	start := time.Now()
+	d.local = make(chan struct{}, d.concurrency)
+	d.relays = make(chan string, len(hosts))
+
+	eg, _ := errgroup.WithContext(context.Background())
+	for _, IP := range registryFirst(hosts, d.registryIP) {
+		ip := IP
+		// the registry host gets the registry besides the rootfs, it is always copied from this host.
+		relay := ""
+		if d.relay && ip != d.registryIP {
+			select {
+			case relay = <-d.relays:
+			case d.local <- struct{}{}:
+			}
+		} else {
+			d.local <- struct{}{}
+		}
+		eg.Go(func() error {
+			if err := d.copy(ip, relay); err != nil {
+				return fmt.Errorf("copy rootfs to %s failed %v", ip, err)
+			}
+			if d.relay && ip != d.registryIP {
+				d.relays <- ip
+			}
+			return after(ip)
+		})
+	}
+	err := eg.Wait()
+
+	elapsed := time.Since(start) + time.Millisecond
+	copied := atomic.LoadInt64(&d.copiedBytes)
+	logger.Info("Distributed rootfs to %d hosts in %s, %s in total, %s/s in aggregate, %d hosts relayed",
+		len(hosts), elapsed.Round(time.Second), utils.FormatBytes(copied),
+		utils.FormatBytes(int64(float64(copied)/elapsed.Seconds())), atomic.LoadInt32(&d.relayedHosts))
+	return err
+}
+
+// copy copies the rootfs to ip from relay, or from this host if relay is empty or failed. The slot of
+// relay or this host taken by distribute is released when it is done.
+func (d *distributor) copy(ip, relay string) error {
+	size := d.rootfsSize
+	if ip != d.registryIP {
+		size -= d.registrySize
+	}
+	if relay != "" && d.incremental && d.hasRootfs(ip) {
+		// syncing the changes from this host moves less than a full copy from relay.
+		d.relays <- relay
+		relay = ""
+		d.local <- struct{}{}
+	}
+	if relay != "" {
+		err := d.relayTo(relay, ip)
+		d.relays <- relay
+		if err == nil {
+			atomic.AddInt64(&d.copiedBytes, size)
+			atomic.AddInt32(&d.relayedHosts, 1)
+			return nil
+		}
+		logger.Warn("failed to relay rootfs from %s to %s, copy it from this host: %v", relay, ip, err)
+		d.local <- struct{}{}
+	}
+	defer func() {
+		<-d.local
+	}()
+
+	sshClient, err := d.hostClient(ip)
+	if err != nil {
+		return fmt.Errorf("get host ssh client failed %v", err)
+	}
+	if s, ok := sshClient.(*ssh.SSH); ok {
+		s.BandwidthLimit = d.bandwidthLimit
+	}
+	if d.incremental {
+		return d.sync(sshClient, ip, size)
+	}
+	if err = copyFiles(sshClient, ip == d.registryIP, ip, d.src, d.target); err != nil {
+		return err
+	}
+	atomic.AddInt64(&d.copiedBytes, size)
+	return nil
+}
+
+// sync copies only the files changed on host, and records the manifest on it. When most of the files are
+// changed, like the host is new, the whole rootfs is copied.
+func (d *distributor) sync(s ssh.Interface, ip string, size int64) error {
+	manifest := d.manifest
+	if ip == d.registryIP {
+		manifest = d.registryManifest
+	}
+	remote, recorded, err := remoteManifest(s, ip, d.target)
+	if err != nil {
+		return fmt.Errorf("failed to get rootfs manifest: %v", err)
+	}
+	changed, removed := manifest.diff(remote)
+	if len(changed)*2 > len(manifest.Files) {
+		if err = copyFiles(s, ip == d.registryIP, ip, d.src, d.target); err != nil {
+			return err
+		}
+		atomic.AddInt64(&d.copiedBytes, size)
+		if _, err = syncFiles(s, ip, d.src, d.target, nil, removed, recorded); err != nil {
+			return err
+		}
+	} else {
+		copied, err := syncFiles(s, ip, d.src, d.target, changed, removed, recorded)
+		atomic.AddInt64(&d.copiedBytes, copied)
+		if err != nil {
+			return err
+		}
+		logger.Debug("synced rootfs to %s: %d files changed, %d removed", ip, len(changed), len(removed))
+	}
+	return writeRemoteManifest(s, ip, d.target, manifest)
+}
+
+func (d *distributor) hasRootfs(ip string) bool {
+	s, err := d.hostClient(ip)
+	if err != nil {
+		return false
+	}
+	exist, err := s.RemoteDirExist(ip, d.target)
+	return err == nil && exist
+}
+
+func (d *distributor) relayCopy(relay, ip string) error {
+	relayClient, err := ssh.GetHostSSHClient(relay, d.cluster)
+	if err != nil {
+		return err
+	}
+	targetClient, err := ssh.GetHostSSHClient(ip, d.cluster)
+	if err != nil {
+		return err
+	}
+	r, rok := relayClient.(*ssh.SSH)
+	t, tok := targetClient.(*ssh.SSH)
+	if !rok || !tok {
+		return ssh.ErrRelayNotSupported
+	}
+	logger.Debug("relay rootfs from %s to %s", relay, ip)
+	return ssh.RelayCopy(r, relay, t, ip, d.target, d.bandwidthLimit)
+}
+
+func registryFirst(hosts []string, registryIP string) []string {
+	ordered := make([]string, 0, len(hosts))
+	for _, ip := range hosts {
+		if ip == registryIP {
+			ordered = append([]string{ip}, ordered...)
+			continue
+		}
+		ordered = append(ordered, ip)
+	}
+	return ordered
+}
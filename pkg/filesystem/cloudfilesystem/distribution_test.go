@@ -0,0 +1,139 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudfilesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// copyRecorder records the copies of rootfs to the hosts, from this host or from a relay.
+type copyRecorder struct {
+	sync.Mutex
+	started  []string
+	copied   map[string]int
+	relayed  map[string]string
+	local    int
+	maxLocal int
+	errs     []string
+}
+
+func (r *copyRecorder) copy(ip, relay string) {
+	r.Lock()
+	r.started = append(r.started, ip)
+	if relay == "" {
+		if r.local++; r.local > r.maxLocal {
+			r.maxLocal = r.local
+		}
+	} else {
+		r.relayed[ip] = relay
+		if r.copied[relay] == 0 {
+			r.errs = append(r.errs, fmt.Sprintf("%s is relayed from %s which has no rootfs", ip, relay))
+		}
+	}
+	r.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	r.Lock()
+	defer r.Unlock()
+	r.copied[ip]++
+	if relay == "" {
+		r.local--
+	}
+}
+
+// fakeCopySSH copies the rootfs by the recorder.
+type fakeCopySSH struct {
+	ssh.Interface
+	r *copyRecorder
+}
+
+func (f *fakeCopySSH) Copy(host, localPath, remotePath string) error {
+	f.r.copy(host, "")
+	return nil
+}
+
+func TestDistributor_Distribute(t *testing.T) {
+	src := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(src, "Kubefile"), []byte("FROM scratch"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := &copyRecorder{copied: map[string]int{}, relayed: map[string]string{}}
+	d := &distributor{
+		src:         src,
+		target:      "/var/lib/sealer/data/my-cluster/rootfs",
+		registryIP:  "192.168.0.3",
+		concurrency: 1,
+		relay:       true,
+		hostClient: func(ip string) (ssh.Interface, error) {
+			return &fakeCopySSH{r: r}, nil
+		},
+		relayTo: func(relay, ip string) error {
+			r.copy(ip, relay)
+			return nil
+		},
+	}
+
+	hosts := []string{"192.168.0.2", "192.168.0.3", "192.168.0.4", "192.168.0.5", "192.168.0.6", "192.168.0.7"}
+	var (
+		afterLock sync.Mutex
+		after     []string
+	)
+	err := d.distribute(hosts, func(ip string) error {
+		afterLock.Lock()
+		defer afterLock.Unlock()
+		after = append(after, ip)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("distribute() error = %v", err)
+	}
+
+	if r.started[0] != d.registryIP {
+		t.Errorf("registry host %s should be copied first, got %v", d.registryIP, r.started)
+	}
+	if relay, ok := r.relayed[d.registryIP]; ok {
+		t.Errorf("registry host should be copied from this host, got relayed from %s", relay)
+	}
+	for _, relay := range r.relayed {
+		if relay == d.registryIP {
+			t.Errorf("registry host should not serve as relay: %v", r.relayed)
+		}
+	}
+	if r.maxLocal > d.concurrency {
+		t.Errorf("%d hosts are copied from this host at the same time, want at most %d", r.maxLocal, d.concurrency)
+	}
+	if len(r.relayed) == 0 || int(d.relayedHosts) != len(r.relayed) {
+		t.Errorf("%d hosts relayed, %d recorded, want some hosts relayed", len(r.relayed), d.relayedHosts)
+	}
+	for _, e := range r.errs {
+		t.Error(e)
+	}
+	for _, ip := range hosts {
+		if r.copied[ip] != 1 {
+			t.Errorf("host %s is copied %d times, want once", ip, r.copied[ip])
+		}
+	}
+	if len(after) != len(hosts) {
+		t.Errorf("after is called on %v, want all hosts", after)
+	}
+}
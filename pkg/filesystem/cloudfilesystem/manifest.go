@@ -0,0 +1,181 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudfilesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	// rootfsManifestFile records the digests of the files synced to the rootfs of a host.
+	rootfsManifestFile  = ".rootfs-manifest.json"
+	remoteDigestsCmd    = "cd %s 2>/dev/null || exit 0; find . -type f ! -name %s -print0 | xargs -0 -r sha256sum"
+	remoteRemoveFileCmd = "cd %s && rm -f %s"
+)
+
+// rootfsManifest is the digests of the files in rootfs, keyed by the path relative to rootfs.
+type rootfsManifest struct {
+	Files map[string]digest.Digest `json:"files"`
+}
+
+// buildManifest digests the regular files in dir.
+func buildManifest(dir string) (*rootfsManifest, error) {
+	m := &rootfsManifest{Files: map[string]digest.Digest{}}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || rel == rootfsManifestFile {
+			return nil
+		}
+		f, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		d, err := digest.Canonical.FromReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to digest %s: %v", path, err)
+		}
+		m.Files[filepath.ToSlash(rel)] = d
+		return nil
+	})
+	return m, err
+}
+
+// withoutRegistry returns the manifest of the hosts which are not the registry.
+func (m *rootfsManifest) withoutRegistry() *rootfsManifest {
+	out := &rootfsManifest{Files: map[string]digest.Digest{}}
+	for path, d := range m.Files {
+		if !strings.HasPrefix(path, common.RegistryDirName+"/") {
+			out.Files[path] = d
+		}
+	}
+	return out
+}
+
+// diff returns the files of m which are missing or changed in remote, and the files of remote not in m.
+func (m *rootfsManifest) diff(remote *rootfsManifest) (changed, removed []string) {
+	for path, d := range m.Files {
+		if remote.Files[path] != d {
+			changed = append(changed, path)
+		}
+	}
+	for path := range remote.Files {
+		if _, ok := m.Files[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// remoteManifest fetches the manifest written by last sync, or digests the rootfs on host if there is none.
+// recorded is false if the manifest is digested, which may have files not synced by sealer.
+func remoteManifest(s ssh.Interface, ip, target string) (m *rootfsManifest, recorded bool, err error) {
+	out, err := s.Cmd(ip, fmt.Sprintf("cat %s", ssh.ShellQuote(filepath.Join(target, rootfsManifestFile))))
+	if err == nil {
+		m = &rootfsManifest{}
+		if err = json.Unmarshal(out, m); err == nil && m.Files != nil {
+			return m, true, nil
+		}
+		logger.Warn("invalid rootfs manifest on %s, digest the rootfs instead: %v", ip, err)
+	}
+
+	out, err = s.Cmd(ip, fmt.Sprintf(remoteDigestsCmd, ssh.ShellQuote(target), rootfsManifestFile))
+	if err != nil {
+		return nil, false, err
+	}
+	return parseSHA256Sums(string(out)), false, nil
+}
+
+// parseSHA256Sums parses the output of sha256sum like "<hex>  ./bin/kubeadm".
+func parseSHA256Sums(out string) *rootfsManifest {
+	m := &rootfsManifest{Files: map[string]digest.Digest{}}
+	for _, line := range strings.Split(strings.ReplaceAll(out, "\r\n", "\n"), "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "  ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		m.Files[strings.TrimPrefix(fields[1], "./")] = digest.NewDigestFromEncoded(digest.SHA256, fields[0])
+	}
+	return m
+}
+
+// syncFiles copies the changed files to host ip in one batch, and removes the removed files from it if they are
+// recorded by last sync. It returns the bytes copied, and fails if any of the files is not copied, so that the
+// manifest is not written for the files missing on host.
+func syncFiles(s ssh.Interface, ip, src, target string, changed, removed []string, recorded bool) (int64, error) {
+	var size int64
+	files := make([]string, 0, len(changed))
+	for _, path := range changed {
+		localFile := filepath.FromSlash(path)
+		if info, err := os.Stat(filepath.Join(src, localFile)); err == nil {
+			size += info.Size()
+		}
+		files = append(files, localFile)
+	}
+	if err := s.CopyFiles(ip, src, target, files); err != nil {
+		return 0, err
+	}
+	// only the files synced by sealer are removed, the others may be generated on host.
+	if recorded && len(removed) > 0 {
+		quoted := make([]string, 0, len(removed))
+		for _, path := range removed {
+			quoted = append(quoted, ssh.ShellQuote(path))
+		}
+		if _, err := s.Cmd(ip, fmt.Sprintf(remoteRemoveFileCmd, ssh.ShellQuote(target), strings.Join(quoted, " "))); err != nil {
+			return size, fmt.Errorf("failed to remove stale files: %v", err)
+		}
+	}
+	return size, nil
+}
+
+func writeRemoteManifest(s ssh.Interface, ip, target string, m *rootfsManifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile("", "rootfs-manifest")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return s.Copy(ip, tmp.Name(), filepath.Join(target, rootfsManifestFile))
+}
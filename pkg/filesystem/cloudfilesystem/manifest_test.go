@@ -0,0 +1,114 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudfilesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+func TestRootfsManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"bin/kubeadm":              "kubeadm v1.20.4",
+		"bin/kubelet":              "kubelet v1.20.4",
+		"etc/kubeadm.yml":          "kind: ClusterConfiguration",
+		"registry/docker/registry": "blob",
+		rootfsManifestFile:         "{}",
+	}
+	for path, content := range files {
+		if err = os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, path), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	full, err := buildManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full.Files) != 4 {
+		t.Fatalf("buildManifest() got %d files, want 4: %v", len(full.Files), full.Files)
+	}
+	local := full.withoutRegistry()
+	if _, ok := local.Files["registry/docker/registry"]; ok || len(local.Files) != 3 {
+		t.Errorf("withoutRegistry() got %v", local.Files)
+	}
+
+	remote := parseSHA256Sums(
+		digest.FromString("kubeadm v1.20.4").Encoded() + "  ./bin/kubeadm\r\n" +
+			digest.FromString("kubelet v1.19.8").Encoded() + "  ./bin/kubelet\r\n" +
+			digest.FromString("old").Encoded() + "  ./bin/kube-proxy\r\n")
+	changed, removed := local.diff(remote)
+	if want := []string{"bin/kubelet", "etc/kubeadm.yml"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("diff() changed = %v, want %v", changed, want)
+	}
+	if want := []string{"bin/kube-proxy"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("diff() removed = %v, want %v", removed, want)
+	}
+}
+
+// fakeSSH records the commands and the batches of files copied.
+type fakeSSH struct {
+	ssh.Interface
+	copied  [][]string
+	cmds    []string
+	copyErr error
+}
+
+func (f *fakeSSH) CopyFiles(host, localDir, remoteDir string, files []string) error {
+	f.copied = append(f.copied, files)
+	return f.copyErr
+}
+
+func (f *fakeSSH) Cmd(host, cmd string) ([]byte, error) {
+	f.cmds = append(f.cmds, cmd)
+	return nil, nil
+}
+
+func TestSyncFiles(t *testing.T) {
+	s := &fakeSSH{}
+	changed := []string{"bin/kubeadm", "etc/kubeadm.yml"}
+	removed := []string{"bin/kube-proxy", "etc/it's $(reboot).yml"}
+	if _, err := syncFiles(s, "192.168.0.2", t.TempDir(), "/var/lib/sealer/data/my-cluster/rootfs", changed, removed, true); err != nil {
+		t.Fatalf("syncFiles() error = %v", err)
+	}
+	if len(s.copied) != 1 || len(s.copied[0]) != 2 {
+		t.Errorf("syncFiles() copied %v, want the changed files in one batch", s.copied)
+	}
+	want := `cd '/var/lib/sealer/data/my-cluster/rootfs' && rm -f 'bin/kube-proxy' 'etc/it'\''s $(reboot).yml'`
+	if len(s.cmds) != 1 || s.cmds[0] != want {
+		t.Errorf("syncFiles() run %q, want %q", s.cmds, want)
+	}
+
+	s = &fakeSSH{copyErr: fmt.Errorf("no space left on device")}
+	if _, err := syncFiles(s, "192.168.0.2", t.TempDir(), "/var/lib/sealer/data/my-cluster/rootfs", changed, removed, true); err == nil {
+		t.Error("syncFiles() should fail if a file is not copied")
+	}
+}
@@ -65,28 +65,23 @@ func mountRootfs(ipList []string, target string, cluster *v2.Cluster, initFlag b
 		return err
 	}
 
-	eg, _ := errgroup.WithContext(context.Background())
-	for _, IP := range ipList {
-		ip := IP
-		eg.Go(func() error {
-			sshClient, err := ssh.GetHostSSHClient(ip, cluster)
-			if err != nil {
-				return fmt.Errorf("get host ssh client failed %v", err)
-			}
-			err = copyFiles(sshClient, ip == config.IP, ip, src, target)
-			if err != nil {
-				return fmt.Errorf("copy rootfs failed %v", err)
-			}
-			if initFlag {
-				err = sshClient.CmdAsync(ip, envProcessor.WrapperShell(ip, initCmd))
-				if err != nil {
-					return fmt.Errorf("exec init.sh failed %v", err)
-				}
-			}
-			return err
-		})
+	d, err := newDistributor(cluster, src, target, config.IP, len(ipList))
+	if err != nil {
+		return err
 	}
-	return eg.Wait()
+	return d.distribute(ipList, func(ip string) error {
+		if !initFlag {
+			return nil
+		}
+		sshClient, err := ssh.GetHostSSHClient(ip, cluster)
+		if err != nil {
+			return fmt.Errorf("get host ssh client failed %v", err)
+		}
+		if err = sshClient.CmdAsync(ip, envProcessor.WrapperShell(ip, initCmd)); err != nil {
+			return fmt.Errorf("exec init.sh failed %v", err)
+		}
+		return nil
+	})
 }
 
 func unmountRootfs(ipList []string, cluster *v2.Cluster) error {
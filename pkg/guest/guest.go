@@ -34,6 +34,8 @@ import (
 type Interface interface {
 	Apply(cluster *v2.Cluster) error
 	Delete(cluster *v2.Cluster) error
+	// GetCmds return the rendered guest cmds of cluster image, without executing them.
+	GetCmds(cluster *v2.Cluster) ([]string, error)
 }
 
 type Default struct {
@@ -50,37 +52,44 @@ func NewGuestManager() (Interface, error) {
 }
 
 func (d *Default) Apply(cluster *v2.Cluster) error {
-	var (
-		clusterRootfs = common.DefaultTheClusterRootfsDir(cluster.Name)
-		ex            = shell.NewLex('\\')
-	)
-
-	image, err := d.imageStore.GetByName(cluster.Spec.Image)
+	clusterRootfs := common.DefaultTheClusterRootfsDir(cluster.Name)
+	cmds, err := d.GetCmds(cluster)
 	if err != nil {
-		return fmt.Errorf("get cluster image failed, %s", err)
+		return err
 	}
-	cmdArgs := d.getGuestCmdArg(cluster, image)
-	cmd := d.getGuestCmd(cluster, image)
 	sshClient, err := ssh.NewStdoutSSHClient(runtime.GetMaster0Ip(cluster), cluster)
 	if err != nil {
 		return err
 	}
 
-	for _, value := range cmd {
+	for _, cmdline := range cmds {
+		if err := sshClient.CmdAsync(runtime.GetMaster0Ip(cluster), fmt.Sprintf(common.CdAndExecCmd, clusterRootfs, cmdline)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Default) GetCmds(cluster *v2.Cluster) ([]string, error) {
+	ex := shell.NewLex('\\')
+	image, err := d.imageStore.GetByName(cluster.Spec.Image)
+	if err != nil {
+		return nil, fmt.Errorf("get cluster image failed, %s", err)
+	}
+	cmdArgs := d.getGuestCmdArg(cluster, image)
+	var cmds []string
+	for _, value := range d.getGuestCmd(cluster, image) {
 		if value == "" {
 			continue
 		}
 		cmdline, err := ex.ProcessWordWithMap(value, cmdArgs)
 		if err != nil {
-			return fmt.Errorf("failed to render build args: %v", err)
-		}
-
-		if err := sshClient.CmdAsync(runtime.GetMaster0Ip(cluster), fmt.Sprintf(common.CdAndExecCmd, clusterRootfs, cmdline)); err != nil {
-			return err
+			return nil, fmt.Errorf("failed to render build args: %v", err)
 		}
+		cmds = append(cmds, cmdline)
 	}
-
-	return nil
+	return cmds, nil
 }
 
 func (d *Default) getGuestCmd(cluster *v2.Cluster, image *v1.Image) []string {
@@ -117,6 +126,8 @@ func (d *Default) getGuestCmdArg(cluster *v2.Cluster, image *v1.Image) map[strin
 	} else {
 		base = utils.MergeMap(image.Spec.ImageConfig.Args.Parent, image.Spec.ImageConfig.Args.Current)
 	}
+	// args take precedence over the env of image.
+	base = utils.MergeMap(image.Spec.ImageConfig.Env, base)
 
 	for k, v := range utils.ConvertEnvListToMap(clusterArgs) {
 		base[k] = v
@@ -31,10 +31,12 @@ import (
 	"github.com/alibaba/sealer/logger"
 	"github.com/alibaba/sealer/pkg/image/distributionutil"
 	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/image/signature"
 	"github.com/alibaba/sealer/pkg/image/store"
 	imageUtils "github.com/alibaba/sealer/pkg/image/utils"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/platform"
 )
 
 // DefaultImageService is the default service, which is used for image pull/push
@@ -51,12 +53,40 @@ func (d DefaultImageService) PullIfNotExist(imageName string) error {
 	}
 	if img != nil {
 		logger.Debug("image %s already exists", imageName)
-		return nil
+		return d.verify(imageName)
 	}
 
 	return d.Pull(imageName)
 }
 
+// PullIfNotExistWithPlatform pulls the image unless the local one is built for platform,
+// it fails if the pulled image is not built for platform either.
+func (d DefaultImageService) PullIfNotExistWithPlatform(imageName string, want v1.Platform) error {
+	img, err := d.GetImageByName(imageName)
+	if err != nil {
+		return err
+	}
+	if img != nil && platform.Matches(want, img.Spec.Platform) {
+		logger.Debug("image %s already exists", imageName)
+		return d.verify(imageName)
+	}
+
+	if err = d.PullWithPlatform(context.Background(), imageName, want); err != nil {
+		return err
+	}
+	if img, err = d.GetImageByName(imageName); err != nil {
+		return err
+	}
+	if img == nil {
+		return fmt.Errorf("image %s is not found after pulled", imageName)
+	}
+	if !platform.Matches(want, img.Spec.Platform) {
+		return fmt.Errorf("image %s is built for platform %s, which does not match %s", imageName,
+			platform.ToString(platform.Normalize(img.Spec.Platform)), platform.ToString(want))
+	}
+	return nil
+}
+
 func (d DefaultImageService) GetImageByName(imageName string) (*v1.Image, error) {
 	var img *v1.Image
 	named, err := reference.ParseToNamed(imageName)
@@ -73,78 +103,97 @@ func (d DefaultImageService) GetImageByName(imageName string) (*v1.Image, error)
 
 // Pull always do pull action
 func (d DefaultImageService) Pull(imageName string) error {
+	return d.PullContext(context.Background(), imageName)
+}
+
+func (d DefaultImageService) PullContext(ctx context.Context, imageName string) error {
+	return d.PullWithPlatform(ctx, imageName, platform.Default())
+}
+
+func (d DefaultImageService) PullWithPlatform(ctx context.Context, imageName string, want v1.Platform) error {
 	named, err := reference.ParseToNamed(imageName)
 	if err != nil {
 		return err
 	}
-	var (
-		reader, writer  = io.Pipe()
-		writeFlusher    = dockerioutils.NewWriteFlusher(writer)
-		progressChanOut = streamformatter.NewJSONProgressOutput(writeFlusher, false)
-		streamOut       = dockerstreams.NewOut(common.StdOut)
-	)
-	defer func() {
-		_ = reader.Close()
-		_ = writer.Close()
-		_ = writeFlusher.Close()
-	}()
 
 	layerStore, err := store.NewDefaultLayerStore()
 	if err != nil {
 		return err
 	}
 
+	policy, err := signature.LoadPolicy(common.DefaultTrustPolicyFile)
+	if err != nil {
+		return err
+	}
+
+	progressChanOut, stop := displayProgress()
+	defer stop()
+
 	puller, err := distributionutil.NewPuller(named, distributionutil.Config{
 		LayerStore:     layerStore,
 		ProgressOutput: progressChanOut,
+		Platform:       want,
+		Policy:         policy,
 	})
 	if err != nil {
 		return err
 	}
 
-	go func() {
-		err := dockerjsonmessage.DisplayJSONMessagesToStream(reader, streamOut, nil)
-		if err != nil && err != io.ErrClosedPipe {
-			logger.Warn("error occurs in display progressing, err: %s", err)
-		}
-	}()
-
 	dockerprogress.Message(progressChanOut, "", fmt.Sprintf("Start to Pull Image %s", named.Raw()))
-	image, err := puller.Pull(context.Background(), named)
+	image, manifestDigest, err := puller.Pull(ctx, named)
 	if err != nil {
 		return err
 	}
+	if !platform.Matches(want, image.Spec.Platform) {
+		logger.Warn("image %s is built for platform %s, which does not match %s", named.Raw(),
+			platform.ToString(platform.Normalize(image.Spec.Platform)), platform.ToString(want))
+	}
 	// TODO use image store to do the job next
 	err = d.imageStore.Save(*image, named.Raw())
-	if err == nil {
-		dockerprogress.Message(progressChanOut, "", fmt.Sprintf("Success to Pull Image %s", named.Raw()))
+	if err != nil {
+		return err
 	}
-	return err
+	// keep the manifest digest the image is pulled from.
+	metadata, err := d.imageStore.GetImageMetadataItem(named.Raw())
+	if err != nil {
+		return err
+	}
+	metadata.ManifestDigest = manifestDigest
+	if err = d.imageStore.SetImageMetadataItem(metadata); err != nil {
+		return err
+	}
+	dockerprogress.Message(progressChanOut, "", fmt.Sprintf("Success to Pull Image %s", named.Raw()))
+	return nil
+}
+
+// verify checks the existing local image by the trust policy.
+func (d DefaultImageService) verify(imageName string) error {
+	named, err := reference.ParseToNamed(imageName)
+	if err != nil {
+		return err
+	}
+	return verifyLocalImage(d.imageStore, named)
 }
 
 // Push push local image to remote registry
 func (d DefaultImageService) Push(imageName string) error {
+	return d.PushContext(context.Background(), imageName)
+}
+
+func (d DefaultImageService) PushContext(ctx context.Context, imageName string) error {
 	named, err := reference.ParseToNamed(imageName)
 	if err != nil {
 		return err
 	}
-	var (
-		reader, writer  = io.Pipe()
-		writeFlusher    = dockerioutils.NewWriteFlusher(writer)
-		progressChanOut = streamformatter.NewJSONProgressOutput(writeFlusher, false)
-		streamOut       = dockerstreams.NewOut(common.StdOut)
-	)
-	defer func() {
-		_ = reader.Close()
-		_ = writer.Close()
-		_ = writeFlusher.Close()
-	}()
 
 	layerStore, err := store.NewDefaultLayerStore()
 	if err != nil {
 		return err
 	}
 
+	progressChanOut, stop := displayProgress()
+	defer stop()
+
 	pusher, err := distributionutil.NewPusher(named,
 		distributionutil.Config{
 			LayerStore:     layerStore,
@@ -153,6 +202,24 @@ func (d DefaultImageService) Push(imageName string) error {
 	if err != nil {
 		return err
 	}
+
+	dockerprogress.Message(progressChanOut, "", fmt.Sprintf("Start to Push Image %s", named.Raw()))
+	err = pusher.Push(ctx, named)
+	if err == nil {
+		dockerprogress.Message(progressChanOut, "", fmt.Sprintf("Success to Push Image %s", named.CompleteName()))
+	}
+	return err
+}
+
+// displayProgress prints the progress of pulling and pushing to stdout until stop is called.
+func displayProgress() (progressChanOut dockerprogress.Output, stop func()) {
+	var (
+		reader, writer = io.Pipe()
+		writeFlusher   = dockerioutils.NewWriteFlusher(writer)
+		streamOut      = dockerstreams.NewOut(common.StdOut)
+	)
+	progressChanOut = streamformatter.NewJSONProgressOutput(writeFlusher, false)
+
 	go func() {
 		err := dockerjsonmessage.DisplayJSONMessagesToStream(reader, streamOut, nil)
 		// reader may be closed in another goroutine
@@ -162,12 +229,11 @@ func (d DefaultImageService) Push(imageName string) error {
 		}
 	}()
 
-	dockerprogress.Message(progressChanOut, "", fmt.Sprintf("Start to Push Image %s", named.Raw()))
-	err = pusher.Push(context.Background(), named)
-	if err == nil {
-		dockerprogress.Message(progressChanOut, "", fmt.Sprintf("Success to Push Image %s", named.CompleteName()))
+	return progressChanOut, func() {
+		_ = reader.Close()
+		_ = writer.Close()
+		_ = writeFlusher.Close()
 	}
-	return err
 }
 
 // Login login into a registry, for saving auth info in ~/.docker/config.json
@@ -26,7 +26,9 @@ import (
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/image/ocilayout"
 	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/image/signature"
 	"github.com/alibaba/sealer/pkg/image/store"
 	"github.com/alibaba/sealer/pkg/image/types"
 	v1 "github.com/alibaba/sealer/types/api/v1"
@@ -39,9 +41,24 @@ type DefaultImageFileService struct {
 	imageStore store.ImageStore
 }
 
+// Load loads the images of imageSrc, which is a tarball saved by Save, or an
+// OCI image layout directory or tarball.
 func (d DefaultImageFileService) Load(imageSrc string) error {
-	imageMetadata, err := d.load(imageSrc)
-	if err == nil {
+	var (
+		imageMetadatas []types.ImageMetadata
+		err            error
+	)
+	if ocilayout.IsLayout(imageSrc) {
+		imageMetadatas, err = d.loadOCI(imageSrc)
+	} else if isOCI, ociErr := isOCIArchive(imageSrc); ociErr == nil && isOCI {
+		imageMetadatas, err = d.loadOCIArchive(imageSrc)
+	} else {
+		var imageMetadata *types.ImageMetadata
+		if imageMetadata, err = d.load(imageSrc); err == nil {
+			imageMetadatas = append(imageMetadatas, *imageMetadata)
+		}
+	}
+	for _, imageMetadata := range imageMetadatas {
 		logger.Info("load image %s [id: %s] successfully", imageMetadata.Name, imageMetadata.ID)
 	}
 	return err
@@ -121,6 +138,23 @@ func (d DefaultImageFileService) save(image *v1.Image, imageTar string) error {
 	}
 
 	pathsToCompress = append(pathsToCompress, imageMetadataTempFile, repofile)
+
+	// the signatures go with the image, so it is still verified where it is loaded offline.
+	sigs, err := exportSignatures(*image)
+	if err != nil {
+		return err
+	}
+	if len(sigs) > 0 {
+		signaturesFile := filepath.Join(tempDir, common.DefaultSignaturesFileName)
+		sigsJSON, err := json.Marshal(sigs)
+		if err != nil {
+			return err
+		}
+		if err = utils.AtomicWriteFile(signaturesFile, sigsJSON, common.FileMode0644); err != nil {
+			return fmt.Errorf("failed to write temp file %s, err: %v ", signaturesFile, err)
+		}
+		pathsToCompress = append(pathsToCompress, signaturesFile)
+	}
 	tarReader, err := archive.TarWithRootDir(pathsToCompress...)
 	if err != nil {
 		return fmt.Errorf("failed to get tar reader for %s, err: %s", image.Name, err)
@@ -176,10 +210,31 @@ func (d DefaultImageFileService) load(imageSrc string) (*types.ImageMetadata, er
 		return nil, fmt.Errorf("failed to parsing %s, err: %v", imageTempFile, err)
 	}
 
+	var sigs []signature.Signature
+	signaturesFile := filepath.Join(common.DefaultLayerDir, common.DefaultSignaturesFileName)
+	defer os.Remove(signaturesFile)
+	if utils.IsFileExist(signaturesFile) {
+		sigsJSON, err := ioutil.ReadFile(filepath.Clean(signaturesFile))
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(sigsJSON, &sigs); err != nil {
+			return nil, fmt.Errorf("failed to parsing %s, err: %v", signaturesFile, err)
+		}
+	}
+
+	backend, err := store.NewFSStoreBackend()
+	if err != nil {
+		return nil, err
+	}
 	for _, layer := range image.Spec.Layers {
 		if layer.ID == "" {
 			continue
 		}
+		// the layers are extracted with the image, make sure they are the ones in the image which is verified.
+		if err = store.VerifyLayerData(backend, layer.ID); err != nil {
+			return nil, err
+		}
 		// TODO distributionMetadata
 		roLayer, err := store.NewROLayer(layer.ID, size, nil)
 		if err != nil {
@@ -197,5 +252,18 @@ func (d DefaultImageFileService) load(imageSrc string) (*types.ImageMetadata, er
 		return nil, err
 	}
 
-	return &imageMetadata, d.imageStore.Save(image, named.Raw())
+	if err = d.imageStore.Save(image, named.Raw()); err != nil {
+		return nil, err
+	}
+	if imageMetadata.ManifestDigest != "" {
+		saved, err := d.imageStore.GetImageMetadataItem(named.Raw())
+		if err != nil {
+			return nil, err
+		}
+		saved.ManifestDigest = imageMetadata.ManifestDigest
+		if err = d.imageStore.SetImageMetadataItem(saved); err != nil {
+			return nil, err
+		}
+	}
+	return &imageMetadata, importSignatures(image, sigs)
 }
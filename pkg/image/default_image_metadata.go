@@ -18,16 +18,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"sort"
 
-	"github.com/docker/distribution"
-	"github.com/docker/distribution/manifest/schema2"
-
+	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/pkg/image/distributionutil"
 	"github.com/alibaba/sealer/pkg/image/reference"
 	"github.com/alibaba/sealer/pkg/image/store"
 	"github.com/alibaba/sealer/pkg/image/types"
 	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/platform"
 )
 
 //DefaultImageMetadataService provide service for image metadata operations
@@ -46,6 +47,8 @@ func (d DefaultImageMetadataService) Tag(imageName, tarImageName string) error {
 		return err
 	}
 	imageMetadata.Name = named.Raw()
+	// the manifest digest is of the repository the image is pulled from, which the new name is not.
+	imageMetadata.ManifestDigest = ""
 	if err := d.imageStore.SetImageMetadataItem(imageMetadata); err != nil {
 		return fmt.Errorf("failed to add tag %s, %s", tarImageName, err)
 	}
@@ -73,6 +76,37 @@ func (d DefaultImageMetadataService) GetImage(imageName string) (*v1.Image, erro
 	return d.imageStore.GetByName(imageName)
 }
 
+// History lists the layers of the local image from the newest one. The sizes of the layers
+// built by the sealer not recording them are counted on disk.
+func (d DefaultImageMetadataService) History(imageName string) ([]types.ImageHistory, error) {
+	named, err := reference.ParseToNamed(imageName)
+	if err != nil {
+		return nil, err
+	}
+	image, err := d.imageStore.GetByName(named.Raw())
+	if err != nil {
+		return nil, err
+	}
+
+	var history []types.ImageHistory
+	for i := len(image.Spec.Layers) - 1; i >= 0; i-- {
+		layer := image.Spec.Layers[i]
+		h := types.ImageHistory{ID: layer.ID, CreatedBy: layer.Type + " " + layer.Value, Size: layer.Size}
+		if layer.Created != nil {
+			created := layer.Created.Time
+			h.Created = &created
+		}
+		if layer.ID != "" && layer.Size == 0 {
+			h.Size, err = utils.GetFilesSize([]string{filepath.Join(common.DefaultLayerDir, layer.ID.Hex())})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get size of layer %s: %v", layer.ID, err)
+			}
+		}
+		history = append(history, h)
+	}
+	return history, nil
+}
+
 // GetRemoteImage will return the v1.Image from remote registry
 func (d DefaultImageMetadataService) GetRemoteImage(imageName string) (v1.Image, error) {
 	var (
@@ -92,23 +126,12 @@ func (d DefaultImageMetadataService) GetRemoteImage(imageName string) (v1.Image,
 		return v1.Image{}, err
 	}
 
-	ms, err := repo.Manifests(ctx)
-	if err != nil {
-		return v1.Image{}, err
-	}
-
-	manifest, err := ms.Get(ctx, "", distribution.WithTagOption{Tag: named.Tag()})
+	// a manifest list is resolved to the manifest of the local platform.
+	scheme2Manifest, _, err := distributionutil.GetManifest(ctx, repo, named.Tag(), platform.Default())
 	if err != nil {
 		return v1.Image{}, err
 	}
 
-	// just transform it to schema2.DeserializedManifest
-	// because we only upload this kind manifest.
-	scheme2Manifest, ok := manifest.(*schema2.DeserializedManifest)
-	if !ok {
-		return v1.Image{}, fmt.Errorf("failed to parse manifest %s to DeserializedManifest", named.RepoTag())
-	}
-
 	bs := repo.Blobs(ctx)
 	configJSONReader, err := bs.Open(ctx, scheme2Manifest.Config.Digest)
 	if err != nil {
@@ -0,0 +1,345 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/image/distributionutil"
+	"github.com/alibaba/sealer/pkg/image/ocilayout"
+	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/image/signature"
+	"github.com/alibaba/sealer/pkg/image/store"
+	"github.com/alibaba/sealer/pkg/image/types"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/archive"
+	"github.com/alibaba/sealer/utils/platform"
+)
+
+const (
+	// AnnotationImageName is the full name of the CloudImage saved into an OCI image layout,
+	// the ref name annotation only keeps its tag.
+	AnnotationImageName = ocilayout.AnnotationImageName
+	// AnnotationImageSignatures is the signatures of the CloudImage kept locally, a JSON array of signature.Signature.
+	AnnotationImageSignatures = "io.sealer.image.signatures"
+	// annotationContainerdImageName is the image name set by containerd and nerdctl.
+	annotationContainerdImageName = "io.containerd.image.name"
+)
+
+// SaveOCI saves image into the OCI image layout output, which is a tarball
+// if output ends with .tar, otherwise a directory. An existing layout
+// directory keeps the images already in it.
+func (d DefaultImageFileService) SaveOCI(image *v1.Image, output string) error {
+	if output == "" {
+		return fmt.Errorf("output cannot be empty")
+	}
+	named, err := reference.ParseToNamed(image.Name)
+	if err != nil {
+		return err
+	}
+
+	layoutDir := output
+	tarball := filepath.Ext(output) == ".tar"
+	if tarball {
+		if err = utils.MkFileFullPathDir(output); err != nil {
+			return fmt.Errorf("failed to create %s, err: %v", output, err)
+		}
+		if err = os.MkdirAll(common.DefaultLayerTmpDir, common.FileMode0755); err != nil {
+			return err
+		}
+		if layoutDir, err = ioutil.TempDir(common.DefaultLayerTmpDir, "oci-layout"); err != nil {
+			return err
+		}
+		defer utils.CleanDir(layoutDir)
+	}
+
+	layout, err := ocilayout.New(layoutDir)
+	if err != nil {
+		return err
+	}
+	manifest, err := d.writeOCIManifest(layout, image)
+	if err != nil {
+		return err
+	}
+	p := platform.Normalize(image.Spec.Platform)
+	manifest.Platform = &ocispec.Platform{Architecture: p.Architecture, OS: p.OS, OSVersion: p.OSVersion, Variant: p.Variant}
+	manifest.Annotations = map[string]string{
+		ocispec.AnnotationRefName: named.Tag(),
+		AnnotationImageName:       named.Raw(),
+	}
+	// the signatures go with the image, so it is still verified where it is loaded offline.
+	sigs, err := exportSignatures(*image)
+	if err != nil {
+		return err
+	}
+	if len(sigs) > 0 {
+		sigsJSON, err := json.Marshal(sigs)
+		if err != nil {
+			return err
+		}
+		manifest.Annotations[AnnotationImageSignatures] = string(sigsJSON)
+	}
+	if err = layout.AddManifest(manifest); err != nil {
+		return err
+	}
+	if !tarball {
+		return nil
+	}
+
+	tarReader, err := archive.TarWithoutRootDir(layoutDir)
+	if err != nil {
+		return fmt.Errorf("failed to get tar reader for %s, err: %s", layoutDir, err)
+	}
+	defer tarReader.Close()
+	file, err := os.Create(filepath.Clean(output))
+	if err != nil {
+		return fmt.Errorf("failed to create %s, err: %v", output, err)
+	}
+	defer file.Close()
+	_, err = io.Copy(file, tarReader)
+	return err
+}
+
+// writeOCIManifest writes the layers, config and manifest of image into layout.
+func (d DefaultImageFileService) writeOCIManifest(layout *ocilayout.Layout, image *v1.Image) (ocispec.Descriptor, error) {
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+	}
+	for _, l := range image.Spec.Layers {
+		if l.ID == "" {
+			continue
+		}
+		roLayer := d.layerStore.Get(store.LayerID(l.ID))
+		if roLayer == nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to save image %s, layer %s not exists locally", image.Name, l.ID)
+		}
+		desc, err := writeOCILayer(layout, roLayer)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to save layer %s, err: %v", l.ID, err)
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+
+	configJSON, err := distributionutil.MarshalImageConfig(*image)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if manifest.Config, err = layout.WriteBlob(bytes.NewReader(configJSON), ocispec.MediaTypeImageConfig); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return layout.WriteJSON(manifest, ocispec.MediaTypeImageManifest)
+}
+
+func writeOCILayer(layout *ocilayout.Layout, roLayer store.Layer) (ocispec.Descriptor, error) {
+	layerContentStream, err := roLayer.TarStream()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	defer layerContentStream.Close()
+	compressed, _ := archive.GzipCompress(layerContentStream)
+	defer compressed.Close()
+	return layout.WriteBlob(compressed, ocispec.MediaTypeImageLayerGzip)
+}
+
+// isOCIArchive reports whether the tarball imageSrc is an OCI image layout.
+func isOCIArchive(imageSrc string) (bool, error) {
+	f, err := os.Open(filepath.Clean(imageSrc))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		switch filepath.Clean(hdr.Name) {
+		case ocispec.ImageLayoutFile:
+			return true, nil
+		case common.DefaultMetadataName, common.DefaultImageMetadataFileName, common.DefaultSignaturesFileName:
+			return false, nil
+		}
+	}
+}
+
+// loadOCIArchive loads the images of the OCI image layout tarball imageSrc.
+func (d DefaultImageFileService) loadOCIArchive(imageSrc string) ([]types.ImageMetadata, error) {
+	f, err := os.Open(filepath.Clean(imageSrc))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s, err : %v", imageSrc, err)
+	}
+	defer f.Close()
+
+	if err = os.MkdirAll(common.DefaultLayerTmpDir, common.FileMode0755); err != nil {
+		return nil, err
+	}
+	layoutDir, err := ioutil.TempDir(common.DefaultLayerTmpDir, "oci-layout")
+	if err != nil {
+		return nil, err
+	}
+	defer utils.CleanDir(layoutDir)
+	if _, err = archive.Untar(f, layoutDir); err != nil {
+		return nil, fmt.Errorf("failed to extract %s, err: %v", imageSrc, err)
+	}
+	return d.loadOCI(layoutDir)
+}
+
+// loadOCI loads the images of the OCI image layout directory layoutDir.
+func (d DefaultImageFileService) loadOCI(layoutDir string) ([]types.ImageMetadata, error) {
+	layout, err := ocilayout.Open(layoutDir)
+	if err != nil {
+		return nil, err
+	}
+	index, err := layout.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index of %s: %v", layoutDir, err)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, fmt.Errorf("no image in %s", layoutDir)
+	}
+
+	var loaded []types.ImageMetadata
+	for _, desc := range index.Manifests {
+		metadata, err := d.loadOCIManifest(layout, desc)
+		if err != nil {
+			return loaded, err
+		}
+		loaded = append(loaded, *metadata)
+	}
+	return loaded, nil
+}
+
+func (d DefaultImageFileService) loadOCIManifest(layout *ocilayout.Layout, desc ocispec.Descriptor) (*types.ImageMetadata, error) {
+	if desc.MediaType != ocispec.MediaTypeImageManifest && desc.MediaType != schema2.MediaTypeManifest {
+		return nil, fmt.Errorf("unsupported manifest %s of media type %s", desc.Digest, desc.MediaType)
+	}
+	name := ociImageName(desc.Annotations)
+	if name == "" {
+		return nil, fmt.Errorf("manifest %s has no image name in annotations %s or %s",
+			desc.Digest, AnnotationImageName, ocispec.AnnotationRefName)
+	}
+	named, err := reference.ParseToNamed(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest ocispec.Manifest
+	if err = layout.ReadJSON(desc, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to read manifest of %s: %v", named.Raw(), err)
+	}
+	var image v1.Image
+	if err = layout.ReadJSON(manifest.Config, &image); err != nil {
+		return nil, fmt.Errorf("failed to read config of %s: %v", named.Raw(), err)
+	}
+	if image.Spec.ID == "" {
+		return nil, fmt.Errorf("%s is not a CloudImage", named.Raw())
+	}
+
+	var layers []v1.Layer
+	for _, l := range image.Spec.Layers {
+		if l.ID != "" {
+			layers = append(layers, l)
+		}
+	}
+	// number of non-empty layer and layer in manifest should be equal
+	if len(layers) != len(manifest.Layers) {
+		return nil, fmt.Errorf("the number layerIDs %d and LayerDescriptor %d are mismatch", len(layers), len(manifest.Layers))
+	}
+	for i, l := range layers {
+		if err = d.loadOCILayer(layout, l, manifest.Layers[i]); err != nil {
+			return nil, fmt.Errorf("failed to load layer %s of %s: %v", l.ID, named.Raw(), err)
+		}
+	}
+
+	if err = d.imageStore.Save(image, named.Raw()); err != nil {
+		return nil, err
+	}
+	if sigsJSON := desc.Annotations[AnnotationImageSignatures]; sigsJSON != "" {
+		var sigs []signature.Signature
+		if err = json.Unmarshal([]byte(sigsJSON), &sigs); err != nil {
+			return nil, fmt.Errorf("failed to parse signatures of %s: %v", named.Raw(), err)
+		}
+		if err = importSignatures(image, sigs); err != nil {
+			return nil, err
+		}
+	}
+	return &types.ImageMetadata{Name: named.Raw(), ID: image.Spec.ID}, nil
+}
+
+func (d DefaultImageFileService) loadOCILayer(layout *ocilayout.Layout, layer v1.Layer, desc ocispec.Descriptor) error {
+	if d.layerStore.Get(store.LayerID(layer.ID)) != nil {
+		return nil
+	}
+	backend, err := store.NewFSStoreBackend()
+	if err != nil {
+		return err
+	}
+	blob, err := layout.OpenBlob(desc)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	size, err := store.DecompressLayer(backend, layer.ID, blob, strings.HasSuffix(desc.MediaType, "gzip"))
+	if err == nil {
+		// read to the end so that the digest of blob is verified.
+		_, err = io.Copy(ioutil.Discard, blob)
+	}
+	if err != nil {
+		_ = os.RemoveAll(backend.LayerDataDir(layer.ID))
+		return err
+	}
+
+	roLayer, err := store.NewROLayer(layer.ID, size, nil)
+	if err != nil {
+		return err
+	}
+	return d.layerStore.RegisterLayerIfNotPresent(roLayer)
+}
+
+// ociImageName returns the image name in the annotations of a manifest in
+// index.json, a ref name which is only a tag is not a name.
+func ociImageName(annotations map[string]string) string {
+	for _, key := range []string{AnnotationImageName, annotationContainerdImageName} {
+		if name := annotations[key]; name != "" {
+			return name
+		}
+	}
+	if name := annotations[ocispec.AnnotationRefName]; strings.ContainsAny(name, ":/") {
+		return name
+	}
+	logger.Debug("ignore ref name %q which is not an image name", annotations[ocispec.AnnotationRefName])
+	return ""
+}
@@ -0,0 +1,173 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package image
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/image/ocilayout"
+	"github.com/alibaba/sealer/pkg/image/store"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func newTestImageFileService(t *testing.T) DefaultImageFileService {
+	common.SetDataRoot(t.TempDir())
+	for _, dir := range []string{common.DefaultLayerDBRoot, common.DefaultLayerDir, common.DefaultTmpDir} {
+		if err := os.MkdirAll(dir, common.FileMode0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	layerStore, err := store.NewDefaultLayerStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imageStore, err := store.NewDefaultImageStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return DefaultImageFileService{layerStore: layerStore, imageStore: imageStore}
+}
+
+func TestDefaultImageFileService_OCI(t *testing.T) {
+	var (
+		output   = t.TempDir()
+		name     = "registry.example.com/sealer/kubernetes:v1.19.8"
+		layerDir = func(layerID digest.Digest) string { return filepath.Join(common.DefaultLayerDir, layerID.Hex()) }
+	)
+	defer common.SetDataRoot(common.DefaultDataRoot)
+
+	d := newTestImageFileService(t)
+	layerSrc := filepath.Join(t.TempDir(), "layer")
+	if err := os.MkdirAll(filepath.Join(layerSrc, "manifests"), common.FileMode0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(layerSrc, "manifests", "dashboard.yaml"), []byte("kind: Deployment"), common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	// the layer id is the digest of its content, which is checked when it is loaded.
+	layerID, err := d.layerStore.RegisterLayerForBuilder(layerSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := &v1.Image{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.ImageSpec{
+			ID:       "kubernetes",
+			Layers:   []v1.Layer{{Type: "CMD", Value: "kubectl get nodes"}, {ID: layerID, Type: "COPY", Value: "manifests manifests"}},
+			Platform: v1.Platform{OS: "linux", Architecture: "arm64"},
+		},
+	}
+
+	layoutDir := filepath.Join(output, "kubernetes-oci")
+	layoutTar := filepath.Join(output, "kubernetes-oci.tar")
+	for _, o := range []string{layoutDir, layoutTar} {
+		if err = d.SaveOCI(image, o); err != nil {
+			t.Fatalf("failed to save image to %s: %v", o, err)
+		}
+	}
+
+	layout, err := ocilayout.Open(layoutDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := layout.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 1 {
+		t.Fatalf("got %d manifests in index, want 1", len(index.Manifests))
+	}
+	desc := index.Manifests[0]
+	if desc.Annotations[ocispec.AnnotationRefName] != "v1.19.8" || desc.Platform == nil || desc.Platform.Architecture != "arm64" {
+		t.Errorf("unexpected manifest descriptor %+v", desc)
+	}
+
+	for _, src := range []string{layoutDir, layoutTar} {
+		d = newTestImageFileService(t)
+		if err = d.Load(src); err != nil {
+			t.Fatalf("failed to load image from %s: %v", src, err)
+		}
+		loaded, err := d.imageStore.GetByName(name)
+		if err != nil {
+			t.Fatalf("image loaded from %s not found: %v", src, err)
+		}
+		if loaded.Spec.ID != image.Spec.ID || len(loaded.Spec.Layers) != 2 {
+			t.Errorf("loaded image %+v does not match saved one", loaded.Spec)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(layerDir(layerID), "manifests", "dashboard.yaml"))
+		if err != nil || string(data) != "kind: Deployment" {
+			t.Errorf("layer file loaded from %s is %q, %v", src, data, err)
+		}
+	}
+}
+
+func TestDefaultImageFileService_OCITwoImagesOfSameTag(t *testing.T) {
+	layoutDir := filepath.Join(t.TempDir(), "images-oci")
+	defer common.SetDataRoot(common.DefaultDataRoot)
+
+	d := newTestImageFileService(t)
+	names := []string{"registry.example.com/sealer/kubernetes:v1", "registry.example.com/sealer/calico:v1"}
+	for i, name := range names {
+		image := &v1.Image{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1.ImageSpec{
+				ID:       fmt.Sprintf("image%d", i),
+				Layers:   []v1.Layer{{Type: "CMD", Value: "kubectl get nodes"}},
+				Platform: v1.Platform{OS: "linux", Architecture: "amd64"},
+			},
+		}
+		if err := d.SaveOCI(image, layoutDir); err != nil {
+			t.Fatalf("failed to save %s: %v", name, err)
+		}
+	}
+
+	d = newTestImageFileService(t)
+	if err := d.Load(layoutDir); err != nil {
+		t.Fatalf("failed to load images: %v", err)
+	}
+	for _, name := range names {
+		if _, err := d.imageStore.GetByName(name); err != nil {
+			t.Errorf("image %s should be kept in the layout: %v", name, err)
+		}
+	}
+}
+
+func TestOCIImageName(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+	}{
+		{"sealer name", map[string]string{AnnotationImageName: "kubernetes:v1.19.8", ocispec.AnnotationRefName: "v1.19.8"}, "kubernetes:v1.19.8"},
+		{"containerd name", map[string]string{annotationContainerdImageName: "docker.io/library/kubernetes:v1.19.8"}, "docker.io/library/kubernetes:v1.19.8"},
+		{"full ref name", map[string]string{ocispec.AnnotationRefName: "kubernetes:v1.19.8"}, "kubernetes:v1.19.8"},
+		{"tag only", map[string]string{ocispec.AnnotationRefName: "v1.19.8"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ociImageName(tt.annotations); got != tt.want {
+				t.Errorf("ociImageName() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package image
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/image/distributionutil"
+	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/image/signature"
+	"github.com/alibaba/sealer/pkg/image/store"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+// DefaultSignatureService signs local images, and verifies them
+// by the trust policy at common.DefaultTrustPolicyFile.
+type DefaultSignatureService struct {
+	imageStore store.ImageStore
+}
+
+func (d DefaultSignatureService) Sign(ctx context.Context, imageName, keyPath string, push bool) error {
+	named, err := reference.ParseToNamed(imageName)
+	if err != nil {
+		return err
+	}
+	key, err := signature.LoadPrivateKey(keyPath)
+	if err != nil {
+		return err
+	}
+	dgst, err := imageDigest(d.imageStore, named)
+	if err != nil {
+		return err
+	}
+
+	sig, err := signature.Sign(key, repositoryOf(named), dgst)
+	if err != nil {
+		return fmt.Errorf("failed to sign image %s: %v", named.Raw(), err)
+	}
+	if err = signature.NewDefaultStore().Add(dgst, sig); err != nil {
+		return fmt.Errorf("failed to save signature of %s: %v", named.Raw(), err)
+	}
+	logger.Info("image %s@%s is signed by key %s", named.Raw(), dgst, sig.KeyID)
+
+	if !push {
+		return nil
+	}
+	repo, err := distributionutil.NewV2Repository(named, "push", "pull")
+	if err != nil {
+		return err
+	}
+	if err = distributionutil.PushSignatures(ctx, repo, dgst, sig); err != nil {
+		return err
+	}
+	logger.Info("signature of %s is pushed to %s:%s", named.Raw(), repositoryOf(named), distributionutil.SignatureTag(dgst))
+	return nil
+}
+
+// imageDigest returns the digest of the local image, which is computed locally so that the images
+// built or loaded without a registry could be signed and verified as well.
+func imageDigest(imageStore store.ImageStore, named reference.Named) (digest.Digest, error) {
+	image, err := imageStore.GetByName(named.Raw())
+	if err != nil {
+		return "", fmt.Errorf("failed to find image %s: %v", named.Raw(), err)
+	}
+	return distributionutil.ImageDigest(*image)
+}
+
+func (d DefaultSignatureService) Verify(imageName string) error {
+	named, err := reference.ParseToNamed(imageName)
+	if err != nil {
+		return err
+	}
+	return verifyLocalImage(d.imageStore, named)
+}
+
+// verifyLocalImage checks the local image by the trust policy with the signatures kept locally,
+// so the images pulled before are still verified offline.
+func verifyLocalImage(imageStore store.ImageStore, named reference.Named) error {
+	policy, err := signature.LoadPolicy(common.DefaultTrustPolicyFile)
+	if err != nil {
+		return err
+	}
+	var (
+		repository = repositoryOf(named)
+		dgst       digest.Digest
+		sigs       []signature.Signature
+	)
+	if policy.RequiresSignature(repository) {
+		if dgst, err = imageDigest(imageStore, named); err != nil {
+			return err
+		}
+		if sigs, err = signature.NewDefaultStore().Get(dgst); err != nil {
+			return err
+		}
+	}
+	if _, err = policy.Verify(repository, dgst, sigs); err != nil {
+		return fmt.Errorf("failed to verify image %s: %v", named.Raw(), err)
+	}
+	return nil
+}
+
+// exportSignatures returns the signatures of image kept locally, to be saved together with it.
+func exportSignatures(image v1.Image) ([]signature.Signature, error) {
+	dgst, err := distributionutil.ImageDigest(image)
+	if err != nil {
+		return nil, err
+	}
+	return signature.NewDefaultStore().Get(dgst)
+}
+
+// importSignatures keeps the signatures saved together with image, they are checked when the image is verified.
+func importSignatures(image v1.Image, sigs []signature.Signature) error {
+	if len(sigs) == 0 {
+		return nil
+	}
+	dgst, err := distributionutil.ImageDigest(image)
+	if err != nil {
+		return err
+	}
+	return signature.NewDefaultStore().Add(dgst, sigs...)
+}
+
+// repositoryOf returns the image name without tag, which signatures are made for.
+func repositoryOf(named reference.Named) string {
+	return named.Domain() + "/" + named.Repo()
+}
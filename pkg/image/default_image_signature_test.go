@@ -0,0 +1,138 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package image
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/image/signature"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func writeTestPolicy(t *testing.T, dir string) (privatePath string) {
+	privatePath, publicPath, err := signature.GenerateKeyPair(filepath.Join(dir, "sealer-io"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.DefaultTrustPolicyFile = filepath.Join(dir, "policy.json")
+	policy := fmt.Sprintf(`{"default":{"type":"accept"},"images":{"registry.cn-qingdao.aliyuncs.com/sealer-io":{"type":"signed","keys":[%q]}}}`, publicPath)
+	if err = ioutil.WriteFile(common.DefaultTrustPolicyFile, []byte(policy), common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath
+}
+
+func TestDefaultSignatureService_Verify(t *testing.T) {
+	var (
+		signed    = "registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8"
+		unsigned  = "registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.20.4"
+		tagged    = "registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:latest"
+		retagged  = "registry.cn-qingdao.aliyuncs.com/sealer-io/k8s:v1.19.8"
+		accepted  = "docker.io/library/kubernetes:v1.19.8"
+		keyDir    = t.TempDir()
+		imageName = map[string]string{signed: "v1.19.8", unsigned: "v1.20.4", accepted: "library-v1.19.8"}
+	)
+	defer func(policyFile string) {
+		common.DefaultTrustPolicyFile = policyFile
+		common.SetDataRoot(common.DefaultDataRoot)
+	}(common.DefaultTrustPolicyFile)
+
+	fs := newTestImageFileService(t)
+	ss := DefaultSignatureService{imageStore: fs.imageStore}
+	ims := DefaultImageMetadataService{imageStore: fs.imageStore}
+	for name, id := range imageName {
+		image := v1.Image{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: v1.ImageSpec{ID: id}}
+		if err := fs.imageStore.Save(image, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	privatePath := writeTestPolicy(t, keyDir)
+	// the image is signed offline, without its manifest in a registry.
+	if err := ss.Sign(context.Background(), signed, privatePath, false); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	for _, name := range []string{tagged, retagged} {
+		if err := ims.Tag(signed, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		image   string
+		wantErr bool
+	}{
+		{signed, false},
+		{unsigned, true},
+		{tagged, false},
+		// the signature is made for the repository kubernetes.
+		{retagged, true},
+		{accepted, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if err := ss.Verify(tt.image); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignaturesSavedWithImage(t *testing.T) {
+	var (
+		name   = "registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8"
+		output = t.TempDir()
+		image  = &v1.Image{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1.ImageSpec{ID: "kubernetes", Layers: []v1.Layer{{Type: "CMD", Value: "kubectl get nodes"}}},
+		}
+	)
+	defer func(policyFile string) {
+		common.DefaultTrustPolicyFile = policyFile
+		common.SetDataRoot(common.DefaultDataRoot)
+	}(common.DefaultTrustPolicyFile)
+
+	d := newTestImageFileService(t)
+	if err := d.imageStore.Save(*image, name); err != nil {
+		t.Fatal(err)
+	}
+	privatePath := writeTestPolicy(t, t.TempDir())
+	if err := (DefaultSignatureService{imageStore: d.imageStore}).Sign(context.Background(), name, privatePath, false); err != nil {
+		t.Fatal(err)
+	}
+	tarball := filepath.Join(output, "kubernetes.tar")
+	layoutDir := filepath.Join(output, "kubernetes-oci")
+	if err := d.Save(image, tarball); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SaveOCI(image, layoutDir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, src := range []string{tarball, layoutDir} {
+		d = newTestImageFileService(t)
+		if err := d.Load(src); err != nil {
+			t.Fatalf("failed to load image from %s: %v", src, err)
+		}
+		if err := (DefaultSignatureService{imageStore: d.imageStore}).Verify(name); err != nil {
+			t.Errorf("image loaded from %s is not verified: %v", src, err)
+		}
+	}
+}
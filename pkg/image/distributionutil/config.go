@@ -20,13 +20,21 @@ import (
 	"github.com/docker/docker/pkg/progress"
 
 	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/image/signature"
 	"github.com/alibaba/sealer/pkg/image/store"
+	v1 "github.com/alibaba/sealer/types/api/v1"
 )
 
 type Config struct {
 	LayerStore     store.LayerStore
 	ProgressOutput progress.Output
 	Named          reference.Named
+	// Platform selects the manifest to pull from a manifest list,
+	// the platform sealer runs on is used if it is empty.
+	Platform v1.Platform
+	// Policy verifies the signatures of the manifest before the layers are pulled,
+	// nothing is verified if it is nil.
+	Policy *signature.Policy
 }
 
 type registryConfig struct {
@@ -0,0 +1,133 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package distributionutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils/platform"
+)
+
+// PlatformImage is a local image to be pushed as an entry of a manifest list.
+type PlatformImage struct {
+	Name     string
+	Image    v1.Image
+	Platform v1.Platform
+}
+
+// GetManifest gets the schema2 manifest of tag from repo. If tag refers to a
+// manifest list or an OCI image index, the manifest built for want is picked.
+// The digest of the returned manifest is what signatures are made over.
+func GetManifest(ctx context.Context, repo distribution.Repository, tag string, want v1.Platform) (schema2.Manifest, digest.Digest, error) {
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		return schema2.Manifest{}, "", err
+	}
+
+	manifest, err := ms.Get(ctx, "", distribution.WithTagOption{Tag: tag})
+	if err != nil {
+		return schema2.Manifest{}, "", err
+	}
+	_, payload, err := manifest.Payload()
+	if err != nil {
+		return schema2.Manifest{}, "", err
+	}
+	dgst := digest.FromBytes(payload)
+
+	if list, ok := manifest.(*manifestlist.DeserializedManifestList); ok {
+		descriptor, err := selectManifest(list, want)
+		if err != nil {
+			return schema2.Manifest{}, "", fmt.Errorf("failed to select manifest of %s:%s: %v", repo.Named().Name(), tag, err)
+		}
+		logger.Debug("select manifest %s of %s:%s for platform %s", descriptor.Digest, repo.Named().Name(), tag, platform.ToString(want))
+		manifest, err = ms.Get(ctx, descriptor.Digest)
+		if err != nil {
+			return schema2.Manifest{}, "", err
+		}
+		if _, payload, err = manifest.Payload(); err != nil {
+			return schema2.Manifest{}, "", err
+		}
+		if dgst = digest.FromBytes(payload); dgst != descriptor.Digest {
+			return schema2.Manifest{}, "", fmt.Errorf("manifest %s of %s:%s is corrupted, got digest %s", descriptor.Digest, repo.Named().Name(), tag, dgst)
+		}
+	}
+
+	switch m := manifest.(type) {
+	case *schema2.DeserializedManifest:
+		return m.Manifest, dgst, nil
+	case *ocischema.DeserializedManifest:
+		// copied into the registry from an OCI image layout, the descriptors are all we need.
+		return schema2.Manifest{Versioned: m.Versioned, Config: m.Config, Layers: m.Layers}, dgst, nil
+	default:
+		return schema2.Manifest{}, "", fmt.Errorf("failed to parse manifest %s:%s to DeserializedManifest", repo.Named().Name(), tag)
+	}
+}
+
+// selectManifest picks the entry of list built for want.
+func selectManifest(list *manifestlist.DeserializedManifestList, want v1.Platform) (distribution.Descriptor, error) {
+	var available []string
+	for _, m := range list.Manifests {
+		got := v1.Platform{
+			OS:           m.Platform.OS,
+			Architecture: m.Platform.Architecture,
+			OSVersion:    m.Platform.OSVersion,
+			Variant:      m.Platform.Variant,
+		}
+		if platform.Matches(want, got) {
+			return m.Descriptor, nil
+		}
+		available = append(available, platform.ToString(platform.Normalize(got)))
+	}
+	return distribution.Descriptor{}, fmt.Errorf("no manifest for platform %s, available platforms: [%s]",
+		platform.ToString(platform.Normalize(want)), strings.Join(available, ", "))
+}
+
+// buildManifestList assembles the manifest list of the pushed manifests.
+func buildManifestList(descriptors []distribution.Descriptor, images []PlatformImage) (*manifestlist.DeserializedManifestList, error) {
+	if len(descriptors) != len(images) {
+		return nil, fmt.Errorf("the number of manifests %d and images %d are mismatch", len(descriptors), len(images))
+	}
+	var (
+		manifests = make([]manifestlist.ManifestDescriptor, 0, len(images))
+		platforms = map[string]string{}
+	)
+	for i, image := range images {
+		p := platform.Normalize(image.Platform)
+		key := platform.ToString(p)
+		if name, ok := platforms[key]; ok {
+			return nil, fmt.Errorf("images %s and %s are both built for platform %s", name, image.Name, key)
+		}
+		platforms[key] = image.Name
+		manifests = append(manifests, manifestlist.ManifestDescriptor{
+			Descriptor: descriptors[i],
+			Platform: manifestlist.PlatformSpec{
+				Architecture: p.Architecture,
+				OS:           p.OS,
+				OSVersion:    p.OSVersion,
+				Variant:      p.Variant,
+			},
+		})
+	}
+	return manifestlist.FromDescriptors(manifests)
+}
@@ -0,0 +1,72 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package distributionutil
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestManifestListSelect(t *testing.T) {
+	var (
+		amd64 = buildBlobs(digest.FromString("amd64"), 100, schema2.MediaTypeManifest)
+		arm64 = buildBlobs(digest.FromString("arm64"), 200, schema2.MediaTypeManifest)
+	)
+	list, err := buildManifestList([]distribution.Descriptor{amd64, arm64}, []PlatformImage{
+		{Name: "kubernetes:amd64", Platform: v1.Platform{}},
+		{Name: "kubernetes:arm64", Platform: v1.Platform{OS: "linux", Architecture: "aarch64"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to build manifest list: %v", err)
+	}
+	if list.MediaType != manifestlist.MediaTypeManifestList {
+		t.Errorf("media type of manifest list is %s, want %s", list.MediaType, manifestlist.MediaTypeManifestList)
+	}
+
+	tests := []struct {
+		name    string
+		want    v1.Platform
+		digest  digest.Digest
+		wantErr bool
+	}{
+		{"select amd64", v1.Platform{OS: "linux", Architecture: "amd64"}, amd64.Digest, false},
+		{"select arm64 by uname", v1.Platform{OS: "linux", Architecture: "aarch64"}, arm64.Digest, false},
+		{"no manifest for ppc64le", v1.Platform{OS: "linux", Architecture: "ppc64le"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectManifest(list, tt.want)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Digest != tt.digest {
+				t.Errorf("selectManifest() = %s, want %s", got.Digest, tt.digest)
+			}
+		})
+	}
+
+	_, err = buildManifestList([]distribution.Descriptor{amd64, arm64}, []PlatformImage{
+		{Name: "kubernetes:a", Platform: v1.Platform{OS: "linux", Architecture: "amd64"}},
+		{Name: "kubernetes:b", Platform: v1.Platform{OS: "linux", Architecture: "x86_64"}},
+	})
+	if err == nil {
+		t.Errorf("images of the same platform should not be in one manifest list")
+	}
+}
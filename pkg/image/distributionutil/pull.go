@@ -19,22 +19,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/schema2"
+	dockerTransport "github.com/docker/distribution/registry/client/transport"
 	"github.com/docker/docker/pkg/progress"
+	"github.com/docker/go-units"
 	"github.com/opencontainers/go-digest"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sys/unix"
 
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
 	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/image/signature"
 	"github.com/alibaba/sealer/pkg/image/store"
 	v1 "github.com/alibaba/sealer/types/api/v1"
-	"github.com/alibaba/sealer/utils/archive"
+	"github.com/alibaba/sealer/utils/platform"
 )
 
+// blobLockInterval is how often a pull checks whether the download locked by another pull is released.
+var blobLockInterval = 100 * time.Millisecond
+
 type Puller interface {
-	Pull(context context.Context, named reference.Named) (*v1.Image, error)
+	// Pull returns the pulled image and the digest of its manifest.
+	Pull(context context.Context, named reference.Named) (*v1.Image, digest.Digest, error)
 }
 
 type ImagePuller struct {
@@ -42,24 +54,26 @@ type ImagePuller struct {
 	repository distribution.Repository
 }
 
-func (puller *ImagePuller) Pull(ctx context.Context, named reference.Named) (*v1.Image, error) {
+func (puller *ImagePuller) Pull(ctx context.Context, named reference.Named) (*v1.Image, digest.Digest, error) {
 	var (
 		layerStore = puller.config.LayerStore
 		layers     = []v1.Layer{}
-		eg         *errgroup.Group
+		limit      = newLimiter()
 	)
 
-	manifest, err := puller.getRemoteManifest(ctx, named)
+	manifest, manifestDigest, err := puller.getRemoteManifest(ctx, named)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
 	v1Image, err := puller.getRemoteImageMetadata(ctx, manifest.Config.Digest)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if err = puller.verifySignatures(ctx, named, v1Image); err != nil {
+		return nil, "", err
 	}
 
-	eg, _ = errgroup.WithContext(context.Background())
+	eg, egCtx := errgroup.WithContext(ctx)
 	for _, l := range v1Image.Spec.Layers {
 		if l.ID == "" {
 			continue
@@ -68,7 +82,7 @@ func (puller *ImagePuller) Pull(ctx context.Context, named reference.Named) (*v1
 	}
 	// number of non-empty layer and layer in distribution should be equal
 	if len(layers) != len(manifest.Layers) {
-		return nil, fmt.Errorf("the number layerIDs %d and LayerDescriptor %d are mismatch", len(layers), len(manifest.Layers))
+		return nil, "", fmt.Errorf("the number layerIDs %d and LayerDescriptor %d are mismatch", len(layers), len(manifest.Layers))
 	}
 
 	for i, l := range manifest.Layers {
@@ -80,6 +94,16 @@ func (puller *ImagePuller) Pull(ctx context.Context, named reference.Named) (*v1
 		// we take hash of layer as real layer id,  hash of descriptor is just
 		// a identifier for remote data
 		eg.Go(func() error {
+			if layerErr := limit.acquire(egCtx); layerErr != nil {
+				return layerErr
+			}
+			defer limit.release()
+			// the download of the layer is shared by the concurrent pulls, which take it in turn.
+			unlock, layerErr := lockBlob(egCtx, descriptor.Digest)
+			if layerErr != nil {
+				return layerErr
+			}
+			defer unlock()
 			// roLayer now does not exist, new one
 			// descriptor.Size is temp size for this layer
 			// real size will be set within downloadLayer
@@ -90,7 +114,7 @@ func (puller *ImagePuller) Pull(ctx context.Context, named reference.Named) (*v1
 				return layerErr
 			}
 
-			layerErr = puller.downloadLayer(ctx, roLayer, descriptor)
+			layerErr = puller.downloadLayer(egCtx, roLayer, descriptor)
 			if layerErr != nil {
 				return layerErr
 			}
@@ -100,17 +124,16 @@ func (puller *ImagePuller) Pull(ctx context.Context, named reference.Named) (*v1
 	}
 	err = eg.Wait()
 	if err != nil {
-		return nil, fmt.Errorf("failed to pull image %s, err: %s", named.Raw(), err)
+		return nil, "", fmt.Errorf("failed to pull image %s, err: %s", named.Raw(), err)
 	}
 
-	return &v1Image, nil
+	return &v1Image, manifestDigest, nil
 }
 
 func (puller *ImagePuller) downloadLayer(ctx context.Context, layer store.Layer, descriptor distribution.Descriptor) error {
 	var (
 		layerStore  = puller.config.LayerStore
 		progressOut = puller.config.ProgressOutput
-		repo        = puller.repository
 	)
 	backend, err := store.NewFSStoreBackend()
 	if err != nil {
@@ -124,48 +147,180 @@ func (puller *ImagePuller) downloadLayer(ctx context.Context, layer store.Layer,
 		return nil
 	}
 
-	bs := repo.Blobs(ctx)
-	layerReader, err := bs.Open(ctx, descriptor.Digest)
+	blobPath := downloadPath(descriptor.Digest)
+	err = retry(ctx, layer.SimpleID(), progressOut, func() error {
+		return puller.fetchBlob(ctx, layer, descriptor, blobPath)
+	})
 	if err != nil {
+		progress.Update(progressOut, layer.SimpleID(), err.Error())
 		return err
 	}
-	defer layerReader.Close()
 
-	digester := digest.Canonical.Digester()
-	layerDownloadReader := ioutil.NopCloser(io.TeeReader(layerReader, digester.Hash()))
-	progressReader := progress.NewProgressReader(layerDownloadReader, progressOut, descriptor.Size, layer.SimpleID(), "pulling")
-	size, err := archive.Decompress(progressReader, backend.LayerDataDir(layer.ID().ToDigest()), archive.Options{Compress: true})
+	blob, err := os.Open(filepath.Clean(blobPath))
 	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	progress.Update(progressOut, layer.SimpleID(), "extracting")
+	// the blob is checked against the manifest which is not signed, the layer id in the verified image config is checked here.
+	size, err := store.DecompressLayer(backend, layer.ID().ToDigest(), blob, true)
+	if err != nil {
+		// the blob is kept, extracting starts over next time.
 		progress.Update(progressOut, layer.SimpleID(), err.Error())
 		return err
 	}
 	// update rolayer size for storing the info under layerdb
 	layer.SetSize(size)
+	if err = os.Remove(blobPath); err != nil {
+		logger.Warn("failed to remove downloaded blob %s: %v", blobPath, err)
+	}
+	progress.Update(progressOut, layer.SimpleID(), "pull completed")
+	return nil
+}
+
+func downloadPath(dgst digest.Digest) string {
+	return filepath.Join(common.DefaultLayerTmpDir, "downloads", dgst.Hex())
+}
+
+// lockBlob locks the download of dgst against the other pulls, in this process or not, until unlock is called.
+// The lock is released by the kernel if the process exits, the lock file is left for the next pull.
+func lockBlob(ctx context.Context, dgst digest.Digest) (unlock func(), err error) {
+	lockPath := downloadPath(dgst) + ".lock"
+	if err = os.MkdirAll(filepath.Dir(lockPath), common.FileMode0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Clean(lockPath), os.O_CREATE|os.O_RDWR, common.FileMode0644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			return func() { _ = f.Close() }, nil
+		}
+		if err != unix.EWOULDBLOCK {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %v", lockPath, err)
+		}
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+			return nil, ctx.Err()
+		case <-time.After(blobLockInterval):
+		}
+	}
+}
+
+// fetchBlob downloads the blob of descriptor to blobPath, resuming from the
+// bytes a previous attempt left there by a ranged request.
+func (puller *ImagePuller) fetchBlob(ctx context.Context, layer store.Layer, descriptor distribution.Descriptor, blobPath string) error {
+	progressOut := puller.config.ProgressOutput
+	if err := os.MkdirAll(filepath.Dir(blobPath), common.FileMode0755); err != nil {
+		return err
+	}
+	blob, err := os.OpenFile(filepath.Clean(blobPath), os.O_CREATE|os.O_RDWR, common.FileMode0644)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	// hash what is downloaded, which also moves the offset to the end of blob.
+	digester := digest.Canonical.Digester()
+	offset, err := io.Copy(digester.Hash(), blob)
+	if err != nil {
+		return err
+	}
+	if offset > descriptor.Size {
+		if offset, err = truncate(blob); err != nil {
+			return err
+		}
+		digester = digest.Canonical.Digester()
+	}
+
+	if offset < descriptor.Size {
+		layerReader, err := puller.repository.Blobs(ctx).Open(ctx, descriptor.Digest)
+		if err != nil {
+			return err
+		}
+		defer layerReader.Close()
+
+		if offset > 0 {
+			progress.Updatef(progressOut, layer.SimpleID(), "Resuming from %s", units.HumanSize(float64(offset)))
+			if _, err = layerReader.Seek(offset, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		reader := newProgressReader(layerReader, progressOut, offset, descriptor.Size, layer.SimpleID(), "pulling")
+		_, err = io.Copy(io.MultiWriter(blob, digester.Hash()), reader)
+		if err == dockerTransport.ErrWrongCodeForByteRange {
+			// the registry does not serve ranges, download it from the start.
+			_, _ = truncate(blob)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	}
+
 	if digester.Digest() != descriptor.Digest {
+		_, _ = truncate(blob)
 		return fmt.Errorf("digest verified failed for %s", layer.ID())
 	}
-	progress.Update(progressOut, layer.SimpleID(), "pull completed")
 	return nil
 }
 
+func truncate(f *os.File) (int64, error) {
+	if err := f.Truncate(0); err != nil {
+		return 0, err
+	}
+	return f.Seek(0, io.SeekStart)
+}
+
 // TODO make a manifest store do this job
-func (puller *ImagePuller) getRemoteManifest(context context.Context, named reference.Named) (schema2.Manifest, error) {
-	repo := puller.repository
-	ms, err := repo.Manifests(context)
-	if err != nil {
-		return schema2.Manifest{}, err
+func (puller *ImagePuller) getRemoteManifest(context context.Context, named reference.Named) (schema2.Manifest, digest.Digest, error) {
+	want := puller.config.Platform
+	if want.Architecture == "" {
+		want = platform.Default()
 	}
+	return GetManifest(context, puller.repository, named.Tag(), want)
+}
 
-	manifest, err := ms.Get(context, "", distribution.WithTagOption{Tag: named.Tag()})
+// verifySignatures checks the image config by the trust policy before any layer of the image is pulled.
+// The trusted signatures in the registry are kept locally, so the image could be verified offline later.
+func (puller *ImagePuller) verifySignatures(ctx context.Context, named reference.Named, image v1.Image) error {
+	policy := puller.config.Policy
+	if policy == nil {
+		return nil
+	}
+	dgst, err := ImageDigest(image)
 	if err != nil {
-		return schema2.Manifest{}, err
+		return err
+	}
+	var (
+		repository = named.Domain() + "/" + named.Repo()
+		sigStore   = signature.NewDefaultStore()
+		sigs       []signature.Signature
+	)
+	if policy.RequiresSignature(repository) {
+		if sigs, err = sigStore.Get(dgst); err != nil {
+			return err
+		}
+		remote, err := FetchSignatures(ctx, puller.repository, dgst)
+		if err != nil {
+			logger.Warn("failed to get signatures of %s from registry: %v", named.Raw(), err)
+		}
+		sigs = signature.Merge(sigs, remote...)
 	}
 
-	_, ok := manifest.(*schema2.DeserializedManifest)
-	if !ok {
-		return schema2.Manifest{}, fmt.Errorf("failed to parse manifest %s to DeserializedManifest", named.RepoTag())
+	trusted, err := policy.Verify(repository, dgst, sigs)
+	if err != nil {
+		return fmt.Errorf("failed to verify image %s: %v", named.Raw(), err)
 	}
-	return manifest.(*schema2.DeserializedManifest).Manifest, nil
+	if len(trusted) > 0 {
+		logger.Info("image %s@%s is verified by %d trusted signatures", named.Raw(), dgst, len(trusted))
+	}
+	return sigStore.Add(dgst, trusted...)
 }
 
 // not docker image, get sealer image metadata
@@ -176,6 +331,10 @@ func (puller *ImagePuller) getRemoteImageMetadata(context context.Context, diges
 	if err != nil {
 		return v1.Image{}, err
 	}
+	// the config is referred to by the verified manifest, make sure it is not replaced.
+	if got := digest.Algorithm().FromBytes(manifestImageBytes); got != digest {
+		return v1.Image{}, fmt.Errorf("image config %s is corrupted, got digest %s", digest, got)
+	}
 
 	img := v1.Image{}
 	return img, json.Unmarshal(manifestImageBytes, &img)
@@ -0,0 +1,175 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributionutil
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/progress"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/image/signature"
+	"github.com/alibaba/sealer/pkg/image/store"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils/archive"
+)
+
+// testLayer returns the gzipped tar of a layer with file, and the digest of its content.
+func testLayer(t *testing.T, file string) ([]byte, digest.Digest) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), []byte(file), common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	layerID, _, err := archive.TarCanonicalDigest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader, err := archive.TarWithoutRootDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tarReader.Close()
+	compressed, _ := archive.GzipCompress(tarReader)
+	blob, err := ioutil.ReadAll(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob, layerID
+}
+
+func TestImagePuller_PullVerifiesLayers(t *testing.T) {
+	common.SetDataRoot(t.TempDir())
+	defer common.SetDataRoot(common.DefaultDataRoot)
+
+	registry := &memRegistry{
+		blobs:     map[digest.Digest][]byte{},
+		manifests: map[string][]byte{},
+		types:     map[string]string{},
+		uploads:   map[string][]byte{},
+	}
+	srv := httptest.NewServer(registry)
+	defer srv.Close()
+	ctx := context.Background()
+	named, err := reference.ParseToNamed(strings.TrimPrefix(srv.URL, "http://") + "/library/test:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(ctx, types.AuthConfig{}, "library/test", registryConfig{Domain: srv.URL}, "pull")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the image is signed, and the policy of the registry requires it.
+	layerBlob, layerID := testLayer(t, "signed")
+	image := v1.Image{Spec: v1.ImageSpec{ID: "test", Layers: []v1.Layer{{ID: layerID, Type: "COPY", Value: "file ."}}}}
+	dgst, err := ImageDigest(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath, publicPath, err := signature.GenerateKeyPair(filepath.Join(t.TempDir(), "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := signature.LoadPrivateKey(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := signature.Sign(key, named.Domain()+"/"+named.Repo(), dgst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = signature.NewDefaultStore().Add(dgst, sig); err != nil {
+		t.Fatal(err)
+	}
+	policyFile := filepath.Join(t.TempDir(), "policy.json")
+	policyJSON := fmt.Sprintf(`{"default": {"type": "reject"}, "images": {"%s": {"type": "signed", "keys": ["%s"]}}}`, named.Domain(), publicPath)
+	if err = ioutil.WriteFile(policyFile, []byte(policyJSON), common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := signature.LoadPolicy(policyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configJSON, err := MarshalImageConfig(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.blobs[digest.FromBytes(configJSON)] = configJSON
+
+	forgedBlob, _ := testLayer(t, "forged")
+	tests := []struct {
+		name    string
+		blob    []byte
+		wantErr bool
+	}{
+		{"signed layer", layerBlob, false},
+		// the manifest is not signed, it could refer to any layer with the signed config.
+		{"swapped layer", forgedBlob, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, dir := range []string{common.DefaultLayerDBRoot, common.DefaultLayerDir} {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(dir, common.FileMode0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			layerStore, err := store.NewDefaultLayerStore()
+			if err != nil {
+				t.Fatal(err)
+			}
+			registry.blobs[digest.FromBytes(tt.blob)] = tt.blob
+			manifest, err := schema2.FromStruct(schema2.Manifest{
+				Versioned: schema2.SchemaVersion,
+				Config:    distribution.Descriptor{MediaType: schema2.MediaTypeImageConfig, Digest: digest.FromBytes(configJSON), Size: int64(len(configJSON))},
+				Layers:    []distribution.Descriptor{{MediaType: schema2.MediaTypeLayer, Digest: digest.FromBytes(tt.blob), Size: int64(len(tt.blob))}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, payload, _ := manifest.Payload()
+			registry.manifests["v1"], registry.types["v1"] = payload, schema2.MediaTypeManifest
+
+			puller := &ImagePuller{
+				repository: repo,
+				config:     Config{LayerStore: layerStore, ProgressOutput: progress.DiscardOutput(), Policy: policy},
+			}
+			_, _, err = puller.Pull(ctx, named)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Pull() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if registered := layerStore.Get(store.LayerID(layerID)) != nil; registered == tt.wantErr {
+				t.Errorf("layer %s registered = %v, want %v", layerID, registered, !tt.wantErr)
+			}
+			if _, err = os.Stat(filepath.Join(common.DefaultLayerDir, layerID.Hex())); tt.wantErr && err == nil {
+				t.Errorf("data of the swapped layer is left in %s", common.DefaultLayerDir)
+			}
+		})
+	}
+}
@@ -19,11 +19,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/docker/pkg/progress"
+	"github.com/docker/go-units"
 	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
@@ -31,11 +34,13 @@ import (
 	"github.com/alibaba/sealer/pkg/image/reference"
 	"github.com/alibaba/sealer/pkg/image/store"
 	v1 "github.com/alibaba/sealer/types/api/v1"
-	"github.com/alibaba/sealer/utils/archive"
 )
 
 type Pusher interface {
 	Push(ctx context.Context, named reference.Named) error
+	// PushManifestList pushes images into the repository of named, and tags
+	// the manifest list of them as named.
+	PushManifestList(ctx context.Context, named reference.Named, images []PlatformImage) error
 }
 
 type ImagePusher struct {
@@ -45,36 +50,70 @@ type ImagePusher struct {
 }
 
 func (pusher *ImagePusher) Push(ctx context.Context, named reference.Named) error {
+	image, err := pusher.imageStore.GetByName(named.Raw())
+	if err != nil {
+		return err
+	}
+
+	_, err = pusher.pushImage(ctx, named, *image, distribution.WithTag(named.Tag()))
+	return err
+}
+
+func (pusher *ImagePusher) PushManifestList(ctx context.Context, named reference.Named, images []PlatformImage) error {
+	var descriptors []distribution.Descriptor
+	for _, image := range images {
+		// entries of the list are pushed by digest, only the list is tagged.
+		descriptor, err := pusher.pushImage(ctx, named, image.Image)
+		if err != nil {
+			return fmt.Errorf("failed to push image %s of manifest list %s: %v", image.Name, named.Raw(), err)
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+
+	list, err := buildManifestList(descriptors, images)
+	if err != nil {
+		return err
+	}
+
+	ms, err := pusher.repository.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = ms.Put(ctx, list, distribution.WithTag(named.Tag()))
+	return err
+}
+
+// pushImage pushes the layers and the manifest of image into the repository of named.
+func (pusher *ImagePusher) pushImage(ctx context.Context, named reference.Named, image v1.Image, options ...distribution.ManifestServiceOption) (distribution.Descriptor, error) {
 	var (
 		layerStore   = pusher.config.LayerStore
 		pushedLayers = map[string]distribution.Descriptor{}
 		pushMux      sync.Mutex
-		eg           *errgroup.Group
+		limit        = newLimiter()
 	)
 
-	image, err := pusher.imageStore.GetByName(named.Raw())
-	if err != nil {
-		return err
-	}
-
-	eg, _ = errgroup.WithContext(context.Background())
+	eg, egCtx := errgroup.WithContext(ctx)
 	for _, l := range image.Spec.Layers {
 		if l.ID == "" {
 			continue
 		}
 		err := l.ID.Validate()
 		if err != nil {
-			return fmt.Errorf("layer hash %s validate failed, err: %s", l.ID, err)
+			return distribution.Descriptor{}, fmt.Errorf("layer hash %s validate failed, err: %s", l.ID, err)
 		}
 
 		// this scope value, safe to pass into eg.Go
 		roLayer := layerStore.Get(store.LayerID(l.ID))
 		if roLayer == nil {
-			return fmt.Errorf("failed to put image %s, layer %s not exists locally", named.Raw(), l.ID.String())
+			return distribution.Descriptor{}, fmt.Errorf("failed to put image %s, layer %s not exists locally", named.Raw(), l.ID.String())
 		}
 
 		eg.Go(func() error {
-			layerDescriptor, layerErr := pusher.uploadLayer(ctx, roLayer)
+			if layerErr := limit.acquire(egCtx); layerErr != nil {
+				return layerErr
+			}
+			defer limit.release()
+			layerDescriptor, layerErr := pusher.uploadLayer(egCtx, roLayer)
 			if layerErr != nil {
 				return layerErr
 			}
@@ -86,9 +125,9 @@ func (pusher *ImagePusher) Push(ctx context.Context, named reference.Named) erro
 			return layerStore.AddDistributionMetadata(roLayer.ID(), named, layerDescriptor.Digest)
 		})
 	}
-	err = eg.Wait()
+	err := eg.Wait()
 	if err != nil {
-		return fmt.Errorf("failed to push layers of %s, err: %s", named.Raw(), err)
+		return distribution.Descriptor{}, fmt.Errorf("failed to push layers of %s, err: %s", named.Raw(), err)
 	}
 
 	// for making descriptors have same order with image layers
@@ -108,21 +147,19 @@ func (pusher *ImagePusher) Push(ctx context.Context, named reference.Named) erro
 		layerDescriptors = append(layerDescriptors, layerDescriptor)
 	}
 	if len(layerDescriptors) != len(pushedLayers) {
-		return errors.New("failed to push image, the number of layerDescriptors and pushedLayers mismatch")
+		return distribution.Descriptor{}, errors.New("failed to push image, the number of layerDescriptors and pushedLayers mismatch")
 	}
 	// push sealer image metadata to registry
-	configJSON, err := pusher.putManifestConfig(ctx, *image)
+	configJSON, err := pusher.putManifestConfig(ctx, image)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 
-	return pusher.putManifest(ctx, configJSON, named, layerDescriptors)
+	return pusher.putManifest(ctx, configJSON, layerDescriptors, options...)
 }
 
 func (pusher *ImagePusher) uploadLayer(ctx context.Context, roLayer store.Layer) (distribution.Descriptor, error) {
 	var (
-		err                      error
-		layerContentStream       io.ReadCloser
 		repo                     = pusher.repository
 		progressChanOut          = pusher.config.ProgressOutput
 		layerDistributionDigests = roLayer.DistributionMetadata()
@@ -142,51 +179,129 @@ func (pusher *ImagePusher) uploadLayer(ctx context.Context, roLayer store.Layer)
 		}
 	}
 
-	// pack layer files into tar.gz
-	progress.Update(progressChanOut, roLayer.SimpleID(), "preparing")
-	layerContentStream, err = roLayer.TarStream()
+	blob, err := stageLayerBlob(roLayer, progressChanOut)
 	if err != nil {
-		return distribution.Descriptor{}, errors.Errorf("failed to get tar stream for layer %s, err: %s", roLayer.ID(), err)
+		return distribution.Descriptor{}, err
 	}
-	//progress.NewProgressReader will close layerContentStream
-	progressReader := progress.NewProgressReader(layerContentStream, progressChanOut, roLayer.Size(), roLayer.SimpleID(), "pushing")
-	uploadStream, _ := archive.GzipCompress(progressReader)
-	defer func() {
-		err := layerContentStream.Close()
-		if err != nil {
-			return
+	// the same layer content may have been pushed without sealer recording it.
+	if remoteLayerDescriptor, err := bs.Stat(ctx, blob.Digest); err == nil {
+		progress.Message(progressChanOut, roLayer.SimpleID(), "already exists")
+		blob.remove()
+		return remoteLayerDescriptor, nil
+	}
+
+	err = retry(ctx, roLayer.SimpleID(), progressChanOut, func() error {
+		return pusher.putBlob(ctx, roLayer, blob)
+	})
+	if err != nil {
+		progress.Update(progressChanOut, roLayer.SimpleID(), "push failed")
+		return distribution.Descriptor{}, fmt.Errorf("failed to upload layer %s, err: %s", roLayer.ID(), err)
+	}
+	blob.remove()
+
+	progress.Update(progressChanOut, roLayer.SimpleID(), "push completed")
+	return buildBlobs(blob.Digest, blob.Size, roLayer.MediaType()), nil
+}
+
+// putBlob uploads blob in chunks, resuming the upload an earlier attempt or
+// push of the blob into the repository left.
+func (pusher *ImagePusher) putBlob(ctx context.Context, roLayer store.Layer, blob *layerBlob) error {
+	repo, ok := pusher.repository.(*registryRepository)
+	if !ok {
+		return pusher.putBlobStream(ctx, roLayer, blob)
+	}
+	// an earlier attempt may have committed it without getting the response.
+	if _, err := repo.Blobs(ctx).Stat(ctx, blob.Digest); err == nil {
+		return nil
+	}
+
+	var (
+		uploadKey = repo.uploadKey()
+		location  = blob.Uploads[uploadKey]
+		offset    int64
+		err       error
+	)
+	if location != "" {
+		offset, err = repo.uploadOffset(ctx, location)
+		if err == errUploadUnknown {
+			location, offset = "", 0
+		} else if err != nil {
+			return err
 		}
-		err = uploadStream.Close()
-		if err != nil {
-			return
+	}
+	if location == "" {
+		if location, err = repo.startUpload(ctx); err != nil {
+			return err
 		}
-	}()
+		if err = blob.saveUpload(uploadKey, location); err != nil {
+			return err
+		}
+	}
 
-	layerUploader, err := bs.Create(ctx)
+	f, err := os.Open(blob.path)
 	if err != nil {
-		progress.Update(progressChanOut, roLayer.SimpleID(), "push failed")
-		return distribution.Descriptor{}, err
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if offset > 0 {
+		progress.Updatef(pusher.config.ProgressOutput, roLayer.SimpleID(), "Resuming from %s", units.HumanSize(float64(offset)))
+	}
+
+	reader := newProgressReader(f, pusher.config.ProgressOutput, offset, blob.Size, roLayer.SimpleID(), "pushing")
+	for offset < blob.Size {
+		size := blob.Size - offset
+		if chunkSize := DefaultTransferConfig.ChunkSize; chunkSize > 0 && size > chunkSize {
+			size = chunkSize
+		}
+		location, err = repo.uploadChunk(ctx, location, io.LimitReader(reader, size), offset, size)
+		if err == errUploadUnknown {
+			// start over in the next attempt.
+			_ = blob.saveUpload(uploadKey, "")
+		}
+		if err != nil {
+			return err
+		}
+		offset += size
+		if err = blob.saveUpload(uploadKey, location); err != nil {
+			return err
+		}
 	}
-	defer layerUploader.Close()
 
-	// calculate hash of layer content stream
-	digester := digest.Canonical.Digester()
-	tee := io.TeeReader(uploadStream, digester.Hash())
-	realSize, err := layerUploader.ReadFrom(tee)
+	err = repo.commitUpload(ctx, location, blob.Digest)
+	if err == errUploadUnknown {
+		_ = blob.saveUpload(uploadKey, "")
+	}
+	return err
+}
+
+// putBlobStream uploads blob in one request, for the repositories not created by NewRepository.
+func (pusher *ImagePusher) putBlobStream(ctx context.Context, roLayer store.Layer, blob *layerBlob) error {
+	f, err := os.Open(blob.path)
 	if err != nil {
-		return distribution.Descriptor{}, fmt.Errorf("failed to upload layer %s, err: %s", roLayer.ID(), err)
+		return err
 	}
+	defer f.Close()
 
-	layerContentDigest := digester.Digest()
-	if _, err = layerUploader.Commit(ctx, distribution.Descriptor{Digest: layerContentDigest}); err != nil {
-		return distribution.Descriptor{}, fmt.Errorf("failed to commit layer to registry, err: %s", err)
+	layerUploader, err := pusher.repository.Blobs(ctx).Create(ctx)
+	if err != nil {
+		return err
 	}
+	defer layerUploader.Close()
 
-	progress.Update(progressChanOut, roLayer.SimpleID(), "push completed")
-	return buildBlobs(layerContentDigest, realSize, roLayer.MediaType()), nil
+	reader := newProgressReader(f, pusher.config.ProgressOutput, 0, blob.Size, roLayer.SimpleID(), "pushing")
+	if _, err = layerUploader.ReadFrom(reader); err != nil {
+		return err
+	}
+	if _, err = layerUploader.Commit(ctx, distribution.Descriptor{Digest: blob.Digest}); err != nil {
+		return fmt.Errorf("failed to commit layer to registry, err: %s", err)
+	}
+	return nil
 }
 
-func (pusher *ImagePusher) putManifest(ctx context.Context, configJSON []byte, named reference.Named, layerDescriptors []distribution.Descriptor) error {
+func (pusher *ImagePusher) putManifest(ctx context.Context, configJSON []byte, layerDescriptors []distribution.Descriptor, options ...distribution.ManifestServiceOption) (distribution.Descriptor, error) {
 	var (
 		bs   = &blobService{descriptors: map[digest.Digest]distribution.Descriptor{}}
 		repo = pusher.repository
@@ -201,34 +316,35 @@ func (pusher *ImagePusher) putManifest(ctx context.Context, configJSON []byte, n
 	for _, d := range layerDescriptors {
 		err := manifestBuilder.AppendReference(d)
 		if err != nil {
-			return err
+			return distribution.Descriptor{}, err
 		}
 	}
 
 	manifest, err := manifestBuilder.Build(ctx)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 
 	ms, err := repo.Manifests(ctx)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 
-	putOptions := []distribution.ManifestServiceOption{distribution.WithTag(named.Tag())}
-	_, err = ms.Put(ctx, manifest, putOptions...)
-	return err
+	dgst, err := ms.Put(ctx, manifest, options...)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	mediaType, payload, err := manifest.Payload()
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	return buildBlobs(dgst, int64(len(payload)), mediaType), nil
 }
 
 func (pusher *ImagePusher) putManifestConfig(ctx context.Context, image v1.Image) ([]byte, error) {
 	repo := pusher.repository
 
-	dockerImageConfig, err := addDockerManifestConfig(image)
-	if err != nil {
-		return nil, fmt.Errorf("add docker manifest config error: %s", err)
-	}
-
-	configJSON, err := json.Marshal(dockerImageConfig)
+	configJSON, err := MarshalImageConfig(image)
 	if err != nil {
 		return nil, err
 	}
@@ -242,6 +358,26 @@ func (pusher *ImagePusher) putManifestConfig(ctx context.Context, image v1.Image
 	return configJSON, err
 }
 
+// MarshalImageConfig returns the config blob of image in its manifest, which is
+// image with the fields of docker image config for the tools not knowing sealer.
+func MarshalImageConfig(image v1.Image) ([]byte, error) {
+	dockerImageConfig, err := addDockerManifestConfig(image)
+	if err != nil {
+		return nil, fmt.Errorf("add docker manifest config error: %s", err)
+	}
+	return json.Marshal(dockerImageConfig)
+}
+
+// ImageDigest returns the digest of the config blob of image, which the signatures of image are made over.
+// It is computed from the image alone, so it is the same wherever the image is pulled, loaded or tagged to.
+func ImageDigest(image v1.Image) (digest.Digest, error) {
+	configJSON, err := MarshalImageConfig(image)
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(configJSON), nil
+}
+
 type dockerImageLayerInfo struct {
 	Created    string `json:"created,omitempty"`
 	CreatedBy  string `json:"created_by,omitempty"`
@@ -274,6 +410,9 @@ func addDockerManifestConfig(image v1.Image) (*dockerManifestConfig, error) {
 
 	for _, layer := range image.Spec.Layers {
 		var tmpLayerInfo = dockerImageLayerInfo{CreatedBy: layer.Type + " " + layer.Value}
+		if layer.Created != nil {
+			tmpLayerInfo.Created = layer.Created.UTC().Format(time.RFC3339Nano)
+		}
 		if layer.ID == "" {
 			tmpLayerInfo.EmptyLayer = true
 		}
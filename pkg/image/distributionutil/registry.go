@@ -16,6 +16,7 @@ package distributionutil
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/alibaba/sealer/logger"
 
@@ -43,3 +44,16 @@ func getV2Repository(authConfig types.AuthConfig, named reference.Named, actions
 	}
 	return NewRepository(context.Background(), authConfig, named.Repo(), registryConfig{Insecure: true, NonSSL: true, Domain: named.Domain()}, actions...)
 }
+
+// ListTags returns all the tags of the repository of named in its registry.
+func ListTags(named reference.Named) ([]string, error) {
+	repo, err := NewV2Repository(named, "pull")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository %s: %v", named.Repo(), err)
+	}
+	tags, err := repo.Tags(context.Background()).All(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %v", named.Repo(), err)
+	}
+	return tags, nil
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/reference"
+	v2 "github.com/docker/distribution/registry/api/v2"
 	dockerRegistryClient "github.com/docker/distribution/registry/client"
 	dockerAuth "github.com/docker/distribution/registry/client/auth"
 	dockerTransport "github.com/docker/distribution/registry/client/transport"
@@ -104,7 +105,30 @@ func NewRepository(ctx context.Context, authConfig types.AuthConfig, repoName st
 		return nil, err
 	}
 
-	return dockerRegistryClient.NewRepository(repoNameRef, rurl.String(), tr)
+	repo, err := dockerRegistryClient.NewRepository(repoNameRef, rurl.String(), tr)
+	if err != nil {
+		return nil, err
+	}
+	urls, err := v2.NewURLBuilderFromString(rurl.String(), false)
+	if err != nil {
+		return nil, err
+	}
+	return &registryRepository{Repository: repo, client: &http.Client{Transport: tr}, urls: urls, host: rurl.Host}, nil
+}
+
+// registryRepository keeps the transport of the repository to send the
+// requests the registry client does not support, like resuming blob uploads.
+type registryRepository struct {
+	distribution.Repository
+	client *http.Client
+	urls   *v2.URLBuilder
+	host   string
+}
+
+// uploadKey is the key of the uploads into the repository, a repository of
+// the same name in another registry has its own uploads.
+func (r *registryRepository) uploadKey() string {
+	return r.host + "/" + r.Named().Name()
 }
 
 type existingTokenHandler struct {
@@ -0,0 +1,123 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package distributionutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/pkg/image/signature"
+)
+
+const (
+	// MediaTypeSignature is the media type of the layers of a signature artifact, each of
+	// which is a signature.Signature in JSON.
+	MediaTypeSignature       = "application/vnd.sealer.signature.v1+json"
+	mediaTypeSignatureConfig = "application/vnd.sealer.signature.config.v1+json"
+)
+
+// SignatureTag is the tag the signatures of image digest dgst are pushed to, in the repository of the image.
+func SignatureTag(dgst digest.Digest) string {
+	return fmt.Sprintf("%s-%s.sig", dgst.Algorithm(), dgst.Hex())
+}
+
+// FetchSignatures gets the signatures of image digest dgst pushed to repo, nothing is returned if there is none.
+func FetchSignatures(ctx context.Context, repo distribution.Repository, dgst digest.Digest) ([]signature.Signature, error) {
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ms.Get(ctx, "", distribution.WithTagOption{Tag: SignatureTag(dgst)})
+	if isManifestUnknown(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, ok := manifest.(*schema2.DeserializedManifest)
+	if !ok {
+		return nil, fmt.Errorf("signature artifact %s is not a schema2 manifest", SignatureTag(dgst))
+	}
+
+	var sigs []signature.Signature
+	for _, l := range m.Layers {
+		if l.MediaType != MediaTypeSignature {
+			continue
+		}
+		data, err := repo.Blobs(ctx).Get(ctx, l.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get signature %s: %v", l.Digest, err)
+		}
+		var sig signature.Signature
+		if err = json.Unmarshal(data, &sig); err != nil {
+			return nil, fmt.Errorf("failed to parse signature %s: %v", l.Digest, err)
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// PushSignatures adds sigs to the signature artifact of image digest dgst in repo.
+func PushSignatures(ctx context.Context, repo distribution.Repository, dgst digest.Digest, sigs ...signature.Signature) error {
+	existing, err := FetchSignatures(ctx, repo, dgst)
+	if err != nil {
+		return fmt.Errorf("failed to get signatures of %s: %v", dgst, err)
+	}
+
+	bs := repo.Blobs(ctx)
+	builder := schema2.NewManifestBuilder(bs, mediaTypeSignatureConfig, []byte("{}"))
+	for _, sig := range signature.Merge(existing, sigs...) {
+		data, err := json.Marshal(sig)
+		if err != nil {
+			return err
+		}
+		desc, err := bs.Put(ctx, MediaTypeSignature, data)
+		if err != nil {
+			return fmt.Errorf("failed to push signature: %v", err)
+		}
+		desc.MediaType = MediaTypeSignature
+		if err = builder.AppendReference(desc); err != nil {
+			return err
+		}
+	}
+	manifest, err := builder.Build(ctx)
+	if err != nil {
+		return err
+	}
+
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err = ms.Put(ctx, manifest, distribution.WithTag(SignatureTag(dgst))); err != nil {
+		return fmt.Errorf("failed to push signature artifact %s: %v", SignatureTag(dgst), err)
+	}
+	return nil
+}
+
+func isManifestUnknown(err error) bool {
+	errs, ok := err.(errcode.Errors)
+	if !ok || len(errs) == 0 {
+		return false
+	}
+	e, ok := errs[0].(errcode.ErrorCoder)
+	return ok && e.ErrorCode() == v2.ErrorCodeManifestUnknown
+}
@@ -0,0 +1,158 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package distributionutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/pkg/image/signature"
+)
+
+// memRegistry keeps the blobs and manifests of one repository in memory.
+type memRegistry struct {
+	sync.Mutex
+	blobs     map[digest.Digest][]byte
+	manifests map[string][]byte
+	types     map[string]string
+	uploads   map[string][]byte
+}
+
+func (r *memRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	path := strings.TrimPrefix(req.URL.Path, "/v2/library/test")
+	body, _ := ioutil.ReadAll(req.Body)
+
+	switch {
+	case req.URL.Path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case strings.HasPrefix(path, "/blobs/uploads/"):
+		id := strings.TrimPrefix(path, "/blobs/uploads/")
+		if req.Method == http.MethodPost {
+			id = strconv.Itoa(len(r.uploads) + 1)
+		}
+		r.uploads[id] = append(r.uploads[id], body...)
+		w.Header().Set("Location", "/v2/library/test/blobs/uploads/"+id)
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.Header().Set("Range", fmt.Sprintf("0-%d", len(r.uploads[id])-1))
+		if req.Method != http.MethodPut {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		dgst := digest.Digest(req.URL.Query().Get("digest"))
+		r.blobs[dgst] = r.uploads[id]
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(path, "/blobs/"):
+		dgst := digest.Digest(strings.TrimPrefix(path, "/blobs/"))
+		blob, ok := r.blobs[dgst]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(blob)
+		}
+	case strings.HasPrefix(path, "/manifests/"):
+		ref := strings.TrimPrefix(path, "/manifests/")
+		if req.Method == http.MethodPut {
+			r.manifests[ref], r.types[ref] = body, req.Header.Get("Content-Type")
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(body).String())
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		manifest, ok := r.manifests[ref]
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`))
+			return
+		}
+		w.Header().Set("Content-Type", r.types[ref])
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(manifest).String())
+		_, _ = w.Write(manifest)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPushSignatures(t *testing.T) {
+	registry := &memRegistry{
+		blobs:     map[digest.Digest][]byte{},
+		manifests: map[string][]byte{},
+		types:     map[string]string{},
+		uploads:   map[string][]byte{},
+	}
+	srv := httptest.NewServer(registry)
+	defer srv.Close()
+	ctx := context.Background()
+	repo, err := NewRepository(ctx, types.AuthConfig{}, "library/test", registryConfig{Domain: srv.URL}, "push", "pull")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+
+	dgst := digest.FromString("manifest")
+	sigs, err := FetchSignatures(ctx, repo, dgst)
+	if err != nil || len(sigs) != 0 {
+		t.Fatalf("FetchSignatures() of unsigned manifest = %v, %v", sigs, err)
+	}
+
+	var signed []signature.Signature
+	for i := 0; i < 2; i++ {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := signature.Sign(key, "docker.io/library/test", dgst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signed = append(signed, sig)
+	}
+	if err = PushSignatures(ctx, repo, dgst, signed[0]); err != nil {
+		t.Fatalf("failed to push signature: %v", err)
+	}
+	if err = PushSignatures(ctx, repo, dgst, signed...); err != nil {
+		t.Fatalf("failed to push signatures: %v", err)
+	}
+
+	if _, ok := registry.manifests[SignatureTag(dgst)]; !ok {
+		t.Errorf("signature artifact is not pushed to tag %s", SignatureTag(dgst))
+	}
+	sigs, err = FetchSignatures(ctx, repo, dgst)
+	if err != nil {
+		t.Fatalf("failed to fetch signatures: %v", err)
+	}
+	if len(sigs) != 2 || !bytes.Equal(sigs[0].Signature, signed[0].Signature) || !bytes.Equal(sigs[1].Signature, signed[1].Signature) {
+		t.Errorf("FetchSignatures() got %d signatures, want the 2 pushed ones", len(sigs))
+	}
+}
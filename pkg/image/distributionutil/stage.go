@@ -0,0 +1,140 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package distributionutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/pkg/progress"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/image/store"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/archive"
+)
+
+// layerBlob is a layer compressed into the temp area of the layer store for
+// uploading. Its state is saved next to it, so that the uploads interrupted
+// are resumed by the next push.
+type layerBlob struct {
+	path   string
+	Digest digest.Digest `json:"digest"`
+	Size   int64         `json:"size"`
+	// Uploads are the locations of the unfinished uploads by registry host and repository name.
+	Uploads map[string]string `json:"uploads,omitempty"`
+}
+
+// stageLayerBlob compresses roLayer into the temp area, unless an earlier push left it there.
+func stageLayerBlob(roLayer store.Layer, progressOut progress.Output) (*layerBlob, error) {
+	dir := filepath.Join(common.DefaultLayerTmpDir, "uploads")
+	blob := &layerBlob{path: filepath.Join(dir, roLayer.ID().ToDigest().Hex()+".tar.gz")}
+	if err := blob.load(); err == nil {
+		return blob, nil
+	}
+
+	if err := os.MkdirAll(dir, common.FileMode0755); err != nil {
+		return nil, err
+	}
+	// pack layer files into tar.gz
+	progress.Update(progressOut, roLayer.SimpleID(), "preparing")
+	layerContentStream, err := roLayer.TarStream()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tar stream for layer %s, err: %s", roLayer.ID(), err)
+	}
+	defer layerContentStream.Close()
+	compressed, _ := archive.GzipCompress(layerContentStream)
+	defer compressed.Close()
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(blob.path))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	digester := digest.Canonical.Digester()
+	blob.Size, err = io.Copy(io.MultiWriter(tmp, digester.Hash()), compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress layer %s, err: %s", roLayer.ID(), err)
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmp.Name(), blob.path); err != nil {
+		return nil, err
+	}
+	blob.Digest = digester.Digest()
+	return blob, blob.save()
+}
+
+func (b *layerBlob) statePath() string {
+	return b.path + ".json"
+}
+
+func (b *layerBlob) load() error {
+	data, err := ioutil.ReadFile(b.statePath())
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, b); err != nil {
+		return err
+	}
+	fi, err := os.Stat(b.path)
+	if err != nil {
+		return err
+	}
+	if fi.Size() != b.Size {
+		return fmt.Errorf("size of %s is %d, want %d", b.path, fi.Size(), b.Size)
+	}
+	return nil
+}
+
+func (b *layerBlob) save() error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return utils.AtomicWriteFile(b.statePath(), data, common.FileMode0644)
+}
+
+// saveUpload records the location of the upload of key, an empty location forgets it.
+func (b *layerBlob) saveUpload(key, location string) error {
+	if b.Uploads == nil {
+		b.Uploads = map[string]string{}
+	}
+	if location == "" {
+		delete(b.Uploads, key)
+	} else {
+		b.Uploads[key] = location
+	}
+	return b.save()
+}
+
+// remove deletes the blob and its state once it is pushed.
+func (b *layerBlob) remove() {
+	for _, f := range []string{b.statePath(), b.path} {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			logger.Warn("failed to remove %s: %v", f, err)
+		}
+	}
+}
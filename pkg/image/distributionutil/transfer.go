@@ -0,0 +1,122 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package distributionutil
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/docker/docker/pkg/progress"
+
+	"github.com/alibaba/sealer/logger"
+)
+
+// TransferConfig controls how layers are transferred between sealer and registries.
+type TransferConfig struct {
+	// MaxConcurrency is the number of layers transferred at the same time.
+	MaxConcurrency int
+	// Retries is how many times a failed layer transfer is retried, a retry
+	// resumes from the bytes the previous attempts transferred.
+	Retries int
+	// RetryBackoff is the wait before the first retry, it doubles every retry.
+	RetryBackoff time.Duration
+	// ChunkSize is the size of the chunks a layer is uploaded in.
+	ChunkSize int64
+}
+
+// DefaultTransferConfig is used by all pullers and pushers.
+var DefaultTransferConfig = TransferConfig{
+	MaxConcurrency: 3,
+	Retries:        5,
+	RetryBackoff:   time.Second,
+	ChunkSize:      64 << 20,
+}
+
+// retry runs fn until it succeeds, ctx is done or the retries are used up,
+// with an exponential backoff between the attempts.
+func retry(ctx context.Context, id string, progressOut progress.Output, fn func() error) error {
+	backoff := DefaultTransferConfig.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= DefaultTransferConfig.Retries {
+			return err
+		}
+		logger.Warn("failed to transfer layer %s, retry %d/%d in %s: %v", id, attempt+1, DefaultTransferConfig.Retries, backoff, err)
+		progress.Updatef(progressOut, id, "Retrying in %s", backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// limiter bounds the number of layers transferred at the same time.
+type limiter chan struct{}
+
+func newLimiter() limiter {
+	n := DefaultTransferConfig.MaxConcurrency
+	if n <= 0 {
+		n = 1
+	}
+	return make(limiter, n)
+}
+
+func (l limiter) acquire(ctx context.Context) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l limiter) release() {
+	<-l
+}
+
+// progressReader reports the progress of a transfer which starts at offset,
+// so that a resumed transfer does not start over from zero.
+type progressReader struct {
+	in      io.Reader
+	out     progress.Output
+	id      string
+	action  string
+	current int64
+	total   int64
+	last    int64
+}
+
+func newProgressReader(in io.Reader, out progress.Output, offset, total int64, id, action string) *progressReader {
+	return &progressReader{in: in, out: out, id: id, action: action, current: offset, total: total, last: offset}
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.in.Read(buf)
+	p.current += int64(n)
+	// update every 512kB or at the end of the stream
+	if p.current-p.last >= 512*1024 || err == io.EOF {
+		p.last = p.current
+		_ = p.out.WriteProgress(progress.Progress{ID: p.id, Action: p.action, Current: p.current, Total: p.total})
+	}
+	return n, err
+}
@@ -0,0 +1,346 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package distributionutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/progress"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/image/store"
+)
+
+// flakyRegistry serves one blob and one upload, and breaks the first
+// download and the second upload chunk in the middle.
+type flakyRegistry struct {
+	sync.Mutex
+	blob       []byte
+	uploaded   []byte
+	committed  digest.Digest
+	downloads  int
+	patches    int
+	rangeStart []int64
+	// lostUpload is an upload location the registry answers with an error.
+	lostUpload string
+}
+
+func (r *flakyRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	switch {
+	case req.URL.Path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case strings.Contains(req.URL.Path, "/blobs/sha256:"):
+		dgst := digest.Digest(req.URL.Path[strings.Index(req.URL.Path, "sha256:"):])
+		if dgst != digest.FromBytes(r.blob) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.serveBlob(w, req)
+	case req.Method == http.MethodPost:
+		w.Header().Set("Location", "/v2/library/test/blobs/uploads/1")
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodGet && req.URL.Path == r.lostUpload:
+		w.WriteHeader(http.StatusBadRequest)
+	case req.Method == http.MethodGet:
+		end := len(r.uploaded) - 1
+		if end < 0 {
+			end = 0
+		}
+		w.Header().Set("Range", fmt.Sprintf("0-%d", end))
+		w.WriteHeader(http.StatusNoContent)
+	case req.Method == http.MethodPatch:
+		r.patches++
+		var start, end int
+		_, _ = fmt.Sscanf(req.Header.Get("Content-Range"), "%d-%d", &start, &end)
+		if start != len(r.uploaded) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		data, _ := ioutil.ReadAll(req.Body)
+		if r.patches == 2 {
+			// keep half of the chunk and drop the connection.
+			r.uploaded = append(r.uploaded, data[:len(data)/2]...)
+			hijack(w)
+			return
+		}
+		r.uploaded = append(r.uploaded, data...)
+		w.Header().Set("Location", "/v2/library/test/blobs/uploads/1")
+		w.Header().Set("Range", fmt.Sprintf("0-%d", len(r.uploaded)-1))
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut:
+		dgst := digest.Digest(req.URL.Query().Get("digest"))
+		if dgst != digest.FromBytes(r.uploaded) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.committed = dgst
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (r *flakyRegistry) serveBlob(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", digest.FromBytes(r.blob).String())
+	if req.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(r.blob)))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var start int64
+	if rng := req.Header.Get("Range"); rng != "" {
+		_, _ = fmt.Sscanf(rng, "bytes=%d-", &start)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(r.blob)-1, len(r.blob)))
+		w.Header().Set("Content-Length", strconv.Itoa(len(r.blob)-int(start)))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.Header().Set("Content-Length", strconv.Itoa(len(r.blob)))
+		w.WriteHeader(http.StatusOK)
+	}
+	r.rangeStart = append(r.rangeStart, start)
+	r.downloads++
+	if r.downloads == 1 {
+		_, _ = w.Write(r.blob[start : len(r.blob)/3])
+		hijack(w)
+		return
+	}
+	_, _ = w.Write(r.blob[start:])
+}
+
+func hijack(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		_ = conn.Close()
+	}
+}
+
+func newFlakyRepository(t *testing.T, registry *flakyRegistry) *registryRepository {
+	srv := httptest.NewServer(registry)
+	t.Cleanup(srv.Close)
+	repo, err := NewRepository(context.Background(), types.AuthConfig{}, "library/test", registryConfig{Domain: srv.URL}, "push", "pull")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	return repo.(*registryRepository)
+}
+
+func withFastRetries(t *testing.T) {
+	saved := DefaultTransferConfig
+	DefaultTransferConfig.RetryBackoff = time.Millisecond
+	DefaultTransferConfig.ChunkSize = 1024
+	t.Cleanup(func() {
+		DefaultTransferConfig = saved
+	})
+}
+
+func randomBlob(size int) []byte {
+	blob := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(blob)
+	return blob
+}
+
+func TestFetchBlobResumes(t *testing.T) {
+	withFastRetries(t)
+	registry := &flakyRegistry{blob: randomBlob(10 * 1024)}
+	puller := &ImagePuller{
+		repository: newFlakyRepository(t, registry),
+		config:     Config{ProgressOutput: progress.DiscardOutput()},
+	}
+	layer, err := store.NewROLayer(digest.FromString("layer"), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptor := distribution.Descriptor{Digest: digest.FromBytes(registry.blob), Size: int64(len(registry.blob))}
+	blobPath := filepath.Join(t.TempDir(), "blob")
+
+	err = retry(context.Background(), layer.SimpleID(), progress.DiscardOutput(), func() error {
+		return puller.fetchBlob(context.Background(), layer, descriptor, blobPath)
+	})
+	if err != nil {
+		t.Fatalf("failed to fetch blob: %v", err)
+	}
+	got, err := ioutil.ReadFile(blobPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, registry.blob) {
+		t.Errorf("fetched blob does not match")
+	}
+	if len(registry.rangeStart) != 2 || registry.rangeStart[1] != int64(len(registry.blob)/3) {
+		t.Errorf("second download should resume from %d, got range starts %v", len(registry.blob)/3, registry.rangeStart)
+	}
+}
+
+func TestLockBlob(t *testing.T) {
+	common.SetDataRoot(t.TempDir())
+	defer common.SetDataRoot(common.DefaultDataRoot)
+	saved := blobLockInterval
+	blobLockInterval = time.Millisecond
+	defer func() { blobLockInterval = saved }()
+
+	dgst := digest.FromString("blob")
+	unlock, err := lockBlob(context.Background(), dgst)
+	if err != nil {
+		t.Fatalf("failed to lock blob: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = lockBlob(ctx, dgst); err != context.DeadlineExceeded {
+		t.Fatalf("locking a locked blob should wait until ctx is done, got %v", err)
+	}
+
+	locked := make(chan error, 1)
+	go func() {
+		unlockAgain, lockErr := lockBlob(context.Background(), dgst)
+		if lockErr == nil {
+			unlockAgain()
+		}
+		locked <- lockErr
+	}()
+	unlock()
+	select {
+	case err = <-locked:
+		if err != nil {
+			t.Fatalf("failed to lock unlocked blob: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("blob is still locked after unlock")
+	}
+}
+
+func TestPutBlobResumes(t *testing.T) {
+	withFastRetries(t)
+	data := randomBlob(5 * 1024)
+	registry := &flakyRegistry{}
+	pusher := &ImagePusher{
+		repository: newFlakyRepository(t, registry),
+		config:     Config{ProgressOutput: progress.DiscardOutput()},
+	}
+	layer, err := store.NewROLayer(digest.FromString("layer"), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := &layerBlob{path: filepath.Join(t.TempDir(), "layer.tar.gz"), Digest: digest.FromBytes(data), Size: int64(len(data))}
+	if err = ioutil.WriteFile(blob.path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = retry(context.Background(), layer.SimpleID(), progress.DiscardOutput(), func() error {
+		return pusher.putBlob(context.Background(), layer, blob)
+	})
+	if err != nil {
+		t.Fatalf("failed to put blob: %v", err)
+	}
+	if registry.committed != blob.Digest {
+		t.Errorf("committed %s, want %s", registry.committed, blob.Digest)
+	}
+	// 5 chunks, plus the one resent after the broken connection
+	if registry.patches != 6 {
+		t.Errorf("sent %d chunks, want 6", registry.patches)
+	}
+	if _, err = os.Stat(blob.statePath()); err != nil {
+		t.Errorf("upload state should be saved: %v", err)
+	}
+}
+
+func TestPutBlobRestartsLostUpload(t *testing.T) {
+	withFastRetries(t)
+	data := randomBlob(2 * 1024)
+	registry := &flakyRegistry{lostUpload: "/v2/library/test/blobs/uploads/lost"}
+	repo := newFlakyRepository(t, registry)
+	pusher := &ImagePusher{
+		repository: repo,
+		config:     Config{ProgressOutput: progress.DiscardOutput()},
+	}
+	layer, err := store.NewROLayer(digest.FromString("layer"), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploads, err := repo.urls.BuildBlobUploadURL(repo.Named())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lostUpload := uploads + "lost"
+	otherRegistry := "other.registry.io/" + repo.Named().Name()
+	blob := &layerBlob{
+		path:   filepath.Join(t.TempDir(), "layer.tar.gz"),
+		Digest: digest.FromBytes(data),
+		Size:   int64(len(data)),
+		Uploads: map[string]string{
+			repo.uploadKey(): lostUpload,
+			otherRegistry:    "/v2/library/test/blobs/uploads/other",
+		},
+	}
+	if err = ioutil.WriteFile(blob.path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = retry(context.Background(), layer.SimpleID(), progress.DiscardOutput(), func() error {
+		return pusher.putBlob(context.Background(), layer, blob)
+	})
+	if err != nil {
+		t.Fatalf("failed to put blob: %v", err)
+	}
+	if registry.committed != blob.Digest {
+		t.Errorf("committed %s, want %s", registry.committed, blob.Digest)
+	}
+	if got := blob.Uploads[repo.uploadKey()]; got == lostUpload {
+		t.Errorf("lost upload %s should be forgotten", got)
+	}
+	if got := blob.Uploads[otherRegistry]; got != "/v2/library/test/blobs/uploads/other" {
+		t.Errorf("upload into another registry should be kept, got %q", got)
+	}
+}
+
+func TestParseUploadRange(t *testing.T) {
+	tests := []struct {
+		rng     string
+		want    int64
+		wantErr bool
+	}{
+		{"0-0", 0, false},
+		{"0-1023", 1024, false},
+		{"1-1023", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseUploadRange(tt.rng)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseUploadRange(%q) = %d, %v, want %d, wantErr %v", tt.rng, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package distributionutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	dockerRegistryClient "github.com/docker/distribution/registry/client"
+	"github.com/opencontainers/go-digest"
+)
+
+// errUploadUnknown means the registry lost the upload, or its offset does not
+// match ours, so the blob has to be uploaded from the start.
+var errUploadUnknown = errors.New("blob upload unknown to registry")
+
+// startUpload starts a chunked blob upload, and returns the location to send its chunks.
+// See https://docs.docker.com/registry/spec/api/#chunked-upload
+func (r *registryRepository) startUpload(ctx context.Context) (string, error) {
+	u, err := r.urls.BuildBlobUploadURL(r.Named())
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.do(ctx, http.MethodPost, u, nil, nil, 0)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return "", dockerRegistryClient.HandleErrorResponse(resp)
+	}
+	return resolveLocation(resp, u)
+}
+
+// uploadOffset returns how many bytes of the upload are received by the registry.
+func (r *registryRepository) uploadOffset(ctx context.Context, location string) (int64, error) {
+	resp, err := r.do(ctx, http.MethodGet, location, nil, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// the registry may answer a lost upload with any error, not only 404, so
+	// the upload is started over instead of failing every later push of the blob.
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, errUploadUnknown
+	}
+	return parseUploadRange(resp.Header.Get("Range"))
+}
+
+// uploadChunk sends size bytes of chunk at offset, and returns the location to send the next chunk.
+func (r *registryRepository) uploadChunk(ctx context.Context, location string, chunk io.Reader, offset, size int64) (string, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+size-1))
+	resp, err := r.do(ctx, http.MethodPatch, location, header, chunk, size)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return "", errUploadUnknown
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return "", dockerRegistryClient.HandleErrorResponse(resp)
+	}
+	return resolveLocation(resp, location)
+}
+
+// commitUpload completes the upload as blob dgst.
+func (r *registryRepository) commitUpload(ctx context.Context, location string, dgst digest.Digest) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	values := u.Query()
+	values.Set("digest", dgst.String())
+	u.RawQuery = values.Encode()
+
+	resp, err := r.do(ctx, http.MethodPut, u.String(), nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errUploadUnknown
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return dockerRegistryClient.HandleErrorResponse(resp)
+	}
+	return nil
+}
+
+// do sends a request of size bytes body to the registry with the credentials of the repository.
+func (r *registryRepository) do(ctx context.Context, method, u string, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	return r.client.Do(req)
+}
+
+func resolveLocation(resp *http.Response, base string) (string, error) {
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("no Location header in the response of %s %s", resp.Request.Method, base)
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	locationURL, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(locationURL).String(), nil
+}
+
+// parseUploadRange parses the Range header of upload status, which is
+// 0-<last byte received>, and 0-0 when nothing is received.
+func parseUploadRange(rng string) (int64, error) {
+	var start, end int64
+	if n, err := fmt.Sscanf(rng, "%d-%d", &start, &end); err != nil || n != 2 || start != 0 || end < start {
+		return 0, fmt.Errorf("bad upload range %q", rng)
+	}
+	if end == 0 {
+		return 0, nil
+	}
+	return end + 1, nil
+}
@@ -63,3 +63,22 @@ func NewDeleteImageService(force bool) (Service, error) {
 		ForceDeleteImage: force,
 	}, nil
 }
+
+func NewManifestListService() (ManifestListService, error) {
+	imageStore, err := store.NewDefaultImageStore()
+	if err != nil {
+		return nil, err
+	}
+	return DefaultManifestListService{
+		imageStore:        imageStore,
+		manifestListStore: store.NewDefaultManifestListStore(),
+	}, nil
+}
+
+func NewSignatureService() (SignatureService, error) {
+	imageStore, err := store.NewDefaultImageStore()
+	if err != nil {
+		return nil, err
+	}
+	return DefaultSignatureService{imageStore: imageStore}, nil
+}
@@ -15,6 +15,8 @@
 package image
 
 import (
+	"context"
+
 	"github.com/alibaba/sealer/pkg/image/store"
 	"github.com/alibaba/sealer/pkg/image/types"
 	v1 "github.com/alibaba/sealer/types/api/v1"
@@ -26,6 +28,8 @@ type MetadataService interface {
 	List() ([]types.ImageMetadata, error)
 	GetImage(imageName string) (*v1.Image, error)
 	GetRemoteImage(imageName string) (v1.Image, error)
+	// History lists the layers of the local image from the newest one.
+	History(imageName string) ([]types.ImageHistory, error)
 	DeleteImage(imageName string) error
 }
 
@@ -33,20 +37,51 @@ type MetadataService interface {
 type FileService interface {
 	Load(imageSrc string) error
 	Save(image *v1.Image, imageTar string) error
+	// SaveOCI saves image into an OCI image layout directory, or a tarball of it if output ends with .tar.
+	SaveOCI(image *v1.Image, output string) error
 	Merge(image *v1.Image) error
 }
 
 // Service is image service
 type Service interface {
 	Pull(imageName string) error
+	// PullContext is Pull which is cancelled once ctx is done.
+	PullContext(ctx context.Context, imageName string) error
 	PullIfNotExist(imageName string) error
+	// PullWithPlatform is PullContext which picks the manifest built for
+	// platform when imageName refers to a manifest list.
+	PullWithPlatform(ctx context.Context, imageName string, platform v1.Platform) error
+	// PullIfNotExistWithPlatform pulls the image unless the local one is built for platform,
+	// it fails if the image is not built for platform.
+	PullIfNotExistWithPlatform(imageName string, platform v1.Platform) error
 	Push(imageName string) error
+	// PushContext is Push which is cancelled once ctx is done.
+	PushContext(ctx context.Context, imageName string) error
 	Delete(imageName string) error
 	Login(RegistryURL, RegistryUsername, RegistryPasswd string) error
 	Prune() error
 	CacheBuilder
 }
 
+// ManifestListService assembles local images built for different platforms
+// into manifest lists, and pushes them under one name.
+type ManifestListService interface {
+	Create(listName string, imageNames []string, amend bool) error
+	Annotate(listName, imageName string, platform v1.Platform) error
+	Inspect(listName string) (*store.ManifestList, error)
+	Push(ctx context.Context, listName, destination string) error
+	Delete(listName string) error
+}
+
+// SignatureService signs the manifests of CloudImages, and verifies them by the trust policy.
+type SignatureService interface {
+	// Sign signs the manifest of the local image by the private key at keyPath, the signature
+	// is kept locally, and pushed to the registry as well if push is true.
+	Sign(ctx context.Context, imageName, keyPath string, push bool) error
+	// Verify checks the local image by the trust policy with the signatures kept locally.
+	Verify(imageName string) error
+}
+
 type LayerService interface {
 	LayerStore() store.LayerStore
 }
@@ -15,8 +15,75 @@
 package image
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/sealer/common"
+	v1 "github.com/alibaba/sealer/types/api/v1"
 )
 
 func TestDefaultImageMetadataService_GetRemoteManifestConfig(t *testing.T) {
 }
+
+func TestDefaultImageMetadataService_History(t *testing.T) {
+	var (
+		created  = time.Date(2022, 4, 1, 10, 0, 0, 0, time.UTC)
+		recorded = digest.FromString("recorded")
+		counted  = digest.FromString("counted")
+		name     = "kubernetes:v1.19.8"
+	)
+	defer common.SetDataRoot(common.DefaultDataRoot)
+
+	fs := newTestImageFileService(t)
+	ims := DefaultImageMetadataService{imageStore: fs.imageStore}
+	for _, id := range []digest.Digest{recorded, counted} {
+		if err := os.MkdirAll(filepath.Join(common.DefaultLayerDir, id.Hex()), common.FileMode0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(common.DefaultLayerDir, counted.Hex(), "kubectl"), make([]byte, 1024), common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	image := v1.Image{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.ImageSpec{
+			ID: "kubernetes",
+			Layers: []v1.Layer{
+				{ID: counted, Type: "COPY", Value: "kubectl bin"},
+				{Type: "CMD", Value: "kubectl get nodes"},
+				{ID: recorded, Type: "BASE", Value: "rootfs cache", Size: 8 << 30, Created: &metav1.Time{Time: created}},
+			},
+		},
+	}
+	if err := fs.imageStore.Save(image, name); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := ims.History(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("History() got %d layers, want 3", len(history))
+	}
+	newest, oldest := history[0], history[2]
+	if newest.ID != recorded || newest.Size != 8<<30 || newest.Created == nil || !newest.Created.Equal(created) ||
+		newest.CreatedBy != "BASE rootfs cache" {
+		t.Errorf("History() newest layer = %+v", newest)
+	}
+	if history[1].ID != "" || history[1].Size != 0 || history[1].CreatedBy != "CMD kubectl get nodes" {
+		t.Errorf("History() empty layer = %+v", history[1])
+	}
+	if oldest.ID != counted || oldest.Size != 1024 || oldest.Created != nil {
+		t.Errorf("History() oldest layer = %+v, its size should be counted on disk", oldest)
+	}
+	if _, err = ims.History("kubernetes:not-exist"); err == nil {
+		t.Error("History() of a missing image should fail")
+	}
+}
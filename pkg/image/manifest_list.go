@@ -0,0 +1,194 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package image
+
+import (
+	"context"
+	"fmt"
+
+	dockerprogress "github.com/docker/docker/pkg/progress"
+
+	"github.com/alibaba/sealer/pkg/image/distributionutil"
+	"github.com/alibaba/sealer/pkg/image/reference"
+	"github.com/alibaba/sealer/pkg/image/store"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils/platform"
+)
+
+// DefaultManifestListService is the default service of manifest lists
+type DefaultManifestListService struct {
+	imageStore        store.ImageStore
+	manifestListStore store.ManifestListStore
+}
+
+// Create creates the manifest list listName of imageNames, the platform of an
+// entry is the one its image is built for. With amend, imageNames are added to
+// an existing list.
+func (d DefaultManifestListService) Create(listName string, imageNames []string, amend bool) error {
+	named, err := reference.ParseToNamed(listName)
+	if err != nil {
+		return err
+	}
+	list := &store.ManifestList{Name: named.Raw()}
+	if existing, err := d.manifestListStore.Get(named.Raw()); err == nil {
+		if !amend {
+			return fmt.Errorf("manifest list %s already exists, use --amend to add images to it", named.Raw())
+		}
+		list = existing
+	}
+
+	for _, imageName := range imageNames {
+		entry, err := d.newEntry(imageName)
+		if err != nil {
+			return err
+		}
+		if i := findEntry(list, entry.Image); i >= 0 {
+			list.Manifests[i] = entry
+			continue
+		}
+		list.Manifests = append(list.Manifests, entry)
+	}
+	return d.manifestListStore.Save(*list)
+}
+
+// Annotate overrides the platform of imageName in the list, empty fields of
+// platform are kept as they are.
+func (d DefaultManifestListService) Annotate(listName, imageName string, p v1.Platform) error {
+	list, err := d.get(listName)
+	if err != nil {
+		return err
+	}
+	named, err := reference.ParseToNamed(imageName)
+	if err != nil {
+		return err
+	}
+	i := findEntry(list, named.Raw())
+	if i < 0 {
+		return fmt.Errorf("image %s is not in manifest list %s", named.Raw(), list.Name)
+	}
+
+	entry := &list.Manifests[i]
+	if p.OS != "" {
+		entry.Platform.OS = p.OS
+	}
+	if p.Architecture != "" {
+		entry.Platform.Architecture = p.Architecture
+	}
+	if p.Variant != "" {
+		entry.Platform.Variant = p.Variant
+	}
+	if p.OSVersion != "" {
+		entry.Platform.OSVersion = p.OSVersion
+	}
+	entry.Platform = platform.Normalize(entry.Platform)
+	return d.manifestListStore.Save(*list)
+}
+
+func (d DefaultManifestListService) Inspect(listName string) (*store.ManifestList, error) {
+	return d.get(listName)
+}
+
+// Push pushes the images of the list into the repository of destination, and
+// tags their manifest list as destination. The list name is used if
+// destination is empty.
+func (d DefaultManifestListService) Push(ctx context.Context, listName, destination string) error {
+	list, err := d.get(listName)
+	if err != nil {
+		return err
+	}
+	if len(list.Manifests) == 0 {
+		return fmt.Errorf("manifest list %s is empty", list.Name)
+	}
+	if destination == "" {
+		destination = list.Name
+	}
+	named, err := reference.ParseToNamed(destination)
+	if err != nil {
+		return err
+	}
+
+	var images []distributionutil.PlatformImage
+	for _, entry := range list.Manifests {
+		image, err := d.imageStore.GetByID(entry.ImageID)
+		if err != nil {
+			return fmt.Errorf("failed to get image %s of manifest list %s: %v", entry.Image, list.Name, err)
+		}
+		images = append(images, distributionutil.PlatformImage{Name: entry.Image, Image: *image, Platform: entry.Platform})
+	}
+
+	layerStore, err := store.NewDefaultLayerStore()
+	if err != nil {
+		return err
+	}
+
+	progressChanOut, stop := displayProgress()
+	defer stop()
+
+	pusher, err := distributionutil.NewPusher(named,
+		distributionutil.Config{
+			LayerStore:     layerStore,
+			ProgressOutput: progressChanOut,
+		})
+	if err != nil {
+		return err
+	}
+
+	dockerprogress.Message(progressChanOut, "", fmt.Sprintf("Start to Push Manifest List %s", named.Raw()))
+	err = pusher.PushManifestList(ctx, named, images)
+	if err == nil {
+		dockerprogress.Message(progressChanOut, "", fmt.Sprintf("Success to Push Manifest List %s", named.CompleteName()))
+	}
+	return err
+}
+
+func (d DefaultManifestListService) Delete(listName string) error {
+	named, err := reference.ParseToNamed(listName)
+	if err != nil {
+		return err
+	}
+	return d.manifestListStore.Delete(named.Raw())
+}
+
+func (d DefaultManifestListService) get(listName string) (*store.ManifestList, error) {
+	named, err := reference.ParseToNamed(listName)
+	if err != nil {
+		return nil, err
+	}
+	return d.manifestListStore.Get(named.Raw())
+}
+
+func (d DefaultManifestListService) newEntry(imageName string) (store.ManifestListEntry, error) {
+	named, err := reference.ParseToNamed(imageName)
+	if err != nil {
+		return store.ManifestListEntry{}, err
+	}
+	image, err := d.imageStore.GetByName(named.Raw())
+	if err != nil {
+		return store.ManifestListEntry{}, fmt.Errorf("failed to get image %s: %v", named.Raw(), err)
+	}
+	return store.ManifestListEntry{
+		Image:    named.Raw(),
+		ImageID:  image.Spec.ID,
+		Platform: platform.Normalize(image.Spec.Platform),
+	}, nil
+}
+
+func findEntry(list *store.ManifestList, imageName string) int {
+	for i, entry := range list.Manifests {
+		if entry.Image == imageName {
+			return i
+		}
+	}
+	return -1
+}
@@ -147,6 +147,9 @@ func merge(base, ima *v1.Image) (*v1.Image, error) {
 	base.Spec.ImageConfig.Args = mergeImageArg(base.Spec.ImageConfig.Args, ima.Spec.ImageConfig.Args, isApp)
 	// merge image config cmd and remove duplicate value
 	base.Spec.ImageConfig.Cmd = mergeImageCmd(base.Spec.ImageConfig.Cmd, ima.Spec.ImageConfig.Cmd, isApp)
+	// merge image config env and labels, the latter image overwrite the former.
+	base.Spec.ImageConfig.Env = utils.MergeMap(base.Spec.ImageConfig.Env, ima.Spec.ImageConfig.Env)
+	base.Spec.ImageConfig.Labels = utils.MergeMap(base.Spec.ImageConfig.Labels, ima.Spec.ImageConfig.Labels)
 
 	// merge image layer
 	res := append(base.Spec.Layers, ima.Spec.Layers...)
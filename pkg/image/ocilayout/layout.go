@@ -0,0 +1,227 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ocilayout
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	indexFile = "index.json"
+	// AnnotationImageName is the full name of the CloudImage saved into the layout,
+	// the ref name annotation only keeps its tag.
+	AnnotationImageName = "io.sealer.image.name"
+)
+
+// Layout is an OCI image layout directory, see
+// https://github.com/opencontainers/image-spec/blob/main/image-layout.md
+type Layout struct {
+	root string
+}
+
+// New initializes an image layout at root, keeping the blobs and manifests
+// already in it.
+func New(root string) (*Layout, error) {
+	if err := os.MkdirAll(filepath.Join(root, "blobs", string(digest.Canonical)), common.FileMode0755); err != nil {
+		return nil, err
+	}
+	l := &Layout{root: root}
+	if _, err := os.Stat(filepath.Join(root, ocispec.ImageLayoutFile)); err == nil {
+		return l, l.checkVersion()
+	}
+	data, err := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+	if err != nil {
+		return nil, err
+	}
+	if err = utils.AtomicWriteFile(filepath.Join(root, ocispec.ImageLayoutFile), data, common.FileMode0644); err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(filepath.Join(root, indexFile)); os.IsNotExist(err) {
+		return l, l.writeIndex(ocispec.Index{Versioned: specs.Versioned{SchemaVersion: 2}})
+	}
+	return l, nil
+}
+
+// Open opens the existing image layout at root.
+func Open(root string) (*Layout, error) {
+	l := &Layout{root: root}
+	return l, l.checkVersion()
+}
+
+// IsLayout reports whether dir is an image layout.
+func IsLayout(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ocispec.ImageLayoutFile))
+	return err == nil
+}
+
+func (l *Layout) checkVersion() error {
+	data, err := ioutil.ReadFile(filepath.Join(l.root, ocispec.ImageLayoutFile))
+	if err != nil {
+		return fmt.Errorf("%s is not an OCI image layout: %v", l.root, err)
+	}
+	var layout ocispec.ImageLayout
+	if err = json.Unmarshal(data, &layout); err != nil {
+		return fmt.Errorf("failed to parse %s of %s: %v", ocispec.ImageLayoutFile, l.root, err)
+	}
+	if layout.Version != ocispec.ImageLayoutVersion {
+		return fmt.Errorf("unsupported OCI image layout version %s of %s", layout.Version, l.root)
+	}
+	return nil
+}
+
+func (l *Layout) blobPath(dgst digest.Digest) string {
+	return filepath.Join(l.root, "blobs", dgst.Algorithm().String(), dgst.Hex())
+}
+
+// WriteBlob stores the content of r as a blob of mediaType.
+func (l *Layout) WriteBlob(r io.Reader, mediaType string) (ocispec.Descriptor, error) {
+	tmp, err := ioutil.TempFile(filepath.Join(l.root, "blobs"), ".blob")
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(io.MultiWriter(tmp, digester.Hash()), r)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if err = tmp.Close(); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if err = os.Chmod(tmp.Name(), common.FileMode0644); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: digester.Digest(), Size: size}
+	return desc, os.Rename(tmp.Name(), l.blobPath(desc.Digest))
+}
+
+// WriteJSON stores v marshaled as a blob of mediaType.
+func (l *Layout) WriteJSON(v interface{}, mediaType string) (ocispec.Descriptor, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	return desc, utils.AtomicWriteFile(l.blobPath(desc.Digest), data, common.FileMode0644)
+}
+
+// OpenBlob opens the blob of desc, the content is verified against the
+// digest once it is read to the end.
+func (l *Layout) OpenBlob(desc ocispec.Descriptor) (io.ReadCloser, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(l.blobPath(desc.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob %s: %v", desc.Digest, err)
+	}
+	return &verifiedReader{f: f, desc: desc, verifier: desc.Digest.Verifier()}, nil
+}
+
+// ReadJSON reads the blob of desc into v.
+func (l *Layout) ReadJSON(desc ocispec.Descriptor, v interface{}) error {
+	rc, err := l.OpenBlob(desc)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Index returns the index.json of the layout.
+func (l *Layout) Index() (ocispec.Index, error) {
+	var index ocispec.Index
+	data, err := ioutil.ReadFile(filepath.Join(l.root, indexFile))
+	if err != nil {
+		return index, err
+	}
+	return index, json.Unmarshal(data, &index)
+}
+
+// AddManifest adds desc into index.json, replacing the manifest of the same
+// image name, ref name and platform. Images of different names sharing a tag
+// are kept side by side.
+func (l *Layout) AddManifest(desc ocispec.Descriptor) error {
+	index, err := l.Index()
+	if err != nil {
+		return err
+	}
+	var manifests []ocispec.Descriptor
+	for _, m := range index.Manifests {
+		if m.Annotations[AnnotationImageName] == desc.Annotations[AnnotationImageName] &&
+			m.Annotations[ocispec.AnnotationRefName] == desc.Annotations[ocispec.AnnotationRefName] &&
+			samePlatform(m.Platform, desc.Platform) {
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+	index.Manifests = append(manifests, desc)
+	return l.writeIndex(index)
+}
+
+func (l *Layout) writeIndex(index ocispec.Index) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return utils.AtomicWriteFile(filepath.Join(l.root, indexFile), data, common.FileMode0644)
+}
+
+func samePlatform(a, b *ocispec.Platform) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.OS == b.OS && a.Architecture == b.Architecture && a.Variant == b.Variant
+}
+
+type verifiedReader struct {
+	f        *os.File
+	desc     ocispec.Descriptor
+	verifier digest.Verifier
+	read     int64
+}
+
+func (r *verifiedReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	r.read += int64(n)
+	_, _ = r.verifier.Write(p[:n])
+	if err == io.EOF && (r.read != r.desc.Size || !r.verifier.Verified()) {
+		return n, fmt.Errorf("blob %s is corrupted", r.desc.Digest)
+	}
+	return n, err
+}
+
+func (r *verifiedReader) Close() error {
+	return r.f.Close()
+}
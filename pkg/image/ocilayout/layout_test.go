@@ -0,0 +1,152 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ocilayout
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/alibaba/sealer/common"
+)
+
+func TestLayout_Blob(t *testing.T) {
+	l, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := l.WriteBlob(strings.NewReader("sealer"), ocispec.MediaTypeImageLayerGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Size != 6 || desc.MediaType != ocispec.MediaTypeImageLayerGzip {
+		t.Fatalf("unexpected descriptor %+v", desc)
+	}
+
+	rc, err := l.OpenBlob(desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(rc)
+	_ = rc.Close()
+	if err != nil || string(data) != "sealer" {
+		t.Fatalf("read blob %q, %v", data, err)
+	}
+
+	if err = ioutil.WriteFile(l.blobPath(desc.Digest), []byte("sealer!"), common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	rc, err = l.OpenBlob(desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err = ioutil.ReadAll(rc); err == nil {
+		t.Error("expected corrupted blob to fail verification")
+	}
+}
+
+func TestLayout_AddManifest(t *testing.T) {
+	root := t.TempDir()
+	l, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := func(tag, arch string) ocispec.Descriptor {
+		desc, err := l.WriteJSON(ocispec.Manifest{Annotations: map[string]string{"arch": arch}}, ocispec.MediaTypeImageManifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc.Annotations = map[string]string{ocispec.AnnotationRefName: tag}
+		desc.Platform = &ocispec.Platform{OS: "linux", Architecture: arch}
+		return desc
+	}
+	for _, desc := range []ocispec.Descriptor{
+		manifest("v1", "amd64"),
+		manifest("v1", "arm64"),
+		manifest("v2", "amd64"),
+		manifest("v1", "amd64"),
+	} {
+		if err = l.AddManifest(desc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !IsLayout(root) || IsLayout(filepath.Join(root, "blobs")) {
+		t.Error("IsLayout() does not match the layout root")
+	}
+	l, err = Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := l.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 3 {
+		t.Fatalf("got %d manifests in index, want 3", len(index.Manifests))
+	}
+	last := index.Manifests[2]
+	if last.Annotations[ocispec.AnnotationRefName] != "v1" || last.Platform.Architecture != "amd64" {
+		t.Errorf("replaced manifest should be last, got %+v", last)
+	}
+	var m ocispec.Manifest
+	if err = l.ReadJSON(last, &m); err != nil || m.Annotations["arch"] != "amd64" {
+		t.Errorf("failed to read manifest %+v: %v", m, err)
+	}
+
+	if l, err = New(root); err != nil {
+		t.Fatalf("failed to reopen layout: %v", err)
+	}
+	if index, err = l.Index(); err != nil || len(index.Manifests) != 3 {
+		t.Errorf("New() should keep existing manifests, got %d: %v", len(index.Manifests), err)
+	}
+}
+
+func TestLayout_AddManifestOfAnotherImage(t *testing.T) {
+	l, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := func(name string) ocispec.Descriptor {
+		desc, err := l.WriteJSON(ocispec.Manifest{Annotations: map[string]string{"name": name}}, ocispec.MediaTypeImageManifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc.Annotations = map[string]string{ocispec.AnnotationRefName: "v1", AnnotationImageName: name}
+		desc.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+		return desc
+	}
+	for _, name := range []string{"kubernetes:v1", "calico:v1"} {
+		if err = l.AddManifest(manifest(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	index, err := l.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 2 {
+		t.Fatalf("got %d manifests in index, want 2", len(index.Manifests))
+	}
+	for i, name := range []string{"kubernetes:v1", "calico:v1"} {
+		if got := index.Manifests[i].Annotations[AnnotationImageName]; got != name {
+			t.Errorf("manifest %d is of image %s, want %s", i, got, name)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+)
+
+const (
+	privateKeyPEMType = "PRIVATE KEY"
+	publicKeyPEMType  = "PUBLIC KEY"
+
+	PrivateKeySuffix = ".key"
+	PublicKeySuffix  = ".pub"
+)
+
+// GenerateKeyPair writes a new ed25519 key pair to prefix.key and prefix.pub, and
+// returns their paths. The existing keys are never overwritten.
+func GenerateKeyPair(prefix string) (privatePath, publicPath string, err error) {
+	privatePath, publicPath = prefix+PrivateKeySuffix, prefix+PublicKeySuffix
+	for _, path := range []string{privatePath, publicPath} {
+		if _, err = os.Stat(path); err == nil {
+			return "", "", fmt.Errorf("key %s already exists", path)
+		}
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", "", err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", "", err
+	}
+	if err = ioutil.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: privateKeyPEMType, Bytes: privDER}), 0600); err != nil {
+		return "", "", fmt.Errorf("failed to write private key: %v", err)
+	}
+	if err = ioutil.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: publicKeyPEMType, Bytes: pubDER}), common.FileMode0644); err != nil {
+		return "", "", fmt.Errorf("failed to write public key: %v", err)
+	}
+	return privatePath, publicPath, nil
+}
+
+// LoadPrivateKey reads the private key generated by GenerateKeyPair.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, privateKeyPEMType)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %v", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an ed25519 key", path)
+	}
+	return priv, nil
+}
+
+// LoadPublicKey reads the public key generated by GenerateKeyPair.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, publicKeyPEMType)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %v", path, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an ed25519 key", path)
+	}
+	return pub, nil
+}
+
+// KeyID returns the digest of the public key, which identifies the key made a signature.
+func KeyID(pub ed25519.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(der).String(), nil
+}
+
+func readPEM(path, pemType string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %v", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, fmt.Errorf("key %s is not a PEM encoded %s", path, pemType)
+	}
+	return block.Bytes, nil
+}
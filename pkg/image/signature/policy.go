@@ -0,0 +1,159 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signature
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/logger"
+)
+
+const (
+	// RequirementAccept accepts the images without checking their signatures.
+	RequirementAccept = "accept"
+	// RequirementReject rejects all the images.
+	RequirementReject = "reject"
+	// RequirementSigned accepts the images signed by one of the keys.
+	RequirementSigned = "signed"
+)
+
+// Requirement is what the trust policy requires of the images in its scope.
+type Requirement struct {
+	Type string `json:"type"`
+	// Keys are the paths of the public keys, a signature made by any of them is trusted.
+	Keys []string `json:"keys,omitempty"`
+
+	publicKeys []ed25519.PublicKey
+}
+
+// Policy is the trust policy of CloudImages, like:
+//
+//	{
+//	  "default": {"type": "reject"},
+//	  "images": {
+//	    "registry.cn-qingdao.aliyuncs.com/sealer-io": {"type": "signed", "keys": ["/etc/sealer/keys/sealer-io.pub"]},
+//	    "sea.hub:5000": {"type": "accept"}
+//	  }
+//	}
+//
+// The scopes of images are a registry, a namespace or a repository, the longest
+// scope containing the image is used, otherwise the default.
+type Policy struct {
+	Default Requirement            `json:"default"`
+	Images  map[string]Requirement `json:"images,omitempty"`
+}
+
+// LoadPolicy reads the policy at path and the keys in it. All the images are
+// accepted if there is no policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	policy := &Policy{Default: Requirement{Type: RequirementAccept}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return policy, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust policy: %v", err)
+	}
+	if err = json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse trust policy %s: %v", path, err)
+	}
+	if err = policy.Default.load(); err != nil {
+		return nil, fmt.Errorf("invalid default of trust policy %s: %v", path, err)
+	}
+	for scope, req := range policy.Images {
+		if err = req.load(); err != nil {
+			return nil, fmt.Errorf("invalid requirement of %s in trust policy %s: %v", scope, path, err)
+		}
+		policy.Images[scope] = req
+	}
+	return policy, nil
+}
+
+func (r *Requirement) load() error {
+	switch r.Type {
+	case RequirementAccept, RequirementReject:
+		return nil
+	case RequirementSigned:
+	default:
+		return fmt.Errorf("unknown requirement type %q", r.Type)
+	}
+	if len(r.Keys) == 0 {
+		return fmt.Errorf("no keys to verify the signatures")
+	}
+	r.publicKeys = nil
+	for _, path := range r.Keys {
+		key, err := LoadPublicKey(path)
+		if err != nil {
+			return err
+		}
+		r.publicKeys = append(r.publicKeys, key)
+	}
+	return nil
+}
+
+// RequirementFor returns the requirement of the images in repository, which is the image
+// name without tag.
+func (p *Policy) RequirementFor(repository string) Requirement {
+	var (
+		scope string
+		req   = p.Default
+	)
+	for s, r := range p.Images {
+		s = strings.TrimSuffix(s, "/")
+		if (repository == s || strings.HasPrefix(repository, s+"/")) && len(s) > len(scope) {
+			scope, req = s, r
+		}
+	}
+	return req
+}
+
+// RequiresSignature reports whether the images in repository have to be signed.
+func (p *Policy) RequiresSignature(repository string) bool {
+	return p.RequirementFor(repository).Type == RequirementSigned
+}
+
+// Verify checks the image of digest dgst in repository by the policy, and returns the
+// trusted ones of sigs.
+func (p *Policy) Verify(repository string, dgst digest.Digest, sigs []Signature) ([]Signature, error) {
+	req := p.RequirementFor(repository)
+	switch req.Type {
+	case RequirementAccept:
+		return nil, nil
+	case RequirementReject:
+		return nil, fmt.Errorf("image %s is rejected by the trust policy", repository)
+	}
+
+	if dgst == "" {
+		return nil, fmt.Errorf("image %s has to be signed, but its image digest is unknown", repository)
+	}
+	var trusted []Signature
+	for _, sig := range sigs {
+		if _, err := sig.Verify(req.publicKeys, repository, dgst); err != nil {
+			logger.Debug("skip signature of %s@%s: %v", repository, dgst, err)
+			continue
+		}
+		trusted = append(trusted, sig)
+	}
+	if len(trusted) == 0 {
+		return nil, fmt.Errorf("image %s@%s is not signed by the keys trusted by the policy, %d signatures checked", repository, dgst, len(sigs))
+	}
+	return trusted, nil
+}
@@ -0,0 +1,108 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signature
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	_, _, publicPath := generateTestKey(t, "sealer-io")
+	tests := []struct {
+		name    string
+		policy  string
+		wantErr bool
+	}{
+		{"no policy file", "", false},
+		{"signed", fmt.Sprintf(`{"default":{"type":"reject"},"images":{"sea.hub:5000":{"type":"signed","keys":[%q]}}}`, publicPath), false},
+		{"unknown type", `{"default":{"type":"insecureAcceptAnything"}}`, true},
+		{"signed without keys", `{"default":{"type":"signed"}}`, true},
+		{"missing key", `{"default":{"type":"signed","keys":["/not/exist.pub"]}}`, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("policy-%d.json", i))
+			if tt.policy != "" {
+				if err := ioutil.WriteFile(path, []byte(tt.policy), common.FileMode0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := LoadPolicy(path); (err != nil) != tt.wantErr {
+				t.Errorf("LoadPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicy_Verify(t *testing.T) {
+	var (
+		priv, _, publicPath = generateTestKey(t, "sealer-io")
+		dgst                = digest.FromString("manifest")
+		path                = filepath.Join(t.TempDir(), "policy.json")
+	)
+	policyJSON := fmt.Sprintf(`{
+  "default": {"type": "reject"},
+  "images": {
+    "registry.cn-qingdao.aliyuncs.com": {"type": "accept"},
+    "registry.cn-qingdao.aliyuncs.com/sealer-io/": {"type": "signed", "keys": [%q]},
+    "registry.cn-qingdao.aliyuncs.com/sealer-io/dashboard": {"type": "accept"}
+  }
+}`, publicPath)
+	if err := ioutil.WriteFile(path, []byte(policyJSON), common.FileMode0644); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := Sign(priv, testRepository, dgst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		repository string
+		dgst       digest.Digest
+		sigs       []Signature
+		wantErr    bool
+	}{
+		{"signed", testRepository, dgst, []Signature{sig}, false},
+		{"unsigned", testRepository, dgst, nil, true},
+		{"signed for other manifest", testRepository, digest.FromString("other"), []Signature{sig}, true},
+		{"unknown digest", testRepository, "", []Signature{sig}, true},
+		{"accepted repository in signed namespace", "registry.cn-qingdao.aliyuncs.com/sealer-io/dashboard", dgst, nil, false},
+		{"accepted registry", "registry.cn-qingdao.aliyuncs.com/sealer-io-test/kubernetes", dgst, nil, false},
+		{"default", "docker.io/library/kubernetes", dgst, []Signature{sig}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trusted, err := policy.Verify(tt.repository, tt.dgst, tt.sigs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if policy.RequiresSignature(tt.repository) && err == nil && len(trusted) != 1 {
+				t.Errorf("Verify() got %d trusted signatures, want 1", len(trusted))
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// Payload is what a signature is made over. It binds the image digest to the repository
+// the image is pushed to, so a signed image could not be passed off as another one.
+type Payload struct {
+	// Repository is the image name without tag, like registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes.
+	Repository string `json:"repository"`
+	// ImageDigest is the digest of the image config, which covers the ids of all the layers.
+	ImageDigest digest.Digest `json:"imageDigest"`
+	Created     time.Time     `json:"created"`
+}
+
+// Signature is a detached signature of a CloudImage.
+type Signature struct {
+	// KeyID is the digest of the public key to verify the signature.
+	KeyID     string `json:"keyID"`
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// Sign signs the image of digest dgst in repository by key.
+func Sign(key ed25519.PrivateKey, repository string, dgst digest.Digest) (Signature, error) {
+	if err := dgst.Validate(); err != nil {
+		return Signature{}, fmt.Errorf("failed to sign invalid image digest %q: %v", dgst, err)
+	}
+	payload, err := json.Marshal(Payload{Repository: repository, ImageDigest: dgst, Created: time.Now().UTC()})
+	if err != nil {
+		return Signature{}, err
+	}
+	pub, ok := key.Public().(ed25519.PublicKey)
+	if !ok {
+		return Signature{}, fmt.Errorf("failed to get public key of private key")
+	}
+	keyID, err := KeyID(pub)
+	if err != nil {
+		return Signature{}, err
+	}
+	return Signature{KeyID: keyID, Payload: payload, Signature: ed25519.Sign(key, payload)}, nil
+}
+
+// Verify checks the signature is made by one of keys over the image of digest dgst in repository.
+func (s Signature) Verify(keys []ed25519.PublicKey, repository string, dgst digest.Digest) (Payload, error) {
+	var payload Payload
+	for _, key := range keys {
+		if id, err := KeyID(key); err != nil || id != s.KeyID {
+			continue
+		}
+		if !ed25519.Verify(key, s.Payload, s.Signature) {
+			return payload, fmt.Errorf("signature made by key %s is invalid", s.KeyID)
+		}
+		if err := json.Unmarshal(s.Payload, &payload); err != nil {
+			return payload, fmt.Errorf("failed to parse signature payload: %v", err)
+		}
+		if payload.Repository != repository || payload.ImageDigest != dgst {
+			return payload, fmt.Errorf("signature is made for %s@%s, not %s@%s",
+				payload.Repository, payload.ImageDigest, repository, dgst)
+		}
+		return payload, nil
+	}
+	return payload, fmt.Errorf("key %s of signature is not trusted", s.KeyID)
+}
+
+// Merge appends the signatures not in sigs yet.
+func Merge(sigs []Signature, more ...Signature) []Signature {
+	for _, m := range more {
+		found := false
+		for _, s := range sigs {
+			if bytes.Equal(s.Signature, m.Signature) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sigs = append(sigs, m)
+		}
+	}
+	return sigs
+}
@@ -0,0 +1,121 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signature
+
+import (
+	"crypto/ed25519"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const testRepository = "registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes"
+
+func generateTestKey(t *testing.T, name string) (ed25519.PrivateKey, ed25519.PublicKey, string) {
+	privatePath, publicPath, err := GenerateKeyPair(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := LoadPrivateKey(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := LoadPublicKey(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, pub, publicPath
+}
+
+func TestSignature_Verify(t *testing.T) {
+	var (
+		priv, pub, _ = generateTestKey(t, "trusted")
+		_, other, _  = generateTestKey(t, "other")
+		dgst         = digest.FromString("manifest")
+	)
+	sig, err := Sign(priv, testRepository, dgst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := sig
+	tampered.Payload = append([]byte(" "), sig.Payload...)
+
+	tests := []struct {
+		name       string
+		sig        Signature
+		keys       []ed25519.PublicKey
+		repository string
+		dgst       digest.Digest
+		wantErr    bool
+	}{
+		{"trusted", sig, []ed25519.PublicKey{other, pub}, testRepository, dgst, false},
+		{"untrusted key", sig, []ed25519.PublicKey{other}, testRepository, dgst, true},
+		{"tampered payload", tampered, []ed25519.PublicKey{pub}, testRepository, dgst, true},
+		{"other repository", sig, []ed25519.PublicKey{pub}, "docker.io/library/kubernetes", dgst, true},
+		{"other manifest", sig, []ed25519.PublicKey{pub}, testRepository, digest.FromString("other"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.sig.Verify(tt.keys, tt.repository, tt.dgst)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (payload.Repository != testRepository || payload.ImageDigest != dgst) {
+				t.Errorf("Verify() payload = %+v", payload)
+			}
+		})
+	}
+}
+
+func TestGenerateKeyPair_KeepsExistingKeys(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "sealer")
+	if _, _, err := GenerateKeyPair(prefix); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := GenerateKeyPair(prefix); err == nil {
+		t.Error("GenerateKeyPair() should not overwrite the existing keys")
+	}
+	if _, err := LoadPublicKey(prefix + PrivateKeySuffix); err == nil {
+		t.Error("LoadPublicKey() should refuse a private key")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	var (
+		s          = &fileStore{root: t.TempDir()}
+		priv, _, _ = generateTestKey(t, "sealer")
+		dgst       = digest.FromString("manifest")
+		sig1, err1 = Sign(priv, testRepository, dgst)
+		sig2, err2 = Sign(priv, "sea.hub:5000/sealer-io/kubernetes", dgst)
+	)
+	if err1 != nil || err2 != nil {
+		t.Fatal(err1, err2)
+	}
+	if sigs, err := s.Get(dgst); err != nil || len(sigs) != 0 {
+		t.Fatalf("Get() of unsigned manifest = %v, %v", sigs, err)
+	}
+	for _, sigs := range [][]Signature{{sig1}, {sig1, sig2}, {sig2}} {
+		if err := s.Add(dgst, sigs...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sigs, err := s.Get(dgst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != 2 {
+		t.Errorf("Get() got %d signatures, want 2", len(sigs))
+	}
+}
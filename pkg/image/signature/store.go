@@ -0,0 +1,84 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	pkgutils "github.com/alibaba/sealer/utils"
+)
+
+// Store keeps the signatures of images locally, so the images could be verified offline.
+type Store interface {
+	Get(dgst digest.Digest) ([]Signature, error)
+
+	// Add keeps sigs of the image digest dgst, the signatures already kept are skipped.
+	Add(dgst digest.Digest, sigs ...Signature) error
+}
+
+type fileStore struct {
+	root string
+}
+
+func (fs *fileStore) path(dgst digest.Digest) string {
+	return filepath.Join(fs.root, dgst.Algorithm().String(), dgst.Hex()+".json")
+}
+
+func (fs *fileStore) Get(dgst digest.Digest) ([]Signature, error) {
+	if err := dgst.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid image digest %q: %v", dgst, err)
+	}
+	data, err := ioutil.ReadFile(fs.path(dgst))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signatures of %s: %v", dgst, err)
+	}
+	var sigs []Signature
+	if err = json.Unmarshal(data, &sigs); err != nil {
+		return nil, fmt.Errorf("failed to parse signatures of %s: %v", dgst, err)
+	}
+	return sigs, nil
+}
+
+func (fs *fileStore) Add(dgst digest.Digest, sigs ...Signature) error {
+	existing, err := fs.Get(dgst)
+	if err != nil {
+		return err
+	}
+	merged := Merge(existing, sigs...)
+	if len(merged) == len(existing) {
+		return nil
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(fs.path(dgst)), common.FileMode0755); err != nil {
+		return err
+	}
+	return pkgutils.AtomicWriteFile(fs.path(dgst), data, common.FileMode0644)
+}
+
+func NewDefaultStore() Store {
+	return &fileStore{root: common.DefaultSignatureDir}
+}
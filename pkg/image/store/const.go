@@ -14,16 +14,8 @@
 
 package store
 
-import (
-	"github.com/alibaba/sealer/common"
-)
-
 const (
-	DefaultLayerTarName   = "layer.tar.gz"
-	layerDataRoot         = common.DefaultLayerDir
-	layerDBRoot           = common.DefaultLayerDBRoot
-	imageDBRoot           = common.DefaultImageDBRootDir
-	imageMetadataFilePath = common.DefaultImageMetadataFile
-	DefaultJSONIndent     = "\t"
-	tarDataGZ             = "tar-data.json.gz"
+	DefaultLayerTarName = "layer.tar.gz"
+	DefaultJSONIndent   = "\t"
+	tarDataGZ           = "tar-data.json.gz"
 )
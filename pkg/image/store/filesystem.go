@@ -80,22 +80,22 @@ type ImageMetadataMap map[string]types.ImageMetadata
 
 func NewFSStoreBackend() (Backend, error) {
 	return &filesystem{
-		layerDataRoot:         layerDataRoot,
-		layerDBRoot:           layerDBRoot,
-		imageDBRoot:           imageDBRoot,
-		imageMetadataFilePath: imageMetadataFilePath,
+		layerDataRoot:         common.DefaultLayerDir,
+		layerDBRoot:           common.DefaultLayerDBRoot,
+		imageDBRoot:           common.DefaultImageDBRootDir,
+		imageMetadataFilePath: common.DefaultImageMetadataFile,
 	}, nil
 }
 
 func metadataDir(v interface{}) string {
 	switch val := v.(type) {
 	case digest.Digest:
-		return filepath.Join(imageDBRoot, val.Hex()+common.YamlSuffix)
+		return filepath.Join(common.DefaultImageDBRootDir, val.Hex()+common.YamlSuffix)
 	case string:
 		if strings.Contains(val, common.YamlSuffix) {
-			return filepath.Join(imageDBRoot, val)
+			return filepath.Join(common.DefaultImageDBRootDir, val)
 		}
-		return filepath.Join(imageDBRoot, val+common.YamlSuffix)
+		return filepath.Join(common.DefaultImageDBRootDir, val+common.YamlSuffix)
 	}
 
 	return ""
@@ -510,7 +510,12 @@ func (fs *filesystem) saveImage(image v1.Image, name string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get image %s size, %v", name, err)
 	}
-	return fs.setImageMetadata(types.ImageMetadata{Name: name, ID: image.Spec.ID, SIZE: size})
+	metadata := types.ImageMetadata{Name: name, ID: image.Spec.ID, SIZE: size}
+	// the manifest digest is still of the same image saved again, like one loaded or pulled twice.
+	if existing, err := fs.getImageMetadataItem(name); err == nil && existing.ID == image.Spec.ID {
+		metadata.ManifestDigest = existing.ManifestDigest
+	}
+	return fs.setImageMetadata(metadata)
 }
 
 func saveImageYaml(image v1.Image, dir string) error {
@@ -62,7 +62,7 @@ var images = []v1.Image{
 }
 
 var dirs = []string{
-	imageDBRoot,
+	common.DefaultImageDBRootDir,
 	common.DefaultTmpDir,
 }
 
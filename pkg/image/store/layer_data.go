@@ -0,0 +1,75 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/utils/archive"
+)
+
+// DecompressLayer extracts the layer tar stream in src to the data dir of layerID, and returns the size of it.
+// The layer id is the digest of the tar stream, which the image config and the signatures over it refer to,
+// so the stream is hashed while it is extracted, and the layer is removed if it is not the content of layerID.
+func DecompressLayer(backend Backend, layerID digest.Digest, src io.Reader, compressed bool) (int64, error) {
+	if err := layerID.Validate(); err != nil {
+		return 0, fmt.Errorf("invalid layer id %s: %v", layerID, err)
+	}
+	if compressed {
+		gz, err := gzip.NewReader(src)
+		if err != nil {
+			return 0, err
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	var (
+		digester     = layerID.Algorithm().Digester()
+		reader       = io.TeeReader(src, digester.Hash())
+		layerDataDir = backend.LayerDataDir(layerID)
+	)
+	size, err := archive.Decompress(reader, layerDataDir, archive.Options{Compress: false})
+	if err == nil {
+		// the tar reader stops at the end of archive, hash the padding after it as well.
+		_, err = io.Copy(ioutil.Discard, reader)
+	}
+	if err == nil && digester.Digest() != layerID {
+		err = fmt.Errorf("layer %s is corrupted, got content digest %s", layerID, digester.Digest())
+	}
+	if err != nil {
+		_ = os.RemoveAll(layerDataDir)
+		return 0, err
+	}
+	return size, nil
+}
+
+// VerifyLayerData checks the extracted data of layerID is the content the layer id is computed from.
+func VerifyLayerData(backend Backend, layerID digest.Digest) error {
+	dgst, _, err := archive.TarCanonicalDigest(backend.LayerDataDir(layerID))
+	if err != nil {
+		return fmt.Errorf("failed to get content digest of layer %s: %v", layerID, err)
+	}
+	if dgst != layerID {
+		return fmt.Errorf("layer %s is corrupted, got content digest %s", layerID, dgst)
+	}
+	return nil
+}
@@ -98,11 +98,11 @@ func TestLayerStore_RegisterLayerForBuilder(t *testing.T) {
 		}
 	}
 
-	err = os.MkdirAll(layerDataRoot, common.FileMode0755)
+	err = os.MkdirAll(common.DefaultLayerDir, common.FileMode0755)
 	if err != nil {
 		t.Error(err)
 	}
-	err = os.MkdirAll(layerDBRoot, common.FileMode0755)
+	err = os.MkdirAll(common.DefaultLayerDBRoot, common.FileMode0755)
 	if err != nil {
 		t.Error(err)
 	}
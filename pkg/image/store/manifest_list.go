@@ -0,0 +1,136 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/alibaba/sealer/common"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	pkgutils "github.com/alibaba/sealer/utils"
+)
+
+// ManifestList is a local list of CloudImages built for different platforms,
+// which is pushed as a manifest list under one name.
+type ManifestList struct {
+	Name      string              `json:"name"`
+	Manifests []ManifestListEntry `json:"manifests"`
+}
+
+// ManifestListEntry is a local image of a ManifestList.
+type ManifestListEntry struct {
+	Image    string      `json:"image"`
+	ImageID  string      `json:"image_id"`
+	Platform v1.Platform `json:"platform"`
+}
+
+type ManifestListStore interface {
+	Get(name string) (*ManifestList, error)
+
+	List() ([]ManifestList, error)
+
+	Save(list ManifestList) error
+
+	Delete(name string) error
+}
+
+type manifestListStore struct {
+	file string
+}
+
+func (ms *manifestListStore) load() (map[string]ManifestList, error) {
+	lists := map[string]ManifestList{}
+	data, err := ioutil.ReadFile(ms.file)
+	if os.IsNotExist(err) {
+		return lists, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest lists: %v", err)
+	}
+	if len(data) == 0 {
+		return lists, nil
+	}
+	if err = json.Unmarshal(data, &lists); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest lists %s: %v", ms.file, err)
+	}
+	return lists, nil
+}
+
+func (ms *manifestListStore) store(lists map[string]ManifestList) error {
+	data, err := json.MarshalIndent(lists, "", DefaultJSONIndent)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(ms.file), common.FileMode0755); err != nil {
+		return err
+	}
+	return pkgutils.AtomicWriteFile(ms.file, data, common.FileMode0644)
+}
+
+func (ms *manifestListStore) Get(name string) (*ManifestList, error) {
+	lists, err := ms.load()
+	if err != nil {
+		return nil, err
+	}
+	list, ok := lists[name]
+	if !ok {
+		return nil, fmt.Errorf("manifest list %s not found", name)
+	}
+	return &list, nil
+}
+
+func (ms *manifestListStore) List() ([]ManifestList, error) {
+	lists, err := ms.load()
+	if err != nil {
+		return nil, err
+	}
+	var res []ManifestList
+	for _, list := range lists {
+		res = append(res, list)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+func (ms *manifestListStore) Save(list ManifestList) error {
+	lists, err := ms.load()
+	if err != nil {
+		return err
+	}
+	lists[list.Name] = list
+	return ms.store(lists)
+}
+
+func (ms *manifestListStore) Delete(name string) error {
+	lists, err := ms.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := lists[name]; !ok {
+		return fmt.Errorf("manifest list %s not found", name)
+	}
+	delete(lists, name)
+	return ms.store(lists)
+}
+
+func NewDefaultManifestListStore() ManifestListStore {
+	return &manifestListStore{file: common.DefaultManifestListFile}
+}
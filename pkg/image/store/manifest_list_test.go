@@ -0,0 +1,74 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestManifestListStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sealer-manifest-list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ms := &manifestListStore{file: filepath.Join(dir, "metadata", "manifest_lists.json")}
+	if _, err = ms.Get("kubernetes:v1.19.8"); err == nil {
+		t.Fatalf("get manifest list from an empty store should fail")
+	}
+
+	list := ManifestList{
+		Name: "kubernetes:v1.19.8",
+		Manifests: []ManifestListEntry{
+			{Image: "kubernetes:v1.19.8-amd64", ImageID: "imagea", Platform: v1.Platform{OS: "linux", Architecture: "amd64"}},
+			{Image: "kubernetes:v1.19.8-arm64", ImageID: "imageb", Platform: v1.Platform{OS: "linux", Architecture: "arm64"}},
+		},
+	}
+	if err = ms.Save(list); err != nil {
+		t.Fatalf("failed to save manifest list: %v", err)
+	}
+	if err = ms.Save(ManifestList{Name: "calico:v3.19"}); err != nil {
+		t.Fatalf("failed to save manifest list: %v", err)
+	}
+
+	got, err := ms.Get(list.Name)
+	if err != nil {
+		t.Fatalf("failed to get manifest list: %v", err)
+	}
+	if !reflect.DeepEqual(*got, list) {
+		t.Errorf("Get() = %v, want %v", *got, list)
+	}
+
+	lists, err := ms.List()
+	if err != nil {
+		t.Fatalf("failed to list manifest lists: %v", err)
+	}
+	if len(lists) != 2 || lists[0].Name != "calico:v3.19" {
+		t.Errorf("List() = %v, want 2 lists sorted by name", lists)
+	}
+
+	if err = ms.Delete(list.Name); err != nil {
+		t.Fatalf("failed to delete manifest list: %v", err)
+	}
+	if err = ms.Delete(list.Name); err == nil {
+		t.Errorf("delete a deleted manifest list should fail")
+	}
+}
@@ -14,11 +14,26 @@
 
 package types
 
-import "time"
+import (
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
 
 type ImageMetadata struct {
 	Name    string    `json:"name,omitempty"`
 	ID      string    `json:"id,omitempty"`
 	CREATED time.Time `json:"created,omitempty"`
 	SIZE    int64     `json:"size,omitempty"`
+	// ManifestDigest is the digest of the manifest the image is pulled from.
+	ManifestDigest digest.Digest `json:"manifest_digest,omitempty"`
+}
+
+// ImageHistory is a layer of an image, which sealer history lists from the newest one.
+type ImageHistory struct {
+	ID digest.Digest `json:"id,omitempty"`
+	// Created is nil for the layers built by the sealer not recording it.
+	Created   *time.Time `json:"created,omitempty"`
+	CreatedBy string     `json:"created_by"`
+	Size      int64      `json:"size"`
 }
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -26,64 +27,101 @@ import (
 
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	"github.com/alibaba/sealer/logger"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	"github.com/alibaba/sealer/version"
 )
 
 const (
-	Run  = "RUN"
-	Cmd  = "CMD"
-	Copy = "COPY"
-	From = "FROM"
-	Arg  = "ARG"
+	Run   = "RUN"
+	Cmd   = "CMD"
+	Copy  = "COPY"
+	From  = "FROM"
+	Arg   = "ARG"
+	Env   = "ENV"
+	Label = "LABEL"
 )
 
 var validCommands = map[string]bool{
-	Run:  true,
-	Cmd:  true,
-	Copy: true,
-	From: true,
-	Arg:  true,
+	Run:   true,
+	Cmd:   true,
+	Copy:  true,
+	From:  true,
+	Arg:   true,
+	Env:   true,
+	Label: true,
 }
 
 var (
 	reWhitespace = regexp.MustCompile(`[\t\v\f\r ]+`)
+	reStageName  = regexp.MustCompile(`^[a-z][a-z0-9-_.]*$`)
 	utf8bom      = []byte{0xEF, 0xBB, 0xBF}
 )
 
 type Interface interface {
-	Parse(kubeFile []byte) *v1.Image
+	// Parse decode the Kubefile to image, the error is a *ParseError with the position of the wrong instruction.
+	Parse(kubeFile []byte) (*v1.Image, error)
 }
 
 type Parser struct{}
 
+// ParseError is the error of Kubefile with the line and column where the wrong instruction or argument starts.
+type ParseError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Kubefile line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+func newParseError(line, column int, format string, a ...interface{}) error {
+	return &ParseError{Line: line, Column: column, Msg: fmt.Sprintf(format, a...)}
+}
+
+// instruction is a logical line of Kubefile, continuation lines are joined.
+type instruction struct {
+	line   int
+	column int
+	cmd    string
+	value  string
+	// valueColumn is the column where value starts.
+	valueColumn int
+}
+
+// parseState keeps the build stages met so far, to check the FROM and COPY --from instructions.
+type parseState struct {
+	stages []string
+}
+
 func NewParse() Interface {
 	return &Parser{}
 }
 
-func (p *Parser) Parse(kubeFile []byte) *v1.Image {
+func (p *Parser) Parse(kubeFile []byte) (*v1.Image, error) {
 	image := &v1.Image{
 		TypeMeta: metaV1.TypeMeta{APIVersion: "", Kind: "Image"},
 		Spec:     v1.ImageSpec{SealerVersion: version.Get().GitVersion},
 		Status:   v1.ImageStatus{},
 	}
 
+	state := &parseState{}
 	currentLine := 0
 	scanner := bufio.NewScanner(bytes.NewReader(kubeFile))
 	scanner.Split(scanLines)
-	var err error
 	for scanner.Scan() {
 		bytesRead := scanner.Bytes()
-		if currentLine == 0 {
+		currentLine++
+		if currentLine == 1 {
 			// First line, strip the BOM.
 			bytesRead = bytes.TrimPrefix(bytesRead, utf8bom)
 		}
+		indent := len(trimNewline(bytesRead)) - len(trimLeadingWhitespace(trimNewline(bytesRead)))
+		bytesRead = processLine(bytesRead, true)
 		if bytes.HasPrefix(bytesRead, []byte("#")) {
 			continue
 		}
-		bytesRead = processLine(bytesRead, true)
-		currentLine++
+		startLine := currentLine
 
 		line, isEndOfLine := trimContinuationCharacter(string(bytesRead))
 		if isEndOfLine && line == "" {
@@ -92,16 +130,12 @@ func (p *Parser) Parse(kubeFile []byte) *v1.Image {
 
 		for !isEndOfLine && scanner.Scan() {
 			bytesRead = processLine(scanner.Bytes(), false)
-			if err != nil {
-				return nil
-			}
+			currentLine++
 
 			if bytes.HasPrefix(bytesRead, []byte("#")) {
 				continue
 			}
 
-			currentLine++
-
 			if isEmptyContinuationLine(bytesRead) {
 				continue
 			}
@@ -112,20 +146,48 @@ func (p *Parser) Parse(kubeFile []byte) *v1.Image {
 
 		layerType, layerValue, err := decodeLine(line)
 		if err != nil {
-			logger.Error("decode kubeFile line failed, err: %v", err)
-			return nil
+			return nil, newParseError(startLine, indent+1, "%v", err)
 		}
-
-		switch layerType {
-		case Arg:
-			dispatchArg(layerValue, image)
-		case Cmd:
-			dispatchCmd(layerValue, image)
-		default:
-			dispatchDefault(layerType, layerValue, image)
+		inst := instruction{
+			line:        startLine,
+			column:      indent + 1,
+			cmd:         layerType,
+			value:       layerValue,
+			valueColumn: indent + 1 + valueIndex(line, layerValue),
+		}
+		if err = dispatch(inst, image, state); err != nil {
+			return nil, err
 		}
 	}
-	return image
+
+	if len(state.stages) == 0 {
+		return nil, newParseError(1, 1, "no %s instruction found", From)
+	}
+	return image, nil
+}
+
+func dispatch(inst instruction, image *v1.Image, state *parseState) error {
+	if len(state.stages) == 0 && inst.cmd != From && inst.cmd != Arg {
+		return newParseError(inst.line, inst.column, "%s instruction must be after %s", inst.cmd, From)
+	}
+
+	switch inst.cmd {
+	case From:
+		return dispatchFrom(inst, image, state)
+	case Arg:
+		return dispatchArg(inst, image)
+	case Cmd:
+		dispatchCmd(inst.value, image)
+	case Env:
+		return dispatchEnv(inst, image)
+	case Label:
+		return dispatchLabel(inst, image)
+	case Copy:
+		return dispatchCopy(inst, image, state)
+	default:
+		dispatchDefault(inst.cmd, inst.value, image)
+	}
+	return nil
 }
 
 func decodeLine(line string) (string, string, error) {
@@ -134,29 +196,136 @@ func decodeLine(line string) (string, string, error) {
 	if !validCommands[cmd] {
 		return "", "", fmt.Errorf("invalid command %s %s", cmdline[0], line)
 	}
+	if len(cmdline) < 2 || strings.TrimSpace(cmdline[1]) == "" {
+		return "", "", fmt.Errorf("%s requires at least one argument", cmd)
+	}
 
 	return cmd, cmdline[1], nil
 }
 
-func dispatchArg(layerValue string, ima *v1.Image) {
+// dispatchFrom start a new build stage, "FROM image AS name" names the stage, so that it can be
+// referred by "COPY --from=name". The ENV, LABEL and CMD of image only come from the last stage.
+func dispatchFrom(inst instruction, ima *v1.Image, state *parseState) error {
+	fields := strings.Fields(inst.value)
+	var name string
+	switch {
+	case len(fields) == 1:
+	case len(fields) == 3 && strings.EqualFold(fields[1], "AS"):
+		name = strings.ToLower(fields[2])
+		if !reStageName.MatchString(name) {
+			return newParseError(inst.line, inst.valueColumn+strings.LastIndex(inst.value, fields[2]),
+				"invalid stage name %q, it must start with a letter and only contain letters, digits, '-', '_' and '.'", fields[2])
+		}
+		for _, s := range state.stages {
+			if s == name {
+				return newParseError(inst.line, inst.valueColumn+strings.LastIndex(inst.value, fields[2]),
+					"duplicated stage name %q", fields[2])
+			}
+		}
+	default:
+		return newParseError(inst.line, inst.valueColumn, "%s requires 'image' or 'image AS name', got %q", From, inst.value)
+	}
+
+	if len(state.stages) > 0 {
+		ima.Spec.ImageConfig.Env = nil
+		ima.Spec.ImageConfig.Labels = nil
+		ima.Spec.ImageConfig.Cmd.Current = nil
+	}
+	state.stages = append(state.stages, name)
+
+	value := fields[0]
+	if name != "" {
+		value = fmt.Sprintf("%s %s %s", fields[0], StageNameSeparator, name)
+	}
+	dispatchDefault(From, value, ima)
+	return nil
+}
+
+// dispatchCopy check the source and destination of COPY, the stage of "--from" must be defined before
+// current stage, otherwise it is treated as an image name.
+func dispatchCopy(inst instruction, ima *v1.Image, state *parseState) error {
+	var (
+		from string
+		args []string
+	)
+	for _, field := range strings.Fields(inst.value) {
+		if !strings.HasPrefix(field, "--") || len(args) > 0 {
+			args = append(args, field)
+			continue
+		}
+		column := inst.valueColumn + strings.Index(inst.value, field)
+		if !strings.HasPrefix(field, CopyFromFlag) {
+			return newParseError(inst.line, column, "unknown flag %s of %s", field, Copy)
+		}
+		from = strings.ToLower(strings.TrimPrefix(field, CopyFromFlag))
+		if from == "" {
+			return newParseError(inst.line, column, "%s requires a stage or image", CopyFromFlag)
+		}
+		current := state.stages[len(state.stages)-1]
+		if from == current || from == strconv.Itoa(len(state.stages)-1) {
+			return newParseError(inst.line, column, "%s can not refer to the current stage", field)
+		}
+	}
+	if len(args) < 2 {
+		return newParseError(inst.line, inst.valueColumn, "%s requires source and destination", Copy)
+	}
+
+	dispatchDefault(Copy, inst.value, ima)
+	return nil
+}
+
+func dispatchArg(inst instruction, ima *v1.Image) error {
 	if ima.Spec.ImageConfig.Args.Current == nil {
 		ima.Spec.ImageConfig.Args.Current = map[string]string{}
 	}
 
-	kv := strings.Split(layerValue, ",")
+	kv := strings.Split(inst.value, ",")
+	offset := 0
 	for _, element := range kv {
+		column := inst.valueColumn + offset
+		offset += len(element) + 1
 		valueLine := strings.SplitN(element, "=", 2)
 		if len(valueLine) != 2 {
-			logger.Error("invalid ARG value %s. ARG format must be key=value", layerValue)
-			return
+			return newParseError(inst.line, column, "invalid ARG value %s, ARG format must be key=value", element)
 		}
 		k := strings.TrimSpace(valueLine[0])
 		if !utils.IsLetterOrNumber(k) {
-			logger.Error("ARG key must be letter or number,invalid ARG format will ignore this key %s.", k)
-			return
+			return newParseError(inst.line, column, "invalid ARG key %s, ARG key must be letter or number", k)
 		}
 		ima.Spec.ImageConfig.Args.Current[k] = strings.TrimSpace(valueLine[1])
 	}
+	return nil
+}
+
+func dispatchEnv(inst instruction, ima *v1.Image) error {
+	pairs, err := parseKeyValues(inst)
+	if err != nil {
+		return err
+	}
+	if ima.Spec.ImageConfig.Env == nil {
+		ima.Spec.ImageConfig.Env = map[string]string{}
+	}
+	for _, p := range pairs {
+		if !utils.IsLetterOrNumber(p.key) {
+			return newParseError(inst.line, p.column, "invalid ENV key %s, ENV key must be letter or number", p.key)
+		}
+		ima.Spec.ImageConfig.Env[p.key] = p.value
+	}
+	return nil
+}
+
+func dispatchLabel(inst instruction, ima *v1.Image) error {
+	pairs, err := parseKeyValues(inst)
+	if err != nil {
+		return err
+	}
+	if ima.Spec.ImageConfig.Labels == nil {
+		ima.Spec.ImageConfig.Labels = map[string]string{}
+	}
+	for _, p := range pairs {
+		ima.Spec.ImageConfig.Labels[p.key] = p.value
+	}
+	return nil
 }
 
 func dispatchCmd(layerValue string, ima *v1.Image) {
@@ -182,6 +351,89 @@ func dispatchDefault(layerType, layerValue string, ima *v1.Image) {
 	})
 }
 
+type keyValue struct {
+	key    string
+	value  string
+	column int
+}
+
+// parseKeyValues parse the value of ENV and LABEL, which is "key=value key2=\"value 2\"",
+// or "key value" with a single pair for ENV. The value can be quoted by ' or ".
+func parseKeyValues(inst instruction) ([]keyValue, error) {
+	value := inst.value
+	if first := strings.Fields(value)[0]; !strings.Contains(first, "=") {
+		if inst.cmd != Env {
+			return nil, newParseError(inst.line, inst.valueColumn, "%s format must be key=value", inst.cmd)
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(value, first))
+		if rest == "" {
+			return nil, newParseError(inst.line, inst.valueColumn, "%s %s requires a value", inst.cmd, first)
+		}
+		return []keyValue{{key: first, value: trimQuotes(rest), column: inst.valueColumn}}, nil
+	}
+
+	var pairs []keyValue
+	i := 0
+	for i < len(value) {
+		for i < len(value) && unicode.IsSpace(rune(value[i])) {
+			i++
+		}
+		if i >= len(value) {
+			break
+		}
+		start := i
+		eq := strings.IndexByte(value[i:], '=')
+		if eq < 0 || strings.IndexFunc(value[i:i+eq], unicode.IsSpace) >= 0 {
+			return nil, newParseError(inst.line, inst.valueColumn+start, "%s format must be key=value", inst.cmd)
+		}
+		key := trimQuotes(value[i : i+eq])
+		if key == "" {
+			return nil, newParseError(inst.line, inst.valueColumn+start, "%s key can not be empty", inst.cmd)
+		}
+		i += eq + 1
+
+		var b strings.Builder
+		var quote byte
+		for ; i < len(value); i++ {
+			c := value[i]
+			if quote != 0 {
+				if c == quote {
+					quote = 0
+					continue
+				}
+				if c == '\\' && quote == '"' && i+1 < len(value) && (value[i+1] == '"' || value[i+1] == '\\') {
+					i++
+					c = value[i]
+				}
+				b.WriteByte(c)
+				continue
+			}
+			if c == '"' || c == '\'' {
+				quote = c
+				continue
+			}
+			if unicode.IsSpace(rune(c)) {
+				break
+			}
+			b.WriteByte(c)
+		}
+		if quote != 0 {
+			return nil, newParseError(inst.line, inst.valueColumn+start, "unterminated quote in %s value of %s", inst.cmd, key)
+		}
+		pairs = append(pairs, keyValue{key: key, value: b.String(), column: inst.valueColumn + start})
+	}
+	return pairs, nil
+}
+
+func trimQuotes(s string) string {
+	if len(s) >= 2 {
+		if c := s[len(s)-1]; s[0] == c && (c == '"' || c == '\'') {
+			return s[1 : len(s)-1]
+		}
+	}
+	return s
+}
+
 func trimNewline(src []byte) []byte {
 	return bytes.TrimRight(src, "\r\n")
 }
@@ -224,6 +476,12 @@ func trimContinuationCharacter(line string) (string, bool) {
 	return line, true
 }
 
+// valueIndex return the index of the instruction value in line.
+func valueIndex(line, value string) int {
+	cmd := trimCommand(line)[0]
+	return len(cmd) + strings.Index(line[len(cmd):], value)
+}
+
 func trimCommand(line string) []string {
 	return reWhitespace.Split(strings.TrimSpace(line), 2)
 }
@@ -127,9 +127,87 @@ RUN kubectl apply -f dashboard`)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := &Parser{}
-			if got := p.Parse(tt.args.kubeFile); !reflect.DeepEqual(got, tt.want) {
+			got, err := p.Parse(tt.args.kubeFile)
+			if err != nil {
+				t.Errorf("Parse() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Parse() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestParser_ParseMultiStage(t *testing.T) {
+	kubeFile := []byte(`FROM busybox AS build
+RUN wget -O /tmp/app.tar.gz http://example.com/app.tar.gz
+FROM kubernetes:v1.19.8
+ENV KUBE_VERSION=v1.19.8 MIRROR="http://mirror example.com"
+ENV EDITION enterprise
+LABEL maintainer=sealer "description"='kubernetes with app'
+COPY --from=build /tmp/app.tar.gz .
+CMD kubectl apply -f app`)
+
+	got, err := NewParse().Parse(kubeFile)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	wantLayers := []v1.Layer{
+		{Type: "FROM", Value: "busybox AS build"},
+		{Type: "RUN", Value: "wget -O /tmp/app.tar.gz http://example.com/app.tar.gz"},
+		{Type: "FROM", Value: "kubernetes:v1.19.8"},
+		{Type: "COPY", Value: "--from=build /tmp/app.tar.gz ."},
+	}
+	if !reflect.DeepEqual(got.Spec.Layers, wantLayers) {
+		t.Errorf("Parse() layers = %v, want %v", got.Spec.Layers, wantLayers)
+	}
+	wantEnv := map[string]string{"KUBE_VERSION": "v1.19.8", "MIRROR": "http://mirror example.com", "EDITION": "enterprise"}
+	if !reflect.DeepEqual(got.Spec.ImageConfig.Env, wantEnv) {
+		t.Errorf("Parse() env = %v, want %v", got.Spec.ImageConfig.Env, wantEnv)
+	}
+	wantLabels := map[string]string{"maintainer": "sealer", "description": "kubernetes with app"}
+	if !reflect.DeepEqual(got.Spec.ImageConfig.Labels, wantLabels) {
+		t.Errorf("Parse() labels = %v, want %v", got.Spec.ImageConfig.Labels, wantLabels)
+	}
+
+	stages := SplitStages(got.Spec.Layers)
+	if len(stages) != 2 || stages[0].Name != "build" || stages[0].Image != "busybox" || stages[1].Image != "kubernetes:v1.19.8" {
+		t.Errorf("SplitStages() = %v", stages)
+	}
+	if s, ok := FindStage(stages, "build"); !ok || s.Index != 0 || len(s.Layers) != 1 {
+		t.Errorf("FindStage() = %v, %v", s, ok)
+	}
+}
+
+func TestParser_ParseError(t *testing.T) {
+	tests := []struct {
+		name     string
+		kubeFile string
+		line     int
+		column   int
+	}{
+		{"empty kubefile", "# only comment\n", 1, 1},
+		{"unknown instruction", "FROM kubernetes:v1.19.8\n\nRUNN echo hello", 3, 1},
+		{"instruction before FROM", "COPY . .\nFROM kubernetes:v1.19.8", 1, 1},
+		{"missing argument", "FROM kubernetes:v1.19.8\n  COPY", 2, 3},
+		{"invalid ARG", "FROM kubernetes:v1.19.8\nARG Version=v1,Mirror", 2, 16},
+		{"invalid LABEL", "FROM kubernetes:v1.19.8\nLABEL a=b c", 2, 11},
+		{"unclosed quote", "FROM kubernetes:v1.19.8\nENV A=\"b", 2, 5},
+		{"duplicated stage", "FROM busybox AS build\nFROM kubernetes:v1.19.8 AS build", 2, 28},
+		{"copy from current stage", "FROM kubernetes:v1.19.8 AS k8s\nCOPY --from=k8s a b", 2, 6},
+		{"continuation line", "FROM kubernetes:v1.19.8\nRUN echo a \\\n  && echo b\nCOPY a", 4, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewParse().Parse([]byte(tt.kubeFile))
+			perr, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("Parse() error = %v, want *ParseError", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column {
+				t.Errorf("Parse() error at %d:%d, want %d:%d: %v", perr.Line, perr.Column, tt.line, tt.column, perr)
+			}
+		})
+	}
+}
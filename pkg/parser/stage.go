@@ -0,0 +1,75 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+const (
+	// StageNameSeparator separate the image and stage name in "FROM image AS name".
+	StageNameSeparator = "AS"
+	CopyFromFlag       = "--from="
+)
+
+// Stage is a build stage of multi-stage Kubefile, which starts from a FROM instruction.
+type Stage struct {
+	// Name is the name given by "FROM image AS name", may be empty.
+	Name  string
+	Index int
+	// Image is the base image of the stage.
+	Image string
+	// Layers are the instructions of the stage after FROM.
+	Layers []v1.Layer
+}
+
+// SplitStages split the layers parsed from Kubefile into build stages, the last one is the stage of image.
+func SplitStages(layers []v1.Layer) []Stage {
+	var stages []Stage
+	for _, layer := range layers {
+		if layer.Type == From {
+			image, name := ParseFromValue(layer.Value)
+			stages = append(stages, Stage{Name: name, Index: len(stages), Image: image})
+			continue
+		}
+		if len(stages) == 0 {
+			continue
+		}
+		stages[len(stages)-1].Layers = append(stages[len(stages)-1].Layers, layer)
+	}
+	return stages
+}
+
+// ParseFromValue split the value of FROM layer to image and stage name.
+func ParseFromValue(value string) (image, name string) {
+	fields := strings.Fields(value)
+	if len(fields) == 3 && strings.EqualFold(fields[1], StageNameSeparator) {
+		return fields[0], fields[2]
+	}
+	return value, ""
+}
+
+// FindStage return the stage referred by "COPY --from", by name or index.
+func FindStage(stages []Stage, ref string) (Stage, bool) {
+	for _, s := range stages {
+		if (s.Name != "" && s.Name == ref) || strconv.Itoa(s.Index) == ref {
+			return s, true
+		}
+	}
+	return Stage{}, false
+}
@@ -28,6 +28,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils/ssh"
 )
 
@@ -43,35 +44,51 @@ func init() {
 }
 
 func (e EtcdBackupPlugin) Run(context Context, phase Phase) error {
-	masterIP, err := getMasterIP(context)
+	_, err := SnapshotEtcd(context.Cluster, context.Plugin.Spec.On)
+	return err
+}
+
+// SnapshotEtcd save the etcd snapshot of cluster to snapshotPath on local, and return the etcd revision when snapshot is taken.
+func SnapshotEtcd(cluster *v2.Cluster, snapshotPath string) (int64, error) {
+	cfg, err := NewEtcdConfig(cluster)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if err := fetchRemoteCert(context, masterIP); err != nil {
-		return err
+	revision, err := getEtcdRevision(cfg)
+	if err != nil {
+		return 0, err
 	}
 
-	cfg, err := connEtcd(masterIP)
+	return revision, snapshotEtcd(snapshotPath, cfg)
+}
+
+// NewEtcdConfig fetches the etcd client certs of master0 and returns the config to connect to its etcd.
+func NewEtcdConfig(cluster *v2.Cluster) (clientv3.Config, error) {
+	masterIP, err := getMasterIP(cluster)
 	if err != nil {
-		return err
+		return clientv3.Config{}, err
 	}
 
-	return snapshotEtcd(context.Plugin.Spec.On, cfg)
+	if err := fetchRemoteCert(cluster, masterIP); err != nil {
+		return clientv3.Config{}, err
+	}
+
+	return connEtcd(masterIP)
 }
 
-func getMasterIP(context Context) (string, error) {
-	masterIPList := context.Cluster.GetMasterIPList()
+func getMasterIP(cluster *v2.Cluster) (string, error) {
+	masterIPList := cluster.GetMasterIPList()
 	if len(masterIPList) == 0 {
 		return "", errors.New("cluster master does not exist")
 	}
 	return masterIPList[0], nil
 }
 
-func fetchRemoteCert(context Context, masterIP string) error {
+func fetchRemoteCert(cluster *v2.Cluster, masterIP string) error {
 	certs := []string{"healthcheck-client.crt", "healthcheck-client.key", "ca.crt"}
 	for _, cert := range certs {
-		sshClient, err := ssh.GetHostSSHClient(masterIP, context.Cluster)
+		sshClient, err := ssh.GetHostSSHClient(masterIP, cluster)
 		if err != nil {
 			return err
 		}
@@ -127,6 +144,22 @@ func connEtcd(masterIP string) (clientv3.Config, error) {
 	return cfg, nil
 }
 
+func getEtcdRevision(cfg clientv3.Config) (int64, error) {
+	cli, err := clientv3.New(cfg)
+	if err != nil {
+		return 0, fmt.Errorf("connect to etcd failed, err:%v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
+	defer cancel()
+	status, err := cli.Status(ctx, cfg.Endpoints[0])
+	if err != nil {
+		return 0, fmt.Errorf("get etcd status err: %v", err)
+	}
+	return status.Header.Revision, nil
+}
+
 func snapshotEtcd(snapshotPath string, cfg clientv3.Config) error {
 	lg, err := zap.NewProduction()
 	if err != nil {
@@ -0,0 +1,251 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	staticPodDir          = "/etc/kubernetes/manifests"
+	staticPodRestoreDir   = "/etc/kubernetes/manifests-restore"
+	etcdManifest          = "etcd.yaml"
+	apiServerManifest     = "kube-apiserver.yaml"
+	etcdSnapshotName      = "etcd-restore-snapshot.db"
+	defaultEtcdDataDir    = "/var/lib/etcd"
+	defaultEtcdPeerPort   = "2380"
+	defaultEtcdClusterTkn = "etcd-cluster"
+
+	// read the flag of etcd from the static pod manifest, it may be moved out by an unfinished restore.
+	getEtcdManifestFlagCmd = "f=" + staticPodDir + "/" + etcdManifest + "; [ -f $f ] || f=" + staticPodRestoreDir + "/" + etcdManifest +
+		"; sed -n 's/^ *- --%s=\\(.*\\)$/\\1/p' $f"
+	getEtcdImageCmd = "f=" + staticPodDir + "/" + etcdManifest + "; [ -f $f ] || f=" + staticPodRestoreDir + "/" + etcdManifest +
+		"; sed -n 's/^ *image: *\\(.*\\)$/\\1/p' $f"
+	moveManifestCmd      = "mkdir -p %s && if [ -f %s/%s ];then mv -f %s/%s %s/;fi"
+	waitControlPlaneDown = "for i in $(seq 60); do ps=$(docker ps) || exit 1; echo \"$ps\" | grep -q -E 'k8s_(etcd|kube-apiserver)_' || exit 0; sleep 2; done; exit 1"
+	backupEtcdDataCmd    = "if [ -d %s ];then mv %s %s.bak-%s;fi"
+	rollbackEtcdDataCmd  = "if [ -d %s.bak-%s ];then rm -rf %s && mv %s.bak-%s %s;fi"
+	restoreEtcdCmd       = "docker run --rm -e ETCDCTL_API=3 -v %s:%s --entrypoint etcdctl %s snapshot restore %s " +
+		"--name %s --initial-cluster %s --initial-cluster-token %s --initial-advertise-peer-urls https://%s:%s --data-dir %s"
+	checkAPIServerCmd = "kubectl get nodes"
+	checkDockerCmd    = "docker ps -q > /dev/null"
+)
+
+var (
+	// runHostCmds runs the commands on the host in order, tests replace it to fake the hosts.
+	runHostCmds = cmdAsync
+	// apiServerCheckInterval is the base interval between the checks of apiserver after etcd restored.
+	apiServerCheckInterval = 3 * time.Second
+)
+
+type etcdMember struct {
+	IP      string
+	Name    string
+	DataDir string
+	Image   string
+}
+
+// RestoreEtcd restore the etcd of cluster from the snapshot taken by the ETCD plugin. The apiservers and etcd of
+// all masters are stopped, every member is restored from the same snapshot with the initial cluster of all masters,
+// then the control plane is started again. The old data dir is kept as <data-dir>.bak-<timestamp>.
+func RestoreEtcd(cluster *v2.Cluster, snapshotPath string) error {
+	masters := cluster.GetMasterIPList()
+	if len(masters) == 0 {
+		return fmt.Errorf("cluster master does not exist")
+	}
+	if !utils.IsFileExist(snapshotPath) {
+		return fmt.Errorf("etcd snapshot %s does not exist", snapshotPath)
+	}
+	if err := checkDocker(cluster, masters); err != nil {
+		return err
+	}
+
+	var members []etcdMember
+	for _, master := range masters {
+		member, err := getEtcdMember(cluster, master)
+		if err != nil {
+			return err
+		}
+		members = append(members, member)
+	}
+	initialCluster := getEtcdInitialCluster(members)
+	logger.Info("etcd will be restored with initial cluster %s", initialCluster)
+
+	for _, m := range members {
+		if err := sendEtcdSnapshot(cluster, m, snapshotPath); err != nil {
+			return err
+		}
+	}
+	if err := restoreMembers(cluster, members, initialCluster, time.Now().Format("20060102150405")); err != nil {
+		return err
+	}
+	logger.Info("etcd is restored from %s", snapshotPath)
+	return nil
+}
+
+// restoreMembers stop the control plane and restore the etcd members from the snapshot sent to them. If it fails
+// before all members are restored, the old data dirs are moved back, and the control plane is started again anyway,
+// so that a failed restore does not leave the cluster down.
+func restoreMembers(cluster *v2.Cluster, members []etcdMember, initialCluster, suffix string) (err error) {
+	var (
+		stopped  []etcdMember
+		restored bool
+	)
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, m := range stopped {
+			if !restored {
+				if rbErr := runHostCmds(cluster, m.IP, fmt.Sprintf(rollbackEtcdDataCmd, m.DataDir, suffix, m.DataDir, m.DataDir, suffix, m.DataDir)); rbErr != nil {
+					logger.Error("failed to move back etcd data dir %s on %s: %v", m.DataDir, m.IP, rbErr)
+				}
+			}
+			if startErr := startControlPlane(cluster, m.IP); startErr != nil {
+				logger.Error("%v, the static pod manifests are in %s", startErr, staticPodRestoreDir)
+			}
+		}
+	}()
+
+	for _, m := range members {
+		// the manifests may be moved before the stop fails.
+		stopped = append(stopped, m)
+		if err = stopControlPlane(cluster, m.IP); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range members {
+		snapshot := filepath.Join(filepath.Dir(m.DataDir), etcdSnapshotName)
+		cmds := []string{
+			fmt.Sprintf(backupEtcdDataCmd, m.DataDir, m.DataDir, m.DataDir, suffix),
+			fmt.Sprintf(restoreEtcdCmd, filepath.Dir(m.DataDir), filepath.Dir(m.DataDir), m.Image, snapshot,
+				m.Name, initialCluster, defaultEtcdClusterTkn, m.IP, defaultEtcdPeerPort, m.DataDir),
+			fmt.Sprintf("rm -f %s", snapshot),
+		}
+		if err = runHostCmds(cluster, m.IP, cmds...); err != nil {
+			return fmt.Errorf("failed to restore etcd member %s on %s: %v", m.Name, m.IP, err)
+		}
+		logger.Info("succeeded in restoring etcd member %s on %s", m.Name, m.IP)
+	}
+	restored = true
+
+	for _, m := range members {
+		if err = startControlPlane(cluster, m.IP); err != nil {
+			return err
+		}
+	}
+	if err = utils.Retry(10, apiServerCheckInterval, func() error {
+		return runHostCmds(cluster, members[0].IP, checkAPIServerCmd)
+	}); err != nil {
+		return fmt.Errorf("apiserver is not ready after etcd restored: %v", err)
+	}
+	return nil
+}
+
+// checkDocker makes sure the masters run the control plane by docker before anything is stopped, the containers
+// are watched and etcdctl is run by it. The clusters on containerd, like k3s, can not be restored.
+func checkDocker(cluster *v2.Cluster, masters []string) error {
+	for _, master := range masters {
+		if err := runHostCmds(cluster, master, checkDockerCmd); err != nil {
+			return fmt.Errorf("failed to check docker on %s, only the cluster running on docker can be restored: %v", master, err)
+		}
+	}
+	return nil
+}
+
+func getEtcdMember(cluster *v2.Cluster, master string) (etcdMember, error) {
+	sshClient, err := ssh.GetHostSSHClient(master, cluster)
+	if err != nil {
+		return etcdMember{}, err
+	}
+	member := etcdMember{IP: master}
+	if member.Image, err = sshClient.CmdToString(master, getEtcdImageCmd, ""); err != nil {
+		return member, fmt.Errorf("failed to get etcd image on %s: %v", master, err)
+	}
+	if member.Name, err = sshClient.CmdToString(master, fmt.Sprintf(getEtcdManifestFlagCmd, "name"), ""); err != nil {
+		return member, fmt.Errorf("failed to get etcd name on %s: %v", master, err)
+	}
+	if member.DataDir, err = sshClient.CmdToString(master, fmt.Sprintf(getEtcdManifestFlagCmd, "data-dir"), ""); err != nil {
+		return member, fmt.Errorf("failed to get etcd data dir on %s: %v", master, err)
+	}
+	member.Image = strings.TrimSpace(member.Image)
+	member.Name = strings.TrimSpace(member.Name)
+	member.DataDir = strings.TrimSpace(member.DataDir)
+	if member.Image == "" || member.Name == "" {
+		return member, fmt.Errorf("etcd static pod not found on %s, only etcd installed by kubeadm can be restored", master)
+	}
+	if member.DataDir == "" {
+		member.DataDir = defaultEtcdDataDir
+	}
+	return member, nil
+}
+
+func getEtcdInitialCluster(members []etcdMember) string {
+	var peers []string
+	for _, m := range members {
+		peers = append(peers, fmt.Sprintf("%s=https://%s:%s", m.Name, m.IP, defaultEtcdPeerPort))
+	}
+	return strings.Join(peers, ",")
+}
+
+func sendEtcdSnapshot(cluster *v2.Cluster, m etcdMember, snapshotPath string) error {
+	sshClient, err := ssh.GetHostSSHClient(m.IP, cluster)
+	if err != nil {
+		return err
+	}
+	if err = sshClient.Copy(m.IP, snapshotPath, filepath.Join(filepath.Dir(m.DataDir), etcdSnapshotName)); err != nil {
+		return fmt.Errorf("failed to send etcd snapshot to %s: %v", m.IP, err)
+	}
+	return nil
+}
+
+// stopControlPlane move the static pod manifests of apiserver and etcd out, kubelet will stop them.
+// The wait fails if docker ps fails, the control plane is not seen as stopped then.
+func stopControlPlane(cluster *v2.Cluster, master string) error {
+	if err := runHostCmds(cluster, master,
+		fmt.Sprintf(moveManifestCmd, staticPodRestoreDir, staticPodDir, apiServerManifest, staticPodDir, apiServerManifest, staticPodRestoreDir),
+		fmt.Sprintf(moveManifestCmd, staticPodRestoreDir, staticPodDir, etcdManifest, staticPodDir, etcdManifest, staticPodRestoreDir),
+		waitControlPlaneDown); err != nil {
+		return fmt.Errorf("failed to stop control plane on %s: %v", master, err)
+	}
+	return nil
+}
+
+func startControlPlane(cluster *v2.Cluster, master string) error {
+	if err := runHostCmds(cluster, master,
+		fmt.Sprintf(moveManifestCmd, staticPodDir, staticPodRestoreDir, etcdManifest, staticPodRestoreDir, etcdManifest, staticPodDir),
+		fmt.Sprintf(moveManifestCmd, staticPodDir, staticPodRestoreDir, apiServerManifest, staticPodRestoreDir, apiServerManifest, staticPodDir),
+		fmt.Sprintf("rm -rf %s", staticPodRestoreDir)); err != nil {
+		return fmt.Errorf("failed to start control plane on %s: %v", master, err)
+	}
+	return nil
+}
+
+func cmdAsync(cluster *v2.Cluster, host string, cmds ...string) error {
+	sshClient, err := ssh.GetHostSSHClient(host, cluster)
+	if err != nil {
+		return err
+	}
+	return sshClient.CmdAsync(host, cmds...)
+}
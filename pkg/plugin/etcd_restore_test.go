@@ -0,0 +1,137 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestGetEtcdInitialCluster(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []etcdMember
+		want    string
+	}{
+		{
+			"single master",
+			[]etcdMember{{IP: "192.168.0.2", Name: "master0"}},
+			"master0=https://192.168.0.2:2380",
+		},
+		{
+			"multi masters",
+			[]etcdMember{{IP: "192.168.0.2", Name: "master0"}, {IP: "192.168.0.3", Name: "master1"}, {IP: "192.168.0.4", Name: "master2"}},
+			"master0=https://192.168.0.2:2380,master1=https://192.168.0.3:2380,master2=https://192.168.0.4:2380",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getEtcdInitialCluster(tt.members); got != tt.want {
+				t.Errorf("getEtcdInitialCluster() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreMembersRollback(t *testing.T) {
+	members := []etcdMember{
+		{IP: "192.168.0.2", Name: "master0", DataDir: defaultEtcdDataDir},
+		{IP: "192.168.0.3", Name: "master1", DataDir: defaultEtcdDataDir},
+	}
+	rollback := fmt.Sprintf(rollbackEtcdDataCmd, defaultEtcdDataDir, "1", defaultEtcdDataDir, defaultEtcdDataDir, "1", defaultEtcdDataDir)
+	startCmd := fmt.Sprintf("rm -rf %s", staticPodRestoreDir)
+
+	tests := []struct {
+		name string
+		// the commands starting with failCmd fail on failHost.
+		failHost, failCmd string
+		wantRollback      []string
+		wantStarted       []string
+	}{
+		{"stop fails", "192.168.0.3", "for i in", []string{"192.168.0.2", "192.168.0.3"}, []string{"192.168.0.2", "192.168.0.3"}},
+		{"restore fails", "192.168.0.3", "docker run", []string{"192.168.0.2", "192.168.0.3"}, []string{"192.168.0.2", "192.168.0.3"}},
+		{"apiserver not ready", "192.168.0.2", checkAPIServerCmd, nil, []string{"192.168.0.2", "192.168.0.3", "192.168.0.2", "192.168.0.3"}},
+	}
+	defer func(f func(*v2.Cluster, string, ...string) error) { runHostCmds = f }(runHostCmds)
+	apiServerCheckInterval = 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rolledBack, started []string
+			runHostCmds = func(cluster *v2.Cluster, host string, cmds ...string) error {
+				for _, cmd := range cmds {
+					if host == tt.failHost && strings.HasPrefix(cmd, tt.failCmd) {
+						return fmt.Errorf("failed")
+					}
+					switch cmd {
+					case rollback:
+						rolledBack = append(rolledBack, host)
+					case startCmd:
+						started = append(started, host)
+					}
+				}
+				return nil
+			}
+			if err := restoreMembers(&v2.Cluster{}, members, "", "1"); err == nil {
+				t.Fatal("restoreMembers() error = nil, want error")
+			}
+			if fmt.Sprint(rolledBack) != fmt.Sprint(tt.wantRollback) {
+				t.Errorf("etcd data dir is moved back on %v, want %v", rolledBack, tt.wantRollback)
+			}
+			if fmt.Sprint(started) != fmt.Sprint(tt.wantStarted) {
+				t.Errorf("control plane is started on %v, want %v", started, tt.wantStarted)
+			}
+		})
+	}
+}
+
+func TestCheckDocker(t *testing.T) {
+	defer func(f func(*v2.Cluster, string, ...string) error) { runHostCmds = f }(runHostCmds)
+	masters := []string{"192.168.0.2", "192.168.0.3"}
+	tests := []struct {
+		name string
+		// docker is not working on noDocker.
+		noDocker string
+		wantErr  bool
+	}{
+		{"docker", "", false},
+		{"containerd", "192.168.0.3", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checked []string
+			runHostCmds = func(cluster *v2.Cluster, host string, cmds ...string) error {
+				for _, cmd := range cmds {
+					if cmd != checkDockerCmd {
+						t.Fatalf("only docker is checked, got %s", cmd)
+					}
+				}
+				checked = append(checked, host)
+				if host == tt.noDocker {
+					return fmt.Errorf("docker: command not found")
+				}
+				return nil
+			}
+			if err := checkDocker(&v2.Cluster{}, masters); (err != nil) != tt.wantErr {
+				t.Errorf("checkDocker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(checked) != fmt.Sprint(masters) {
+				t.Errorf("docker is checked on %v, want %v", checked, masters)
+			}
+		})
+	}
+}
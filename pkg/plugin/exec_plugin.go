@@ -0,0 +1,210 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+// The exec plugin protocol: sealer starts the plugin binary, writes an ExecRequest as JSON to its stdin,
+// and reads an ExecResponse as JSON from its stdout. Stderr of the plugin is shown to the user.
+const (
+	ExecPluginAPIVersion = "sealer.cloud/v1"
+	ExecRequestKind      = "PluginRequest"
+	ExecResponseKind     = "PluginResponse"
+
+	// ExecOperationDescribe asks the plugin for its plugin type, it is sent when loading the plugin.
+	ExecOperationDescribe = "Describe"
+	// ExecOperationRun asks the plugin to run at the phase.
+	ExecOperationRun = "Run"
+
+	ExecStatusSuccess = "Success"
+	ExecStatusFailure = "Failure"
+	ExecStatusSkipped = "Skipped"
+
+	// ExecPluginPrefix is the name prefix of exec plugin binaries, the other executables in the plugin dir
+	// like the scripts used by shell plugins are not loaded.
+	ExecPluginPrefix = "sealer-plugin-"
+)
+
+type ExecRequest struct {
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Operation  string      `json:"operation"`
+	Phase      Phase       `json:"phase,omitempty"`
+	Plugin     *v1.Plugin  `json:"plugin,omitempty"`
+	Cluster    *v2.Cluster `json:"cluster,omitempty"`
+	Hosts      []string    `json:"hosts,omitempty"`
+}
+
+type ExecResponse struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Type is the plugin type, which is required by the Describe operation.
+	Type    string `json:"type,omitempty"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// ExecPlugin is an out of tree plugin running as a separate process, so that it does not need to be
+// built with the same golang toolchain and dependencies as sealer.
+type ExecPlugin struct {
+	Path string
+	Type string
+}
+
+// NewExecPlugin describe the plugin binary to get its plugin type.
+func NewExecPlugin(path string) (*ExecPlugin, error) {
+	p := &ExecPlugin{Path: path}
+	resp, err := p.call(context.Background(), &ExecRequest{Operation: ExecOperationDescribe})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe plugin %s: %v", path, err)
+	}
+	if resp.Type == "" {
+		return nil, fmt.Errorf("plugin %s does not describe its type", path)
+	}
+	p.Type = resp.Type
+	return p, nil
+}
+
+func (e *ExecPlugin) Run(pluginCtx Context, phase Phase) error {
+	return e.RunWithContext(context.Background(), pluginCtx, phase)
+}
+
+// RunWithContext run the plugin, the plugin process is killed when ctx is done.
+func (e *ExecPlugin) RunWithContext(ctx context.Context, pluginCtx Context, phase Phase) error {
+	resp, err := e.call(ctx, &ExecRequest{
+		Operation: ExecOperationRun,
+		Phase:     phase,
+		Plugin:    pluginCtx.Plugin,
+		Cluster:   pluginCtx.Cluster,
+		Hosts:     pluginCtx.Hosts,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run plugin %s: %v", e.Path, err)
+	}
+	if resp.Status == ExecStatusSkipped {
+		logger.Debug("plugin %s skipped at %s: %s", e.Type, phase, resp.Message)
+		return nil
+	}
+	if resp.Message != "" {
+		logger.Info("plugin %s: %s", e.Type, resp.Message)
+	}
+	return nil
+}
+
+func (e *ExecPlugin) call(ctx context.Context, req *ExecRequest) (*ExecResponse, error) {
+	req.APIVersion, req.Kind = ExecPluginAPIVersion, ExecRequestKind
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	// #nosec
+	cmd := exec.CommandContext(ctx, e.Path)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	resp := &ExecResponse{Status: ExecStatusSuccess}
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err = json.Unmarshal(out, resp); err != nil {
+			if runErr != nil {
+				return nil, runErr
+			}
+			return nil, fmt.Errorf("invalid response %q: %v", string(out), err)
+		}
+	} else if req.Operation == ExecOperationDescribe && runErr == nil {
+		return nil, fmt.Errorf("empty response")
+	}
+
+	if resp.Status == ExecStatusFailure || runErr != nil {
+		msg := resp.Message
+		if ctx.Err() == context.DeadlineExceeded {
+			msg = "plugin process is killed for timeout"
+		} else if msg == "" && runErr != nil {
+			msg = runErr.Error()
+		}
+		return nil, fmt.Errorf("%s", strings.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+// isExecPluginFile return true if the file is an executable regular file, which is loaded
+// as exec plugin if it is named with ExecPluginPrefix.
+func isExecPluginFile(f os.FileInfo) bool {
+	return f.Mode().IsRegular() && f.Mode().Perm()&0111 != 0
+}
+
+// ServeExec is the entrypoint of an exec plugin written in golang, it reads the request from stdin,
+// run the plugin, and writes the response to stdout:
+//
+//	func main() {
+//		plugin.ServeExec("MY_PLUGIN", &MyPlugin{})
+//	}
+func ServeExec(pluginType string, p Interface) {
+	if err := serveExec(pluginType, p, os.Stdin, os.Stdout); err != nil {
+		os.Exit(1)
+	}
+}
+
+func serveExec(pluginType string, p Interface, in io.Reader, out io.Writer) error {
+	resp := &ExecResponse{
+		APIVersion: ExecPluginAPIVersion,
+		Kind:       ExecResponseKind,
+		Type:       pluginType,
+		Status:     ExecStatusSuccess,
+	}
+	err := func() error {
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		req := &ExecRequest{}
+		if err = json.Unmarshal(data, req); err != nil {
+			return fmt.Errorf("invalid request: %v", err)
+		}
+		switch req.Operation {
+		case ExecOperationDescribe:
+			return nil
+		case ExecOperationRun:
+			return p.Run(Context{Plugin: req.Plugin, Cluster: req.Cluster, Hosts: req.Hosts}, req.Phase)
+		default:
+			return fmt.Errorf("unknown operation %s", req.Operation)
+		}
+	}()
+	if err != nil {
+		resp.Status, resp.Message = ExecStatusFailure, err.Error()
+	}
+
+	if encodeErr := json.NewEncoder(out).Encode(resp); encodeErr != nil {
+		return encodeErr
+	}
+	return err
+}
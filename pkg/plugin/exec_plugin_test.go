@@ -0,0 +1,149 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+const testExecPlugin = `#!/bin/sh
+req=$(cat)
+case "$req" in
+  *'"operation":"Describe"'*)
+    echo '{"apiVersion":"sealer.cloud/v1","kind":"PluginResponse","type":"EXEC_TEST","status":"Success"}' ;;
+  *'"data":"fail"'*)
+    echo '{"apiVersion":"sealer.cloud/v1","kind":"PluginResponse","status":"Failure","message":"failed on purpose"}'
+    exit 1 ;;
+  *'"phase":"PostInstall"'*)
+    echo '{"apiVersion":"sealer.cloud/v1","kind":"PluginResponse","status":"Success"}' ;;
+  *)
+    exit 2 ;;
+esac
+`
+
+func TestExecPlugin_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exec_test")
+	if err := ioutil.WriteFile(path, []byte(testExecPlugin), 0700); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewExecPlugin(path)
+	if err != nil {
+		t.Fatalf("NewExecPlugin() error = %v", err)
+	}
+	if p.Type != "EXEC_TEST" {
+		t.Errorf("NewExecPlugin() type = %s, want EXEC_TEST", p.Type)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		phase   Phase
+		wantErr string
+	}{
+		{"success", "ok", PhasePostInstall, ""},
+		{"failure response", "fail", PhasePostInstall, "failed on purpose"},
+		{"exit without response", "ok", PhasePreInit, "exit status 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := Context{
+				Plugin:  &v1.Plugin{Spec: v1.PluginSpec{Type: "EXEC_TEST", Data: tt.data, Action: string(tt.phase)}},
+				Cluster: &v2.Cluster{},
+			}
+			err := p.Run(ctx, tt.phase)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Run() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Run() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type testPlugin struct{}
+
+func (testPlugin) Run(context Context, phase Phase) error {
+	if context.Plugin.Spec.Data == "fail" {
+		return fmt.Errorf("failed at %s", phase)
+	}
+	return nil
+}
+
+func TestServeExec(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        ExecRequest
+		wantStatus string
+	}{
+		{"describe", ExecRequest{Operation: ExecOperationDescribe}, ExecStatusSuccess},
+		{"run", ExecRequest{Operation: ExecOperationRun, Phase: PhasePostInstall, Plugin: &v1.Plugin{}}, ExecStatusSuccess},
+		{"run failed", ExecRequest{Operation: ExecOperationRun, Phase: PhasePostInstall,
+			Plugin: &v1.Plugin{Spec: v1.PluginSpec{Data: "fail"}}}, ExecStatusFailure},
+		{"unknown operation", ExecRequest{Operation: "Unknown"}, ExecStatusFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := json.Marshal(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			err = serveExec("TEST", testPlugin{}, bytes.NewReader(in), &out)
+			resp := &ExecResponse{}
+			if jsonErr := json.Unmarshal(out.Bytes(), resp); jsonErr != nil {
+				t.Fatalf("invalid response %s: %v", out.String(), jsonErr)
+			}
+			if resp.Status != tt.wantStatus || resp.Type != "TEST" {
+				t.Errorf("serveExec() = %+v, want status %s", resp, tt.wantStatus)
+			}
+			if (err != nil) != (tt.wantStatus == ExecStatusFailure) {
+				t.Errorf("serveExec() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestPluginsProcessor_LoadExecPlugin(t *testing.T) {
+	dir := t.TempDir()
+	// the script used by a shell plugin is executable as well, it must not be run when loading.
+	files := map[string]string{
+		ExecPluginPrefix + "exec-test": testExecPlugin,
+		"install.sh":                   "#!/bin/sh\nexit 1\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer delete(pluginFactories, "EXEC_TEST")
+	c := &PluginsProcessor{}
+	if err := c.loadDir(dir); err != nil {
+		t.Fatalf("loadDir() error = %v", err)
+	}
+	p, ok := pluginFactories["EXEC_TEST"].(*ExecPlugin)
+	if !ok || p.Path != filepath.Join(dir, ExecPluginPrefix+"exec-test") {
+		t.Errorf("exec plugin EXEC_TEST is not registered from %s, got %+v", dir, pluginFactories["EXEC_TEST"])
+	}
+}
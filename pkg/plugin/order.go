@@ -0,0 +1,150 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+const (
+	FailurePolicyFail   = "Fail"
+	FailurePolicyIgnore = "Ignore"
+	FailurePolicyRetry  = "Retry"
+)
+
+// runPolicy is the parsed timeout and failure policy of a plugin.
+type runPolicy struct {
+	timeout       time.Duration
+	failurePolicy string
+	retries       int
+}
+
+func parseRunPolicy(p v1.Plugin) (runPolicy, error) {
+	policy := runPolicy{failurePolicy: FailurePolicyFail}
+	if p.Spec.Timeout != "" {
+		timeout, err := time.ParseDuration(p.Spec.Timeout)
+		if err != nil || timeout <= 0 {
+			return policy, fmt.Errorf("invalid timeout %q of plugin %s", p.Spec.Timeout, p.Name)
+		}
+		policy.timeout = timeout
+	}
+
+	fields := strings.Fields(p.Spec.FailurePolicy)
+	switch {
+	case len(fields) == 0:
+	case len(fields) == 1 && (fields[0] == FailurePolicyFail || fields[0] == FailurePolicyIgnore):
+		policy.failurePolicy = fields[0]
+	case len(fields) == 2 && fields[0] == FailurePolicyRetry:
+		retries, err := strconv.Atoi(fields[1])
+		if err != nil || retries <= 0 {
+			return policy, fmt.Errorf("invalid retry times %q of plugin %s", fields[1], p.Name)
+		}
+		policy.failurePolicy, policy.retries = FailurePolicyRetry, retries
+	default:
+		return policy, fmt.Errorf("invalid failure policy %q of plugin %s, must be one of Fail, Ignore and Retry N",
+			p.Spec.FailurePolicy, p.Name)
+	}
+	return policy, nil
+}
+
+// SortPlugins return the plugins of the phase in the order to run: a plugin runs after all plugins it depends on,
+// otherwise by ascending order, and then the loading order. The plugins of other phases are only used to check
+// the dependencies exist.
+func SortPlugins(plugins []v1.Plugin, phase Phase) ([]v1.Plugin, error) {
+	var (
+		all         = map[string]bool{}
+		phasePlugin []v1.Plugin
+		indexes     = map[string]int{}
+	)
+	for _, p := range plugins {
+		all[p.Name] = true
+		if p.Spec.Action != string(phase) {
+			continue
+		}
+		if _, err := parseRunPolicy(p); err != nil {
+			return nil, err
+		}
+		if p.Name != "" {
+			indexes[p.Name] = len(phasePlugin)
+		}
+		phasePlugin = append(phasePlugin, p)
+	}
+
+	// waiting counts the dependencies not sorted of each plugin in the phase, dependencies of other phases are ignored.
+	waiting := make([]int, len(phasePlugin))
+	dependents := make([][]int, len(phasePlugin))
+	for i, p := range phasePlugin {
+		for _, dep := range p.Spec.DependsOn {
+			if !all[dep] {
+				return nil, fmt.Errorf("plugin %s depends on plugin %s which is not found", p.Name, dep)
+			}
+			j, ok := indexes[dep]
+			if !ok {
+				continue
+			}
+			if j == i {
+				return nil, fmt.Errorf("plugin %s depends on itself", p.Name)
+			}
+			waiting[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	var ready, sorted []int
+	for i := range phasePlugin {
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		sort.SliceStable(ready, func(a, b int) bool {
+			pa, pb := phasePlugin[ready[a]], phasePlugin[ready[b]]
+			if pa.Spec.Order != pb.Spec.Order {
+				return pa.Spec.Order < pb.Spec.Order
+			}
+			return ready[a] < ready[b]
+		})
+		next := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, next)
+		for _, d := range dependents[next] {
+			waiting[d]--
+			if waiting[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	if len(sorted) != len(phasePlugin) {
+		var cycle []string
+		for i, w := range waiting {
+			if w > 0 {
+				cycle = append(cycle, phasePlugin[i].Name)
+			}
+		}
+		return nil, fmt.Errorf("circular dependency found in plugins %s of phase %s", strings.Join(cycle, ","), phase)
+	}
+
+	res := make([]v1.Plugin, 0, len(sorted))
+	for _, i := range sorted {
+		res = append(res, phasePlugin[i])
+	}
+	return res, nil
+}
@@ -0,0 +1,257 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestPlugin(name, phase string, order int, dependsOn ...string) v1.Plugin {
+	return v1.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1.PluginSpec{Type: "ORDER_TEST", Action: phase, Order: order, DependsOn: dependsOn},
+	}
+}
+
+func TestSortPlugins(t *testing.T) {
+	tests := []struct {
+		name    string
+		plugins []v1.Plugin
+		want    []string
+		wantErr bool
+	}{
+		{
+			"loading order",
+			[]v1.Plugin{newTestPlugin("b", "PostInstall", 0), newTestPlugin("a", "PostInstall", 0)},
+			[]string{"b", "a"},
+			false,
+		},
+		{
+			"order and other phase",
+			[]v1.Plugin{newTestPlugin("a", "PostInstall", 2), newTestPlugin("b", "PostInstall", 1),
+				newTestPlugin("c", "PreInit", 0)},
+			[]string{"b", "a"},
+			false,
+		},
+		{
+			"depends on",
+			[]v1.Plugin{newTestPlugin("a", "PostInstall", 0, "c"), newTestPlugin("b", "PostInstall", 1),
+				newTestPlugin("c", "PostInstall", 2, "d"), newTestPlugin("d", "PreInit", 0)},
+			[]string{"b", "c", "a"},
+			false,
+		},
+		{
+			"dependency not found",
+			[]v1.Plugin{newTestPlugin("a", "PostInstall", 0, "x")},
+			nil,
+			true,
+		},
+		{
+			"circular dependency",
+			[]v1.Plugin{newTestPlugin("a", "PostInstall", 0, "b"), newTestPlugin("b", "PostInstall", 0, "a")},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SortPlugins(tt.plugins, PhasePostInstall)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SortPlugins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, p := range got {
+				names = append(names, p.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("SortPlugins() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRunPolicy(t *testing.T) {
+	tests := []struct {
+		timeout       string
+		failurePolicy string
+		want          runPolicy
+		wantErr       bool
+	}{
+		{"", "", runPolicy{failurePolicy: FailurePolicyFail}, false},
+		{"30s", "Ignore", runPolicy{timeout: 30 * time.Second, failurePolicy: FailurePolicyIgnore}, false},
+		{"", "Retry 3", runPolicy{failurePolicy: FailurePolicyRetry, retries: 3}, false},
+		{"", "Retry", runPolicy{}, true},
+		{"", "Retry -1", runPolicy{}, true},
+		{"", "ignore", runPolicy{}, true},
+		{"5", "", runPolicy{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.timeout+"/"+tt.failurePolicy, func(t *testing.T) {
+			p := v1.Plugin{Spec: v1.PluginSpec{Timeout: tt.timeout, FailurePolicy: tt.failurePolicy}}
+			got, err := parseRunPolicy(p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRunPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseRunPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// flakyPlugin fails until it is run the given times, and sleeps if the data is a duration.
+type flakyPlugin struct {
+	sync.Mutex
+	runs map[string]int
+}
+
+func (f *flakyPlugin) Run(context Context, phase Phase) error {
+	f.Lock()
+	f.runs[context.Plugin.Name]++
+	runs := f.runs[context.Plugin.Name]
+	f.Unlock()
+	if d, err := time.ParseDuration(context.Plugin.Spec.Data); err == nil {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-context.cancelContext().Done():
+			return context.Cancel.Err()
+		}
+	}
+	if context.Plugin.Spec.Data == "fail" || runs < 2 && context.Plugin.Spec.Data == "flaky" {
+		return fmt.Errorf("%s failed", context.Plugin.Name)
+	}
+	return nil
+}
+
+func TestPluginsProcessor_RunPolicy(t *testing.T) {
+	pluginRetryInterval = 0
+	f := &flakyPlugin{runs: map[string]int{}}
+	pluginFactories["POLICY_TEST"] = f
+	defer delete(pluginFactories, "POLICY_TEST")
+
+	newPlugin := func(name, data, policy, timeout string, dependsOn ...string) v1.Plugin {
+		return v1.Plugin{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1.PluginSpec{Type: "POLICY_TEST", Action: "PostInstall", Data: data, FailurePolicy: policy,
+				Timeout: timeout, DependsOn: dependsOn},
+		}
+	}
+	c := &PluginsProcessor{Plugins: []v1.Plugin{
+		newPlugin("flaky", "flaky", "Retry 2", ""),
+		newPlugin("ignored", "fail", "Ignore", ""),
+		newPlugin("dependent", "", "", "", "ignored"),
+		newPlugin("slow", "1s", "Ignore", "10ms"),
+		newPlugin("failed", "fail", "", ""),
+		newPlugin("never", "", "", ""),
+	}}
+	cluster := &v2.Cluster{}
+	if err := c.Run(cluster, PhasePostInstall); err == nil {
+		t.Fatalf("Run() should fail on plugin failed")
+	}
+
+	want := []struct {
+		outcome  v2.PluginOutcome
+		attempts int
+	}{
+		{v2.PluginSucceeded, 2},
+		{v2.PluginIgnored, 1},
+		{v2.PluginSkipped, 0},
+		{v2.PluginIgnored, 1},
+		{v2.PluginFailed, 1},
+	}
+	runs := cluster.Status.PluginRuns
+	if len(runs) != len(want) {
+		t.Fatalf("Run() recorded %d runs, want %d: %+v", len(runs), len(want), runs)
+	}
+	for i, w := range want {
+		if runs[i].Outcome != w.outcome || runs[i].Attempts != w.attempts {
+			t.Errorf("run of plugin %s = %s with %d attempts, want %s with %d attempts",
+				runs[i].Name, runs[i].Outcome, runs[i].Attempts, w.outcome, w.attempts)
+		}
+	}
+	f.Lock()
+	defer f.Unlock()
+	if f.runs["dependent"] != 0 || f.runs["never"] != 0 {
+		t.Errorf("skipped plugins should not run: %v", f.runs)
+	}
+}
+
+// stubbornPlugin ignores the cancellation, returns err after sleep, and counts the runs at the same time.
+type stubbornPlugin struct {
+	sleep               time.Duration
+	err                 error
+	running, maxRunning int32
+}
+
+func (s *stubbornPlugin) Run(context Context, phase Phase) error {
+	running := atomic.AddInt32(&s.running, 1)
+	defer atomic.AddInt32(&s.running, -1)
+	for {
+		maxRunning := atomic.LoadInt32(&s.maxRunning)
+		if running <= maxRunning || atomic.CompareAndSwapInt32(&s.maxRunning, maxRunning, running) {
+			break
+		}
+	}
+	time.Sleep(s.sleep)
+	return s.err
+}
+
+func TestRunPluginTimedOutAttempt(t *testing.T) {
+	defer func(interval time.Duration) {
+		pluginRetryInterval = interval
+	}(pluginRetryInterval)
+	tests := []struct {
+		name          string
+		plugin        *stubbornPlugin
+		retryInterval time.Duration
+		wantErr       bool
+		wantAttempts  int
+		// returnsWithin is the time runPlugin should return in, not checked if 0.
+		returnsWithin time.Duration
+	}{
+		// the attempt still running is not waited, and not retried to run along with itself.
+		{"still running", &stubbornPlugin{sleep: 500 * time.Millisecond}, 10 * time.Millisecond, true, 1, 200 * time.Millisecond},
+		{"late success", &stubbornPlugin{sleep: 30 * time.Millisecond}, 200 * time.Millisecond, false, 1, 0},
+		{"late failure", &stubbornPlugin{sleep: 30 * time.Millisecond, err: fmt.Errorf("fake failure")}, 200 * time.Millisecond, true, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pluginRetryInterval = tt.retryInterval
+			config := v1.Plugin{ObjectMeta: metav1.ObjectMeta{Name: "stubborn"}}
+			var record v2.PluginRunStatus
+			start := time.Now()
+			err := runPlugin(tt.plugin, Context{Plugin: &config}, PhasePostInstall, runPolicy{timeout: 10 * time.Millisecond, retries: 2}, &record)
+			if (err != nil) != tt.wantErr || record.Attempts != tt.wantAttempts {
+				t.Fatalf("runPlugin() = %v with %d attempts, want error %t with %d attempts", err, record.Attempts, tt.wantErr, tt.wantAttempts)
+			}
+			if elapsed := time.Since(start); tt.returnsWithin > 0 && elapsed > tt.returnsWithin {
+				t.Errorf("runPlugin() returned after %s, want within %s", elapsed, tt.returnsWithin)
+			}
+			if atomic.LoadInt32(&tt.plugin.maxRunning) != 1 {
+				t.Errorf("attempts should not run at the same time, %d ran at most", tt.plugin.maxRunning)
+			}
+		})
+	}
+}
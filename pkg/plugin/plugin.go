@@ -15,6 +15,8 @@
 package plugin
 
 import (
+	"context"
+
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 )
@@ -32,6 +34,14 @@ const (
 	PhaseOriginally  = Phase("Originally")
 	PhasePreGuest    = Phase("PreGuest")
 	PhasePostClean   = Phase("PostClean")
+	// PhasePreJoin and PhasePostJoin run around joining new hosts, the new hosts are in the context.
+	PhasePreJoin  = Phase("PreJoin")
+	PhasePostJoin = Phase("PostJoin")
+	// PhasePreDelete and PhasePostDelete run for each host deleted from the cluster, the host is in the context.
+	PhasePreDelete   = Phase("PreDelete")
+	PhasePostDelete  = Phase("PostDelete")
+	PhasePreUpgrade  = Phase("PreUpgrade")
+	PhasePostUpgrade = Phase("PostUpgrade")
 )
 
 const (
@@ -54,4 +64,16 @@ const (
 type Context struct {
 	Plugin  *v1.Plugin
 	Cluster *v2.Cluster
+	// Hosts is the hosts the phase works on, like the new hosts of PreJoin, empty means the whole cluster.
+	Hosts []string
+	// Cancel is done when the run of the plugin times out, the plugin should stop its work then.
+	Cancel context.Context
+}
+
+// cancelContext returns Cancel, or a context never done if the run has no timeout.
+func (c Context) cancelContext() context.Context {
+	if c.Cancel == nil {
+		return context.Background()
+	}
+	return c.Cancel
 }
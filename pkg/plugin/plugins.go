@@ -15,19 +15,27 @@
 package plugin
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"plugin"
+	"strings"
+	"time"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/events"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// pluginRetryInterval is the interval between the attempts of plugin with Retry failure policy.
+var pluginRetryInterval = 3 * time.Second
+
 type InvalidPluginTypeError struct {
 	Name string
 }
@@ -39,7 +47,7 @@ func (err InvalidPluginTypeError) Error() string {
 type Plugins interface {
 	Dump(plugins []v1.Plugin) error
 	Load() error
-	Run(cluster *v2.Cluster, phase Phase) error
+	Run(cluster *v2.Cluster, phase Phase, hosts ...string) error
 }
 
 // PluginsProcessor : process two list: plugin config list and embed pluginFactories that contains plugin interface.
@@ -56,10 +64,13 @@ func NewPlugins(clusterName string) Plugins {
 	}
 }
 
-// Load plugin configs and shared object(.so) file from $rootfs/plugins dir.
+// Load plugin configs, exec plugin binaries and shared object(.so) file from $rootfs/plugins dir.
 func (c *PluginsProcessor) Load() error {
+	return c.loadDir(common.DefaultTheClusterRootfsPluginDir(c.ClusterName))
+}
+
+func (c *PluginsProcessor) loadDir(path string) error {
 	c.Plugins = nil
-	path := common.DefaultTheClusterRootfsPluginDir(c.ClusterName)
 	_, err := os.Stat(path)
 	if os.IsNotExist(err) {
 		return nil
@@ -72,12 +83,14 @@ func (c *PluginsProcessor) Load() error {
 	for _, f := range files {
 		// load shared object(.so) file
 		if filepath.Ext(f.Name()) == ".so" {
-			soFile := filepath.Join(common.DefaultTheClusterRootfsPluginDir(c.ClusterName), f.Name())
+			logger.Warn("golang plugin %s is deprecated, please use exec plugin instead", f.Name())
+			soFile := filepath.Join(path, f.Name())
 			p, pt, err := c.loadOutOfTree(soFile)
 			if err != nil {
 				return err
 			}
 			Register(pt, p)
+			continue
 		}
 		if utils.YamlMatcher(f.Name()) {
 			plugins, err := utils.DecodePlugins(filepath.Join(path, f.Name()))
@@ -85,17 +98,50 @@ func (c *PluginsProcessor) Load() error {
 				return fmt.Errorf("failed to load plugin %v", err)
 			}
 			c.Plugins = append(c.Plugins, plugins...)
+			continue
+		}
+		if !isExecPluginFile(f) {
+			continue
+		}
+		if !strings.HasPrefix(f.Name(), ExecPluginPrefix) {
+			logger.Warn("executable %s in plugin dir is not loaded, exec plugin should be named %s*", f.Name(), ExecPluginPrefix)
+			continue
+		}
+		if err = c.loadExecPlugin(filepath.Join(path, f.Name())); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// Run execute each in-tree or out-of-tree plugin by traversing the plugin list.
-func (c *PluginsProcessor) Run(cluster *v2.Cluster, phase Phase) error {
-	for _, config := range c.Plugins {
-		if config.Spec.Action != string(phase) {
-			continue
+// loadExecPlugin register the exec plugin by the type it describes, the same binary may be loaded again.
+func (c *PluginsProcessor) loadExecPlugin(path string) error {
+	p, err := NewExecPlugin(path)
+	if err != nil {
+		return err
+	}
+	if registered, ok := pluginFactories[p.Type]; ok {
+		if e, isExec := registered.(*ExecPlugin); isExec && e.Path == path {
+			return nil
 		}
+		return fmt.Errorf("failed to load plugin %s: plugin type %s already registered", path, p.Type)
+	}
+	Register(p.Type, p)
+	logger.Debug("load exec plugin %s of type %s", path, p.Type)
+	return nil
+}
+
+// Run execute each in-tree or out-of-tree plugin of the phase in order, and record each run to cluster status.
+// hosts is passed to the plugins when the phase only works on some hosts.
+func (c *PluginsProcessor) Run(cluster *v2.Cluster, phase Phase, hosts ...string) error {
+	plugins, err := SortPlugins(c.Plugins, phase)
+	if err != nil {
+		return err
+	}
+	// failed is the plugins not succeeded, the plugins depend on them are skipped.
+	failed := map[string]bool{}
+	for i := range plugins {
+		config := plugins[i]
 		p, ok := pluginFactories[config.Spec.Type]
 		// if we use cluster file dump plugin config,some plugin load after mount rootfs,
 		// we still need to return those not find error.
@@ -103,15 +149,134 @@ func (c *PluginsProcessor) Run(cluster *v2.Cluster, phase Phase) error {
 		if !ok {
 			return InvalidPluginTypeError{config.Spec.Type}
 		}
-		// #nosec
-		err := p.Run(Context{Cluster: cluster, Plugin: &config}, phase)
+
+		record := v2.PluginRunStatus{
+			Name:      config.Name,
+			Type:      config.Spec.Type,
+			Phase:     string(phase),
+			Hosts:     hosts,
+			StartTime: metav1.Now(),
+		}
+		if dep := failedDependency(config, failed); dep != "" {
+			record.Outcome = v2.PluginSkipped
+			record.Message = fmt.Sprintf("dependency %s did not succeed", dep)
+			logger.Warn("plugin %s is skipped: %s", config.Name, record.Message)
+			failed[config.Name] = true
+			cluster.Status.PluginRuns = append(cluster.Status.PluginRuns, record)
+			events.Emit(events.Event{Kind: events.KindPlugin, Status: events.Skipped, Cluster: cluster.Name, Name: config.Name,
+				Phase: string(phase), Hosts: hosts, Error: record.Message})
+			continue
+		}
+
+		policy, err := parseRunPolicy(config)
 		if err != nil {
 			return err
 		}
+		done := events.Start(events.Event{Kind: events.KindPlugin, Cluster: cluster.Name, Name: config.Name, Phase: string(phase), Hosts: hosts})
+		// #nosec
+		err = runPlugin(p, Context{Cluster: cluster, Plugin: &config, Hosts: hosts}, phase, policy, &record)
+		done(err)
+		cluster.Status.PluginRuns = append(cluster.Status.PluginRuns, record)
+		if err == nil {
+			logger.Debug("plugin %s succeeded at %s in %s", config.Name, phase, record.Duration)
+			continue
+		}
+		failed[config.Name] = true
+		if record.Outcome == v2.PluginIgnored {
+			logger.Warn("plugin %s failed at %s and is ignored: %v", config.Name, phase, err)
+			continue
+		}
+		return fmt.Errorf("failed to run plugin %s at %s: %v", config.Name, phase, err)
 	}
 	return nil
 }
 
+// runPlugin run the plugin by its timeout and failure policy, and fill the outcome to record.
+func runPlugin(p Interface, ctx Context, phase Phase, policy runPolicy, record *v2.PluginRunStatus) error {
+	var (
+		start = time.Now()
+		late  <-chan error
+		err   error
+	)
+attempts:
+	for record.Attempts = 1; ; record.Attempts++ {
+		if late, err = runWithTimeout(p, ctx, phase, policy.timeout); err == nil || record.Attempts > policy.retries {
+			break
+		}
+		if late == nil {
+			logger.Warn("plugin %s failed at attempt %d: %v, will retry", ctx.Plugin.Name, record.Attempts, err)
+			time.Sleep(pluginRetryInterval)
+			continue
+		}
+		// the timed out attempt is still running, it is retried only if it fails before the retry interval,
+		// so that a retry never runs along with it.
+		select {
+		case lateErr := <-late:
+			if lateErr == nil {
+				err = nil
+				break attempts
+			}
+			logger.Warn("plugin %s failed at attempt %d: %v, will retry", ctx.Plugin.Name, record.Attempts, lateErr)
+		case <-time.After(pluginRetryInterval):
+			logger.Warn("plugin %s is still running after timeout at attempt %d, will not retry", ctx.Plugin.Name, record.Attempts)
+			break attempts
+		}
+	}
+	record.Duration = time.Since(start).Round(time.Millisecond).String()
+
+	switch {
+	case err == nil:
+		record.Outcome = v2.PluginSucceeded
+	case policy.failurePolicy == FailurePolicyIgnore:
+		record.Outcome = v2.PluginIgnored
+	default:
+		record.Outcome = v2.PluginFailed
+	}
+	if err != nil {
+		record.Message = err.Error()
+	}
+	return err
+}
+
+// runWithTimeout stop the plugin when timeout, exec plugin process is killed, and in-tree
+// plugin is cancelled by the Cancel of its context. An in-tree plugin not returned at timeout
+// is left to finish in the background, the returned late channel receives its result then.
+func runWithTimeout(p Interface, ctx Context, phase Phase, timeout time.Duration) (late <-chan error, err error) {
+	if timeout <= 0 {
+		return nil, p.Run(ctx, phase)
+	}
+	c, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if e, ok := p.(*ExecPlugin); ok {
+		return nil, e.RunWithContext(c, ctx, phase)
+	}
+
+	ctx.Cancel = c
+	errCh := make(chan error, 1)
+	go func() {
+		err := p.Run(ctx, phase)
+		if c.Err() == context.DeadlineExceeded {
+			logger.Warn("plugin %s returned after timeout of %s: %v", ctx.Plugin.Name, timeout, err)
+		}
+		errCh <- err
+	}()
+	select {
+	case err = <-errCh:
+		return nil, err
+	case <-c.Done():
+		return errCh, fmt.Errorf("timeout after %s", timeout)
+	}
+}
+
+func failedDependency(p v1.Plugin, failed map[string]bool) string {
+	for _, dep := range p.Spec.DependsOn {
+		if failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
 func (c *PluginsProcessor) loadOutOfTree(soFile string) (Interface, string, error) {
 	plug, err := plugin.Open(soFile)
 	if err != nil {
@@ -15,6 +15,7 @@
 package plugin
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/alibaba/sealer/pkg/clusterfile"
@@ -125,3 +126,34 @@ func TestDumperPlugin_Run(t *testing.T) {
 		})
 	}
 }
+
+type hostsRecorder struct {
+	hosts []string
+}
+
+func (h *hostsRecorder) Run(context Context, phase Phase) error {
+	h.hosts = append(h.hosts, context.Hosts...)
+	return nil
+}
+
+func TestPluginsProcessor_RunWithHosts(t *testing.T) {
+	recorder := &hostsRecorder{}
+	pluginFactories["HOSTS_TEST"] = recorder
+	defer delete(pluginFactories, "HOSTS_TEST")
+
+	c := &PluginsProcessor{Plugins: []v1.Plugin{
+		{Spec: v1.PluginSpec{Type: "HOSTS_TEST", Action: string(PhasePreJoin)}},
+		{Spec: v1.PluginSpec{Type: "HOSTS_TEST", Action: string(PhasePostJoin)}},
+	}}
+	cluster := &v2.Cluster{}
+	if err := c.Run(cluster, PhasePreJoin, "192.168.0.3", "192.168.0.4"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := []string{"192.168.0.3", "192.168.0.4"}
+	if !reflect.DeepEqual(recorder.hosts, want) {
+		t.Errorf("plugin got hosts %v, want %v", recorder.hosts, want)
+	}
+	if len(cluster.Status.PluginRuns) != 1 || !reflect.DeepEqual(cluster.Status.PluginRuns[0].Hosts, want) {
+		t.Errorf("Run() recorded %+v, want hosts %v", cluster.Status.PluginRuns, want)
+	}
+}
@@ -16,6 +16,7 @@ package plugin
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/alibaba/sealer/pkg/env"
 
@@ -23,6 +24,9 @@ import (
 	"github.com/alibaba/sealer/utils/ssh"
 )
 
+// PluginHostsEnv is the env of shell plugin, which is the hosts of phase separated by space.
+const PluginHostsEnv = "SEALER_PLUGIN_HOSTS"
+
 type Sheller struct{}
 
 func NewShellPlugin() Interface {
@@ -42,8 +46,12 @@ func (s Sheller) Run(context Context, phase Phase) (err error) {
 	if phase != PhaseOriginally {
 		pluginCmd = fmt.Sprintf(common.CdAndExecCmd, common.DefaultTheClusterRootfsDir(context.Cluster.Name), pluginCmd)
 	}
-	//get all host ip
+	//get all host ip, or the hosts of phase like the new hosts of PreJoin, which are also exported to the shell.
 	allHostIP := append(context.Cluster.GetMasterIPList(), context.Cluster.GetNodeIPList()...)
+	if len(context.Hosts) > 0 {
+		allHostIP = context.Hosts
+		pluginCmd = fmt.Sprintf("export %s=\"%s\"; %s", PluginHostsEnv, strings.Join(context.Hosts, " "), pluginCmd)
+	}
 	if on := context.Plugin.Spec.On; on != "" {
 		allHostIP, err = GetIpsByOnField(on, context, phase)
 		if err != nil {
@@ -56,7 +64,7 @@ func (s Sheller) Run(context Context, phase Phase) (err error) {
 		if err != nil {
 			return err
 		}
-		err = sshClient.CmdAsync(ip, envProcessor.WrapperShell(ip, pluginCmd))
+		err = sshClient.CmdAsyncContext(context.cancelContext(), ip, envProcessor.WrapperShell(ip, pluginCmd))
 		if err != nil {
 			return fmt.Errorf("failed to run shell cmd,  %v", err)
 		}
@@ -110,6 +110,11 @@ func (l LabelsNodes) getAddress(addresses []v1.NodeAddress) string {
 	return ""
 }
 
+// main serves the plugin as an exec plugin: go build -o label_nodes label_nodes.go
+func main() {
+	plugin.ServeExec(PluginType, Plugin)
+}
+
 // Plugin is the exposed variable sealer will look up it.
 //nolint
 var Plugin LabelsNodes
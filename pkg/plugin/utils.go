@@ -29,11 +29,21 @@ const (
 	ColonSymbol = ":"
 )
 
+// inClusterPhases are the phases the kubernetes cluster is running, so nodes can be selected by label.
+var inClusterPhases = map[Phase]bool{
+	PhasePostInstall: true,
+	PhasePreJoin:     true,
+	PhasePostJoin:    true,
+	PhasePreDelete:   true,
+	PhasePreUpgrade:  true,
+	PhasePostUpgrade: true,
+}
+
 func GetIpsByOnField(on string, context Context, phase Phase) (ipList []string, err error) {
 	on = strings.TrimSpace(on)
 	if strings.Contains(on, EqualSymbol) {
-		if phase != PhasePostInstall {
-			return nil, fmt.Errorf("the action must be PostInstall, When nodes is specified with a label")
+		if !inClusterPhases[phase] {
+			return nil, fmt.Errorf("the action must be one of PostInstall, PreJoin, PostJoin, PreDelete, PreUpgrade and PostUpgrade, When nodes is specified with a label")
 		}
 		client, err := k8s.Newk8sClient()
 		if err != nil {
@@ -0,0 +1,80 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+const (
+	KubeadmRuntimeType = "kubeadm"
+	K3sRuntimeType     = "k3s"
+)
+
+// Factory create the runtime of cluster, clusterfileKubeConfig is only used by kubeadm runtime.
+type Factory func(cluster *v2.Cluster, clusterfileKubeConfig *KubeadmConfig) (Interface, error)
+
+var runtimeFactories = make(map[string]Factory)
+
+// contextRuntime is a runtime whose commands on the hosts are killed once the context set is done,
+// and which confirms the operations by the options set.
+type contextRuntime interface {
+	setContext(ctx context.Context, opts Options)
+}
+
+func Register(name string, factory Factory) {
+	if factory == nil {
+		panic("Must not provide nil runtimeFactory")
+	}
+	_, registered := runtimeFactories[name]
+	if registered {
+		panic(fmt.Sprintf("runtimeFactory named %s already registered", name))
+	}
+
+	runtimeFactories[name] = factory
+}
+
+// GetClusterRuntimeType read the runtime type from the Metadata of cluster image, the mounted cloud image
+// is preferred, then the cluster rootfs, kubeadm is used if the Metadata not exist or not set runtime.
+func GetClusterRuntimeType(cluster *v2.Cluster) (string, error) {
+	for _, rootfs := range []string{common.DefaultMountCloudImageDir(cluster.Name), common.DefaultTheClusterRootfsDir(cluster.Name)} {
+		md, err := LoadMetadata(rootfs)
+		if err != nil {
+			return "", err
+		}
+		if md != nil && md.ClusterRuntime != "" {
+			return md.ClusterRuntime, nil
+		}
+	}
+	return KubeadmRuntimeType, nil
+}
+
+func newRuntime(cluster *v2.Cluster, clusterfileKubeConfig *KubeadmConfig) (Interface, error) {
+	runtimeType, err := GetClusterRuntimeType(cluster)
+	if err != nil {
+		return nil, err
+	}
+	factory, ok := runtimeFactories[runtimeType]
+	if !ok {
+		return nil, fmt.Errorf("cluster runtime not registered: %s", runtimeType)
+	}
+	logger.Debug("use %s runtime for cluster %s", runtimeType, cluster.Name)
+	return factory(cluster, clusterfileKubeConfig)
+}
@@ -0,0 +1,418 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/cert"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	k3sBinaryPath      = "/usr/local/bin/k3s"
+	k3sConfigDir       = "/etc/rancher/k3s"
+	k3sDataDir         = "/var/lib/rancher"
+	k3sTokenFile       = "/var/lib/rancher/k3s/server/token"
+	k3sKubeConfigFile  = "/etc/rancher/k3s/k3s.yaml"
+	k3sServerService   = "k3s"
+	k3sAgentService    = "k3s-agent"
+	k3sSystemdUnitPath = "/etc/systemd/system/%s.service"
+	k3sFileEOF         = "SEALER_K3S_EOF"
+
+	RemoteInstallK3sBinary = "cp -f %s/bin/k3s " + k3sBinaryPath + " && chmod +x " + k3sBinaryPath +
+		" && ln -sf " + k3sBinaryPath + " /usr/local/bin/crictl && cp -f " + k3sBinaryPath + " " + common.KubectlPath
+	// the quoted heredoc keeps the content as it is, the shell does not expand $, ` and \ in it.
+	RemoteWriteK3sFile    = "mkdir -p $(dirname %s) && cat > %s <<'" + k3sFileEOF + "'\n%s\n" + k3sFileEOF
+	RemoteStartK3sService = "systemctl daemon-reload && systemctl enable %s && systemctl restart %s"
+	RemoteK3sAdminConf    = "mkdir -p /etc/kubernetes && sed 's/127.0.0.1/%s/g' " + k3sKubeConfigFile + " > " + common.KubeAdminConf
+	RemoteCleanK3sNode    = "systemctl disable --now %s %s || true && rm -f /etc/systemd/system/%s.service /etc/systemd/system/%s.service && " +
+		"systemctl daemon-reload && if [ -f /usr/local/bin/k3s-killall.sh ];then sh /usr/local/bin/k3s-killall.sh;fi && " +
+		"rm -rf " + k3sConfigDir + " " + k3sDataDir + " " + k3sBinaryPath + " /usr/local/bin/crictl"
+	RemoteCatK3sToken = "cat " + k3sTokenFile
+
+	k3sSystemdUnit = `[Unit]
+Description=Lightweight Kubernetes
+Documentation=https://k3s.io
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=notify
+KillMode=process
+Delegate=yes
+LimitNOFILE=1048576
+LimitNPROC=infinity
+LimitCORE=infinity
+TasksMax=infinity
+TimeoutStartSec=0
+Restart=always
+RestartSec=5s
+ExecStartPre=-/sbin/modprobe br_netfilter
+ExecStartPre=-/sbin/modprobe overlay
+ExecStart=` + k3sBinaryPath + ` %s
+
+[Install]
+WantedBy=multi-user.target`
+)
+
+// K3sRuntime install the cluster by the k3s binary in the cluster image rootfs, the image Metadata
+// should set "clusterRuntime" to "k3s". Masters run the k3s server with embedded etcd, nodes run the k3s agent.
+type K3sRuntime struct {
+	*v2.Cluster
+	ctx  context.Context
+	opts Options
+}
+
+func init() {
+	Register(K3sRuntimeType, newK3sRuntime)
+}
+
+func newK3sRuntime(cluster *v2.Cluster, _ *KubeadmConfig) (Interface, error) {
+	if len(cluster.Spec.Hosts) == 0 {
+		return nil, fmt.Errorf("master hosts cannot be empty")
+	}
+	if cluster.GetMaster0IP() == "" {
+		return nil, fmt.Errorf("master hosts ip cannot be empty")
+	}
+	return &K3sRuntime{Cluster: cluster}, nil
+}
+
+func (k *K3sRuntime) Init(cluster *v2.Cluster) error {
+	pipeline := []func() error{
+		k.generateRegistryCert,
+		k.applyRegistry,
+		k.initMaster0,
+		k.getKubectlAndKubeconfig,
+	}
+
+	for _, f := range pipeline {
+		if err := f(); err != nil {
+			return fmt.Errorf("failed to init master0 %v", err)
+		}
+	}
+	return nil
+}
+
+func (k *K3sRuntime) Upgrade() error {
+	// servers are upgraded one by one before agents, as k3s requires.
+	hosts := append(k.GetMasterIPList(), k.GetNodeIPList()...)
+	for _, host := range hosts {
+		service := k3sAgentService
+		if utils.InList(host, k.GetMasterIPList()) {
+			service = k3sServerService
+		}
+		if err := k.cmdAsync(host, fmt.Sprintf(RemoteInstallK3sBinary, k.getRootfs()),
+			fmt.Sprintf(RemoteStartK3sService, service, service)); err != nil {
+			return fmt.Errorf("failed to upgrade %s: %v", host, err)
+		}
+		logger.Info("succeeded in upgrading k3s on %s", host)
+	}
+	return nil
+}
+
+func (k *K3sRuntime) Reset() error {
+	logger.Info("Start to delete cluster: master %s, node %s", k.GetMasterIPList(), k.GetNodeIPList())
+	if err := k.opts.ConfirmDeleteNodes(); err != nil {
+		return err
+	}
+	for _, host := range append(k.GetNodeIPList(), k.GetMasterIPList()...) {
+		if err := k.cleanNode(host); err != nil {
+			logger.Error("delete node %s failed %v", host, err)
+		}
+	}
+	if _, err := utils.RunSimpleCmd(fmt.Sprintf(RemoteRemoveAPIServerEtcHost, DefaultAPIserverDomain)); err != nil {
+		return err
+	}
+	return deleteRegistry(k.Cluster)
+}
+
+func (k *K3sRuntime) JoinMasters(newMastersIPList []string) error {
+	if len(newMastersIPList) == 0 {
+		return nil
+	}
+	logger.Info("%s will be added as master", newMastersIPList)
+	token, err := k.getToken()
+	if err != nil {
+		return err
+	}
+	// k3s servers join the embedded etcd cluster one by one.
+	for _, master := range newMastersIPList {
+		if err := k.installNode(master, k3sServerService, k.serverConfig(master, token)); err != nil {
+			return fmt.Errorf("failed to join master %s: %v", master, err)
+		}
+		logger.Info("succeeded in joining %s as master", master)
+	}
+	return nil
+}
+
+func (k *K3sRuntime) JoinNodes(newNodesIPList []string) error {
+	if len(newNodesIPList) == 0 {
+		return nil
+	}
+	token, err := k.getToken()
+	if err != nil {
+		return err
+	}
+	eg, _ := errgroup.WithContext(context.Background())
+	for _, node := range newNodesIPList {
+		node := node
+		eg.Go(func() error {
+			if err := k.installNode(node, k3sAgentService, k.agentConfig(node, token)); err != nil {
+				return fmt.Errorf("failed to join node %s: %v", node, err)
+			}
+			logger.Info("succeeded in joining %s as worker", node)
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+func (k *K3sRuntime) DeleteMasters(mastersIPList []string) error {
+	if len(mastersIPList) == 0 {
+		return nil
+	}
+	logger.Info("master %s will be deleted", mastersIPList)
+	if err := k.opts.ConfirmDeleteNodes(); err != nil {
+		return err
+	}
+	return k.deleteNodes(mastersIPList)
+}
+
+func (k *K3sRuntime) DeleteNodes(nodesIPList []string) error {
+	if len(nodesIPList) == 0 {
+		return nil
+	}
+	logger.Info("worker %s will be deleted", nodesIPList)
+	if err := k.opts.ConfirmDeleteNodes(); err != nil {
+		return err
+	}
+	return k.deleteNodes(nodesIPList)
+}
+
+func (k *K3sRuntime) GetClusterMetadata() (*Metadata, error) {
+	metadata, err := LoadMetadata(k.getImageMountDir())
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		metadata, err = LoadMetadata(k.getRootfs())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if metadata == nil {
+		return nil, fmt.Errorf("failed to find metadata of cluster image %s", k.Spec.Image)
+	}
+	return metadata, nil
+}
+
+func (k *K3sRuntime) UpdateCert(certs []string) error {
+	token, err := k.getToken()
+	if err != nil {
+		return err
+	}
+	for _, master := range k.GetMasterIPList() {
+		config := k.serverConfig(master, token, certs...)
+		if master == k.GetMaster0IP() {
+			config = k.master0Config(certs...)
+		}
+		if err := k.installNode(master, k3sServerService, config); err != nil {
+			return fmt.Errorf("failed to update cert of %s: %v", master, err)
+		}
+	}
+	return nil
+}
+
+func (k *K3sRuntime) initMaster0() error {
+	master0 := k.GetMaster0IP()
+	if err := k.installNode(master0, k3sServerService, k.master0Config()); err != nil {
+		return err
+	}
+	return k.cmdAsync(master0, fmt.Sprintf(RemoteK3sAdminConf, DefaultAPIserverDomain))
+}
+
+func (k *K3sRuntime) getKubectlAndKubeconfig() error {
+	client, err := k.getHostSSHClient(k.GetMaster0IP())
+	if err != nil {
+		return err
+	}
+	return GetKubectlAndKubeconfig(client, k.GetMaster0IP())
+}
+
+// installNode install the k3s binary, write the config and start the k3s service on host.
+func (k *K3sRuntime) installNode(host, service, config string) error {
+	cf := GetRegistryConfig(k.getImageMountDir(), k.GetMaster0IP())
+	registryHost := getRegistryHost(k.getImageMountDir(), k.GetMaster0IP())
+	apiServerHost := getAPIServerHost(k.GetMaster0IP(), DefaultAPIserverDomain)
+	args := "server"
+	if service == k3sAgentService {
+		args = "agent"
+	}
+	cmds := []string{
+		fmt.Sprintf(RemoteInstallK3sBinary, k.getRootfs()),
+		fmt.Sprintf(RemoteAddEtcHosts, registryHost, registryHost),
+		fmt.Sprintf(RemoteAddEtcHosts, apiServerHost, apiServerHost),
+		k.writeFileCmd(filepath.Join(k3sConfigDir, "config.yaml"), config),
+		k.writeFileCmd(filepath.Join(k3sConfigDir, "registries.yaml"), k3sRegistriesConfig(cf)),
+		k.writeFileCmd(fmt.Sprintf(k3sSystemdUnitPath, service), fmt.Sprintf(k3sSystemdUnit, args)),
+		fmt.Sprintf(RemoteStartK3sService, service, service),
+	}
+	return k.cmdAsync(host, cmds...)
+}
+
+func (k *K3sRuntime) master0Config(certs ...string) string {
+	return fmt.Sprintf("cluster-init: true\nnode-ip: %s\n%s", k.GetMaster0IP(), k.tlsSANConfig(certs))
+}
+
+func (k *K3sRuntime) serverConfig(host, token string, certs ...string) string {
+	return fmt.Sprintf("server: https://%s:6443\ntoken: %s\nnode-ip: %s\n%s", k.GetMaster0IP(), token, host, k.tlsSANConfig(certs))
+}
+
+func (k *K3sRuntime) agentConfig(host, token string) string {
+	return fmt.Sprintf("server: https://%s:6443\ntoken: %s\nnode-ip: %s\n", k.GetMaster0IP(), token, host)
+}
+
+func (k *K3sRuntime) tlsSANConfig(certs []string) string {
+	sans := append([]string{"127.0.0.1", DefaultAPIserverDomain}, k.GetMasterIPList()...)
+	sans = append(sans, certs...)
+	var b strings.Builder
+	b.WriteString("tls-san:\n")
+	for _, san := range utils.RemoveDuplicate(sans) {
+		b.WriteString(fmt.Sprintf("  - %s\n", san))
+	}
+	return b.String()
+}
+
+// k3sRegistriesConfig make containerd of k3s pull images from the registry of cluster image.
+func k3sRegistriesConfig(cf *RegistryConfig) string {
+	registry := cf.Domain + ":" + cf.Port
+	config := fmt.Sprintf("mirrors:\n  %s:\n    endpoint:\n      - https://%s\nconfigs:\n  %s:\n    tls:\n      insecure_skip_verify: true\n",
+		registry, registry, registry)
+	if cf.Username != "" && cf.Password != "" {
+		config += fmt.Sprintf("    auth:\n      username: %s\n      password: %s\n", cf.Username, cf.Password)
+	}
+	return config
+}
+
+func (k *K3sRuntime) writeFileCmd(path, content string) string {
+	return fmt.Sprintf(RemoteWriteK3sFile, path, path, strings.TrimSuffix(content, "\n"))
+}
+
+func (k *K3sRuntime) getToken() (string, error) {
+	client, err := k.getHostSSHClient(k.GetMaster0IP())
+	if err != nil {
+		return "", err
+	}
+	token, err := client.CmdToString(k.GetMaster0IP(), RemoteCatK3sToken, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to get k3s token from master0: %v", err)
+	}
+	return strings.TrimSpace(token), nil
+}
+
+func (k *K3sRuntime) deleteNodes(hosts []string) error {
+	for _, host := range hosts {
+		hostname, err := k.getHostName(host)
+		if err != nil {
+			return err
+		}
+		if err = k.cleanNode(host); err != nil {
+			return fmt.Errorf("failed to clean node %s: %v", host, err)
+		}
+		if err = k.cmdAsync(k.GetMaster0IP(), fmt.Sprintf(KubeDeleteNode, hostname)); err != nil {
+			return fmt.Errorf("delete node %s failed %v", hostname, err)
+		}
+	}
+	return nil
+}
+
+func (k *K3sRuntime) cleanNode(host string) error {
+	registryHost := getRegistryHost(k.getRootfs(), k.GetMaster0IP())
+	return k.cmdAsync(host,
+		fmt.Sprintf(RemoteCleanK3sNode, k3sServerService, k3sAgentService, k3sServerService, k3sAgentService),
+		RemoveKubeConfig,
+		fmt.Sprintf(RemoteRemoveAPIServerEtcHost, DefaultAPIserverDomain),
+		fmt.Sprintf(RemoteRemoveAPIServerEtcHost, registryHost))
+}
+
+func (k *K3sRuntime) getHostName(host string) (string, error) {
+	client, err := k.getHostSSHClient(host)
+	if err != nil {
+		return "", err
+	}
+	hostname, err := client.CmdToString(host, "hostname", "")
+	if err != nil {
+		return "", fmt.Errorf("failed to get hostname of %s: %v", host, err)
+	}
+	return strings.ToLower(strings.TrimSpace(hostname)), nil
+}
+
+func (k *K3sRuntime) generateRegistryCert() error {
+	cf := GetRegistryConfig(k.getImageMountDir(), k.GetMaster0IP())
+	if err := cert.GenerateRegistryCert(common.TheDefaultClusterCertDir(k.Name), cf.Domain); err != nil {
+		return err
+	}
+	client, err := k.getHostSSHClient(cf.IP)
+	if err != nil {
+		return err
+	}
+	return client.Copy(cf.IP, common.TheDefaultClusterCertDir(k.Name), filepath.Join(k.getRootfs(), "certs"))
+}
+
+func (k *K3sRuntime) applyRegistry() error {
+	_, err := startRegistry(k.Cluster)
+	return err
+}
+
+func (k *K3sRuntime) cmdAsync(host string, cmds ...string) error {
+	client, err := k.getHostSSHClient(host)
+	if err != nil {
+		return err
+	}
+	return client.CmdAsync(host, cmds...)
+}
+
+func (k *K3sRuntime) getHostSSHClient(hostIP string) (ssh.Interface, error) {
+	client, err := ssh.NewStdoutSSHClient(hostIP, k.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.WithContext(k.ctx, client), nil
+}
+
+func (k *K3sRuntime) setContext(ctx context.Context, opts Options) {
+	k.ctx = ctx
+	k.opts = opts
+}
+
+// /var/lib/sealer/data/my-cluster/rootfs
+func (k *K3sRuntime) getRootfs() string {
+	return common.DefaultTheClusterRootfsDir(k.Name)
+}
+
+// /var/lib/sealer/data/my-cluster/mount
+func (k *K3sRuntime) getImageMountDir() string {
+	return common.DefaultMountCloudImageDir(k.Name)
+}
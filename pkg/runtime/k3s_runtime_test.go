@@ -0,0 +1,123 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/common"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestK3sRuntime_Configs(t *testing.T) {
+	cluster := &v2.Cluster{
+		Spec: v2.ClusterSpec{
+			Hosts: []v2.Host{
+				{IPS: []string{"192.168.0.2", "192.168.0.3"}, Roles: []string{common.MASTER}},
+				{IPS: []string{"192.168.0.4"}, Roles: []string{common.NODE}},
+			},
+		},
+	}
+	k := &K3sRuntime{Cluster: cluster}
+	tests := []struct {
+		name   string
+		config string
+		want   map[string]interface{}
+	}{
+		{
+			name:   "master0 init the embedded etcd",
+			config: k.master0Config("10.0.0.1"),
+			want: map[string]interface{}{
+				"cluster-init": true,
+				"node-ip":      "192.168.0.2",
+				"tls-san":      []interface{}{"127.0.0.1", DefaultAPIserverDomain, "192.168.0.2", "192.168.0.3", "10.0.0.1"},
+			},
+		},
+		{
+			name:   "other master join master0",
+			config: k.serverConfig("192.168.0.3", "token"),
+			want: map[string]interface{}{
+				"server":  "https://192.168.0.2:6443",
+				"token":   "token",
+				"node-ip": "192.168.0.3",
+				"tls-san": []interface{}{"127.0.0.1", DefaultAPIserverDomain, "192.168.0.2", "192.168.0.3"},
+			},
+		},
+		{
+			name:   "agent join master0",
+			config: k.agentConfig("192.168.0.4", "token"),
+			want: map[string]interface{}{
+				"server":  "https://192.168.0.2:6443",
+				"token":   "token",
+				"node-ip": "192.168.0.4",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(tt.config), &got); err != nil {
+				t.Fatalf("failed to unmarshal config %s: %v", tt.config, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("config = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestK3sRegistriesConfig(t *testing.T) {
+	config := k3sRegistriesConfig(&RegistryConfig{Domain: SeaHub, Port: "5000", Username: "admin", Password: "passw0rd"})
+	got := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(config), &got); err != nil {
+		t.Fatalf("failed to unmarshal registries config %s: %v", config, err)
+	}
+	want := map[string]interface{}{
+		"mirrors": map[string]interface{}{
+			"sea.hub:5000": map[string]interface{}{"endpoint": []interface{}{"https://sea.hub:5000"}},
+		},
+		"configs": map[string]interface{}{
+			"sea.hub:5000": map[string]interface{}{
+				"tls":  map[string]interface{}{"insecure_skip_verify": true},
+				"auth": map[string]interface{}{"username": "admin", "password": "passw0rd"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("k3sRegistriesConfig() = %v, want %v", got, want)
+	}
+}
+
+func TestK3sRuntime_writeFileCmd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "k3s", "registries.yaml")
+	content := "configs:\n  sea.hub:5000:\n    auth:\n      username: admin\n      password: \"p$a`s\\s'w0rd\"\n"
+	cmd := (&K3sRuntime{}).writeFileCmd(path, content)
+	if out, err := exec.Command("sh", "-c", cmd).CombinedOutput(); err != nil {
+		t.Fatalf("failed to run %s: %s %v", cmd, out, err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("writeFileCmd() wrote %q, want %q", got, content)
+	}
+}
@@ -43,6 +43,10 @@ type Config struct {
 	APIServerDomain       string
 }
 
+func init() {
+	Register(KubeadmRuntimeType, newKubeadmRuntime)
+}
+
 func newKubeadmRuntime(cluster *v2.Cluster, clusterFileKubeConfig *KubeadmConfig) (Interface, error) {
 	k := &KubeadmRuntime{
 		Cluster: cluster,
@@ -90,7 +94,16 @@ func (k *KubeadmRuntime) getClusterMetadata() (*Metadata, error) {
 }
 
 func (k *KubeadmRuntime) getHostSSHClient(hostIP string) (ssh.Interface, error) {
-	return ssh.NewStdoutSSHClient(hostIP, k.Cluster)
+	client, err := ssh.NewStdoutSSHClient(hostIP, k.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.WithContext(k.ctx, client), nil
+}
+
+func (k *KubeadmRuntime) setContext(ctx context.Context, opts Options) {
+	k.ctx = ctx
+	k.opts = opts
 }
 
 // /var/lib/sealer/data/my-cluster
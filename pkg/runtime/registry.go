@@ -21,7 +21,9 @@ import (
 	"github.com/alibaba/sealer/common"
 
 	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -51,35 +53,51 @@ func getRegistryHost(rootfs, defaultRegistry string) (host string) {
 
 // ApplyRegistry Only use this for join and init, due to the initiation operations.
 func (k *KubeadmRuntime) ApplyRegistry() error {
-	cf := GetRegistryConfig(k.getImageMountDir(), k.GetMaster0IP())
-	ssh, err := k.getHostSSHClient(cf.IP)
+	cf, err := startRegistry(k.Cluster)
 	if err != nil {
-		return fmt.Errorf("failed to get registry ssh client: %v", err)
+		return err
+	}
+	if cf.Username == "" || cf.Password == "" {
+		return nil
+	}
+	ssh, err := k.getHostSSHClient(k.GetMaster0IP())
+	if err != nil {
+		return fmt.Errorf("failed to get master0 ssh client: %v", err)
+	}
+	return ssh.CmdAsync(k.GetMaster0IP(), fmt.Sprintf(DockerLoginCommand, cf.Domain+":"+cf.Port, cf.Username, cf.Password))
+}
+
+// startRegistry run the registry of cluster image on the registry host and add the registry domain to hosts of master0,
+// it is shared by all the runtimes.
+func startRegistry(cluster *v2.Cluster) (*RegistryConfig, error) {
+	rootfs := common.DefaultTheClusterRootfsDir(cluster.Name)
+	mountDir := common.DefaultMountCloudImageDir(cluster.Name)
+	cf := GetRegistryConfig(mountDir, cluster.GetMaster0IP())
+	ssh, err := ssh.NewStdoutSSHClient(cf.IP, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry ssh client: %v", err)
 	}
 
 	if cf.Username != "" && cf.Password != "" {
 		htpasswd, err := cf.GenerateHtPasswd()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		err = ssh.CmdAsync(cf.IP, fmt.Sprintf("echo '%s' > %s", htpasswd, filepath.Join(k.getRootfs(), "etc", DefaultRegistryHtPasswdFile)))
+		err = ssh.CmdAsync(cf.IP, fmt.Sprintf("echo '%s' > %s", htpasswd, filepath.Join(rootfs, "etc", DefaultRegistryHtPasswdFile)))
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
-	initRegistry := fmt.Sprintf("cd %s/scripts && sh init-registry.sh %s %s %s", k.getRootfs(), cf.Port, fmt.Sprintf("%s/registry", k.getRootfs()), cf.Domain)
-	registryHost := getRegistryHost(k.getImageMountDir(), k.GetMaster0IP())
+	initRegistry := fmt.Sprintf("cd %s/scripts && sh init-registry.sh %s %s %s", rootfs, cf.Port, fmt.Sprintf("%s/registry", rootfs), cf.Domain)
+	registryHost := getRegistryHost(mountDir, cluster.GetMaster0IP())
 	addRegistryHosts := fmt.Sprintf(RemoteAddEtcHosts, registryHost, registryHost)
 	if err = ssh.CmdAsync(cf.IP, initRegistry); err != nil {
-		return err
+		return nil, err
 	}
-	if err = ssh.CmdAsync(k.GetMaster0IP(), addRegistryHosts); err != nil {
-		return err
+	if err = ssh.CmdAsync(cluster.GetMaster0IP(), addRegistryHosts); err != nil {
+		return nil, err
 	}
-	if cf.Username == "" || cf.Password == "" {
-		return nil
-	}
-	return ssh.CmdAsync(k.GetMaster0IP(), fmt.Sprintf(DockerLoginCommand, cf.Domain+":"+cf.Port, cf.Username, cf.Password))
+	return cf, nil
 }
 
 func (r *RegistryConfig) GenerateHtPasswd() (string, error) {
@@ -127,8 +145,12 @@ func GetRegistryConfig(rootfs, defaultRegistry string) *RegistryConfig {
 }
 
 func (k *KubeadmRuntime) DeleteRegistry() error {
-	cf := GetRegistryConfig(k.getRootfs(), k.GetMaster0IP())
-	ssh, err := k.getHostSSHClient(cf.IP)
+	return deleteRegistry(k.Cluster)
+}
+
+func deleteRegistry(cluster *v2.Cluster) error {
+	cf := GetRegistryConfig(common.DefaultTheClusterRootfsDir(cluster.Name), cluster.GetMaster0IP())
+	ssh, err := ssh.NewStdoutSSHClient(cf.IP, cluster)
 	if err != nil {
 		return fmt.Errorf("failed to delete registry: %v", err)
 	}
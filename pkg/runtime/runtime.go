@@ -15,6 +15,7 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -44,6 +45,8 @@ type Metadata struct {
 	//KubeVersion is a SemVer constraint specifying the version of Kubernetes required.
 	KubeVersion string `json:"kubeVersion"`
 	NydusFlag   bool   `json:"NydusFlag"`
+	// ClusterRuntime is the runtime used to install the cluster, like kubeadm or k3s, default is kubeadm.
+	ClusterRuntime string `json:"clusterRuntime,omitempty"`
 }
 
 type KubeadmRuntime struct {
@@ -51,10 +54,22 @@ type KubeadmRuntime struct {
 	*v2.Cluster
 	*KubeadmConfig
 	*Config
+	ctx  context.Context
+	opts Options
 }
 
+// ForceDelete is set by the --force flag, the nodes are deleted without confirmation by all the runtimes.
 var ForceDelete bool
 
+// Options are the options of a runtime created by NewDefaultRuntimeContext.
+type Options struct {
+	// ForceDelete deletes the nodes without confirmation.
+	ForceDelete bool
+	// NonInteractive never reads stdin: deleting nodes fails unless ForceDelete is set,
+	// and a paused upgrade is aborted.
+	NonInteractive bool
+}
+
 func (k *KubeadmRuntime) Init(cluster *v2.Cluster) error {
 	return k.init(cluster)
 }
@@ -65,7 +80,7 @@ func (k *KubeadmRuntime) Upgrade() error {
 
 func (k *KubeadmRuntime) Reset() error {
 	logger.Info("Start to delete cluster: master %s, node %s", k.Cluster.GetMasterIPList(), k.Cluster.GetNodeIPList())
-	if err := k.confirmDeleteNodes(); err != nil {
+	if err := k.opts.ConfirmDeleteNodes(); err != nil {
 		return err
 	}
 	return k.reset()
@@ -88,7 +103,7 @@ func (k *KubeadmRuntime) JoinNodes(newNodesIPList []string) error {
 func (k *KubeadmRuntime) DeleteMasters(mastersIPList []string) error {
 	if len(mastersIPList) != 0 {
 		logger.Info("master %s will be deleted", mastersIPList)
-		if err := k.confirmDeleteNodes(); err != nil {
+		if err := k.opts.ConfirmDeleteNodes(); err != nil {
 			return err
 		}
 	}
@@ -98,20 +113,25 @@ func (k *KubeadmRuntime) DeleteMasters(mastersIPList []string) error {
 func (k *KubeadmRuntime) DeleteNodes(nodesIPList []string) error {
 	if len(nodesIPList) != 0 {
 		logger.Info("worker %s will be deleted", nodesIPList)
-		if err := k.confirmDeleteNodes(); err != nil {
+		if err := k.opts.ConfirmDeleteNodes(); err != nil {
 			return err
 		}
 	}
 	return k.deleteNodes(nodesIPList)
 }
 
-func (k *KubeadmRuntime) confirmDeleteNodes() error {
-	if !ForceDelete {
-		if pass, err := utils.ConfirmOperation("Are you sure to delete these nodes? "); err != nil {
-			return err
-		} else if !pass {
-			return fmt.Errorf("exit the operation of delete these nodes")
-		}
+// ConfirmDeleteNodes ask user to confirm deleting nodes, unless ForceDelete is set by o or the --force flag.
+func (o Options) ConfirmDeleteNodes() error {
+	if o.ForceDelete || ForceDelete {
+		return nil
+	}
+	if o.NonInteractive {
+		return fmt.Errorf("exit the operation of delete these nodes, it is not confirmed in non-interactive mode")
+	}
+	if pass, err := utils.ConfirmOperation("Are you sure to delete these nodes? "); err != nil {
+		return err
+	} else if !pass {
+		return fmt.Errorf("exit the operation of delete these nodes")
 	}
 	return nil
 }
@@ -124,7 +144,21 @@ func (k *KubeadmRuntime) UpdateCert(certs []string) error {
 	return k.updateCert(certs)
 }
 
-// NewDefaultRuntime arg "clusterfileKubeConfig" is the Clusterfile path/name, runtime need read kubeadm config from it
+// NewDefaultRuntime arg "clusterfileKubeConfig" is the Clusterfile path/name, runtime need read kubeadm config from it.
+// The runtime is selected by the ClusterRuntime of cluster image Metadata.
 func NewDefaultRuntime(cluster *v2.Cluster, clusterfileKubeConfig *KubeadmConfig) (Interface, error) {
-	return newKubeadmRuntime(cluster, clusterfileKubeConfig)
+	return newRuntime(cluster, clusterfileKubeConfig)
+}
+
+// NewDefaultRuntimeContext is NewDefaultRuntime whose commands on the hosts are killed once ctx is done,
+// and which deletes nodes and handles a paused upgrade by opts.
+func NewDefaultRuntimeContext(ctx context.Context, cluster *v2.Cluster, clusterfileKubeConfig *KubeadmConfig, opts Options) (Interface, error) {
+	r, err := newRuntime(cluster, clusterfileKubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(contextRuntime); ok {
+		c.setContext(ctx, opts)
+	}
+	return r, nil
 }
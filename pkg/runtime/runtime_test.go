@@ -0,0 +1,36 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import "testing"
+
+func TestOptions_ConfirmDeleteNodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"force delete", Options{ForceDelete: true}, false},
+		{"force delete in non-interactive mode", Options{ForceDelete: true, NonInteractive: true}, false},
+		{"not confirmed in non-interactive mode", Options{NonInteractive: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.ConfirmDeleteNodes(); (err != nil) != tt.wantErr {
+				t.Errorf("ConfirmDeleteNodes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -18,93 +18,306 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/client/k8s"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
 )
 
 const (
-	chmodCmd       = `chmod +x %s/*`
-	mvCmd          = `mv %s/* /usr/bin`
-	getNodeNameCmd = `$(uname -n | tr '[A-Z]' '[a-z]')`
-	drainCmd       = `kubectl drain ` + getNodeNameCmd + ` --ignore-daemonsets`
+	chmodCmd = `chmod +x %s/*`
+	// binaries are copied rather than moved, so that a failed host can be upgraded again.
+	cpBinCmd       = `cp -f %s/* /usr/bin`
 	upgradeCmd     = `kubeadm upgrade %s`
+	upgradePlanCmd = `%s/kubeadm upgrade plan %s`
 	restartCmd     = `systemctl daemon-reload && systemctl restart kubelet`
-	uncordonCmd    = `kubectl uncordon ` + getNodeNameCmd
+	// the pods with emptyDir volumes and the ones not managed by a controller are evicted as well.
+	drainCmd    = `kubectl drain %s --ignore-daemonsets %s --force --timeout=%s`
+	uncordonCmd = `kubectl uncordon %s`
+	// backup the binaries to be replaced, kubelet config and static pod manifests before upgrade,
+	// the backup is only taken once, so it is not overwritten when the host is upgraded again.
+	backupCmd = `if [ ! -d %[2]s ]; then mkdir -p %[2]s.tmp/bin && for f in $(ls %[1]s); do if [ -f /usr/bin/$f ]; then cp -f /usr/bin/$f %[2]s.tmp/bin/; fi; done` +
+		` && cp -f /var/lib/kubelet/config.yaml %[2]s.tmp/ && if [ -d /etc/kubernetes/manifests ]; then cp -rf /etc/kubernetes/manifests %[2]s.tmp/; fi` +
+		` && mv %[2]s.tmp %[2]s; fi`
+	restoreCmd = `if [ -d %[1]s ]; then cp -f %[1]s/bin/* /usr/bin && cp -f %[1]s/config.yaml /var/lib/kubelet/config.yaml` +
+		` && if [ -d %[1]s/manifests ]; then cp -rf %[1]s/manifests/* /etc/kubernetes/manifests/; fi; fi`
+
+	defaultDrainTimeout = 5 * time.Minute
+	defaultReadyTimeout = 5 * time.Minute
+	nodeReadyInterval   = 5 * time.Second
 )
 
-func (k *KubeadmRuntime) upgrade() error {
+// confirmUpgradeRetry ask user whether to retry the failed hosts when upgrade is paused.
+var confirmUpgradeRetry = utils.ConfirmOperation
+
+type upgradeStrategy struct {
+	maxUnavailable int
+	drainTimeout   time.Duration
+	readyTimeout   time.Duration
+	failurePolicy  v2.UpgradeFailurePolicy
+}
+
+func newUpgradeStrategy(s *v2.UpgradeStrategy, nodes int) (*upgradeStrategy, error) {
+	strategy := &upgradeStrategy{
+		maxUnavailable: 1,
+		drainTimeout:   defaultDrainTimeout,
+		readyTimeout:   defaultReadyTimeout,
+		failurePolicy:  v2.UpgradeAbort,
+	}
+	if s == nil {
+		return strategy, nil
+	}
+
+	if s.MaxUnavailable != nil {
+		n, err := intstr.GetScaledValueFromIntOrPercent(s.MaxUnavailable, nodes, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maxUnavailable %s: %v", s.MaxUnavailable.String(), err)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("invalid maxUnavailable %s: must not be negative", s.MaxUnavailable.String())
+		}
+		// upgrade at least one node at a time.
+		if n > 0 {
+			strategy.maxUnavailable = n
+		}
+	}
 	var err error
-	binpath := filepath.Join(k.getRootfs(), `bin`)
+	if s.DrainTimeout != "" {
+		if strategy.drainTimeout, err = time.ParseDuration(s.DrainTimeout); err != nil {
+			return nil, fmt.Errorf("invalid drainTimeout %s: %v", s.DrainTimeout, err)
+		}
+	}
+	if s.ReadyTimeout != "" {
+		if strategy.readyTimeout, err = time.ParseDuration(s.ReadyTimeout); err != nil {
+			return nil, fmt.Errorf("invalid readyTimeout %s: %v", s.ReadyTimeout, err)
+		}
+	}
+	switch s.FailurePolicy {
+	case "":
+	case v2.UpgradeAbort, v2.UpgradePause, v2.UpgradeRollback:
+		strategy.failurePolicy = s.FailurePolicy
+	default:
+		return nil, fmt.Errorf("invalid upgrade failurePolicy %s, must be one of Abort, Pause and Rollback", s.FailurePolicy)
+	}
+	return strategy, nil
+}
 
-	err = k.upgradeFirstMaster(k.GetMaster0IP(), binpath, k.getKubeVersion())
-	if err != nil {
-		return err
+// batchHosts split hosts into batches of the size.
+func batchHosts(hosts []string, size int) [][]string {
+	var batches [][]string
+	for i := 0; i < len(hosts); i += size {
+		end := i + size
+		if end > len(hosts) {
+			end = len(hosts)
+		}
+		batches = append(batches, hosts[i:end])
 	}
-	err = k.upgradeOtherMasters(k.GetMasterIPList()[1:], binpath)
+	return batches
+}
+
+// rollingUpgrade upgrade the batches one by one, the hosts of a batch are upgraded at the same time.
+// When a batch fails, it is handled by the failure policy.
+func rollingUpgrade(batches [][]string, policy v2.UpgradeFailurePolicy, upgrade, rollback func(host string) error) error {
+	var upgraded []string
+	for i := 0; i < len(batches); i++ {
+		batch := batches[i]
+		logger.Info("start to upgrade hosts %s (batch %d/%d)", batch, i+1, len(batches))
+		eg := errgroup.Group{}
+		for _, host := range batch {
+			host := host
+			eg.Go(func() error {
+				if err := upgrade(host); err != nil {
+					return fmt.Errorf("failed to upgrade host %s: %v", host, err)
+				}
+				return nil
+			})
+		}
+		err := eg.Wait()
+		if err == nil {
+			upgraded = append(upgraded, batch...)
+			continue
+		}
+
+		switch policy {
+		case v2.UpgradePause:
+			logger.Error("upgrade is paused: %v", err)
+			retry, confirmErr := confirmUpgradeRetry(fmt.Sprintf("retry to upgrade hosts %s after fixing them? ", batch))
+			if confirmErr != nil || !retry {
+				return err
+			}
+			i--
+		case v2.UpgradeRollback:
+			logger.Error("upgrade will be rolled back: %v", err)
+			// the failed batch is rolled back first, and then the upgraded hosts in the reverse order.
+			hosts := append(append([]string{}, upgraded...), batch...)
+			for j := len(hosts) - 1; j >= 0; j-- {
+				if rollbackErr := rollback(hosts[j]); rollbackErr != nil {
+					return fmt.Errorf("failed to rollback host %s: %v, upgrade error: %v", hosts[j], rollbackErr, err)
+				}
+			}
+			return fmt.Errorf("upgrade is rolled back: %v", err)
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// hostUpgrader upgrade a host: backup, drain, upgrade, wait for it to be Ready, and uncordon.
+type hostUpgrader struct {
+	runtime  *KubeadmRuntime
+	client   *k8s.Client
+	strategy *upgradeStrategy
+	binPath  string
+	version  string
+}
+
+func (k *KubeadmRuntime) upgrade() error {
+	strategy, err := newUpgradeStrategy(k.Spec.UpgradeStrategy, len(k.GetNodeIPList()))
 	if err != nil {
 		return err
 	}
-	err = k.upgradeNodes(k.GetNodeIPList(), binpath)
+	client, err := k8s.Newk8sClient()
 	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client to check nodes: %v", err)
+	}
+	u := &hostUpgrader{
+		runtime:  k,
+		client:   client,
+		strategy: strategy,
+		binPath:  filepath.Join(k.getRootfs(), `bin`),
+		version:  k.getKubeVersion(),
+	}
+	if err = u.plan(); err != nil {
 		return err
 	}
-	return nil
+
+	// masters are upgraded one by one, the first one applies the upgrade of control plane.
+	var batches [][]string
+	for _, master := range k.GetMasterIPList() {
+		batches = append(batches, []string{master})
+	}
+	batches = append(batches, batchHosts(k.GetNodeIPList(), strategy.maxUnavailable)...)
+	policy := strategy.failurePolicy
+	if policy == v2.UpgradePause && k.opts.NonInteractive {
+		logger.Warn("upgrade failure policy %s needs confirmation to retry, use %s in non-interactive mode", policy, v2.UpgradeAbort)
+		policy = v2.UpgradeAbort
+	}
+	return rollingUpgrade(batches, policy, u.upgrade, u.rollback)
 }
 
-func (k *KubeadmRuntime) upgradeFirstMaster(IP string, binpath, version string) error {
-	var firstMasterCmds = []string{
-		fmt.Sprintf(chmodCmd, binpath),
-		fmt.Sprintf(mvCmd, binpath),
-		drainCmd,
-		fmt.Sprintf(upgradeCmd, strings.Join([]string{`apply`, version, `-y`}, " ")),
-		restartCmd,
-		uncordonCmd,
+// plan run the pre-flight checks of the new kubeadm on master0 before any host is changed.
+func (u *hostUpgrader) plan() error {
+	master0 := u.runtime.GetMaster0IP()
+	if err := u.cmdAsync(master0, fmt.Sprintf(chmodCmd, u.binPath), fmt.Sprintf(upgradePlanCmd, u.binPath, u.version)); err != nil {
+		return fmt.Errorf("kubeadm upgrade plan failed: %v", err)
 	}
-	ssh, err := k.getHostSSHClient(IP)
+	return nil
+}
+
+func (u *hostUpgrader) upgrade(host string) error {
+	node, err := u.client.GetNodeByIP(host)
 	if err != nil {
-		return fmt.Errorf("upgrade master0 failed %v", err)
+		return err
 	}
-	return ssh.CmdAsync(IP, firstMasterCmds...)
-}
 
-func (k *KubeadmRuntime) upgradeOtherMasters(IPs []string, binpath string) error {
-	var otherMasterCmds = []string{
-		fmt.Sprintf(chmodCmd, binpath),
-		fmt.Sprintf(mvCmd, binpath),
-		drainCmd,
-		fmt.Sprintf(upgradeCmd, `node`),
-		restartCmd,
-		uncordonCmd,
+	upgrade := fmt.Sprintf(upgradeCmd, `node`)
+	if host == u.runtime.GetMaster0IP() {
+		upgrade = fmt.Sprintf(upgradeCmd, strings.Join([]string{`apply`, u.version, `-y`}, " "))
 	}
-	var err error
-	for _, ip := range IPs {
-		ssh, err := k.getHostSSHClient(ip)
-		if err != nil {
-			return fmt.Errorf("upgrade other masters failed: %v", err)
-		}
-		err = ssh.CmdAsync(ip, otherMasterCmds...)
-		if err != nil {
+	if err = u.cmdAsync(host, fmt.Sprintf(backupCmd, u.binPath, u.backupDir())); err != nil {
+		return fmt.Errorf("failed to backup: %v", err)
+	}
+	master0 := node
+	if host != u.runtime.GetMaster0IP() {
+		if master0, err = u.client.GetNodeByIP(u.runtime.GetMaster0IP()); err != nil {
 			return err
 		}
 	}
-	return err
+	// kubectl on master0 is upgraded along with its kubelet.
+	drain := drainCommand(master0.Status.NodeInfo.KubeletVersion, node.Name, u.strategy.drainTimeout)
+	if err = u.cmdAsync(u.runtime.GetMaster0IP(), drain); err != nil {
+		return fmt.Errorf("failed to drain: %v", err)
+	}
+	if err = u.cmdAsync(host, fmt.Sprintf(chmodCmd, u.binPath), fmt.Sprintf(cpBinCmd, u.binPath), upgrade, restartCmd); err != nil {
+		return err
+	}
+	if err = u.waitNodeReady(node.Name, u.version); err != nil {
+		return err
+	}
+	return u.cmdAsync(u.runtime.GetMaster0IP(), fmt.Sprintf(uncordonCmd, node.Name))
 }
 
-func (k *KubeadmRuntime) upgradeNodes(IPs []string, binpath string) error {
-	var nodeCmds = []string{
-		fmt.Sprintf(chmodCmd, binpath),
-		fmt.Sprintf(mvCmd, binpath),
-		fmt.Sprintf(upgradeCmd, `node`),
-		restartCmd,
+// drainCommand returns the command draining the node by the kubectl of version, which renames
+// --delete-local-data to --delete-emptydir-data since v1.20.
+func drainCommand(version, node string, timeout time.Duration) string {
+	deleteData := "--delete-emptydir-data"
+	if !VersionCompare(version, V1200) {
+		deleteData = "--delete-local-data"
 	}
-	var err error
-	for _, ip := range IPs {
-		ssh, err := k.getHostSSHClient(ip)
+	return fmt.Sprintf(drainCmd, node, deleteData, timeout)
+}
+
+// rollback restore the binaries, kubelet config and manifests of the host from the backup, and uncordon it.
+func (u *hostUpgrader) rollback(host string) error {
+	logger.Info("start to rollback host %s", host)
+	node, err := u.client.GetNodeByIP(host)
+	if err != nil {
+		return err
+	}
+	if err = u.cmdAsync(host, fmt.Sprintf(restoreCmd, u.backupDir()), restartCmd); err != nil {
+		return err
+	}
+	if err = u.waitNodeReady(node.Name, ""); err != nil {
+		return err
+	}
+	return u.cmdAsync(u.runtime.GetMaster0IP(), fmt.Sprintf(uncordonCmd, node.Name))
+}
+
+// waitNodeReady wait for the node to be Ready, and its kubelet to be the version if it is not empty.
+func (u *hostUpgrader) waitNodeReady(name, version string) error {
+	err := wait.PollImmediate(nodeReadyInterval, u.strategy.readyTimeout, func() (bool, error) {
+		node, err := u.client.GetNode(name)
 		if err != nil {
-			return fmt.Errorf("upgrade node failed: %v", err)
+			logger.Debug("failed to get node %s: %v", name, err)
+			return false, nil
 		}
-		err = ssh.CmdAsync(ip, nodeCmds...)
-		if err != nil {
-			return err
+		return isNodeReady(node, version), nil
+	})
+	if err != nil {
+		return fmt.Errorf("node %s is not ready with version %s in %s", name, version, u.strategy.readyTimeout)
+	}
+	return nil
+}
+
+func isNodeReady(node *corev1.Node, version string) bool {
+	if version != "" && strings.TrimPrefix(node.Status.NodeInfo.KubeletVersion, "v") != strings.TrimPrefix(version, "v") {
+		return false
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
 		}
 	}
-	return err
+	return false
+}
+
+// backupDir is in the rootfs on the host, which keeps the binaries before upgrading to the version.
+func (u *hostUpgrader) backupDir() string {
+	return filepath.Join(u.runtime.getRootfs(), "upgrade-backup", u.version)
+}
+
+func (u *hostUpgrader) cmdAsync(host string, cmds ...string) error {
+	ssh, err := u.runtime.getHostSSHClient(host)
+	if err != nil {
+		return err
+	}
+	return ssh.CmdAsync(host, cmds...)
 }
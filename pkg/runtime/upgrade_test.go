@@ -0,0 +1,172 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+func TestNewUpgradeStrategy(t *testing.T) {
+	percent := intstr.FromString("10%")
+	five := intstr.FromInt(5)
+	tests := []struct {
+		name     string
+		strategy *v2.UpgradeStrategy
+		want     *upgradeStrategy
+		wantErr  bool
+	}{
+		{
+			"default",
+			nil,
+			&upgradeStrategy{maxUnavailable: 1, drainTimeout: defaultDrainTimeout, readyTimeout: defaultReadyTimeout, failurePolicy: v2.UpgradeAbort},
+			false,
+		},
+		{
+			"percent",
+			&v2.UpgradeStrategy{MaxUnavailable: &percent, DrainTimeout: "1m", FailurePolicy: v2.UpgradeRollback},
+			&upgradeStrategy{maxUnavailable: 20, drainTimeout: time.Minute, readyTimeout: defaultReadyTimeout, failurePolicy: v2.UpgradeRollback},
+			false,
+		},
+		{
+			"number",
+			&v2.UpgradeStrategy{MaxUnavailable: &five, ReadyTimeout: "10m", FailurePolicy: v2.UpgradePause},
+			&upgradeStrategy{maxUnavailable: 5, drainTimeout: defaultDrainTimeout, readyTimeout: 10 * time.Minute, failurePolicy: v2.UpgradePause},
+			false,
+		},
+		{"invalid timeout", &v2.UpgradeStrategy{DrainTimeout: "5"}, nil, true},
+		{"invalid policy", &v2.UpgradeStrategy{FailurePolicy: "Continue"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newUpgradeStrategy(tt.strategy, 200)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newUpgradeStrategy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newUpgradeStrategy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchHosts(t *testing.T) {
+	got := batchHosts([]string{"a", "b", "c", "d", "e"}, 2)
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("batchHosts() = %v, want %v", got, want)
+	}
+}
+
+// fakeHosts record the upgrade and rollback of hosts, the hosts in failures fail the given times.
+type fakeHosts struct {
+	sync.Mutex
+	failures   map[string]int
+	upgraded   []string
+	rolledBack []string
+}
+
+func (f *fakeHosts) upgrade(host string) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.failures[host] > 0 {
+		f.failures[host]--
+		return fmt.Errorf("fake failure")
+	}
+	f.upgraded = append(f.upgraded, host)
+	return nil
+}
+
+func (f *fakeHosts) rollback(host string) error {
+	f.rolledBack = append(f.rolledBack, host)
+	return nil
+}
+
+func TestRollingUpgrade(t *testing.T) {
+	batches := [][]string{{"master0"}, {"node1", "node2"}, {"node3"}}
+	defer func() {
+		confirmUpgradeRetry = utils.ConfirmOperation
+	}()
+
+	f := &fakeHosts{failures: map[string]int{"node2": 1}}
+	if err := rollingUpgrade(batches, v2.UpgradeAbort, f.upgrade, f.rollback); err == nil {
+		t.Errorf("rollingUpgrade() should abort on failure")
+	}
+	if len(f.upgraded) != 2 || len(f.rolledBack) != 0 {
+		t.Errorf("Abort upgraded %v, rolled back %v", f.upgraded, f.rolledBack)
+	}
+
+	f = &fakeHosts{failures: map[string]int{"node2": 1}}
+	confirmUpgradeRetry = func(string) (bool, error) { return true, nil }
+	if err := rollingUpgrade(batches, v2.UpgradePause, f.upgrade, f.rollback); err != nil {
+		t.Errorf("rollingUpgrade() error = %v", err)
+	}
+	if len(f.upgraded) != 5 {
+		t.Errorf("Pause upgraded %v, want all hosts with node1 upgraded twice", f.upgraded)
+	}
+
+	f = &fakeHosts{failures: map[string]int{"node3": 1}}
+	if err := rollingUpgrade(batches, v2.UpgradeRollback, f.upgrade, f.rollback); err == nil {
+		t.Errorf("rollingUpgrade() should fail when rolled back")
+	}
+	want := []string{"node3", "node2", "node1", "master0"}
+	if !reflect.DeepEqual(f.rolledBack, want) {
+		t.Errorf("Rollback rolled back %v, want %v", f.rolledBack, want)
+	}
+}
+
+func TestIsNodeReady(t *testing.T) {
+	node := &corev1.Node{Status: corev1.NodeStatus{
+		NodeInfo:   corev1.NodeSystemInfo{KubeletVersion: "v1.20.4"},
+		Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+	}}
+	if !isNodeReady(node, "v1.20.4") || !isNodeReady(node, "") {
+		t.Errorf("isNodeReady() should be true")
+	}
+	if isNodeReady(node, "v1.19.8") {
+		t.Errorf("isNodeReady() should be false for another version")
+	}
+	node.Status.Conditions[0].Status = corev1.ConditionFalse
+	if isNodeReady(node, "") {
+		t.Errorf("isNodeReady() should be false for NotReady node")
+	}
+}
+
+func TestDrainCommand(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"v1.19.8", "kubectl drain node1 --ignore-daemonsets --delete-local-data --force --timeout=5m0s"},
+		{"v1.20.4", "kubectl drain node1 --ignore-daemonsets --delete-emptydir-data --force --timeout=5m0s"},
+		{"v1.22.15", "kubectl drain node1 --ignore-daemonsets --delete-emptydir-data --force --timeout=5m0s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			if got := drainCommand(tt.version, "node1", defaultDrainTimeout); got != tt.want {
+				t.Errorf("drainCommand() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/alibaba/sealer/apply"
+	"github.com/alibaba/sealer/apply/applydriver"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+var (
+	clusterLocksLock sync.Mutex
+	// clusterLocks run the operations on a cluster one at a time, the work dir and status of it are not shared.
+	clusterLocks = map[string]*sync.Mutex{}
+)
+
+// lockCluster waits for the operation running on the cluster, and returns the func to unlock it.
+func lockCluster(name string) func() {
+	clusterLocksLock.Lock()
+	lock, ok := clusterLocks[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		clusterLocks[name] = lock
+	}
+	clusterLocksLock.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// Apply creates the cluster of the Clusterfile at path, or makes the cluster like it, like sealer apply -f.
+// A cancelled apply kills the commands running on the hosts and stops before the next step, and it is
+// resumed by the next Apply of the same Clusterfile. It waits for the Apply or Delete running on the cluster.
+func (c *Client) Apply(ctx context.Context, clusterfile string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	applier, err := c.newApplier(clusterfile)
+	if err != nil {
+		return err
+	}
+	defer lockCluster(applier.ClusterDesired.Name)()
+	defer c.track(applier.ClusterDesired)()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	return applier.ApplyContext(ctx)
+}
+
+// Plan computes the actions Apply will take for the Clusterfile at path, without touching any host.
+func (c *Client) Plan(ctx context.Context, clusterfile string) (*applydriver.Plan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	applier, err := c.newApplier(clusterfile)
+	if err != nil {
+		return nil, err
+	}
+	return applier.Plan()
+}
+
+// Delete deletes the cluster of the Clusterfile at path, like sealer delete -f.
+// It waits for the Apply or Delete running on the cluster.
+func (c *Client) Delete(ctx context.Context, clusterfile string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	applier, err := c.newApplier(clusterfile)
+	if err != nil {
+		return err
+	}
+	defer lockCluster(applier.ClusterDesired.Name)()
+	defer c.track(applier.ClusterDesired)()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	return applier.DeleteContext(ctx)
+}
+
+// newApplier creates the applier of the Clusterfile at path with the options of the client.
+func (c *Client) newApplier(clusterfile string) (*applydriver.Applier, error) {
+	i, err := apply.NewApplierFromFile(clusterfile)
+	if err != nil {
+		return nil, err
+	}
+	applier, ok := i.(*applydriver.Applier)
+	if !ok {
+		return nil, fmt.Errorf("unsupported applier %T of %s", i, clusterfile)
+	}
+	if c.opts.NonInteractive {
+		applier.RuntimeOptions = runtime.Options{ForceDelete: true, NonInteractive: true}
+	}
+	return applier, nil
+}
+
+// track sends the events of cluster and its hosts to the client, until the returned func is called.
+func (c *Client) track(cluster *v2.Cluster) func() {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.clusters[cluster.Name]++
+	for _, host := range hosts {
+		c.hosts[host]++
+	}
+	return func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.clusters[cluster.Name]--
+		for _, host := range hosts {
+			c.hosts[host]--
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"context"
+
+	"github.com/alibaba/sealer/build"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/image"
+	"github.com/alibaba/sealer/pkg/image/types"
+)
+
+// BuildOptions are the options of Build, like the flags of sealer build.
+type BuildOptions struct {
+	// ImageName is the name of the built image.
+	ImageName string
+	// Context is the build context dir.
+	Context string
+	// Kubefile is the path of Kubefile, Kubefile in the working dir if empty.
+	Kubefile string
+	// BuildType is lite, local or cloud, lite if empty.
+	BuildType string
+	NoCache   bool
+	NoBase    bool
+	BuildArgs map[string]string
+}
+
+// Build builds a cluster image, the lite and local build stop before the next layer once ctx is done.
+func (c *Client) Build(ctx context.Context, opts BuildOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if opts.BuildType == "" {
+		opts.BuildType = common.LiteBuild
+	}
+	if opts.Kubefile == "" {
+		opts.Kubefile = "Kubefile"
+	}
+	c.lock.Lock()
+	c.builds++
+	c.lock.Unlock()
+	defer func() {
+		c.lock.Lock()
+		c.builds--
+		c.lock.Unlock()
+	}()
+	builder, err := build.NewBuilder(&build.Config{
+		BuildType: opts.BuildType,
+		NoCache:   opts.NoCache,
+		NoBase:    opts.NoBase,
+		ImageName: opts.ImageName,
+		BuildArgs: opts.BuildArgs,
+		Context:   ctx,
+	})
+	if err != nil {
+		return err
+	}
+	return builder.Build(opts.ImageName, opts.Context, opts.Kubefile)
+}
+
+// Pull pulls the image from registry.
+func (c *Client) Pull(ctx context.Context, imageName string) error {
+	imageService, err := image.NewImageService()
+	if err != nil {
+		return err
+	}
+	return imageService.PullContext(ctx, imageName)
+}
+
+// Push pushes the local image to registry.
+func (c *Client) Push(ctx context.Context, imageName string) error {
+	imageService, err := image.NewImageService()
+	if err != nil {
+		return err
+	}
+	return imageService.PushContext(ctx, imageName)
+}
+
+// Images lists the local images.
+func (c *Client) Images(ctx context.Context) ([]types.ImageMetadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	metadataService, err := image.NewImageMetadataService()
+	if err != nil {
+		return nil, err
+	}
+	return metadataService.List()
+}
+
+// History lists the layers of the local image from the newest one, with their sizes and the instructions built them.
+func (c *Client) History(ctx context.Context, imageName string) ([]types.ImageHistory, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	metadataService, err := image.NewImageMetadataService()
+	if err != nil {
+		return nil, err
+	}
+	return metadataService.History(imageName)
+}
+
+// RemoveImage removes the local image, force removes it even if it has several names.
+func (c *Client) RemoveImage(ctx context.Context, imageName string, force bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	imageService, err := image.NewDeleteImageService(force)
+	if err != nil {
+		return err
+	}
+	return imageService.Delete(imageName)
+}
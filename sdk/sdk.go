@@ -0,0 +1,147 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sdk is the stable entry of sealer embedded as a Go library, like a controller managing clusters.
+// The operations of Client take a context.Context, which stops them before the next step once it is done.
+package sdk
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/sealer/boot"
+	"github.com/alibaba/sealer/utils/events"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// Options configures a Client. DataRoot and Logger take effect for the whole process, so the clients
+// in a process should be created with the same ones.
+type Options struct {
+	// DataRoot is the local dir of images, temp files, logs and cluster work dirs, common.DefaultDataRoot if empty.
+	// The rootfs of clusters is still under /var/lib/sealer/data as it is on the hosts.
+	DataRoot string
+	// Logger receives the logs instead of the console and log files if set.
+	Logger logger.Writer
+	// NonInteractive never reads stdin in the Apply and Delete of the client: the nodes are deleted without
+	// confirmation, and a paused upgrade is aborted instead of asking to retry.
+	NonInteractive bool
+	// OnEvent receives the progress events of the Apply, Delete and Build of the client, see events.Event.
+	OnEvent events.Handler
+}
+
+// Client runs the operations of sealer. The clients may be used by several goroutines, the Apply and Delete
+// of different clusters run at the same time, and the ones of a cluster run one at a time in the process.
+type Client struct {
+	opts        Options
+	unsubscribe func()
+	closeOnce   sync.Once
+
+	// lock guards the clusters, hosts and builds the client is running, whose events are sent to OnEvent.
+	lock     sync.Mutex
+	clusters map[string]int
+	hosts    map[string]int
+	builds   int
+}
+
+var (
+	processLock sync.Mutex
+	// processOpts are the process-wide options set by the first client.
+	processOpts *Options
+	// openClients is the number of clients not closed, the pooled ssh connections are closed with the last one.
+	openClients int
+)
+
+// New creates a Client with opts, it fails if the process-wide options differ from the clients created before.
+func New(opts Options) (*Client, error) {
+	if opts.DataRoot == "" {
+		opts.DataRoot = common.DefaultDataRoot
+	}
+	if err := setProcessOptions(opts); err != nil {
+		return nil, err
+	}
+	c := &Client{opts: opts, unsubscribe: func() {}, clusters: map[string]int{}, hosts: map[string]int{}}
+	processLock.Lock()
+	openClients++
+	processLock.Unlock()
+	if opts.OnEvent != nil {
+		c.unsubscribe = events.Subscribe(func(e events.Event) {
+			if c.receives(e) {
+				opts.OnEvent(e)
+			}
+		})
+	}
+	return c, nil
+}
+
+// receives tells whether e is sent by an operation of the client. Apply events are told apart by Cluster,
+// copy events by Hosts, and layer events are received by all the clients building at that time.
+func (c *Client) receives(e events.Event) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e.Cluster != "" {
+		return c.clusters[e.Cluster] > 0
+	}
+	switch e.Kind {
+	case events.KindCopy:
+		for _, host := range e.Hosts {
+			if c.hosts[host] > 0 {
+				return true
+			}
+		}
+	case events.KindLayer:
+		return c.builds > 0
+	}
+	return false
+}
+
+func setProcessOptions(opts Options) error {
+	processLock.Lock()
+	defer processLock.Unlock()
+	if processOpts != nil {
+		if processOpts.DataRoot != opts.DataRoot {
+			return fmt.Errorf("data root %s differs from the client created before: %s", opts.DataRoot, processOpts.DataRoot)
+		}
+		if opts.Logger != nil {
+			logger.SetWriter(opts.Logger)
+		}
+		return nil
+	}
+
+	if opts.DataRoot != common.DefaultDataRoot {
+		common.SetDataRoot(opts.DataRoot)
+	}
+	if err := boot.OnBoot(); err != nil {
+		return err
+	}
+	if opts.Logger != nil {
+		logger.SetWriter(opts.Logger)
+	}
+	processOpts = &opts
+	return nil
+}
+
+// Close stops sending events to OnEvent of the client, and closes the pooled ssh connections
+// once all the clients are closed.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		c.unsubscribe()
+		processLock.Lock()
+		defer processLock.Unlock()
+		if openClients--; openClients == 0 {
+			ssh.ClosePool()
+		}
+	})
+}
@@ -0,0 +1,97 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/events"
+)
+
+func TestNew(t *testing.T) {
+	root, err := ioutil.TempDir("", "sealer-sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	var received []events.Event
+	c, err := New(Options{DataRoot: root, NonInteractive: true, OnEvent: func(e events.Event) {
+		received = append(received, e)
+	}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if common.DefaultLayerDir != filepath.Join(root, "data", "overlay2") || !utils.IsFileExist(common.DefaultLayerDir) {
+		t.Errorf("layer dir %s is not created under data root %s", common.DefaultLayerDir, root)
+	}
+	if got := common.GetClusterWorkDir("my-cluster"); got != filepath.Join(root, "clusters", "my-cluster") {
+		t.Errorf("GetClusterWorkDir() = %s, want it under data root %s", got, root)
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"same options", Options{DataRoot: root, NonInteractive: true}, false},
+		{"another data root", Options{DataRoot: filepath.Join(root, "another"), NonInteractive: true}, true},
+		{"interactive", Options{DataRoot: root}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts); (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	cluster := &v2.Cluster{}
+	cluster.Name = "my-cluster"
+	cluster.Spec.Hosts = []v2.Host{{IPS: []string{"192.168.0.2"}, Roles: []string{common.MASTER}}}
+	events.Emit(events.Event{Kind: events.KindStep, Status: events.Started, Cluster: "my-cluster", Name: "Init"})
+	untrack := c.track(cluster)
+	events.Emit(events.Event{Kind: events.KindStep, Status: events.Started, Cluster: "my-cluster", Name: "Init"})
+	events.Emit(events.Event{Kind: events.KindStep, Status: events.Started, Cluster: "another-cluster", Name: "Init"})
+	events.Emit(events.Event{Kind: events.KindCopy, Status: events.Started, Hosts: []string{"192.168.0.2"}})
+	events.Emit(events.Event{Kind: events.KindCopy, Status: events.Started, Hosts: []string{"192.168.0.3"}})
+	events.Emit(events.Event{Kind: events.KindLayer, Status: events.Started, Name: "COPY . ."})
+	untrack()
+	events.Emit(events.Event{Kind: events.KindStep, Status: events.Succeeded, Cluster: "my-cluster", Name: "Init"})
+	if len(received) != 2 || received[0].Cluster != "my-cluster" || received[1].Hosts[0] != "192.168.0.2" {
+		t.Errorf("got events %+v, want only the ones of my-cluster and its host while it is applied", received)
+	}
+	received = nil
+	untrack = c.track(cluster)
+	c.Close()
+	events.Emit(events.Event{Kind: events.KindStep, Status: events.Started, Cluster: "my-cluster", Name: "Join"})
+	untrack()
+	if len(received) != 0 {
+		t.Errorf("got events %+v after Close", received)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Apply(ctx, filepath.Join(root, "Clusterfile")); err != context.Canceled {
+		t.Errorf("Apply() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -21,17 +21,19 @@ import (
 	"github.com/alibaba/sealer/common"
 )
 
-var rootDirs = []string{
-	common.DefaultLogDir,
-	common.DefaultTmpDir,
-	common.DefaultImageRootDir,
-	common.DefaultImageMetaRootDir,
-	common.DefaultImageDBRootDir,
-	common.DefaultLayerDir,
-	common.DefaultLayerDBRoot}
+func rootDirs() []string {
+	return []string{
+		common.DefaultLogDir,
+		common.DefaultTmpDir,
+		common.DefaultImageRootDir,
+		common.DefaultImageMetaRootDir,
+		common.DefaultImageDBRootDir,
+		common.DefaultLayerDir,
+		common.DefaultLayerDBRoot}
+}
 
 func initRootDirectory() error {
-	for _, dir := range rootDirs {
+	for _, dir := range rootDirs() {
 		err := os.MkdirAll(dir, common.FileMode0755)
 		if err != nil {
 			return fmt.Errorf("failed to mkdir %s, err: %s", dir, err)
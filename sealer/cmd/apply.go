@@ -15,25 +15,43 @@
 package cmd
 
 import (
+	"github.com/alibaba/sealer/apply/processor"
 	"github.com/alibaba/sealer/pkg/runtime"
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/apply"
+	"github.com/alibaba/sealer/common"
 )
 
-var clusterFile string
+var (
+	clusterFile string
+	applyDryRun bool
+)
 
 // applyCmd represents the apply command
 var applyCmd = &cobra.Command{
-	Use:     "apply",
-	Short:   "apply a kubernetes cluster",
-	Example: `sealer apply -f Clusterfile`,
-	Args:    cobra.NoArgs,
+	Use:   "apply",
+	Short: "apply a kubernetes cluster",
+	Example: `sealer apply -f Clusterfile
+resume a failed apply from a given step of create pipeline:
+	sealer apply -f Clusterfile --from-step Join
+ignore the saved checkpoint and run all the steps again:
+	sealer apply -f Clusterfile --restart
+print the plan of apply without touching any host:
+	sealer apply -f Clusterfile --dry-run`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		applier, err := apply.NewApplierFromFile(clusterFile)
 		if err != nil {
 			return err
 		}
+		if applyDryRun {
+			plan, err := applier.Plan()
+			if err != nil {
+				return err
+			}
+			return plan.Print(common.StdOut)
+		}
 		return applier.Apply()
 	},
 }
@@ -42,4 +60,7 @@ func init() {
 	rootCmd.AddCommand(applyCmd)
 	applyCmd.Flags().StringVarP(&clusterFile, "Clusterfile", "f", "Clusterfile", "apply a kubernetes cluster")
 	applyCmd.Flags().BoolVar(&runtime.ForceDelete, "force", false, "We also can input an --force flag to delete cluster by force")
+	applyCmd.Flags().StringVar(&processor.FromStep, "from-step", "", "run the create pipeline from the given step, ignore the saved checkpoint")
+	applyCmd.Flags().BoolVar(&processor.Restart, "restart", false, "ignore the saved checkpoint and run the create pipeline from the first step")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "print the plan of hosts, upgrade, plugins, configs and guest cmds, without touching any host")
 }
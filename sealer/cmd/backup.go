@@ -0,0 +1,178 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/backup"
+	"github.com/alibaba/sealer/pkg/clusterfile"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+var (
+	backupClusterName string
+	backupTarget      string
+	backupSchedule    time.Duration
+	backupRetain      int
+	pruneRetain       int
+	pruneAll          bool
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "manage the etcd backups of cluster",
+	Long: `manage the etcd backups of cluster, backups are saved to the target storage:
+  /data/backup or file:///data/backup: the dir on local, default is ~/.sealer/<cluster>/backup
+  ssh://192.168.0.2/data/backup: the dir on remote host, logged in by the ssh config of cluster
+  s3://bucket/prefix?endpoint=127.0.0.1:9000&region=us-east-1: S3-compatible storage, the access key is read from env AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY`,
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "create an etcd backup, or create backups periodically with --schedule",
+	Example: `sealer backup create -c my-cluster
+sealer backup create -c my-cluster --target ssh://192.168.0.2/data/backup --schedule 6h --retain 7`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cluster, storage, err := getBackupStorage()
+		if err != nil {
+			return err
+		}
+		if backupSchedule > 0 {
+			stop := make(chan struct{})
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sig
+				close(stop)
+			}()
+			return backup.Schedule(cluster, storage, backupSchedule, backupRetain, stop)
+		}
+
+		md, err := backup.Create(cluster, storage)
+		if err != nil {
+			return err
+		}
+		if backupRetain > 0 {
+			if _, err = backup.Prune(storage, backupRetain); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(common.StdOut, "%s\n", md.Name)
+		return nil
+	},
+}
+
+var backupListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "list the etcd backups of cluster",
+	Example: `sealer backup list -c my-cluster`,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, storage, err := getBackupStorage()
+		if err != nil {
+			return err
+		}
+		backups, err := backup.List(storage)
+		if err != nil {
+			return err
+		}
+		table := tablewriter.NewWriter(common.StdOut)
+		table.SetHeader([]string{"NAME", "REVISION", "SIZE", "KUBE VERSION", "IMAGE", "CREATE"})
+		for _, md := range backups {
+			table.Append([]string{md.Name, strconv.FormatInt(md.Revision, 10), formatSize(md.Size),
+				md.KubeVersion, md.Image, md.CreationTime.Format(timeDefaultFormat)})
+		}
+		table.Render()
+		return nil
+	},
+}
+
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "delete the etcd backups of cluster except the newest ones",
+	Example: `sealer backup prune -c my-cluster --retain 7
+sealer backup prune -c my-cluster --all`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pruneAll && cmd.Flags().Changed("retain") {
+			return fmt.Errorf("--retain and --all cannot be used together")
+		}
+		if !pruneAll && pruneRetain <= 0 {
+			return fmt.Errorf("--retain must be positive, use --all to delete all the backups")
+		}
+		_, storage, err := getBackupStorage()
+		if err != nil {
+			return err
+		}
+		var pruned []*backup.Metadata
+		if pruneAll {
+			pruned, err = backup.PruneAll(storage)
+		} else {
+			pruned, err = backup.Prune(storage, pruneRetain)
+		}
+		if err != nil {
+			return err
+		}
+		for _, md := range pruned {
+			fmt.Fprintf(common.StdOut, "deleted: %s\n", md.Name)
+		}
+		return nil
+	},
+}
+
+func getBackupStorage() (*v2.Cluster, backup.Storage, error) {
+	var err error
+	if backupClusterName == "" {
+		backupClusterName, err = clusterfile.GetDefaultClusterName()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	cluster, err := clusterfile.GetClusterFromFile(common.GetClusterWorkClusterfile(backupClusterName))
+	if err != nil {
+		return nil, nil, err
+	}
+	storage, err := backup.NewStorage(backupTarget, cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cluster, storage, nil
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+
+	backupCmd.PersistentFlags().StringVarP(&backupClusterName, "cluster", "c", "", "the name of cluster")
+	backupCmd.PersistentFlags().StringVar(&backupTarget, "target", "", "the storage to save backups, default is ~/.sealer/<cluster>/backup")
+	backupCreateCmd.Flags().DurationVar(&backupSchedule, "schedule", 0, "create backups periodically at this interval until interrupted, like 6h")
+	backupCreateCmd.Flags().IntVar(&backupRetain, "retain", 0, "the number of newest backups to keep after creating, 0 means keep all")
+	backupPruneCmd.Flags().IntVar(&pruneRetain, "retain", 7, "the number of newest backups to keep, must be positive")
+	backupPruneCmd.Flags().BoolVar(&pruneAll, "all", false, "delete all the backups")
+}
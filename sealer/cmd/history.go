@@ -0,0 +1,120 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/image"
+	"github.com/alibaba/sealer/pkg/image/types"
+)
+
+const (
+	historyLayerID   = "LAYER ID"
+	historyCreated   = "CREATED"
+	historyCreatedBy = "CREATED BY"
+	historyNone      = "<none>"
+	createdByTrunc   = 45
+)
+
+type historyFlag struct {
+	NoTrunc bool
+	Format  string
+}
+
+var historyOpts historyFlag
+
+// historyView is what the template of sealer history --format is executed on.
+type historyView struct {
+	ID        string
+	Created   string
+	CreatedBy string
+	Size      string
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "show the layers of a local cloud image, and what built them",
+	Example: `sealer history kubernetes:v1.19.8
+sealer history kubernetes:v1.19.8 --format "{{.Size}} {{.CreatedBy}}"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ims, err := image.NewImageMetadataService()
+		if err != nil {
+			return err
+		}
+		history, err := ims.History(args[0])
+		if err != nil {
+			return err
+		}
+
+		var views []historyView
+		for _, h := range history {
+			views = append(views, newHistoryView(h, historyOpts.NoTrunc))
+		}
+		if historyOpts.Format != "" {
+			if history == nil {
+				history = []types.ImageHistory{}
+			}
+			var formatViews []interface{}
+			for _, v := range views {
+				formatViews = append(formatViews, v)
+			}
+			return printFormatted(historyOpts.Format, history, formatViews)
+		}
+
+		table := tablewriter.NewWriter(common.StdOut)
+		table.SetHeader([]string{historyLayerID, historyCreated, historyCreatedBy, imageSize})
+		table.SetAutoWrapText(false)
+		for _, v := range views {
+			table.Append([]string{v.ID, v.Created, v.CreatedBy, v.Size})
+		}
+		table.Render()
+		return nil
+	},
+}
+
+func newHistoryView(h types.ImageHistory, noTrunc bool) historyView {
+	v := historyView{
+		ID:        historyNone,
+		Created:   historyNone,
+		CreatedBy: h.CreatedBy,
+		Size:      formatSize(h.Size),
+	}
+	if h.ID != "" {
+		v.ID = h.ID.Hex()
+		if !noTrunc {
+			v.ID = v.ID[:12]
+		}
+	}
+	if h.Created != nil {
+		v.Created = units.HumanDuration(time.Since(*h.Created)) + " ago"
+	}
+	if createdBy := []rune(v.CreatedBy); !noTrunc && len(createdBy) > createdByTrunc {
+		v.CreatedBy = strings.TrimSpace(string(createdBy[:createdByTrunc-3])) + "..."
+	}
+	return v
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().BoolVar(&historyOpts.NoTrunc, "no-trunc", false, "do not truncate the layer IDs and instructions")
+	historyCmd.Flags().StringVar(&historyOpts.Format, "format", "", "print the layers as json, or by a Go template like \"{{.ID}} {{.Created}} {{.CreatedBy}} {{.Size}}\"")
+}
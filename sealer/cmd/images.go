@@ -15,13 +15,16 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"text/template"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/pkg/image"
+	"github.com/alibaba/sealer/pkg/image/types"
 )
 
 const (
@@ -32,11 +35,24 @@ const (
 	timeDefaultFormat = "2006-01-02 15:04:05"
 )
 
+var imagesFormat string
+
+// imageView is what the template of sealer images --format is executed on.
+type imageView struct {
+	ID             string
+	Name           string
+	Created        string
+	Size           string
+	ManifestDigest string
+}
+
 var listCmd = &cobra.Command{
-	Use:     "images",
-	Short:   "list all cluster images",
-	Args:    cobra.NoArgs,
-	Example: `sealer images`,
+	Use:   "images",
+	Short: "list all cluster images",
+	Args:  cobra.NoArgs,
+	Example: `sealer images
+sealer images --format json
+sealer images --format "{{.Name}} {{.Size}}"`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ims, err := image.NewImageMetadataService()
 		if err != nil {
@@ -47,6 +63,23 @@ var listCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		if imagesFormat != "" {
+			var views []interface{}
+			if imageMetadataList == nil {
+				imageMetadataList = []types.ImageMetadata{}
+			}
+			for _, image := range imageMetadataList {
+				views = append(views, imageView{
+					ID:             image.ID,
+					Name:           image.Name,
+					Created:        image.CREATED.Format(timeDefaultFormat),
+					Size:           formatSize(image.SIZE),
+					ManifestDigest: image.ManifestDigest.String(),
+				})
+			}
+			return printFormatted(imagesFormat, imageMetadataList, views)
+		}
+
 		table := tablewriter.NewWriter(common.StdOut)
 		table.SetHeader([]string{imageID, imageName, imageCreate, imageSize})
 		for _, image := range imageMetadataList {
@@ -61,6 +94,33 @@ var listCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVar(&imagesFormat, "format", "", "print the images as json, or by a Go template like \"{{.ID}} {{.Name}} {{.Created}} {{.Size}} {{.ManifestDigest}}\"")
+}
+
+// printFormatted prints v in indented JSON if format is json, otherwise executes format as a Go template on each of views.
+func printFormatted(format string, v interface{}, views []interface{}) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(common.StdOut, string(data))
+		return err
+	}
+
+	tmpl, err := template.New("format").Parse(format)
+	if err != nil {
+		return fmt.Errorf("failed to parse format %q: %v", format, err)
+	}
+	for _, view := range views {
+		if err = tmpl.Execute(common.StdOut, view); err != nil {
+			return fmt.Errorf("failed to execute format %q: %v", format, err)
+		}
+		if _, err = fmt.Fprintln(common.StdOut); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func formatSize(size int64) (Size string) {
@@ -28,11 +28,12 @@ var imageSrc string
 
 // loadCmd represents the load command
 var loadCmd = &cobra.Command{
-	Use:     "load",
-	Short:   "load image",
-	Long:    `Load an image from a tar archive`,
-	Example: `sealer load -i kubernetes.tar`,
-	Args:    cobra.NoArgs,
+	Use:   "load",
+	Short: "load image",
+	Long:  `Load images from a tar archive saved by sealer save, or an OCI image layout directory or tarball`,
+	Example: `sealer load -i kubernetes.tar
+sealer load -i kubernetes-oci`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ifs, err := image.NewImageFileService()
 		if err != nil {
@@ -47,7 +48,7 @@ var loadCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(loadCmd)
-	loadCmd.Flags().StringVarP(&imageSrc, "input", "i", "", "read image from tar archive file")
+	loadCmd.Flags().StringVarP(&imageSrc, "input", "i", "", "read images from tar archive file or OCI image layout directory")
 	if err := loadCmd.MarkFlagRequired("input"); err != nil {
 		logger.Error("failed to init flag: %v", err)
 		os.Exit(1)
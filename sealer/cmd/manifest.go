@@ -0,0 +1,158 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/image"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+type manifestAnnotateFlag struct {
+	OS        string
+	Arch      string
+	Variant   string
+	OSVersion string
+}
+
+var (
+	manifestAmend        bool
+	manifestAnnotateOpts manifestAnnotateFlag
+)
+
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "manage manifest lists of cloud images built for different platforms",
+}
+
+var manifestCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "create a local manifest list of cloud images",
+	Example: `sealer manifest create registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8 \
+  kubernetes:v1.19.8-amd64 kubernetes:v1.19.8-arm64`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mls, err := image.NewManifestListService()
+		if err != nil {
+			return err
+		}
+		if err = mls.Create(args[0], args[1:], manifestAmend); err != nil {
+			return err
+		}
+		logger.Info("create manifest list %s success", args[0])
+		return nil
+	},
+}
+
+var manifestAnnotateCmd = &cobra.Command{
+	Use:     "annotate",
+	Short:   "set the platform of a cloud image in a local manifest list",
+	Example: `sealer manifest annotate registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8 kubernetes:v1.19.8-arm64 --arch arm64 --variant v8`,
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mls, err := image.NewManifestListService()
+		if err != nil {
+			return err
+		}
+		return mls.Annotate(args[0], args[1], v1.Platform{
+			OS:           manifestAnnotateOpts.OS,
+			Architecture: manifestAnnotateOpts.Arch,
+			Variant:      manifestAnnotateOpts.Variant,
+			OSVersion:    manifestAnnotateOpts.OSVersion,
+		})
+	},
+}
+
+var manifestInspectCmd = &cobra.Command{
+	Use:     "inspect",
+	Short:   "print a local manifest list",
+	Example: `sealer manifest inspect registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mls, err := image.NewManifestListService()
+		if err != nil {
+			return err
+		}
+		list, err := mls.Inspect(args[0])
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(common.StdOut, string(data))
+		return err
+	},
+}
+
+var manifestPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "push a local manifest list and its cloud images to registry",
+	Example: `sealer manifest push registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8
+sealer manifest push kubernetes:v1.19.8 registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mls, err := image.NewManifestListService()
+		if err != nil {
+			return err
+		}
+		destination := ""
+		if len(args) == 2 {
+			destination = args[1]
+		}
+		return mls.Push(context.Background(), args[0], destination)
+	},
+}
+
+var manifestRmCmd = &cobra.Command{
+	Use:     "rm",
+	Short:   "delete local manifest lists, the cloud images in them are kept",
+	Example: `sealer manifest rm registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8`,
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mls, err := image.NewManifestListService()
+		if err != nil {
+			return err
+		}
+		for _, name := range args {
+			if err = mls.Delete(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(manifestCmd)
+	manifestCmd.AddCommand(manifestCreateCmd)
+	manifestCmd.AddCommand(manifestAnnotateCmd)
+	manifestCmd.AddCommand(manifestInspectCmd)
+	manifestCmd.AddCommand(manifestPushCmd)
+	manifestCmd.AddCommand(manifestRmCmd)
+
+	manifestCreateCmd.Flags().BoolVar(&manifestAmend, "amend", false, "add the images to the manifest list if it exists")
+	manifestAnnotateCmd.Flags().StringVar(&manifestAnnotateOpts.OS, "os", "", "set the operating system of the image")
+	manifestAnnotateCmd.Flags().StringVar(&manifestAnnotateOpts.Arch, "arch", "", "set the architecture of the image")
+	manifestAnnotateCmd.Flags().StringVar(&manifestAnnotateOpts.Variant, "variant", "", "set the cpu variant of the image, such as v7 for arm")
+	manifestAnnotateCmd.Flags().StringVar(&manifestAnnotateOpts.OSVersion, "os-version", "", "set the operating system version of the image")
+}
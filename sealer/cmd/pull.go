@@ -15,25 +15,37 @@
 package cmd
 
 import (
+	"context"
+
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/logger"
 	"github.com/alibaba/sealer/pkg/image"
+	"github.com/alibaba/sealer/utils/platform"
 )
 
 // pullCmd represents the pull command
+var pullPlatform string
+
 var pullCmd = &cobra.Command{
-	Use:     "pull",
-	Short:   "pull cloud image to local",
-	Example: `sealer pull registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8`,
-	Args:    cobra.ExactArgs(1),
+	Use:   "pull",
+	Short: "pull cloud image to local",
+	Example: `sealer pull registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8
+sealer pull --platform linux/arm64 registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		imgSvc, err := image.NewImageService()
 		if err != nil {
 			return err
 		}
 
-		if err := imgSvc.Pull(args[0]); err != nil {
+		want := platform.Default()
+		if pullPlatform != "" {
+			if want, err = platform.Parse(pullPlatform); err != nil {
+				return err
+			}
+		}
+		if err := imgSvc.PullWithPlatform(context.Background(), args[0], want); err != nil {
 			return err
 		}
 		logger.Info("Pull %s success", args[0])
@@ -43,4 +55,5 @@ var pullCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(pullCmd)
+	pullCmd.Flags().StringVar(&pullPlatform, "platform", "", "pull the image of platform os/arch[/variant] from a manifest list, defaults to the local platform")
 }
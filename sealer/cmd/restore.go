@@ -0,0 +1,99 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/backup"
+	"github.com/alibaba/sealer/pkg/clusterfile"
+	"github.com/alibaba/sealer/pkg/plugin"
+	"github.com/alibaba/sealer/utils"
+)
+
+var (
+	restoreClusterName string
+	restoreSnapshot    string
+	restoreBackup      string
+	restoreTarget      string
+	restoreForce       bool
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "restore the etcd of cluster from a snapshot",
+	Long: `restore the etcd of cluster from the snapshot saved by the ETCD plugin,
+the apiserver and etcd on all masters will be stopped during restoring, and data written after the snapshot is lost.`,
+	Example: `sealer restore --snapshot /tmp/etcd-snapshot.db -c my-cluster
+sealer restore --backup my-cluster-20211201080000 --target ssh://192.168.0.2/data/backup -c my-cluster`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		if restoreSnapshot == "" && restoreBackup == "" {
+			return fmt.Errorf("snapshot file must be specified by --snapshot or --backup")
+		}
+		if restoreClusterName == "" {
+			restoreClusterName, err = clusterfile.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+		}
+		cluster, err := clusterfile.GetClusterFromFile(common.GetClusterWorkClusterfile(restoreClusterName))
+		if err != nil {
+			return err
+		}
+		if restoreBackup != "" {
+			storage, err := backup.NewStorage(restoreTarget, cluster)
+			if err != nil {
+				return err
+			}
+			tmpDir, err := ioutil.TempDir("", "sealer-restore")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tmpDir)
+			restoreSnapshot = filepath.Join(tmpDir, restoreBackup+backup.SnapshotSuffix)
+			if err = storage.Get(restoreBackup+backup.SnapshotSuffix, restoreSnapshot); err != nil {
+				return fmt.Errorf("failed to get snapshot of backup %s: %v", restoreBackup, err)
+			}
+		}
+		if !restoreForce {
+			pass, err := utils.ConfirmOperation(fmt.Sprintf("Are you sure to restore the etcd of cluster %s from %s? ", cluster.Name, restoreSnapshot))
+			if err != nil {
+				return err
+			}
+			if !pass {
+				return nil
+			}
+		}
+		return plugin.RestoreEtcd(cluster, restoreSnapshot)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().StringVarP(&restoreClusterName, "cluster", "c", "", "the name of cluster to restore")
+	restoreCmd.Flags().StringVar(&restoreSnapshot, "snapshot", "", "the local path of etcd snapshot file")
+	restoreCmd.Flags().StringVar(&restoreBackup, "backup", "", "the name of backup created by sealer backup create")
+	restoreCmd.Flags().StringVar(&restoreTarget, "target", "", "the storage of backup, default is ~/.sealer/<cluster>/backup")
+	restoreCmd.Flags().BoolVarP(&restoreForce, "force", "f", false, "restore without confirmation")
+}
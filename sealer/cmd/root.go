@@ -18,21 +18,30 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/image/distributionutil"
+	"github.com/alibaba/sealer/utils/events"
+	"github.com/alibaba/sealer/utils/ssh"
 )
 
 type rootOpts struct {
 	cfgFile     string
 	debugModeOn bool
+	eventsFile  string
 }
 
 var rootOpt rootOpts
 
+// eventsFDPrefix is the prefix of --events-file to write the events to a file descriptor opened by the caller.
+const eventsFDPrefix = "fd:"
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "sealer",
@@ -43,7 +52,9 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	ssh.ClosePool()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
@@ -53,6 +64,9 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&rootOpt.cfgFile, "config", "", "config file (default is $HOME/.sealer.json)")
 	rootCmd.PersistentFlags().BoolVarP(&rootOpt.debugModeOn, "debug", "d", false, "turn on debug mode")
+	rootCmd.PersistentFlags().StringVar(&rootOpt.eventsFile, "events-file", "", "write the progress events as JSON lines to the file, or fd:N for the file descriptor N opened by the caller")
+	rootCmd.PersistentFlags().IntVar(&distributionutil.DefaultTransferConfig.MaxConcurrency, "max-concurrent-transfers", distributionutil.DefaultTransferConfig.MaxConcurrency, "number of image layers pulled or pushed at the same time")
+	rootCmd.PersistentFlags().IntVar(&distributionutil.DefaultTransferConfig.Retries, "transfer-retries", distributionutil.DefaultTransferConfig.Retries, "times to retry a failed layer pull or push, which resumes from where it stopped")
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	rootCmd.DisableAutoGenTag = true
 }
@@ -75,4 +89,38 @@ func initConfig() {
 	})
 
 	logger.Cfg(rootOpt.debugModeOn)
+
+	if err := subscribeEvents(rootOpt.eventsFile); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+}
+
+// subscribeEvents writes the progress events to file for the programs wrapping sealer. The events are never
+// written to stdout or stderr, which have the logs and the progress bars.
+func subscribeEvents(file string) error {
+	if file == "" {
+		return nil
+	}
+	if strings.HasPrefix(file, eventsFDPrefix) {
+		fd, err := strconv.Atoi(strings.TrimPrefix(file, eventsFDPrefix))
+		if err != nil || fd <= 2 {
+			return fmt.Errorf("invalid events file %s, the file descriptor must be a number greater than 2", file)
+		}
+		f := os.NewFile(uintptr(fd), file)
+		if _, err = f.Stat(); err != nil {
+			return fmt.Errorf("failed to open events file %s: %v", file, err)
+		}
+		events.Subscribe(events.JSONLines(f))
+		return nil
+	}
+	if file == "-" {
+		return fmt.Errorf("events cannot be written to stdout which has the logs, use a file path or %sN", eventsFDPrefix)
+	}
+	f, err := os.OpenFile(filepath.Clean(file), os.O_CREATE|os.O_WRONLY|os.O_APPEND, common.FileMode0644)
+	if err != nil {
+		return fmt.Errorf("failed to open events file %s: %v", file, err)
+	}
+	events.Subscribe(events.JSONLines(f))
+	return nil
 }
@@ -27,7 +27,10 @@ import (
 	"github.com/alibaba/sealer/pkg/image"
 )
 
-var ImageTar string
+var (
+	ImageTar   string
+	saveFormat string
+)
 
 // saveCmd represents the save command
 var saveCmd = &cobra.Command{
@@ -37,7 +40,10 @@ var saveCmd = &cobra.Command{
 	Example: `
 sealer save -o [output file name] [image name]
 save kubernetes:v1.19.8 image to kubernetes.tar file:
-sealer save -o kubernetes.tar kubernetes:v1.19.8`,
+sealer save -o kubernetes.tar kubernetes:v1.19.8
+save kubernetes:v1.19.8 image to an OCI image layout directory or tarball:
+sealer save --format oci -o kubernetes-oci kubernetes:v1.19.8
+sealer save --format oci -o kubernetes-oci.tar kubernetes:v1.19.8`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ifs, err := image.NewImageFileService()
@@ -57,7 +63,15 @@ sealer save -o kubernetes.tar kubernetes:v1.19.8`,
 			return err
 		}
 
-		if err = ifs.Save(image, ImageTar); err != nil {
+		switch saveFormat {
+		case "sealer":
+			err = ifs.Save(image, ImageTar)
+		case "oci":
+			err = ifs.SaveOCI(image, ImageTar)
+		default:
+			return fmt.Errorf("unsupported format %s, must be sealer or oci", saveFormat)
+		}
+		if err != nil {
 			return fmt.Errorf("failed to save image %s: %v", args[0], err)
 		}
 		logger.Info("save image %s to %s successfully", args[0], ImageTar)
@@ -68,6 +82,7 @@ sealer save -o kubernetes.tar kubernetes:v1.19.8`,
 func init() {
 	rootCmd.AddCommand(saveCmd)
 	saveCmd.Flags().StringVarP(&ImageTar, "output", "o", "", "write the image to a file")
+	saveCmd.Flags().StringVar(&saveFormat, "format", "sealer", "format of the output, sealer or oci, an oci output is a tarball if it ends with .tar, otherwise a directory")
 	if err := saveCmd.MarkFlagRequired("output"); err != nil {
 		logger.Error("failed to init flag: %v", err)
 		os.Exit(1)
@@ -0,0 +1,100 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/image"
+	"github.com/alibaba/sealer/pkg/image/signature"
+)
+
+var (
+	signKey       string
+	signPush      bool
+	signKeyPrefix string
+)
+
+var signCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "sign a local cloud image by a local private key",
+	Long: `Sign a local cloud image, built, loaded or pulled, without a registry. The signature is kept locally,
+and pushed to the registry as well with --push, which is verified by the trust policy ` + common.DefaultTrustPolicyFile + `
+on sealer pull and apply.`,
+	Example: `generate a key pair sealer.key and sealer.pub:
+sealer sign generate-key
+
+sign the image and push the signature to the registry:
+sealer sign registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8 --key sealer.key --push`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ss, err := image.NewSignatureService()
+		if err != nil {
+			return err
+		}
+		return ss.Sign(context.Background(), args[0], signKey, signPush)
+	},
+}
+
+var signGenerateKeyCmd = &cobra.Command{
+	Use:     "generate-key",
+	Short:   "generate a key pair to sign cloud images",
+	Example: `sealer sign generate-key --output-prefix /etc/sealer/keys/sealer-io`,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		privatePath, publicPath, err := signature.GenerateKeyPair(signKeyPrefix)
+		if err != nil {
+			return err
+		}
+		logger.Info("private key is written to %s, keep it secret", privatePath)
+		logger.Info("public key is written to %s, add it to the trust policy to verify images", publicPath)
+		return nil
+	},
+}
+
+var verifyCmd = &cobra.Command{
+	Use:     "verify",
+	Short:   "verify a local cloud image by the trust policy " + common.DefaultTrustPolicyFile,
+	Example: `sealer verify registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ss, err := image.NewSignatureService()
+		if err != nil {
+			return err
+		}
+		if err = ss.Verify(args[0]); err != nil {
+			return err
+		}
+		logger.Info("image %s is verified", args[0])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(signCmd)
+	rootCmd.AddCommand(verifyCmd)
+	signCmd.AddCommand(signGenerateKeyCmd)
+	signCmd.Flags().StringVar(&signKey, "key", "", "path of the private key to sign the image")
+	signCmd.Flags().BoolVar(&signPush, "push", false, "push the signature to the registry of the image")
+	signGenerateKeyCmd.Flags().StringVar(&signKeyPrefix, "output-prefix", "sealer", "the keys are written to <prefix>.key and <prefix>.pub")
+	if err := signCmd.MarkFlagRequired("key"); err != nil {
+		logger.Error("failed to init flag: %v", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/clusterfile"
+)
+
+var statusClusterName string
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:     "status",
+	Short:   "print the status of cluster saved by the last apply",
+	Example: `sealer status -c my-cluster`,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		if statusClusterName == "" {
+			statusClusterName, err = clusterfile.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+		}
+		cluster, err := clusterfile.GetClusterFromFile(common.GetClusterWorkClusterfile(statusClusterName))
+		if err != nil {
+			return err
+		}
+
+		status := cluster.Status
+		fmt.Fprintf(common.StdOut, "Cluster:       %s\n", cluster.Name)
+		fmt.Fprintf(common.StdOut, "Image:         %s\n", cluster.Spec.Image)
+		fmt.Fprintf(common.StdOut, "Image ID:      %s\n", status.ImageID)
+		fmt.Fprintf(common.StdOut, "Kube Version:  %s\n", status.KubeVersion)
+		fmt.Fprintf(common.StdOut, "Phase:         %s\n", status.Phase)
+		fmt.Fprintf(common.StdOut, "Plugin Phases: %s\n", strings.Join(status.PluginPhases, ","))
+		if !status.LastUpdateTime.IsZero() {
+			fmt.Fprintf(common.StdOut, "Last Update:   %s\n", status.LastUpdateTime.Format(timeDefaultFormat))
+		}
+		if status.LastError != "" {
+			fmt.Fprintf(common.StdOut, "Last Error:    %s\n", status.LastError)
+		}
+
+		table := tablewriter.NewWriter(common.StdOut)
+		table.SetHeader([]string{"HOST", "ROLE", "PHASE", "LAST TRANSITION"})
+		for _, host := range status.Hosts {
+			table.Append([]string{host.IP, host.Role, string(host.Phase), host.LastTransitionTime.Format(timeDefaultFormat)})
+		}
+		table.Render()
+
+		if len(status.PluginRuns) == 0 {
+			return nil
+		}
+		fmt.Fprintln(common.StdOut)
+		pluginTable := tablewriter.NewWriter(common.StdOut)
+		pluginTable.SetHeader([]string{"PLUGIN", "TYPE", "PHASE", "OUTCOME", "ATTEMPTS", "DURATION", "MESSAGE"})
+		for _, run := range status.PluginRuns {
+			pluginTable.Append([]string{run.Name, run.Type, run.Phase, string(run.Outcome),
+				strconv.Itoa(run.Attempts), run.Duration, run.Message})
+		}
+		pluginTable.Render()
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().StringVarP(&statusClusterName, "cluster", "c", "", "the name of cluster to print status")
+}
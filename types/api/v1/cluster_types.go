@@ -24,6 +24,26 @@ type SSH struct {
 	Pk       string `json:"pk,omitempty"`
 	PkPasswd string `json:"pkPasswd,omitempty"`
 	Port     string `json:"port,omitempty"`
+	// Agent authenticates with the keys of the ssh-agent at $SSH_AUTH_SOCK, like the keys on hardware tokens.
+	Agent bool `json:"agent,omitempty"`
+	// ForwardAgent forwards the ssh-agent at $SSH_AUTH_SOCK to the host.
+	ForwardAgent bool `json:"forwardAgent,omitempty"`
+	// KnownHosts is the known_hosts file to verify the host keys, like /root/.ssh/known_hosts.
+	// The host keys are not verified if it is empty.
+	KnownHosts string `json:"knownHosts,omitempty"`
+	// ProxyJump is the jump hosts to connect to the host through, in order.
+	ProxyJump []JumpHost `json:"proxyJump,omitempty"`
+	// Sudo runs the commands by sudo, set it if the user is not root and the commands need privilege.
+	Sudo bool `json:"sudo,omitempty"`
+	// SudoPasswd is the password of sudo, the user is supposed to have NOPASSWD sudo if it is empty.
+	SudoPasswd string `json:"sudoPasswd,omitempty"`
+}
+
+type JumpHost struct {
+	// Host is the address of the jump host.
+	Host string `json:"host"`
+	// SSH is the ssh config of the jump host, its proxyJump is ignored.
+	SSH `json:",inline"`
 }
 
 type Network struct {
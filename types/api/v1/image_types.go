@@ -26,6 +26,10 @@ type Layer struct {
 	ID    digest.Digest `json:"id,omitempty"` // shaxxx:d6a6c9bfd4ad2901695be1dceca62e1c35a8482982ad6be172fe6958bc4f79d7
 	Type  string        `json:"type,omitempty"`
 	Value string        `json:"value,omitempty"`
+	// Size is the bytes of the layer content, recorded when the image is built.
+	Size int64 `json:"size,omitempty"`
+	// Created is when the layer is built, the instruction building it is Type and Value.
+	Created *metav1.Time `json:"created,omitempty"`
 }
 
 // ImageSpec defines the desired state of Image
@@ -76,6 +80,8 @@ type ImageConfig struct {
 	Cmd       ImageCmd          `json:"cmd,omitempty"`
 	Args      ImageArg          `json:"args,omitempty"`
 	Labels    map[string]string `json:"labels,omitempty"`
+	// Env set by ENV instruction, which is inherited from the base image.
+	Env map[string]string `json:"env,omitempty"`
 }
 
 type ImageCmd struct {
@@ -36,6 +36,15 @@ type PluginSpec struct {
 	Data   string `json:"data,omitempty"`
 	Action string `json:"action,omitempty"`
 	On     string `json:"on,omitempty"`
+	// Order sorts the plugins of the same phase ascending, plugins with the same order run in the order they are loaded.
+	Order int `json:"order,omitempty"`
+	// DependsOn is the names of plugins which must run before this one in the same phase.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Timeout is the max duration of a plugin run, like "5m", no timeout if empty.
+	Timeout string `json:"timeout,omitempty"`
+	// FailurePolicy is what to do when the plugin fails: "Fail" (default) stops the apply,
+	// "Ignore" goes on, and "Retry N" runs it again at most N times before failing.
+	FailurePolicy string `json:"failurePolicy,omitempty"`
 }
 
 // PluginStatus defines the observed state of Plugin
@@ -19,6 +19,7 @@ package v2
 import (
 	"github.com/alibaba/sealer/common"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	v1 "github.com/alibaba/sealer/types/api/v1"
 )
@@ -39,6 +40,50 @@ type ClusterSpec struct {
 	CMD     []string `json:"cmd,omitempty"`
 	Hosts   []Host   `json:"hosts,omitempty"`
 	SSH     v1.SSH   `json:"ssh,omitempty"`
+	// UpgradeStrategy controls how the hosts are upgraded, the default is one by one and abort on failure.
+	UpgradeStrategy *UpgradeStrategy `json:"upgradeStrategy,omitempty"`
+	// Distribution controls how the rootfs is copied to the hosts, the default copies to all hosts at the same time.
+	Distribution *Distribution `json:"distribution,omitempty"`
+}
+
+type Distribution struct {
+	// Concurrency is the max number of hosts copied from this host at the same time, default 0 means no limit.
+	Concurrency int `json:"concurrency,omitempty"`
+	// BandwidthLimit is the max bandwidth to copy to each host in bytes per second, like "20Mi", default no limit.
+	BandwidthLimit string `json:"bandwidthLimit,omitempty"`
+	// Relay lets the hosts which have received the rootfs copy it to the later hosts over ssh between them,
+	// the private key of the later host is lent by ssh agent forwarding. Hosts with password only are copied
+	// from this host.
+	Relay bool `json:"relay,omitempty"`
+	// Incremental copies only the files missing or changed on the hosts which have the rootfs, by comparing the
+	// digests of files with the manifest recorded on the host at last sync.
+	Incremental bool `json:"incremental,omitempty"`
+}
+
+type UpgradeFailurePolicy string
+
+const (
+	// UpgradeAbort stops the upgrade, the failed hosts are left as they are.
+	UpgradeAbort UpgradeFailurePolicy = "Abort"
+	// UpgradePause waits for user to fix the failed hosts, and retries them.
+	UpgradePause UpgradeFailurePolicy = "Pause"
+	// UpgradeRollback restores the binaries and manifests of upgraded hosts from the backup.
+	UpgradeRollback UpgradeFailurePolicy = "Rollback"
+)
+
+type UpgradeStrategy struct {
+	// MaxUnavailable is the max number or percentage of worker nodes upgraded at the same time, like 5 or "10%",
+	// default 1. Masters are always upgraded one by one.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// DrainTimeout is the timeout to drain a host, like "5m", default 5m.
+	DrainTimeout string `json:"drainTimeout,omitempty"`
+	// ReadyTimeout is the timeout to wait for an upgraded host to be Ready, default 5m.
+	ReadyTimeout  string               `json:"readyTimeout,omitempty"`
+	FailurePolicy UpgradeFailurePolicy `json:"failurePolicy,omitempty"`
+	// ImageRepository is where to find the intermediate cluster images when upgrading over more than
+	// one minor version, like registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes.
+	// Default is the repository of the cluster image, the image tags are the kubernetes versions.
+	ImageRepository string `json:"imageRepository,omitempty"`
 }
 
 type Host struct {
@@ -50,10 +95,73 @@ type Host struct {
 	Env []string `json:"env,omitempty"`
 }
 
+type ClusterPhase string
+
+const (
+	ClusterCreating  ClusterPhase = "Creating"
+	ClusterScaling   ClusterPhase = "Scaling"
+	ClusterUpgrading ClusterPhase = "Upgrading"
+	ClusterRunning   ClusterPhase = "Running"
+	ClusterFailed    ClusterPhase = "Failed"
+)
+
+type HostPhase string
+
+const (
+	HostPending       HostPhase = "Pending"
+	HostRootfsMounted HostPhase = "RootfsMounted"
+	HostInitialized   HostPhase = "Initialized"
+	HostJoined        HostPhase = "Joined"
+	HostUpgraded      HostPhase = "Upgraded"
+)
+
+type HostStatus struct {
+	IP    string    `json:"ip"`
+	Role  string    `json:"role,omitempty"`
+	Phase HostPhase `json:"phase,omitempty"`
+	// LastTransitionTime is the last time the host phase changed.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+type PluginOutcome string
+
+const (
+	PluginSucceeded PluginOutcome = "Succeeded"
+	PluginFailed    PluginOutcome = "Failed"
+	// PluginIgnored means the plugin failed, but its failure policy is Ignore.
+	PluginIgnored PluginOutcome = "Ignored"
+	// PluginSkipped means the plugin did not run, because a plugin it depends on did not succeed.
+	PluginSkipped PluginOutcome = "Skipped"
+)
+
+// PluginRunStatus is the record of a plugin run.
+type PluginRunStatus struct {
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+	Phase string `json:"phase"`
+	// Hosts is the hosts the phase works on, empty means the whole cluster.
+	Hosts    []string      `json:"hosts,omitempty"`
+	Outcome  PluginOutcome `json:"outcome"`
+	Attempts int           `json:"attempts,omitempty"`
+	// Duration is the total duration of all attempts, like "1.5s".
+	Duration  string      `json:"duration,omitempty"`
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	Message   string      `json:"message,omitempty"`
+}
+
 // ClusterStatus defines the observed state of Cluster
 type ClusterStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	Phase       ClusterPhase `json:"phase,omitempty"`
+	KubeVersion string       `json:"kubeVersion,omitempty"`
+	ImageID     string       `json:"imageID,omitempty"`
+	Hosts       []HostStatus `json:"hosts,omitempty"`
+	// PluginPhases is the plugin phases executed by the last apply, in order.
+	PluginPhases []string `json:"pluginPhases,omitempty"`
+	// PluginRuns records each plugin run of the last apply, in order.
+	PluginRuns []PluginRunStatus `json:"pluginRuns,omitempty"`
+	// LastError is the error which stopped the last apply, empty if it succeeded.
+	LastError      string      `json:"lastError,omitempty"`
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -99,6 +207,62 @@ func (in *Cluster) GetIPSByRole(role string) []string {
 	}
 	return hosts
 }
+
+// SetHostPhase sets the phase of the given hosts, hosts not tracked yet are added to status.
+func (in *Cluster) SetHostPhase(phase HostPhase, hosts ...string) {
+	now := metav1.Now()
+	for _, ip := range hosts {
+		status := in.GetHostStatus(ip)
+		if status == nil {
+			in.Status.Hosts = append(in.Status.Hosts, HostStatus{IP: ip})
+			status = &in.Status.Hosts[len(in.Status.Hosts)-1]
+		}
+		status.Role = in.getRoleByIP(ip)
+		if status.Phase != phase {
+			status.Phase = phase
+			status.LastTransitionTime = now
+		}
+	}
+}
+
+// RemoveHostStatus drops the given hosts from status, used when hosts are deleted from cluster.
+func (in *Cluster) RemoveHostStatus(hosts ...string) {
+	var remain []HostStatus
+	for _, status := range in.Status.Hosts {
+		deleted := false
+		for _, ip := range hosts {
+			if status.IP == ip {
+				deleted = true
+				break
+			}
+		}
+		if !deleted {
+			remain = append(remain, status)
+		}
+	}
+	in.Status.Hosts = remain
+}
+
+func (in *Cluster) GetHostStatus(ip string) *HostStatus {
+	for i := range in.Status.Hosts {
+		if in.Status.Hosts[i].IP == ip {
+			return &in.Status.Hosts[i]
+		}
+	}
+	return nil
+}
+
+func (in *Cluster) getRoleByIP(ip string) string {
+	for _, host := range in.Spec.Hosts {
+		for _, hostIP := range host.IPS {
+			if hostIP == ip && len(host.Roles) > 0 {
+				return host.Roles[0]
+			}
+		}
+	}
+	return ""
+}
+
 func (in *Cluster) GetAnnotationsByKey(key string) string {
 	return in.Annotations[key]
 }
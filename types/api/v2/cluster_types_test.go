@@ -0,0 +1,75 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+)
+
+func TestCluster_RemoveHostStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		remove  []string
+		wantIPs []string
+	}{
+		{"scale down a node", []string{"192.168.0.4"}, []string{"192.168.0.2", "192.168.0.3"}},
+		{"scale down a master and a node", []string{"192.168.0.2", "192.168.0.3"}, []string{"192.168.0.4"}},
+		{"host without status", []string{"192.168.0.5"}, []string{"192.168.0.2", "192.168.0.3", "192.168.0.4"}},
+		{"all hosts", []string{"192.168.0.2", "192.168.0.3", "192.168.0.4"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &Cluster{}
+			cluster.Spec.Hosts = []Host{
+				{IPS: []string{"192.168.0.2"}, Roles: []string{common.MASTER}},
+				{IPS: []string{"192.168.0.3", "192.168.0.4"}, Roles: []string{common.NODE}},
+			}
+			cluster.SetHostPhase(HostJoined, "192.168.0.2", "192.168.0.3", "192.168.0.4")
+			cluster.RemoveHostStatus(tt.remove...)
+			var ips []string
+			for _, status := range cluster.Status.Hosts {
+				if status.Phase != HostJoined {
+					t.Errorf("host %s phase = %s, want %s kept", status.IP, status.Phase, HostJoined)
+				}
+				ips = append(ips, status.IP)
+			}
+			if !reflect.DeepEqual(ips, tt.wantIPs) {
+				t.Errorf("RemoveHostStatus(%v) left hosts %v, want %v", tt.remove, ips, tt.wantIPs)
+			}
+			for _, ip := range tt.remove {
+				if cluster.GetHostStatus(ip) != nil {
+					t.Errorf("GetHostStatus(%s) should be nil after it is removed", ip)
+				}
+			}
+		})
+	}
+}
+
+func TestCluster_SetHostPhase(t *testing.T) {
+	cluster := &Cluster{}
+	cluster.Spec.Hosts = []Host{{IPS: []string{"192.168.0.2"}, Roles: []string{common.MASTER}}}
+	cluster.SetHostPhase(HostPending, "192.168.0.2")
+	status := cluster.GetHostStatus("192.168.0.2")
+	if status == nil || status.Phase != HostPending || status.Role != common.MASTER || status.LastTransitionTime.IsZero() {
+		t.Fatalf("SetHostPhase() status = %+v, want master Pending with transition time", status)
+	}
+	cluster.SetHostPhase(HostJoined, "192.168.0.2")
+	if len(cluster.Status.Hosts) != 1 || cluster.Status.Hosts[0].Phase != HostJoined {
+		t.Errorf("SetHostPhase() hosts = %+v, want the host Joined", cluster.Status.Hosts)
+	}
+}
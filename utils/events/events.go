@@ -0,0 +1,135 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Kind is what the event is about.
+type Kind string
+
+const (
+	KindCluster Kind = "cluster"
+	KindStep    Kind = "step"
+	KindCopy    Kind = "copy"
+	KindPlugin  Kind = "plugin"
+	KindLayer   Kind = "layer"
+)
+
+type Status string
+
+const (
+	Started   Status = "started"
+	Progress  Status = "progress"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	Skipped   Status = "skipped"
+)
+
+// Event is a machine-readable progress event of apply and build.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Kind   Kind      `json:"kind"`
+	Status Status    `json:"status"`
+	// Cluster is the name of cluster applied, empty for build and copy.
+	Cluster string `json:"cluster,omitempty"`
+	// Name is the name of step or plugin, or the instruction of layer.
+	Name string `json:"name,omitempty"`
+	// Phase is the phase of cluster or plugin.
+	Phase string   `json:"phase,omitempty"`
+	Hosts []string `json:"hosts,omitempty"`
+	// Current and Total are the copied and total files of copy, or the index and count of layer.
+	Current  int    `json:"current,omitempty"`
+	Total    int    `json:"total,omitempty"`
+	Duration string `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Handler receives the events, it is called synchronously by the emitter so it should not block.
+type Handler func(e Event)
+
+var (
+	mu       sync.RWMutex
+	handlers = map[int]Handler{}
+	nextID   int
+)
+
+// Subscribe adds handler to receive the events, the returned func removes it.
+func Subscribe(handler Handler) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	handlers[id] = handler
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(handlers, id)
+	}
+}
+
+// Emit sends e to the subscribed handlers, its time is now if not set.
+func Emit(e Event) {
+	mu.RLock()
+	subscribed := make([]Handler, 0, len(handlers))
+	for _, handler := range handlers {
+		subscribed = append(subscribed, handler)
+	}
+	mu.RUnlock()
+	if len(subscribed) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	for _, handler := range subscribed {
+		handler(e)
+	}
+}
+
+// Start emits the started event of e, and returns the func to emit its succeeded or failed event by err.
+func Start(e Event) func(err error) {
+	start := time.Now()
+	e.Status = Started
+	Emit(e)
+	return func(err error) {
+		e.Time = time.Time{}
+		e.Status = Succeeded
+		e.Duration = time.Since(start).Round(time.Millisecond).String()
+		if err != nil {
+			e.Status = Failed
+			e.Error = err.Error()
+		}
+		Emit(e)
+	}
+}
+
+// JSONLines returns the handler writing the events to w, one JSON object per line.
+func JSONLines(w io.Writer) Handler {
+	var lock sync.Mutex
+	return func(e Event) {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		_, _ = w.Write(append(data, '\n'))
+	}
+}
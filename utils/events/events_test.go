@@ -0,0 +1,76 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus Status
+		wantError  string
+	}{
+		{"succeeded", nil, Succeeded, ""},
+		{"failed", errors.New("exit status 1"), Failed, "exit status 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Event
+			unsubscribe := Subscribe(func(e Event) {
+				got = append(got, e)
+			})
+			defer unsubscribe()
+
+			Start(Event{Kind: KindStep, Name: "Init"})(tt.err)
+			if len(got) != 2 {
+				t.Fatalf("got %d events, want 2", len(got))
+			}
+			if got[0].Status != Started || got[0].Time.IsZero() {
+				t.Errorf("got first event %+v, want started", got[0])
+			}
+			if got[1].Status != tt.wantStatus || got[1].Error != tt.wantError || got[1].Duration == "" || got[1].Name != "Init" {
+				t.Errorf("got last event %+v, want %s with error %q", got[1], tt.wantStatus, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	unsubscribe := Subscribe(JSONLines(&buf))
+	Emit(Event{Kind: KindCopy, Status: Progress, Hosts: []string{"192.168.0.2"}, Current: 1, Total: 2})
+	Emit(Event{Kind: KindLayer, Status: Started, Name: "RUN echo", Current: 1, Total: 3})
+	unsubscribe()
+	Emit(Event{Kind: KindStep, Status: Started, Name: "not written"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %s", len(lines), buf.String())
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Kind != KindCopy || e.Hosts[0] != "192.168.0.2" || e.Current != 1 || e.Total != 2 {
+		t.Errorf("got %+v, want the copy progress", e)
+	}
+}
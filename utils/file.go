@@ -475,6 +475,20 @@ func GetFilesSize(paths []string) (int64, error) {
 	return size, nil
 }
 
+// FormatBytes formats size in the binary units like 1.5GiB.
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 func DecodeCluster(filepath string) (clusters []v1.Cluster, err error) {
 	decodeClusters, err := DecodeV1CRD(filepath, common.Cluster)
 	if err != nil {
@@ -0,0 +1,132 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package platform
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// archAliases maps `uname -m` outputs and common spellings to GOARCH names.
+var archAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"aarch64": "arm64",
+	"armv7l":  "arm",
+	"armhf":   "arm",
+	"i386":    "386",
+	"i686":    "386",
+}
+
+// Default returns the platform sealer itself runs on.
+func Default() v1.Platform {
+	return Normalize(v1.Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH})
+}
+
+// Normalize fills in the defaults of a CloudImage platform: images built
+// before platforms were recorded are linux/amd64.
+func Normalize(p v1.Platform) v1.Platform {
+	p.OS = strings.ToLower(p.OS)
+	if p.OS == "" {
+		p.OS = "linux"
+	}
+	p.Architecture = strings.ToLower(p.Architecture)
+	if arch, ok := archAliases[p.Architecture]; ok {
+		p.Architecture = arch
+	}
+	if p.Architecture == "" {
+		p.Architecture = "amd64"
+	}
+	if p.Architecture == "arm64" && p.Variant == "v8" {
+		p.Variant = ""
+	}
+	return p
+}
+
+// Matches reports whether an image built for got can run on want. An empty
+// variant in want accepts any variant.
+func Matches(want, got v1.Platform) bool {
+	want, got = Normalize(want), Normalize(got)
+	if want.OS != got.OS || want.Architecture != got.Architecture {
+		return false
+	}
+	return want.Variant == "" || want.Variant == got.Variant
+}
+
+// Parse parses a platform in the form os/arch[/variant], e.g. linux/arm64.
+func Parse(s string) (v1.Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return v1.Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+	}
+	p := v1.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return Normalize(p), nil
+}
+
+// ToString formats p as os/arch[/variant].
+func ToString(p v1.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// GetClusterPlatform detects the platform of the cluster hosts over ssh. All
+// hosts must share one platform because they mount the same rootfs.
+func GetClusterPlatform(cluster *v2.Cluster) (v1.Platform, error) {
+	var (
+		hosts     = append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+		platforms = make([]v1.Platform, len(hosts))
+		eg        errgroup.Group
+	)
+	if len(hosts) == 0 {
+		return v1.Platform{}, fmt.Errorf("cluster %s has no hosts", cluster.Name)
+	}
+	for i := range hosts {
+		i := i
+		eg.Go(func() error {
+			client, err := ssh.GetHostSSHClient(hosts[i], cluster)
+			if err != nil {
+				return err
+			}
+			arch, err := client.CmdToString(hosts[i], "uname -m", "")
+			if err != nil {
+				return fmt.Errorf("failed to get architecture of host %s: %v", hosts[i], err)
+			}
+			platforms[i] = Normalize(v1.Platform{OS: "linux", Architecture: strings.TrimSpace(arch)})
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return v1.Platform{}, err
+	}
+	for i, p := range platforms {
+		if p != platforms[0] {
+			return v1.Platform{}, fmt.Errorf("hosts %s and %s have different platforms %s and %s",
+				hosts[0], hosts[i], ToString(platforms[0]), ToString(p))
+		}
+	}
+	return platforms[0], nil
+}
@@ -0,0 +1,93 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package platform
+
+import (
+	"testing"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		want v1.Platform
+		got  v1.Platform
+		res  bool
+	}{
+		{
+			"empty image platform is linux/amd64",
+			v1.Platform{OS: "linux", Architecture: "amd64"},
+			v1.Platform{},
+			true,
+		},
+		{
+			"uname output is normalized",
+			v1.Platform{OS: "linux", Architecture: "aarch64"},
+			v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+			true,
+		},
+		{
+			"different architecture",
+			v1.Platform{OS: "linux", Architecture: "arm64"},
+			v1.Platform{OS: "linux", Architecture: "amd64"},
+			false,
+		},
+		{
+			"empty variant accepts any variant",
+			v1.Platform{OS: "linux", Architecture: "arm"},
+			v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			true,
+		},
+		{
+			"different variant",
+			v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := Matches(tt.want, tt.got); res != tt.res {
+				t.Errorf("Matches(%v, %v) = %v, want %v", tt.want, tt.got, res, tt.res)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    v1.Platform
+		wantErr bool
+	}{
+		{"linux/amd64", v1.Platform{OS: "linux", Architecture: "amd64"}, false},
+		{"linux/x86_64", v1.Platform{OS: "linux", Architecture: "amd64"}, false},
+		{"linux/arm/v7", v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, false},
+		{"amd64", v1.Platform{}, true},
+		{"linux/", v1.Platform{}, true},
+		{"linux/arm/v7/x", v1.Platform{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%s) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%s) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
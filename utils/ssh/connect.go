@@ -26,6 +26,8 @@ import (
 	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 
 	"github.com/alibaba/sealer/logger"
 )
@@ -36,8 +38,111 @@ import (
 
 const DefaultSSHPort = "22"
 
-func (s *SSH) connect(host string) (*ssh.Client, error) {
+// dial opens a new connection to host through the jump hosts, use the pooled one by newSession if it is not
+// to be owned by the caller. The connections to the jump hosts are closed with the returned one.
+func (s *SSH) dial(host string) (*ssh.Client, error) {
+	var (
+		client *ssh.Client
+		jumps  []*ssh.Client
+	)
+	closeJumps := func() {
+		for i := len(jumps) - 1; i >= 0; i-- {
+			_ = jumps[i].Close()
+		}
+	}
+	for _, jump := range s.ProxyJump {
+		jumpSSH := NewSSHClient(jump.SSH.DeepCopy(), false).(*SSH)
+		jumpSSH.ProxyJump = nil
+		jumpSSH.Timeout = s.Timeout
+		if jumpSSH.KnownHosts == "" {
+			jumpSSH.KnownHosts = s.KnownHosts
+		}
+		c, err := jumpSSH.dialVia(client, jump.Host)
+		if err != nil {
+			closeJumps()
+			return nil, fmt.Errorf("failed to connect to jump host %s: %v", jump.Host, err)
+		}
+		client = c
+		jumps = append(jumps, c)
+	}
+
+	c, err := s.dialVia(client, host)
+	if err != nil {
+		closeJumps()
+		return nil, err
+	}
+	if len(jumps) > 0 {
+		go func() {
+			_ = c.Wait()
+			closeJumps()
+		}()
+	}
+	return c, nil
+}
+
+// dialVia connects to host directly if via is nil, or through the connection of via.
+func (s *SSH) dialVia(via *ssh.Client, host string) (*ssh.Client, error) {
+	agentClient, agentConn, err := s.agent()
+	if err != nil {
+		return nil, err
+	}
+	closeAgent := func() {
+		if agentConn != nil {
+			_ = agentConn.Close()
+		}
+	}
+	config, err := s.clientConfig(agentClient)
+	if err != nil {
+		closeAgent()
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(host, s.Port)
+	var client *ssh.Client
+	if via == nil {
+		client, err = ssh.Dial("tcp", addr, config)
+	} else {
+		client, err = dialThrough(via, addr, config)
+	}
+	if err != nil {
+		closeAgent()
+		return nil, err
+	}
+
+	if !s.ForwardAgent || agentClient == nil {
+		closeAgent()
+		return client, nil
+	}
+	if err = agent.ForwardToAgent(client, agentClient); err != nil {
+		closeAgent()
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to forward ssh-agent to %s: %v", host, err)
+	}
+	go func() {
+		_ = client.Wait()
+		closeAgent()
+	}()
+	return client, nil
+}
+
+func dialThrough(via *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+func (s *SSH) clientConfig(agentClient agent.Agent) (*ssh.ClientConfig, error) {
 	auth := s.sshAuthMethod(s.Password, s.PkFile, s.PkPassword)
+	if s.Agent && agentClient != nil {
+		auth = append([]ssh.AuthMethod{ssh.PublicKeysCallback(agentClient.Signers)}, auth...)
+	}
 	config := ssh.Config{
 		Ciphers: []string{"aes128-ctr", "aes192-ctr", "aes256-ctr", "aes128-gcm@openssh.com", "arcfour256", "arcfour128", "aes128-cbc", "3des-cbc", "aes192-cbc", "aes256-cbc"},
 	}
@@ -45,23 +150,47 @@ func (s *SSH) connect(host string) (*ssh.Client, error) {
 	if s.Timeout == nil {
 		s.Timeout = &DefaultTimeout
 	}
-	clientConfig := &ssh.ClientConfig{
-		User:    s.User,
-		Auth:    auth,
-		Timeout: *s.Timeout,
-		Config:  config,
-		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		},
+	hostKeyCallback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return nil
+	}
+	if s.KnownHosts != "" {
+		callback, err := knownhosts.New(s.KnownHosts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load known hosts %s: %v", s.KnownHosts, err)
+		}
+		hostKeyCallback = callback
 	}
 	if s.Port == "" {
 		s.Port = DefaultSSHPort
 	}
-	return ssh.Dial("tcp", fmt.Sprintf("%s:%s", host, s.Port), clientConfig)
+	return &ssh.ClientConfig{
+		User:            s.User,
+		Auth:            auth,
+		Timeout:         *s.Timeout,
+		Config:          config,
+		HostKeyCallback: hostKeyCallback,
+	}, nil
 }
 
+// agent connects to the ssh-agent at $SSH_AUTH_SOCK if it is used to authenticate or forwarded.
+func (s *SSH) agent() (agent.ExtendedAgent, net.Conn, error) {
+	if !s.Agent && !s.ForwardAgent {
+		return nil, nil, nil
+	}
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, nil, fmt.Errorf("ssh-agent is not available, SSH_AUTH_SOCK is empty")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to ssh-agent: %v", err)
+	}
+	return agent.NewClient(conn), conn, nil
+}
+
+// Connect opens a new connection to host and a session with pty on it, the caller owns and closes both.
 func (s *SSH) Connect(host string) (*ssh.Client, *ssh.Session, error) {
-	client, err := s.connect(host)
+	client, err := s.dial(host)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -72,13 +201,7 @@ func (s *SSH) Connect(host string) (*ssh.Client, *ssh.Session, error) {
 		return nil, nil, err
 	}
 
-	modes := ssh.TerminalModes{
-		ssh.ECHO:          0,     //disable echoing
-		ssh.TTY_OP_ISPEED: 14400, // input speed = 14.4kbaud
-		ssh.TTY_OP_OSPEED: 14400, // output speed = 14.4kbaud
-	}
-
-	if err := session.RequestPty("xterm", 80, 40, modes); err != nil {
+	if err := s.prepareSession(session); err != nil {
 		_ = session.Close()
 		_ = client.Close()
 		return nil, nil, err
@@ -87,6 +210,43 @@ func (s *SSH) Connect(host string) (*ssh.Client, *ssh.Session, error) {
 	return client, session, nil
 }
 
+// connectSession opens a session with pty on the pooled connection of host, close it by the returned func.
+func (s *SSH) connectSession(host string) (*ssh.Session, func(), error) {
+	session, release, err := s.newSession(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeSession := func() {
+		_ = session.Close()
+		release()
+	}
+	if err := s.prepareSession(session); err != nil {
+		closeSession()
+		return nil, nil, err
+	}
+	return session, closeSession, nil
+}
+
+// prepareSession requests the agent forwarding if enabled, and the pty which makes the remote command hung up
+// when the session is closed. There is no pty if the sudo password is written to stdin, the command is killed
+// by signal when cancelled.
+func (s *SSH) prepareSession(session *ssh.Session) error {
+	if s.ForwardAgent {
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			return fmt.Errorf("failed to request agent forwarding: %v", err)
+		}
+	}
+	if s.useSudo() && s.SudoPassword != "" {
+		return nil
+	}
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          0,     //disable echoing
+		ssh.TTY_OP_ISPEED: 14400, // input speed = 14.4kbaud
+		ssh.TTY_OP_OSPEED: 14400, // output speed = 14.4kbaud
+	}
+	return session.RequestPty("xterm", 80, 40, modes)
+}
+
 func (s *SSH) sshAuthMethod(password, pkFile, pkPasswd string) (auth []ssh.AuthMethod) {
 	if fileExist(pkFile) {
 		am, err := s.sshPrivateKeyMethod(pkFile, pkPasswd)
@@ -0,0 +1,212 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	// sshd allows 10 sessions on a connection by default(MaxSessions), leave some for others.
+	maxSessionsPerConn = 8
+	keepAliveInterval  = 30 * time.Second
+)
+
+// defaultPool is shared by all the SSH clients, so the callers get the pooled connections without changes.
+var defaultPool = newConnPool()
+
+// connPool keeps the connections of each host and credential, the sessions are multiplexed on them.
+// A new connection is dialed only when all the connections of the host have maxSessionsPerConn sessions.
+type connPool struct {
+	mu    sync.Mutex
+	hosts map[string]*hostConns
+}
+
+type hostConns struct {
+	// mu is held when dialing, so concurrent callers of the same host share the new connection.
+	mu    sync.Mutex
+	conns []*pooledConn
+}
+
+type pooledConn struct {
+	client   *ssh.Client
+	sessions int
+	// limit is the sessions the connection takes, lowered once sshd refuses a session on it.
+	limit int
+}
+
+func newConnPool() *connPool {
+	return &connPool{hosts: map[string]*hostConns{}}
+}
+
+func poolKey(s *SSH, host string) string {
+	// the same host reached with different credentials, agent or jump hosts are different connections.
+	options := fmt.Sprintf("%s%s%t%t%s%+v", s.Password, s.PkPassword, s.Agent, s.ForwardAgent, s.KnownHosts, s.ProxyJump)
+	return fmt.Sprintf("%s@%s:%s/%s/%s", s.User, host, s.Port, s.PkFile, utils.MD5([]byte(options)))
+}
+
+func (p *connPool) hostConns(s *SSH, host string) *hostConns {
+	key := poolKey(s, host)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.hosts[key]
+	if !ok {
+		h = &hostConns{}
+		p.hosts[key] = h
+	}
+	return h
+}
+
+// acquire returns a connection of host with a free session slot, release must be called when the
+// session on it is closed.
+func (p *connPool) acquire(s *SSH, host string) (*ssh.Client, func(), error) {
+	h := p.hostConns(s, host)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var conn *pooledConn
+	for _, c := range h.conns {
+		if c.sessions < c.limit {
+			conn = c
+			break
+		}
+	}
+	if conn == nil {
+		client, err := s.dial(host)
+		if err != nil {
+			return nil, nil, err
+		}
+		conn = &pooledConn{client: client, limit: maxSessionsPerConn}
+		h.conns = append(h.conns, conn)
+		go h.keepAlive(host, client)
+	}
+	conn.sessions++
+
+	var once sync.Once
+	return conn.client, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			conn.sessions--
+			h.mu.Unlock()
+		})
+	}, nil
+}
+
+// keepAlive probes the connection until it is broken, and drops it so that a new one is dialed.
+func (h *hostConns) keepAlive(host string, client *ssh.Client) {
+	t := time.NewTicker(keepAliveInterval)
+	defer t.Stop()
+	closed := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(closed)
+	}()
+	for {
+		select {
+		case <-closed:
+			h.drop(client)
+			return
+		case <-t.C:
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				logger.Debug("ssh connection to %s is broken: %v", host, err)
+				h.drop(client)
+				return
+			}
+		}
+	}
+}
+
+// sessionFailed handles the connection client that a session failed to be opened on. The connection
+// may carry the sessions of other callers, so it is only dropped if it is broken. A session refused
+// by sshd, like by a MaxSessions lower than maxSessionsPerConn, limits the connection to the sessions
+// on it, and a new connection is dialed for the next one.
+func (h *hostConns) sessionFailed(client *ssh.Client, err error) {
+	if _, refused := err.(*ssh.OpenChannelError); !refused {
+		if _, _, probeErr := client.SendRequest("keepalive@openssh.com", true, nil); probeErr != nil {
+			h.drop(client)
+		}
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, c := range h.conns {
+		if c.client != client {
+			continue
+		}
+		if c.sessions > 0 {
+			c.limit = c.sessions
+			return
+		}
+		// no session is allowed on it at all.
+		h.conns = append(h.conns[:i], h.conns[i+1:]...)
+		_ = client.Close()
+		return
+	}
+}
+
+// drop removes client from the pool and closes it.
+func (h *hostConns) drop(client *ssh.Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, c := range h.conns {
+		if c.client == client {
+			h.conns = append(h.conns[:i], h.conns[i+1:]...)
+			break
+		}
+	}
+	_ = client.Close()
+}
+
+// newSession opens a session on a pooled connection of host, and retries once on a new connection if
+// the pooled one is broken or refuses more sessions. The returned release must be called after the session is closed.
+func (s *SSH) newSession(host string) (*ssh.Session, func(), error) {
+	var lastErr error
+	for i := 0; i < 2; i++ {
+		client, release, err := defaultPool.acquire(s, host)
+		if err != nil {
+			return nil, nil, err
+		}
+		session, err := client.NewSession()
+		if err == nil {
+			return session, release, nil
+		}
+		release()
+		lastErr = err
+		defaultPool.hostConns(s, host).sessionFailed(client, err)
+	}
+	return nil, nil, fmt.Errorf("failed to create ssh session for %s: %v", host, lastErr)
+}
+
+// ClosePool closes all the pooled connections.
+func ClosePool() {
+	defaultPool.mu.Lock()
+	defer defaultPool.mu.Unlock()
+	for key, h := range defaultPool.hosts {
+		h.mu.Lock()
+		for _, c := range h.conns {
+			_ = c.client.Close()
+		}
+		h.conns = nil
+		h.mu.Unlock()
+		delete(defaultPool.hosts, key)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+// fakeServer is a ssh server which echoes the exec command, hangs on "sleep", and forwards tcp as a jump host.
+type fakeServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.PublicKey
+	conns    int32
+	// maxSessions refuses the sessions over it on a connection like sshd MaxSessions, 0 means no limit.
+	maxSessions int32
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{listener: l, config: config, hostKey: signer.PublicKey()}
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.conns, 1)
+		go func() {
+			_, chans, reqs, err := ssh.NewServerConn(c, s.config)
+			if err != nil {
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+			var sessions int32
+			for newChan := range chans {
+				if newChan.ChannelType() == "direct-tcpip" {
+					go forwardTCP(newChan)
+					continue
+				}
+				if s.maxSessions > 0 && atomic.LoadInt32(&sessions) >= s.maxSessions {
+					_ = newChan.Reject(ssh.Prohibited, "too many sessions")
+					continue
+				}
+				ch, chReqs, err := newChan.Accept()
+				if err != nil {
+					continue
+				}
+				atomic.AddInt32(&sessions, 1)
+				go func() {
+					handleSession(ch, chReqs)
+					atomic.AddInt32(&sessions, -1)
+				}()
+			}
+		}()
+	}
+}
+
+func forwardTCP(newChan ssh.NewChannel) {
+	var target struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(newChan.ExtraData(), &target); err != nil {
+		_ = newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		_ = newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newChan.Accept()
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(ch, conn)
+		_ = ch.Close()
+	}()
+	_, _ = io.Copy(conn, ch)
+	_ = conn.Close()
+}
+
+func handleSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			_ = req.Reply(true, nil)
+		case "exec":
+			_ = req.Reply(true, nil)
+			cmd := string(req.Payload[4:])
+			if strings.HasPrefix(cmd, "sleep") {
+				continue
+			}
+			_, _ = ch.Write([]byte(cmd + "\n"))
+			_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			_ = ch.Close()
+			return
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+func TestSSH_PooledCmd(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.listener.Close()
+	defer ClosePool()
+
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	s := &SSH{User: "root", Password: "passwd", Port: port}
+	for i := 0; i < 3; i++ {
+		out, err := s.Cmd(host, "hostname")
+		if err != nil {
+			t.Fatalf("Cmd() error = %v", err)
+		}
+		if strings.TrimSpace(string(out)) != "hostname" {
+			t.Errorf("Cmd() got %q, want hostname", out)
+		}
+	}
+	if err := s.Ping(host); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+	if conns := atomic.LoadInt32(&server.conns); conns != 1 {
+		t.Errorf("got %d connections, want 1 pooled connection", conns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.CmdContext(ctx, host, "sleep 3600"); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("CmdContext() error = %v, want cancelled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("CmdContext() returned after %s, want it cancelled by timeout", elapsed)
+	}
+	if err := s.CmdAsyncContext(ctx, host, "sleep 3600"); err == nil {
+		t.Errorf("CmdAsyncContext() with done ctx should fail")
+	}
+	if _, err := WithContext(ctx, s).Cmd(host, "hostname"); err == nil {
+		t.Errorf("Cmd() of the client with done ctx should fail")
+	}
+}
+
+func TestSSH_SessionRefused(t *testing.T) {
+	server := newFakeServer(t)
+	server.maxSessions = 1
+	defer server.listener.Close()
+	defer ClosePool()
+
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	s := &SSH{User: "root", Password: "passwd", Port: port}
+	ctx, cancel := context.WithCancel(context.Background())
+	sleepErr := make(chan error, 1)
+	go func() {
+		_, err := s.CmdContext(ctx, host, "sleep 3600")
+		sleepErr <- err
+	}()
+	for i := 0; atomic.LoadInt32(&server.conns) == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// sshd refuses the second session on the connection, it goes to a new connection.
+	out, err := s.Cmd(host, "hostname")
+	if err != nil {
+		t.Fatalf("Cmd() error = %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "hostname" {
+		t.Errorf("Cmd() got %q, want hostname", out)
+	}
+	if conns := atomic.LoadInt32(&server.conns); conns != 2 {
+		t.Errorf("got %d connections, want 2", conns)
+	}
+	select {
+	case err = <-sleepErr:
+		t.Fatalf("the running command is broken by the refused session: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	cancel()
+	if err = <-sleepErr; err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("CmdContext() error = %v, want cancelled", err)
+	}
+}
+
+func TestSSH_ProxyJumpAndKnownHosts(t *testing.T) {
+	target := newFakeServer(t)
+	defer target.listener.Close()
+	jump := newFakeServer(t)
+	defer jump.listener.Close()
+	defer ClosePool()
+
+	dir, err := ioutil.TempDir("", "known-hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	targetAddr := target.listener.Addr().String()
+	jumpAddr := jump.listener.Addr().String()
+	knownHosts := filepath.Join(dir, "known_hosts")
+	lines := knownhosts.Line([]string{knownhosts.Normalize(targetAddr)}, target.hostKey) + "\n" +
+		knownhosts.Line([]string{knownhosts.Normalize(jumpAddr)}, jump.hostKey) + "\n"
+	if err = ioutil.WriteFile(knownHosts, []byte(lines), 0600); err != nil {
+		t.Fatal(err)
+	}
+	otherHosts := filepath.Join(dir, "other_hosts")
+	other := knownhosts.Line([]string{knownhosts.Normalize(targetAddr)}, jump.hostKey) + "\n"
+	if err = ioutil.WriteFile(otherHosts, []byte(other), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	host, port, _ := net.SplitHostPort(targetAddr)
+	jumpHost, jumpPort, _ := net.SplitHostPort(jumpAddr)
+	proxyJump := []v1.JumpHost{{Host: jumpHost, SSH: v1.SSH{Passwd: "passwd", Port: jumpPort}}}
+
+	tests := []struct {
+		name       string
+		knownHosts string
+		wantErr    bool
+	}{
+		{"known host through jump host", knownHosts, false},
+		{"mismatched host key", otherHosts, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SSH{User: "root", Password: "passwd", Port: port, KnownHosts: tt.knownHosts, ProxyJump: proxyJump}
+			out, err := s.Cmd(host, "hostname")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Cmd() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && strings.TrimSpace(string(out)) != "hostname" {
+				t.Errorf("Cmd() got %q, want hostname", out)
+			}
+		})
+	}
+	if conns := atomic.LoadInt32(&jump.conns); conns == 0 {
+		t.Errorf("got no connection to jump host")
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/alibaba/sealer/common"
+)
+
+const (
+	// the relay host copies to target host with its own ssh client, the credential comes from the forwarded agent.
+	relayCopyCmd = "scp -r -p -q -o StrictHostKeyChecking=%s -o UserKnownHostsFile=%s -o BatchMode=yes -P %s %s %s %s@%s:%s"
+	// the known hosts of target are written to a temp file on relay host, which is removed after the copy.
+	relayKnownHostsCmd = `known_hosts=$(mktemp) && trap 'rm -f "$known_hosts"' EXIT && printf '%%s' %s > "$known_hosts" && %s`
+)
+
+// ErrRelayNotSupported is returned when the target host has no private key or agent to be forwarded to the relay
+// host, or the user of target host is not root.
+var ErrRelayNotSupported = errors.New("relay copy needs private key or ssh-agent authentication of root on target host")
+
+// RelayCopy copies dir on relay host to the same path on target host over ssh between them, so the data does not
+// go through this host. The private key of target is lent to the relay host by agent forwarding, which is
+// never written to the relay host, or the local ssh-agent is forwarded if target authenticates with it.
+// bandwidthLimit is in bytes per second, 0 means unlimited.
+func RelayCopy(relay *SSH, relayHost string, target *SSH, targetHost, dir string, bandwidthLimit int64) error {
+	if target.useSudo() && target.User != common.ROOT {
+		// the rootfs is written by the user on relay, which has no privilege on target.
+		return ErrRelayNotSupported
+	}
+	keyring, closeKeyring, err := target.agentKeyring()
+	if err != nil {
+		return err
+	}
+	defer closeKeyring()
+
+	parent := filepath.Dir(dir)
+	if _, err = target.Cmd(targetHost, fmt.Sprintf("mkdir -p %s", parent)); err != nil {
+		return fmt.Errorf("failed to create %s on %s: %v", parent, targetHost, err)
+	}
+
+	// the agent forwarding is bound to the connection, so it is not the pooled one, and the keyring of target
+	// takes the place of the agent forwarded by relay itself.
+	relaySSH := *relay
+	relaySSH.ForwardAgent = false
+	client, err := relaySSH.dial(relayHost)
+	if err != nil {
+		return fmt.Errorf("failed to connect to relay host %s: %v", relayHost, err)
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+	if err = agent.ForwardToAgent(client, keyring); err != nil {
+		return fmt.Errorf("failed to forward ssh agent to %s: %v", relayHost, err)
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+	if err = agent.RequestAgentForwarding(session); err != nil {
+		return fmt.Errorf("failed to request agent forwarding on %s: %v", relayHost, err)
+	}
+
+	cmd, err := relayCopyCommand(target, targetHost, dir, bandwidthLimit)
+	if err != nil {
+		return err
+	}
+	if out, err := session.CombinedOutput(cmd); err != nil {
+		return fmt.Errorf("failed to copy %s from %s to %s: %v, %s", dir, relayHost, targetHost, err, string(out))
+	}
+	return nil
+}
+
+// relayCopyCommand returns the command copying dir from relay host to target host. The host key of target is
+// checked against the known hosts of target if they are set, like the connections from this host.
+func relayCopyCommand(target *SSH, targetHost, dir string, bandwidthLimit int64) (string, error) {
+	limit := ""
+	if bandwidthLimit > 0 {
+		// scp limits in Kbit/s.
+		limit = fmt.Sprintf("-l %d", bandwidthLimit*8/1000)
+	}
+	port := target.Port
+	if port == "" {
+		port = DefaultSSHPort
+	}
+	if target.KnownHosts == "" {
+		return fmt.Sprintf(relayCopyCmd, "no", "/dev/null", port, limit, dir, target.User, targetHost, filepath.Dir(dir)), nil
+	}
+
+	knownHosts, err := ioutil.ReadFile(filepath.Clean(target.KnownHosts))
+	if err != nil {
+		return "", fmt.Errorf("failed to read known hosts %s: %v", target.KnownHosts, err)
+	}
+	cmd := fmt.Sprintf(relayCopyCmd, "yes", `"$known_hosts"`, port, limit, dir, target.User, targetHost, filepath.Dir(dir))
+	return fmt.Sprintf(relayKnownHostsCmd, ShellQuote(string(knownHosts)), cmd), nil
+}
+
+func (s *SSH) agentKeyring() (agent.Agent, func(), error) {
+	if !fileExist(s.PkFile) {
+		if !s.Agent {
+			return nil, nil, ErrRelayNotSupported
+		}
+		client, conn, err := (&SSH{Agent: true}).agent()
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, func() { _ = conn.Close() }, nil
+	}
+	pkData, err := ioutil.ReadFile(filepath.Clean(s.PkFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	var key interface{}
+	if s.PkPassword == "" {
+		key, err = ssh.ParseRawPrivateKey(pkData)
+	} else {
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(pkData, []byte(s.PkPassword))
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse private key %s: %v", s.PkFile, err)
+	}
+	keyring := agent.NewKeyring()
+	if err = keyring.Add(agent.AddedKey{PrivateKey: key, LifetimeSecs: 3600}); err != nil {
+		return nil, nil, err
+	}
+	return keyring, func() {}, nil
+}
+
+// rateLimiter limits the average rate of the readers it wraps, nil or zero rate means unlimited.
+type rateLimiter struct {
+	mu    sync.Mutex
+	rate  int64
+	start time.Time
+	sent  int64
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: bytesPerSecond}
+}
+
+// wait blocks until n more bytes are allowed to be sent.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	if l.start.IsZero() {
+		l.start = time.Now()
+	}
+	l.sent += int64(n)
+	expected := time.Duration(float64(l.sent) / float64(l.rate) * float64(time.Second))
+	elapsed := time.Since(l.start)
+	l.mu.Unlock()
+	if expected > elapsed {
+		time.Sleep(expected - elapsed)
+	}
+}
+
+func (l *rateLimiter) reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{r: r, limiter: l}
+}
+
+type limitedReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	// read in small chunks so the rate is smooth.
+	if len(p) > 32*KByte {
+		p = p[:32*KByte]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		lr.limiter.wait(n)
+	}
+	return n, err
+}
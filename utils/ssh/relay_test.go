@@ -0,0 +1,86 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 64*KByte)
+	start := time.Now()
+	out, err := ioutil.ReadAll(newRateLimiter(256 * KByte).reader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(data) {
+		t.Errorf("read %d bytes, want %d", len(out), len(data))
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("read 64KiB at 256KiB/s in %s, want about 250ms", elapsed)
+	}
+
+	var unlimited *rateLimiter
+	if r := bytes.NewReader(data); unlimited.reader(r) != r {
+		t.Errorf("nil rate limiter should not wrap the reader")
+	}
+}
+
+func TestRelayCopyCommand(t *testing.T) {
+	target := &SSH{User: "root"}
+	cmd, err := relayCopyCommand(target, "192.168.0.3", "/var/lib/sealer/data/my-cluster/rootfs", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cmd, "StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null") {
+		t.Errorf("relayCopyCommand() = %q, want no host key checking without known hosts", cmd)
+	}
+
+	dir := t.TempDir()
+	knownHosts := "192.168.0.3 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIB it's $(reboot)\n"
+	target.KnownHosts = filepath.Join(dir, "known_hosts")
+	if err = ioutil.WriteFile(target.KnownHosts, []byte(knownHosts), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err = relayCopyCommand(target, "192.168.0.3", "/var/lib/sealer/data/my-cluster/rootfs", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cmd, "StrictHostKeyChecking=yes") {
+		t.Errorf("relayCopyCommand() = %q, want host key checking with known hosts", cmd)
+	}
+
+	// the fake scp prints the known hosts file it is given.
+	fakeScp := "#!/bin/sh\nfor arg; do case $arg in UserKnownHostsFile=*) cat \"${arg#UserKnownHostsFile=}\";; esac; done\n"
+	if err = ioutil.WriteFile(filepath.Join(dir, "scp"), []byte(fakeScp), 0700); err != nil {
+		t.Fatal(err)
+	}
+	sh := exec.Command("sh", "-c", cmd)
+	sh.Env = append(os.Environ(), "PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	out, err := sh.CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to run %q: %v, %s", cmd, err, out)
+	}
+	if string(out) != knownHosts {
+		t.Errorf("scp got known hosts %q, want %q", out, knownHosts)
+	}
+}
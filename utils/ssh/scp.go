@@ -27,13 +27,13 @@ import (
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
 	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/events"
 	dockerstreams "github.com/docker/cli/cli/streams"
 	dockerioutils "github.com/docker/docker/pkg/ioutils"
 	dockerjsonmessage "github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/progress"
 	"github.com/docker/docker/pkg/streamformatter"
 	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
 )
 
 const KByte = 1024
@@ -49,12 +49,16 @@ var (
 	writer          *io.PipeWriter
 	writeFlusher    *dockerioutils.WriteFlusher
 	progressChanOut progress.Output
-	epuMap          = map[string]*easyProgressUtil{}
+	// epuLock guards epuMap, the copies to the hosts run at the same time.
+	epuLock sync.Mutex
+	epuMap  = map[string]*easyProgressUtil{}
 )
 
 type easyProgressUtil struct {
+	sync.Mutex
 	output         progress.Output
 	copyID         string
+	host           string
 	completeNumber int
 	total          int
 }
@@ -69,6 +73,7 @@ func registerEpu(ip string, total int) {
 		epuMap[ip] = &easyProgressUtil{
 			output:         progressChanOut,
 			copyID:         "copying files to " + ip,
+			host:           ip,
 			completeNumber: 0,
 			total:          total,
 		}
@@ -78,16 +83,38 @@ func registerEpu(ip string, total int) {
 }
 
 func (epu *easyProgressUtil) increment() {
+	epu.Lock()
+	defer epu.Unlock()
 	epu.completeNumber = epu.completeNumber + 1
 	progress.Update(epu.output, epu.copyID, fmt.Sprintf("%d/%d", epu.completeNumber, epu.total))
+	status := events.Progress
+	if epu.completeNumber >= epu.total {
+		status = events.Succeeded
+	}
+	epu.emit(status, nil)
 }
 
 func (epu *easyProgressUtil) fail(err error) {
+	epu.Lock()
+	defer epu.Unlock()
 	progress.Update(epu.output, epu.copyID, fmt.Sprintf("failed, err: %s", err))
+	epu.emit(events.Failed, err)
 }
 
 func (epu *easyProgressUtil) startMessage() {
+	epu.Lock()
+	defer epu.Unlock()
 	progress.Update(epu.output, epu.copyID, fmt.Sprintf("%d/%d", epu.completeNumber, epu.total))
+	epu.emit(events.Started, nil)
+}
+
+func (epu *easyProgressUtil) emit(status events.Status, err error) {
+	e := events.Event{Kind: events.KindCopy, Status: status, Hosts: []string{epu.host},
+		Current: epu.completeNumber, Total: epu.total}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	events.Emit(e)
 }
 
 func displayInit() {
@@ -129,16 +156,29 @@ func (s *SSH) CmdToString(host, cmd, spilt string) (string, error) {
 	return str, fmt.Errorf("command %s %s return nil", host, cmd)
 }
 
-//SftpConnect  is
-func (s *SSH) sftpConnect(host string) (*ssh.Client, *sftp.Client, error) {
-	sshClient, err := s.connect(host)
-	if err != nil {
-		return nil, nil, err
-	}
+// sftpConnect opens a sftp client on a pooled connection of host, close it by the returned func.
+// Like newSession, it retries once on a new connection.
+func (s *SSH) sftpConnect(host string) (*sftp.Client, func(), error) {
+	var lastErr error
+	for i := 0; i < 2; i++ {
+		client, release, err := defaultPool.acquire(s, host)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	// create sftp client
-	sftpClient, err := sftp.NewClient(sshClient)
-	return sshClient, sftpClient, err
+		// create sftp client
+		sftpClient, err := sftp.NewClient(client)
+		if err == nil {
+			return sftpClient, func() {
+				_ = sftpClient.Close()
+				release()
+			}, nil
+		}
+		release()
+		lastErr = err
+		defaultPool.hostConns(s, host).sessionFailed(client, err)
+	}
+	return nil, nil, lastErr
 }
 
 // CopyRemoteFileToLocal is scp remote file to local
@@ -150,14 +190,18 @@ func (s *SSH) Fetch(host, localFilePath, remoteFilePath string) error {
 		}
 		return nil
 	}
-	sshClient, sftpClient, err := s.sftpConnect(host)
+	if s.privileged(host, remoteFilePath, false) {
+		return s.fetchStaged(host, localFilePath, remoteFilePath)
+	}
+	return s.fetch(host, localFilePath, remoteFilePath)
+}
+
+func (s *SSH) fetch(host, localFilePath, remoteFilePath string) error {
+	sftpClient, closeSftp, err := s.sftpConnect(host)
 	if err != nil {
 		return fmt.Errorf("new sftp client failed %v", err)
 	}
-	defer func() {
-		_ = sftpClient.Close()
-		_ = sshClient.Close()
-	}()
+	defer closeSftp()
 	// open remote source file
 	srcFile, err := sftpClient.Open(remoteFilePath)
 	if err != nil {
@@ -197,15 +241,19 @@ func (s *SSH) Copy(host, localPath, remotePath string) error {
 		logger.Debug("local copy files src %s to dst %s", localPath, remotePath)
 		return utils.RecursionCopy(localPath, remotePath)
 	}
+	if s.privileged(host, remotePath, true) {
+		return s.copyStaged(host, localPath, remotePath)
+	}
+	return s.copy(host, localPath, remotePath)
+}
+
+func (s *SSH) copy(host, localPath, remotePath string) error {
 	logger.Debug("remote copy files src %s to dst %s", localPath, remotePath)
-	sshClient, sftpClient, err := s.sftpConnect(host)
+	sftpClient, closeSftp, err := s.sftpConnect(host)
 	if err != nil {
 		return fmt.Errorf("new sftp client failed %s", err)
 	}
-	defer func() {
-		_ = sftpClient.Close()
-		_ = sshClient.Close()
-	}()
+	defer closeSftp()
 
 	f, err := os.Stat(localPath)
 	if err != nil {
@@ -227,62 +275,149 @@ func (s *SSH) Copy(host, localPath, remotePath string) error {
 	if number == 0 {
 		return nil
 	}
-	epu, ok := epuMap[host]
-	if !ok {
-		registerEpu(host, number)
-		epu = epuMap[host]
-	} else {
-		epu.total += number
-	}
-
+	epu := hostProgress(host, number)
 	epu.startMessage()
+	limiter := newRateLimiter(s.BandwidthLimit)
 	if f.IsDir() {
-		s.copyLocalDirToRemote(host, sftpClient, localPath, remotePath, epu)
-	} else {
-		err = s.copyLocalFileToRemote(host, sftpClient, localPath, remotePath)
-		if err != nil {
-			epu.fail(err)
-		}
+		err = s.copyLocalDirToRemote(host, sftpClient, localPath, remotePath, epu, limiter)
+	} else if err = s.copyLocalFileToRemote(host, sftpClient, localPath, remotePath, limiter); err == nil {
 		epu.increment()
 	}
-	return nil
+	if err != nil {
+		epu.fail(err)
+	}
+	return err
 }
 
-func (s *SSH) copyLocalDirToRemote(host string, sftpClient *sftp.Client, localPath, remotePath string, epu *easyProgressUtil) {
+// hostProgress returns the copy progress of host, which adds number files to copy.
+func hostProgress(host string, number int) *easyProgressUtil {
+	epuLock.Lock()
+	defer epuLock.Unlock()
+	epu, ok := epuMap[host]
+	if !ok {
+		registerEpu(host, number)
+		return epuMap[host]
+	}
+	epu.Lock()
+	epu.total += number
+	epu.Unlock()
+	return epu
+}
+
+func (s *SSH) copyLocalDirToRemote(host string, sftpClient *sftp.Client, localPath, remotePath string, epu *easyProgressUtil, limiter *rateLimiter) error {
 	localFiles, err := ioutil.ReadDir(localPath)
 	if err != nil {
-		logger.Error("read local path dir failed %s %s", host, localPath)
-		return
+		return fmt.Errorf("read local path dir failed %s %s: %v", host, localPath, err)
 	}
 	if err = sftpClient.MkdirAll(remotePath); err != nil {
-		logger.Error("failed to create remote path %s:%v", remotePath, err)
-		return
+		return fmt.Errorf("failed to create remote path %s:%v", remotePath, err)
 	}
 	for _, file := range localFiles {
 		lfp := path.Join(localPath, file.Name())
 		rfp := path.Join(remotePath, file.Name())
 		if file.IsDir() {
-			if err = sftpClient.MkdirAll(rfp); err != nil {
-				logger.Error("failed to create remote path %s:%v", rfp, err)
-				return
+			if err = s.copyLocalDirToRemote(host, sftpClient, lfp, rfp, epu, limiter); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = s.copyLocalFileToRemote(host, sftpClient, lfp, rfp, limiter); err != nil {
+			return fmt.Errorf("copy local file to remote failed %v %s %s %s", err, host, lfp, rfp)
+		}
+		epu.increment()
+	}
+	return nil
+}
+
+// CopyFiles copies the files of localDir, given by their paths relative to it, to the same paths under remoteDir.
+// Unlike calling Copy for each of them, they are copied over one sftp session without checking their md5 first,
+// and it fails on the first file not copied.
+func (s *SSH) CopyFiles(host, localDir, remoteDir string, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	go displayInitOnce.Do(displayInit)
+	if utils.IsLocalIP(host, s.LocalAddress) {
+		if localDir == remoteDir {
+			return nil
+		}
+		for _, file := range files {
+			if err := utils.RecursionCopy(filepath.Join(localDir, file), filepath.Join(remoteDir, file)); err != nil {
+				return fmt.Errorf("failed to copy %s: %v", file, err)
 			}
-			s.copyLocalDirToRemote(host, sftpClient, lfp, rfp, epu)
-		} else {
-			err := s.copyLocalFileToRemote(host, sftpClient, lfp, rfp)
-			if err != nil {
-				errMsg := fmt.Sprintf("copy local file to remote failed %v %s %s %s", err, host, lfp, rfp)
+		}
+		return nil
+	}
+	if s.privileged(host, remoteDir, true) {
+		return s.copyFilesStaged(host, localDir, remoteDir, files)
+	}
+	return s.copyFiles(host, localDir, remoteDir, files)
+}
+
+func (s *SSH) copyFiles(host, localDir, remoteDir string, files []string) error {
+	logger.Debug("remote copy %d files of %s to %s", len(files), localDir, remoteDir)
+	sftpClient, closeSftp, err := s.sftpConnect(host)
+	if err != nil {
+		return fmt.Errorf("new sftp client failed %s", err)
+	}
+	defer closeSftp()
+
+	epu := hostProgress(host, len(files))
+	epu.startMessage()
+	limiter := newRateLimiter(s.BandwidthLimit)
+	createdDirs := map[string]bool{}
+	for _, file := range files {
+		remotePath := path.Join(remoteDir, filepath.ToSlash(file))
+		if dir := path.Dir(remotePath); !createdDirs[dir] {
+			if err = sftpClient.MkdirAll(dir); err != nil {
+				err = fmt.Errorf("failed to create remote path %s:%v", dir, err)
 				epu.fail(err)
-				logger.Error(errMsg)
-				return
+				return err
 			}
-			epu.increment()
+			createdDirs[dir] = true
+		}
+		if err = uploadFile(sftpClient, filepath.Join(localDir, file), remotePath, limiter); err != nil {
+			err = fmt.Errorf("failed to copy %s to %s:%s: %v", file, host, remotePath, err)
+			epu.fail(err)
+			return err
 		}
+		epu.increment()
 	}
+	return nil
+}
+
+// uploadFile writes localPath to remotePath with its mode, the remote file is checked to have all the bytes.
+func uploadFile(sftpClient *sftp.Client, localPath, remotePath string, limiter *rateLimiter) error {
+	srcFile, err := os.Open(filepath.Clean(localPath))
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	fileStat, err := srcFile.Stat()
+	if err != nil {
+		return fmt.Errorf("get file stat failed %v", err)
+	}
+
+	dstFile, err := sftpClient.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(dstFile, limiter.reader(srcFile))
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n != fileStat.Size() {
+		return fmt.Errorf("copied %d bytes of %d", n, fileStat.Size())
+	}
+	return sftpClient.Chmod(remotePath, fileStat.Mode().Perm())
 }
 
 // check the remote file existence before copying
 // solve the sesion
-func (s *SSH) copyLocalFileToRemote(host string, sftpClient *sftp.Client, localPath, remotePath string) error {
+func (s *SSH) copyLocalFileToRemote(host string, sftpClient *sftp.Client, localPath, remotePath string, limiter *rateLimiter) error {
 	var (
 		srcMd5, dstMd5 string
 	)
@@ -321,7 +456,7 @@ func (s *SSH) copyLocalFileToRemote(host string, sftpClient *sftp.Client, localP
 			logger.Fatal("failed to close file")
 		}
 	}()
-	_, err = io.Copy(dstFile, srcFile)
+	_, err = io.Copy(dstFile, limiter.reader(srcFile))
 	if err != nil {
 		return err
 	}
@@ -343,14 +478,11 @@ func LocalMd5Sum(localPath string) string {
 
 //if remote file not exist return false and nil
 func (s *SSH) RemoteDirExist(host, remoteDirpath string) (bool, error) {
-	sshClient, sftpClient, err := s.sftpConnect(host)
+	sftpClient, closeSftp, err := s.sftpConnect(host)
 	if err != nil {
 		return false, err
 	}
-	defer func() {
-		_ = sftpClient.Close()
-		_ = sshClient.Close()
-	}()
+	defer closeSftp()
 	if _, err := sftpClient.ReadDir(remoteDirpath); err != nil {
 		return false, err
 	}
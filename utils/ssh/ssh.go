@@ -36,12 +36,18 @@ type Interface interface {
 	// scp -r /tmp root@192.168.0.2:/root/tmp => Copy("192.168.0.2","tmp","/root/tmp")
 	// need check md5sum
 	Copy(host, srcFilePath, dstFilePath string) error
+	// copy the files of localDir, the paths relative to it, to the same paths under remoteDir in one batch
+	CopyFiles(host, localDir, remoteDir string, files []string) error
 	// copy remote host files to localhost
 	Fetch(host, srcFilePath, dstFilePath string) error
 	// exec command on remote host, and asynchronous return logs
 	CmdAsync(host string, cmd ...string) error
+	// CmdAsync with ctx, the running command is killed when ctx is done, like context.WithTimeout
+	CmdAsyncContext(ctx context.Context, host string, cmd ...string) error
 	// exec command on remote host, and return combined standard output and standard error
 	Cmd(host, cmd string) ([]byte, error)
+	// Cmd with ctx, the running command is killed when ctx is done, like context.WithTimeout
+	CmdContext(ctx context.Context, host, cmd string) ([]byte, error)
 	// check remote file exist or not
 	IsFileExist(host, remoteFilePath string) bool
 	//Remote file existence returns true, nil
@@ -60,6 +66,14 @@ type SSH struct {
 	PkPassword   string
 	Timeout      *time.Duration
 	LocalAddress *[]net.Addr
+	// BandwidthLimit is the max bytes per second of Copy, 0 means unlimited.
+	BandwidthLimit int64
+	Agent          bool
+	ForwardAgent   bool
+	KnownHosts     string
+	ProxyJump      []v1.JumpHost
+	Sudo           bool
+	SudoPassword   string
 }
 
 func NewSSHByCluster(cluster *v1.Cluster) Interface {
@@ -77,6 +91,12 @@ func NewSSHByCluster(cluster *v1.Cluster) Interface {
 		PkFile:       cluster.Spec.SSH.Pk,
 		PkPassword:   cluster.Spec.SSH.PkPasswd,
 		LocalAddress: address,
+		Agent:        cluster.Spec.SSH.Agent,
+		ForwardAgent: cluster.Spec.SSH.ForwardAgent,
+		KnownHosts:   cluster.Spec.SSH.KnownHosts,
+		ProxyJump:    cluster.Spec.SSH.ProxyJump,
+		Sudo:         cluster.Spec.SSH.Sudo,
+		SudoPassword: cluster.Spec.SSH.SudoPasswd,
 	}
 }
 
@@ -96,6 +116,12 @@ func NewSSHClient(ssh *v1.SSH, isStdout bool) Interface {
 		PkFile:       ssh.Pk,
 		PkPassword:   ssh.PkPasswd,
 		LocalAddress: address,
+		Agent:        ssh.Agent,
+		ForwardAgent: ssh.ForwardAgent,
+		KnownHosts:   ssh.KnownHosts,
+		ProxyJump:    ssh.ProxyJump,
+		Sudo:         ssh.Sudo,
+		SudoPassword: ssh.SudoPasswd,
 	}
 }
 
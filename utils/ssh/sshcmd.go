@@ -16,45 +16,44 @@ package ssh
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"strings"
 	"sync"
 
-	"github.com/alibaba/sealer/common"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/alibaba/sealer/utils"
 )
 
-const SUDO = "sudo "
-
 func (s *SSH) Ping(host string) error {
-	client, _, err := s.Connect(host)
+	_, closeSession, err := s.connectSession(host)
 	if err != nil {
 		return fmt.Errorf("[ssh %s]create ssh session failed, %v", host, err)
 	}
-	err = client.Close()
-	if err != nil {
-		return err
-	}
+	closeSession()
 	return nil
 }
 
 func (s *SSH) CmdAsync(host string, cmds ...string) error {
+	return s.CmdAsyncContext(context.Background(), host, cmds...)
+}
+
+// CmdAsyncContext is CmdAsync which kills the running command and returns when ctx is done.
+func (s *SSH) CmdAsyncContext(ctx context.Context, host string, cmds ...string) error {
 	for _, cmd := range cmds {
 		if cmd == "" {
 			continue
 		}
-		if s.User != common.ROOT {
-			cmd = SUDO + cmd
-		}
+		cmd = s.sudoCommand(cmd)
 		if err := func(cmd string) error {
-			client, session, err := s.Connect(host)
+			session, closeSession, err := s.connectSession(host)
 			if err != nil {
 				return fmt.Errorf("failed to create ssh session for %s: %v", host, err)
 			}
-			defer client.Close()
-			defer session.Close()
+			defer closeSession()
+			s.feedSudoPassword(session)
 			stdout, err := session.StdoutPipe()
 			if err != nil {
 				return fmt.Errorf("failed to create stdout pipe for %s: %v", host, err)
@@ -70,19 +69,25 @@ func (s *SSH) CmdAsync(host string, cmds ...string) error {
 
 			var combineSlice []string
 			var combineLock sync.Mutex
-			doneout := make(chan error, 1)
-			doneerr := make(chan error, 1)
-			go func() {
-				doneerr <- readPipe(stderr, &combineSlice, &combineLock, s.isStdout)
-			}()
-			go func() {
-				doneout <- readPipe(stdout, &combineSlice, &combineLock, s.isStdout)
-			}()
-			<-doneerr
-			<-doneout
-
-			err = session.Wait()
+			err = waitSession(ctx, session, func() error {
+				doneout := make(chan error, 1)
+				doneerr := make(chan error, 1)
+				go func() {
+					doneerr <- readPipe(stderr, &combineSlice, &combineLock, s.isStdout)
+				}()
+				go func() {
+					doneout <- readPipe(stdout, &combineSlice, &combineLock, s.isStdout)
+				}()
+				<-doneerr
+				<-doneout
+				return session.Wait()
+			})
+			if ctx.Err() != nil {
+				return fmt.Errorf("[ssh][%s] command [%s] is cancelled: %v", host, cmd, ctx.Err())
+			}
 			if err != nil {
+				combineLock.Lock()
+				defer combineLock.Unlock()
 				return utils.WrapExecResult(host, cmd, []byte(strings.Join(combineSlice, "\n")), err)
 			}
 
@@ -96,16 +101,58 @@ func (s *SSH) CmdAsync(host string, cmds ...string) error {
 }
 
 func (s *SSH) Cmd(host, cmd string) ([]byte, error) {
-	if s.User != common.ROOT {
-		cmd = SUDO + cmd
+	return s.CmdContext(context.Background(), host, cmd)
+}
+
+// CmdContext is Cmd which kills the running command and returns when ctx is done.
+func (s *SSH) CmdContext(ctx context.Context, host, cmd string) ([]byte, error) {
+	return s.run(ctx, host, cmd, s.useSudo())
+}
+
+// contextSSH runs the commands of Interface with ctx, so that they are killed once ctx is done.
+type contextSSH struct {
+	Interface
+	ctx context.Context
+}
+
+// WithContext returns s which runs Cmd and CmdAsync with ctx, like an apply cancelled by the caller.
+func WithContext(ctx context.Context, s Interface) Interface {
+	if ctx == nil {
+		return s
 	}
-	client, session, err := s.Connect(host)
+	return &contextSSH{Interface: s, ctx: ctx}
+}
+
+func (c *contextSSH) CmdAsync(host string, cmds ...string) error {
+	return c.CmdAsyncContext(c.ctx, host, cmds...)
+}
+
+func (c *contextSSH) Cmd(host, cmd string) ([]byte, error) {
+	return c.CmdContext(c.ctx, host, cmd)
+}
+
+// run runs cmd on host as the login user, or by sudo if sudo is true.
+func (s *SSH) run(ctx context.Context, host, cmd string, sudo bool) ([]byte, error) {
+	if sudo {
+		cmd = s.sudoCommand(cmd)
+	}
+	session, closeSession, err := s.connectSession(host)
 	if err != nil {
 		return nil, fmt.Errorf("[ssh][%s] create ssh session failed, %s", host, err)
 	}
-	defer client.Close()
-	defer session.Close()
-	b, err := session.CombinedOutput(cmd)
+	defer closeSession()
+	if sudo {
+		s.feedSudoPassword(session)
+	}
+	var b []byte
+	err = waitSession(ctx, session, func() error {
+		var runErr error
+		b, runErr = session.CombinedOutput(cmd)
+		return runErr
+	})
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("[ssh][%s] command [%s] is cancelled: %v", host, cmd, ctx.Err())
+	}
 	if err != nil {
 		return b, fmt.Errorf("[ssh][%s]run command failed [%s]", host, cmd)
 	}
@@ -113,6 +160,24 @@ func (s *SSH) Cmd(host, cmd string) ([]byte, error) {
 	return b, nil
 }
 
+// waitSession waits for run to return, or kills the command of session when ctx is done. The command
+// is also hung up by the pty when the session is closed, in case the server does not support signals.
+func waitSession(ctx context.Context, session *ssh.Session, run func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- run()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGKILL)
+		_ = session.Close()
+		<-done
+		return ctx.Err()
+	}
+}
+
 func readPipe(pipe io.Reader, combineSlice *[]string, combineLock *sync.Mutex, isStdout bool) error {
 	r := bufio.NewReader(pipe)
 	for {
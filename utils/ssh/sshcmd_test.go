@@ -33,14 +33,10 @@ func TestSSH_Cmd(t *testing.T) {
 		{
 			name: "touch test.txt",
 			args: args{
-				ssh: SSH{false,
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+				ssh: SSH{
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/touchTxt.sh",
@@ -51,14 +47,10 @@ func TestSSH_Cmd(t *testing.T) {
 		{
 			name: "ls /opt/test",
 			args: args{
-				ssh: SSH{false,
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+				ssh: SSH{
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "ls /opt/test",
@@ -69,14 +61,10 @@ func TestSSH_Cmd(t *testing.T) {
 		{
 			name: "remove test.txt",
 			args: args{
-				ssh: SSH{false,
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+				ssh: SSH{
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/removeTxt.sh",
@@ -87,14 +75,10 @@ func TestSSH_Cmd(t *testing.T) {
 		{
 			name: "exist 1",
 			args: args{
-				ssh: SSH{false,
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+				ssh: SSH{
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/exit1.sh",
@@ -132,14 +116,10 @@ func TestSSH_CmdAsync(t *testing.T) {
 		{
 			name: "touch test.txt",
 			args: args{
-				ssh: SSH{false,
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+				ssh: SSH{
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/touchTxt.sh",
@@ -149,14 +129,10 @@ func TestSSH_CmdAsync(t *testing.T) {
 		{
 			name: "ls /opt/test",
 			args: args{
-				ssh: SSH{false,
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+				ssh: SSH{
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "ls /opt/test",
@@ -166,14 +142,10 @@ func TestSSH_CmdAsync(t *testing.T) {
 		{
 			name: "remove test.txt",
 			args: args{
-				ssh: SSH{false,
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+				ssh: SSH{
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/removeTxt.sh",
@@ -183,14 +155,10 @@ func TestSSH_CmdAsync(t *testing.T) {
 		{
 			name: "exist 1",
 			args: args{
-				ssh: SSH{false,
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+				ssh: SSH{
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/exit1.sh",
@@ -0,0 +1,159 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+)
+
+// stagingDir is under the home of the user, the copies to privileged paths are written here and moved by sudo.
+const stagingDir = ".sealer-staging"
+
+// useSudo tells whether the commands are run by sudo, which is only used if it is set.
+func (s *SSH) useSudo() bool {
+	return s.Sudo
+}
+
+// sudoCommand wraps cmd to be run by sudo as a whole, so the pipes and redirections in it are privileged too.
+// Without password, sudo fails at once instead of waiting for the password.
+func (s *SSH) sudoCommand(cmd string) string {
+	if !s.useSudo() {
+		return cmd
+	}
+	if s.SudoPassword == "" {
+		return fmt.Sprintf("sudo -n bash -c %s", ShellQuote(cmd))
+	}
+	return fmt.Sprintf("sudo -S -p '' bash -c %s", ShellQuote(cmd))
+}
+
+// feedSudoPassword writes the sudo password to the stdin of session, which is read by sudo -S.
+func (s *SSH) feedSudoPassword(session *ssh.Session) {
+	if s.useSudo() && s.SudoPassword != "" {
+		session.Stdin = strings.NewReader(s.SudoPassword + "\n")
+	}
+}
+
+// privileged tells whether remotePath can not be written, or read if not write, by the user itself over sftp.
+// If remotePath does not exist, its nearest existing parent is checked.
+func (s *SSH) privileged(host, remotePath string, write bool) bool {
+	if !s.useSudo() || s.User == common.ROOT {
+		return false
+	}
+	p := ShellQuote(remotePath)
+	cmd := fmt.Sprintf("test -r %s", p)
+	if write {
+		cmd = fmt.Sprintf(`if [ -e %[1]s ]; then [ -z "$(find %[1]s ! -writable -print -quit)" ]; `+
+			`else p=%[1]s; while [ ! -e "$p" ]; do p=$(dirname "$p"); done; test -w "$p"; fi`, p)
+	}
+	_, err := s.run(context.Background(), host, cmd, false)
+	return err != nil
+}
+
+// stagingPath returns a new path in the staging dir for remotePath, and the func to remove it.
+func (s *SSH) stagingPath(host, remotePath string) (string, func(), error) {
+	sftpClient, closeSftp, err := s.sftpConnect(host)
+	if err != nil {
+		return "", nil, fmt.Errorf("new sftp client failed %v", err)
+	}
+	home, err := sftpClient.Getwd()
+	closeSftp()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get home dir of %s on %s: %v", s.User, host, err)
+	}
+	dir := path.Join(home, stagingDir, strconv.FormatInt(time.Now().UnixNano(), 10))
+	return path.Join(dir, path.Base(remotePath)), func() {
+		if _, err := s.run(context.Background(), host, fmt.Sprintf("rm -rf %s", ShellQuote(dir)), false); err != nil {
+			logger.Warn("failed to remove staging dir %s on %s: %v", dir, host, err)
+		}
+	}, nil
+}
+
+// copyStaged copies localPath to the staging dir, then moves it to the privileged remotePath by sudo.
+func (s *SSH) copyStaged(host, localPath, remotePath string) error {
+	f, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("get file stat failed %s", err)
+	}
+	staging, cleanup, err := s.stagingPath(host, remotePath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if err = s.copy(host, localPath, staging); err != nil {
+		return err
+	}
+
+	dst := ShellQuote(remotePath)
+	cmd := fmt.Sprintf("mkdir -p %s && cp -f --preserve=mode %s %s", ShellQuote(path.Dir(remotePath)), ShellQuote(staging), dst)
+	if f.IsDir() {
+		cmd = fmt.Sprintf("mkdir -p %s && cp -rf --preserve=mode %s/. %s/", dst, ShellQuote(staging), dst)
+	}
+	if _, err = s.Cmd(host, cmd); err != nil {
+		return fmt.Errorf("failed to move %s to %s on %s: %v", staging, remotePath, host, err)
+	}
+	return nil
+}
+
+// copyFilesStaged copies files to the staging dir, then moves them to the privileged remoteDir by sudo.
+func (s *SSH) copyFilesStaged(host, localDir, remoteDir string, files []string) error {
+	staging, cleanup, err := s.stagingPath(host, remoteDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if err = s.copyFiles(host, localDir, staging, files); err != nil {
+		return err
+	}
+
+	dst := ShellQuote(remoteDir)
+	cmd := fmt.Sprintf("mkdir -p %s && cp -rf --preserve=mode %s/. %s/", dst, ShellQuote(staging), dst)
+	if _, err = s.Cmd(host, cmd); err != nil {
+		return fmt.Errorf("failed to move %s to %s on %s: %v", staging, remoteDir, host, err)
+	}
+	return nil
+}
+
+// fetchStaged copies the privileged remotePath to the staging dir by sudo, then fetches it.
+func (s *SSH) fetchStaged(host, localFilePath, remoteFilePath string) error {
+	staging, cleanup, err := s.stagingPath(host, remoteFilePath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if _, err = s.run(context.Background(), host, fmt.Sprintf("mkdir -p %s", ShellQuote(path.Dir(staging))), false); err != nil {
+		return fmt.Errorf("failed to create staging dir on %s: %v", host, err)
+	}
+	cmd := fmt.Sprintf("cp -f %s %s && chown %s %s", ShellQuote(remoteFilePath), ShellQuote(staging), ShellQuote(s.User), ShellQuote(staging))
+	if _, err = s.Cmd(host, cmd); err != nil {
+		return fmt.Errorf("failed to copy %s to %s on %s: %v", remoteFilePath, staging, host, err)
+	}
+	return s.fetch(host, localFilePath, staging)
+}
+
+// ShellQuote quotes str as a single word of shell.
+func ShellQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
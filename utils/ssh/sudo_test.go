@@ -0,0 +1,60 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSSH_sudoCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		ssh  SSH
+		cmd  string
+		want string
+	}{
+		{"root", SSH{User: "root"}, "echo 1 > /etc/a", "echo 1 > /etc/a"},
+		{"root with sudo", SSH{User: "root", Sudo: true}, "echo 1 > /etc/a", "sudo -n bash -c 'echo 1 > /etc/a'"},
+		{"non-root without sudo", SSH{User: "ops"}, "cd /root && bash init.sh", "cd /root && bash init.sh"},
+		{"non-root", SSH{User: "ops", Sudo: true}, "cd /root && bash init.sh", "sudo -n bash -c 'cd /root && bash init.sh'"},
+		{"quotes", SSH{User: "ops", Sudo: true}, "echo 'a b'", `sudo -n bash -c 'echo '\''a b'\'''`},
+		{"password", SSH{User: "ops", Sudo: true, SudoPassword: "pw"}, "kubeadm reset -f", "sudo -S -p '' bash -c 'kubeadm reset -f'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ssh.sudoCommand(tt.cmd); got != tt.want {
+				t.Errorf("sudoCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSH_SudoCmd(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.listener.Close()
+	defer ClosePool()
+
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	s := &SSH{User: "ops", Password: "passwd", Port: port, Sudo: true, SudoPassword: "pw"}
+	out, err := s.Cmd(host, "echo 1 >> /etc/hosts")
+	if err != nil {
+		t.Fatalf("Cmd() error = %v", err)
+	}
+	if got, want := strings.TrimSpace(string(out)), "sudo -S -p '' bash -c 'echo 1 >> /etc/hosts'"; got != want {
+		t.Errorf("Cmd() ran %q, want %q", got, want)
+	}
+}